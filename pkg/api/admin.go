@@ -6,6 +6,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/bus"
 	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/setting"
 )
 
@@ -49,3 +50,7 @@ func AdminGetStats(c *m.ReqContext) {
 
 	c.JSON(200, statsQuery.Result)
 }
+
+func AdminGetPluginProcesses(c *m.ReqContext) {
+	c.JSON(200, plugins.GetProcessStatuses())
+}
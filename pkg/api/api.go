@@ -240,11 +240,26 @@ func (hs *HTTPServer) registerRoutes() {
 			datasourceRoute.Delete("/:id", Wrap(DeleteDataSourceById))
 			datasourceRoute.Delete("/name/:name", Wrap(DeleteDataSourceByName))
 			datasourceRoute.Get("/:id", Wrap(GetDataSourceById))
+			datasourceRoute.Get("/:id/health", Wrap(hs.CheckDataSourceHealth))
 			datasourceRoute.Get("/name/:name", Wrap(GetDataSourceByName))
 		}, reqOrgAdmin)
 
 		apiRoute.Get("/datasources/id/:name", Wrap(GetDataSourceIdByName), reqSignedIn)
 
+		apiRoute.Group("/datasources/:id/permissions", func(dsPermissionRoute routing.RouteRegister) {
+			dsPermissionRoute.Get("/", Wrap(GetDataSourcePermissionList))
+			dsPermissionRoute.Post("/", bind(dtos.UpdateDataSourceAclCommand{}), Wrap(UpdateDataSourcePermissions))
+		}, reqSignedIn)
+
+		// Sql macro library
+		apiRoute.Group("/sql-macros", func(sqlMacroRoute routing.RouteRegister) {
+			sqlMacroRoute.Get("/", Wrap(GetSqlMacros))
+			sqlMacroRoute.Get("/:id", Wrap(GetSqlMacroById))
+			sqlMacroRoute.Post("/", reqOrgAdmin, bind(m.AddSqlMacroCommand{}), Wrap(AddSqlMacro))
+			sqlMacroRoute.Put("/:id", reqOrgAdmin, bind(m.UpdateSqlMacroCommand{}), Wrap(UpdateSqlMacro))
+			sqlMacroRoute.Delete("/:id", reqOrgAdmin, Wrap(DeleteSqlMacro))
+		}, reqEditorRole)
+
 		apiRoute.Get("/plugins", Wrap(hs.GetPluginList))
 		apiRoute.Get("/plugins/:pluginId/settings", Wrap(GetPluginSettingByID))
 		apiRoute.Get("/plugins/:pluginId/markdown/:name", Wrap(GetPluginMarkdown))
@@ -257,6 +272,8 @@ func (hs *HTTPServer) registerRoutes() {
 		apiRoute.Get("/frontend/settings/", hs.GetFrontendSettings)
 		apiRoute.Any("/datasources/proxy/:id/*", reqSignedIn, hs.ProxyDataSourceRequest)
 		apiRoute.Any("/datasources/proxy/:id", reqSignedIn, hs.ProxyDataSourceRequest)
+		apiRoute.Any("/datasources/:id/resources/*", reqSignedIn, hs.CallDataSourceResource)
+		apiRoute.Any("/plugins/:pluginId/resources/*", reqSignedIn, hs.CallPluginResource)
 
 		// Folders
 		apiRoute.Group("/folders", func(folderRoute routing.RouteRegister) {
@@ -373,6 +390,7 @@ func (hs *HTTPServer) registerRoutes() {
 		adminRoute.Get("/users/:id/quotas", Wrap(GetUserQuotas))
 		adminRoute.Put("/users/:id/quotas/:target", bind(m.UpdateUserQuotaCmd{}), Wrap(UpdateUserQuota))
 		adminRoute.Get("/stats", AdminGetStats)
+		adminRoute.Get("/plugins", AdminGetPluginProcesses)
 		adminRoute.Post("/pause-all-alerts", bind(dtos.PauseAllAlertsCommand{}), Wrap(PauseAllAlerts))
 	}, reqGrafanaAdmin)
 
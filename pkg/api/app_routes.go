@@ -59,8 +59,14 @@ func AppPluginRoute(route *plugins.AppPluginRoute, appID string) macaron.Handler
 	return func(c *m.ReqContext) {
 		path := c.Params("*")
 
+		transport, err := pluginproxy.GetAppRouteTransport(c.OrgId, appID, route, pluginProxyTransport)
+		if err != nil {
+			c.JsonApiErr(500, "Unable to load route TLS configuration", err)
+			return
+		}
+
 		proxy := pluginproxy.NewApiPluginProxy(c, path, route, appID)
-		proxy.Transport = pluginProxyTransport
+		proxy.Transport = transport
 		proxy.ServeHTTP(c.Resp, c.Req.Request)
 	}
 }
@@ -248,7 +248,7 @@ func PostDashboard(c *m.ReqContext, cmd m.SaveDashboardCommand) Response {
 		return Error(400, err.Error(), nil)
 	}
 
-	if err == m.ErrDashboardUpdateAccessDenied {
+	if err == m.ErrDashboardUpdateAccessDenied || err == m.ErrDataSourceAccessDenied {
 		return Error(403, err.Error(), err)
 	}
 
@@ -1,20 +1,25 @@
 package api
 
 import (
+	"time"
+
 	"github.com/grafana/grafana/pkg/api/pluginproxy"
 	"github.com/grafana/grafana/pkg/metrics"
 	m "github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/audit"
 )
 
 func (hs *HTTPServer) ProxyDataSourceRequest(c *m.ReqContext) {
 	c.TimeRequest(metrics.M_DataSource_ProxyReq_Timer)
+	start := time.Now()
 
 	dsId := c.ParamsInt64(":id")
 	ds, err := hs.DatasourceCache.GetDatasource(dsId, c.SignedInUser, c.SkipCache)
 	if err != nil {
 		if err == m.ErrDataSourceAccessDenied {
 			c.JsonApiErr(403, "Access denied to datasource", err)
+			hs.AuditService.Log(c, &m.DataSource{Id: dsId}, audit.SourceProxy, nil, 403, time.Since(start))
 			return
 		}
 		c.JsonApiErr(500, "Unable to load datasource meta data", err)
@@ -31,8 +36,17 @@ func (hs *HTTPServer) ProxyDataSourceRequest(c *m.ReqContext) {
 	// macaron does not include trailing slashes when resolving a wildcard path
 	proxyPath := ensureProxyPathTrailingSlash(c.Req.URL.Path, c.Params("*"))
 
+	// the body is recorded while it is streamed to the data source
+	var body *audit.BodyRecorder
+	if hs.AuditService.Enabled() && c.Req.Request.Body != nil {
+		body = hs.AuditService.NewBodyRecorder()
+		c.Req.Request.Body = body.Record(c.Req.Request.Body)
+	}
+
 	proxy := pluginproxy.NewDataSourceProxy(ds, plugin, c, proxyPath)
 	proxy.HandleRequest()
+
+	hs.AuditService.Log(c, ds, audit.SourceProxy, body, c.Resp.Status(), time.Since(start))
 }
 
 // ensureProxyPathTrailingSlash Check for a trailing slash in original path and makes
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// GET /api/datasources/:id/health
+func (hs *HTTPServer) CheckDataSourceHealth(c *m.ReqContext) Response {
+	ds, err := hs.DatasourceCache.GetDatasource(c.ParamsInt64(":id"), c.SignedInUser, c.SkipCache)
+	if err != nil {
+		if err == m.ErrDataSourceNotFound {
+			return Error(404, "没有找到数据源", nil)
+		}
+		return Error(500, "无法查询数据源", err)
+	}
+
+	result, check, err := hs.DataSourceHealth.CheckHealth(c.Req.Context(), ds)
+	if err == tsdb.ErrHealthCheckNotSupported {
+		return JSON(200, util.DynMap{
+			"status":  tsdb.HealthStatusUnknown,
+			"message": err.Error(),
+		})
+	}
+	if result == nil {
+		return Error(500, "无法检查数据源健康状态", err)
+	}
+	if err != nil {
+		hs.log.Error("Failed to save health check", "datasource", ds.Name, "error", err)
+	}
+
+	limit := c.QueryInt("limit")
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := m.GetDataSourceHealthChecksQuery{OrgId: ds.OrgId, DataSourceId: ds.Id, Limit: limit}
+	if err := bus.Dispatch(&query); err != nil {
+		return Error(500, "无法查询数据源健康检查记录", err)
+	}
+
+	res := util.DynMap{
+		"status":  result.Status,
+		"message": result.Message,
+		"details": result.Details,
+		"history": query.Result,
+	}
+	if check != nil {
+		res["durationMs"] = check.DurationMs
+	}
+
+	return JSON(200, res)
+}
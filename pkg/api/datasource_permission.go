@@ -0,0 +1,134 @@
+package api
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/guardian"
+)
+
+// GET /api/datasources/:id/permissions
+func GetDataSourcePermissionList(c *m.ReqContext) Response {
+	ds, rsp := getDataSourceForAdmin(c)
+	if rsp != nil {
+		return rsp
+	}
+
+	query := m.GetDataSourceAclInfoListQuery{OrgId: c.OrgId, DataSourceId: ds.Id}
+	if err := bus.Dispatch(&query); err != nil {
+		return Error(500, "无法获得数据源权限", err)
+	}
+
+	for _, perm := range query.Result {
+		perm.UserAvatarUrl = dtos.GetGravatarUrl(perm.UserEmail)
+
+		if perm.TeamId > 0 {
+			perm.TeamAvatarUrl = dtos.GetGravatarUrlWithDefault(perm.TeamEmail, perm.Team)
+		}
+	}
+
+	return JSON(200, query.Result)
+}
+
+// POST /api/datasources/:id/permissions
+func UpdateDataSourcePermissions(c *m.ReqContext, apiCmd dtos.UpdateDataSourceAclCommand) Response {
+	ds, rsp := getDataSourceForAdmin(c)
+	if rsp != nil {
+		return rsp
+	}
+
+	cmd := m.UpdateDataSourceAclCommand{OrgId: c.OrgId, DataSourceId: ds.Id}
+
+	users := make(map[int64]bool)
+	teams := make(map[int64]bool)
+	for _, item := range apiCmd.Items {
+		if (item.UserId > 0 && users[item.UserId]) || (item.TeamId > 0 && teams[item.TeamId]) {
+			return Error(400, guardian.ErrGuardianPermissionExists.Error(), nil)
+		}
+		users[item.UserId] = true
+		teams[item.TeamId] = true
+
+		cmd.Items = append(cmd.Items, &m.DataSourceAcl{
+			OrgId:        c.OrgId,
+			DataSourceId: ds.Id,
+			UserId:       item.UserId,
+			TeamId:       item.TeamId,
+			Permission:   item.Permission,
+			Created:      time.Now(),
+			Updated:      time.Now(),
+		})
+	}
+
+	if c.OrgRole != m.ROLE_ADMIN {
+		keepsAdmin, err := keepsDataSourceAdminPermission(c.SignedInUser, cmd.Items)
+		if err != nil {
+			return Error(500, "检查数据源权限时出错", err)
+		}
+		if !keepsAdmin {
+			return Error(403, "无法删除数据源的自己的管理员权限", nil)
+		}
+	}
+
+	if err := bus.Dispatch(&cmd); err != nil {
+		if err == m.ErrDataSourceAclInfoMissing || err == m.ErrDataSourceAclPermissionInvalid || err == m.ErrDataSourceAclDataSourceEmpty ||
+			err == m.ErrDataSourceAclUserNotInOrg || err == m.ErrDataSourceAclTeamNotInOrg {
+			return Error(400, err.Error(), err)
+		}
+		return Error(500, "更新数据源权限失败", err)
+	}
+
+	return Success("数据源权限更新成功")
+}
+
+// getDataSourceForAdmin returns the data source of the request if the user
+// is allowed to administrate its permissions.
+func getDataSourceForAdmin(c *m.ReqContext) (*m.DataSource, Response) {
+	query := m.GetDataSourceByIdQuery{Id: c.ParamsInt64(":id"), OrgId: c.OrgId}
+	if err := bus.Dispatch(&query); err != nil {
+		if err == m.ErrDataSourceNotFound {
+			return nil, Error(404, "没有找到数据源", nil)
+		}
+		return nil, Error(500, "无法查询数据源", err)
+	}
+
+	filterQuery := m.DatasourcesPermissionFilterQuery{
+		User:        c.SignedInUser,
+		Datasources: []*m.DataSource{query.Result},
+		Permission:  m.DsPermissionAdmin,
+	}
+	if err := bus.Dispatch(&filterQuery); err != nil {
+		return nil, Error(500, "检查数据源权限时出错", err)
+	}
+	if len(filterQuery.Result) == 0 {
+		return nil, Error(403, "拒绝访问数据源", nil)
+	}
+
+	return query.Result, nil
+}
+
+// keepsDataSourceAdminPermission returns true if the user is still allowed to
+// administrate the data source with the updated permissions.
+func keepsDataSourceAdminPermission(user *m.SignedInUser, items []*m.DataSourceAcl) (bool, error) {
+	query := m.GetTeamsByUserQuery{OrgId: user.OrgId, UserId: user.UserId}
+	if err := bus.Dispatch(&query); err != nil {
+		return false, err
+	}
+
+	teams := make(map[int64]bool)
+	for _, team := range query.Result {
+		teams[team.Id] = true
+	}
+
+	for _, item := range items {
+		if item.Permission != m.DsPermissionAdmin {
+			continue
+		}
+		if (item.UserId > 0 && item.UserId == user.UserId) || (item.TeamId > 0 && teams[item.TeamId]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	m "github.com/grafana/grafana/pkg/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDataSourcePermissionApiEndpoint(t *testing.T) {
+	Convey("数据源权限测试", t, func() {
+		Convey("给定数据源不存在", func() {
+			loggedInUserScenarioWithRole("当发生GET请求时", "GET", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_ADMIN, func(sc *scenarioContext) {
+				bus.AddHandler("test", func(query *m.GetDataSourceByIdQuery) error {
+					return m.ErrDataSourceNotFound
+				})
+
+				callGetDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 404)
+			})
+		})
+
+		Convey("给定用户没有管理员权限", func() {
+			loggedInUserScenarioWithRole("当发生GET请求时", "GET", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_EDITOR, func(sc *scenarioContext) {
+				mockDataSourcePermissions(false)
+
+				callGetDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 403)
+			})
+
+			cmd := dtos.UpdateDataSourceAclCommand{
+				Items: []dtos.DataSourceAclUpdateItem{
+					{UserId: 1000, Permission: m.DsPermissionAdmin},
+				},
+			}
+
+			updateDataSourcePermissionScenario("当发生POST请求时", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_EDITOR, cmd, func(sc *scenarioContext) {
+				mockDataSourcePermissions(false)
+
+				callUpdateDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("给定用户有管理员权限", func() {
+			loggedInUserScenarioWithRole("当发生GET请求时", "GET", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_EDITOR, func(sc *scenarioContext) {
+				mockDataSourcePermissions(true)
+				bus.AddHandler("test", func(query *m.GetDataSourceAclInfoListQuery) error {
+					query.Result = []*m.DataSourceAclInfoDTO{
+						{DataSourceId: 1, UserId: 2, Permission: m.DsPermissionQuery},
+						{DataSourceId: 1, TeamId: 1, Team: "team", Permission: m.DsPermissionAdmin},
+					}
+					return nil
+				})
+
+				callGetDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 200)
+
+				respJSON, err := simplejson.NewJson(sc.resp.Body.Bytes())
+				So(err, ShouldBeNil)
+				So(len(respJSON.MustArray()), ShouldEqual, 2)
+				So(respJSON.GetIndex(1).Get("teamId").MustInt(), ShouldEqual, 1)
+				So(respJSON.GetIndex(1).Get("permission").MustInt(), ShouldEqual, m.DsPermissionAdmin)
+			})
+
+			cmd := dtos.UpdateDataSourceAclCommand{
+				Items: []dtos.DataSourceAclUpdateItem{
+					{UserId: 1000, Permission: m.DsPermissionQuery},
+					{TeamId: 1, Permission: m.DsPermissionAdmin},
+				},
+			}
+
+			updateDataSourcePermissionScenario("当通过团队保留管理员权限时", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_EDITOR, cmd, func(sc *scenarioContext) {
+				mockDataSourcePermissions(true)
+				mockUserTeams(1)
+
+				callUpdateDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 200)
+			})
+
+			updateDataSourcePermissionScenario("当删除自己的管理员权限时", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_EDITOR, cmd, func(sc *scenarioContext) {
+				mockDataSourcePermissions(true)
+				mockUserTeams(2)
+
+				callUpdateDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 403)
+			})
+
+			updateDataSourcePermissionScenario("当组织管理员删除所有管理员权限时", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_ADMIN, dtos.UpdateDataSourceAclCommand{}, func(sc *scenarioContext) {
+				mockDataSourcePermissions(true)
+
+				callUpdateDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 200)
+			})
+
+			duplicateCmd := dtos.UpdateDataSourceAclCommand{
+				Items: []dtos.DataSourceAclUpdateItem{
+					{UserId: 1000, Permission: m.DsPermissionQuery},
+					{UserId: 1000, Permission: m.DsPermissionAdmin},
+				},
+			}
+
+			updateDataSourcePermissionScenario("当权限重复时", "/api/datasources/1/permissions", "/api/datasources/:id/permissions", m.ROLE_ADMIN, duplicateCmd, func(sc *scenarioContext) {
+				mockDataSourcePermissions(true)
+
+				callUpdateDataSourcePermissions(sc)
+				So(sc.resp.Code, ShouldEqual, 400)
+			})
+		})
+	})
+}
+
+func mockDataSourcePermissions(canAdmin bool) {
+	bus.AddHandler("test", func(query *m.GetDataSourceByIdQuery) error {
+		query.Result = &m.DataSource{Id: query.Id, OrgId: query.OrgId, Name: "test"}
+		return nil
+	})
+
+	bus.AddHandler("test", func(query *m.DatasourcesPermissionFilterQuery) error {
+		query.Result = []*m.DataSource{}
+		if canAdmin && query.Permission == m.DsPermissionAdmin {
+			query.Result = query.Datasources
+		}
+		return nil
+	})
+}
+
+func mockUserTeams(teamIds ...int64) {
+	bus.AddHandler("test", func(query *m.GetTeamsByUserQuery) error {
+		for _, id := range teamIds {
+			query.Result = append(query.Result, &m.TeamDTO{Id: id})
+		}
+		return nil
+	})
+}
+
+func callGetDataSourcePermissions(sc *scenarioContext) {
+	sc.handlerFunc = GetDataSourcePermissionList
+	sc.fakeReqWithParams("GET", sc.url, map[string]string{}).exec()
+}
+
+func callUpdateDataSourcePermissions(sc *scenarioContext) {
+	bus.AddHandler("test", func(cmd *m.UpdateDataSourceAclCommand) error {
+		return nil
+	})
+
+	sc.fakeReqWithParams("POST", sc.url, map[string]string{}).exec()
+}
+
+func updateDataSourcePermissionScenario(desc string, url string, routePattern string, role m.RoleType, cmd dtos.UpdateDataSourceAclCommand, fn scenarioFunc) {
+	Convey(desc+" "+url, func() {
+		defer bus.ClearBusHandlers()
+
+		sc := setupScenarioContext(url)
+
+		sc.defaultHandler = Wrap(func(c *m.ReqContext) Response {
+			sc.context = c
+			sc.context.OrgId = TestOrgID
+			sc.context.UserId = TestUserID
+			sc.context.OrgRole = role
+
+			return UpdateDataSourcePermissions(c, cmd)
+		})
+
+		sc.m.Post(routePattern, sc.defaultHandler)
+
+		fn(sc)
+	})
+}
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"context"
 	"sort"
+	"time"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/securejsondata"
 	m "github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
+	es "github.com/grafana/grafana/pkg/tsdb/elasticsearch/client"
 	"github.com/grafana/grafana/pkg/util"
 )
 
@@ -26,7 +30,6 @@ func GetDataSources(c *m.ReqContext) Response {
 			Url:       ds.Url,
 			Type:      ds.Type,
 			Access:    ds.Access,
-			Password:  ds.Password,
 			Database:  ds.Database,
 			User:      ds.User,
 			BasicAuth: ds.BasicAuth,
@@ -125,6 +128,17 @@ func DeleteDataSourceByName(c *m.ReqContext) Response {
 func AddDataSource(c *m.ReqContext, cmd m.AddDataSourceCommand) Response {
 	cmd.OrgId = c.OrgId
 
+	detectDataSourceVersion(c, &m.DataSource{
+		Type:           cmd.Type,
+		Url:            cmd.Url,
+		User:           cmd.User,
+		BasicAuth:      cmd.BasicAuth,
+		BasicAuthUser:  cmd.BasicAuthUser,
+		JsonData:       cmd.JsonData,
+		SecureJsonData: securejsondata.GetEncryptedJsonData(m.SecureJsonDataWithPasswords(cmd.SecureJsonData, cmd.Password, cmd.BasicAuthPassword)),
+		Updated:        time.Now(),
+	})
+
 	if err := bus.Dispatch(&cmd); err != nil {
 		if err == m.ErrDataSourceNameExists {
 			return Error(409, err.Error(), err)
@@ -151,6 +165,22 @@ func UpdateDataSource(c *m.ReqContext, cmd m.UpdateDataSourceCommand) Response {
 		return Error(500, "无法更新数据源", err)
 	}
 
+	secureJsonData := m.SecureJsonDataWithPasswords(cmd.SecureJsonData, cmd.Password, cmd.BasicAuthPassword)
+	if cmd.Type == m.DS_ES {
+		secureJsonData = withStoredSecureJsonData(cmd.Id, cmd.OrgId, secureJsonData)
+	}
+
+	detectDataSourceVersion(c, &m.DataSource{
+		Type:           cmd.Type,
+		Url:            cmd.Url,
+		User:           cmd.User,
+		BasicAuth:      cmd.BasicAuth,
+		BasicAuthUser:  cmd.BasicAuthUser,
+		JsonData:       cmd.JsonData,
+		SecureJsonData: securejsondata.GetEncryptedJsonData(secureJsonData),
+		Updated:        time.Now(),
+	})
+
 	err = bus.Dispatch(&cmd)
 	if err != nil {
 		if err == m.ErrDataSourceUpdatingOldVersion {
@@ -181,11 +211,54 @@ func UpdateDataSource(c *m.ReqContext, cmd m.UpdateDataSourceCommand) Response {
 	})
 }
 
+// dataSourceVersionTimeout bounds the request to the data source while it is
+// saved.
+var dataSourceVersionTimeout = 5 * time.Second
+
+// detectDataSourceVersion stores the version reported by the data source in
+// its json data. The configured version is kept when detection fails.
+func detectDataSourceVersion(c *m.ReqContext, ds *m.DataSource) {
+	if ds.Type != m.DS_ES || ds.JsonData == nil || ds.Url == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Req.Context(), dataSourceVersionTimeout)
+	defer cancel()
+
+	version, err := es.DetectVersion(ctx, ds)
+	if err != nil {
+		c.Logger.Warn("Failed to detect elasticsearch version", "url", ds.Url, "error", err)
+		return
+	}
+
+	ds.JsonData.Set("esVersion", version)
+}
+
+// withStoredSecureJsonData returns the secure json data of an update with the
+// stored values of the data source for the keys the update does not change.
+func withStoredSecureJsonData(id int64, orgID int64, secureJsonData map[string]string) map[string]string {
+	ds, err := getRawDataSourceById(id, orgID)
+	if err != nil {
+		return secureJsonData
+	}
+
+	result := ds.SecureJsonData.Decrypt()
+	for k, v := range secureJsonData {
+		result[k] = v
+	}
+
+	return result
+}
+
 func fillWithSecureJSONData(cmd *m.UpdateDataSourceCommand) error {
-	if len(cmd.SecureJsonData) == 0 {
+	if len(cmd.SecureJsonData) == 0 && cmd.Password == "" && cmd.BasicAuthPassword == "" {
 		return nil
 	}
 
+	if cmd.SecureJsonData == nil {
+		cmd.SecureJsonData = make(map[string]string)
+	}
+
 	ds, err := getRawDataSourceById(cmd.Id, cmd.OrgId)
 	if err != nil {
 		return err
@@ -256,24 +329,22 @@ func GetDataSourceIdByName(c *m.ReqContext) Response {
 
 func convertModelToDtos(ds *m.DataSource) dtos.DataSource {
 	dto := dtos.DataSource{
-		Id:                ds.Id,
-		OrgId:             ds.OrgId,
-		Name:              ds.Name,
-		Url:               ds.Url,
-		Type:              ds.Type,
-		Access:            ds.Access,
-		Password:          ds.Password,
-		Database:          ds.Database,
-		User:              ds.User,
-		BasicAuth:         ds.BasicAuth,
-		BasicAuthUser:     ds.BasicAuthUser,
-		BasicAuthPassword: ds.BasicAuthPassword,
-		WithCredentials:   ds.WithCredentials,
-		IsDefault:         ds.IsDefault,
-		JsonData:          ds.JsonData,
-		SecureJsonFields:  map[string]bool{},
-		Version:           ds.Version,
-		ReadOnly:          ds.ReadOnly,
+		Id:               ds.Id,
+		OrgId:            ds.OrgId,
+		Name:             ds.Name,
+		Url:              ds.Url,
+		Type:             ds.Type,
+		Access:           ds.Access,
+		Database:         ds.Database,
+		User:             ds.User,
+		BasicAuth:        ds.BasicAuth,
+		BasicAuthUser:    ds.BasicAuthUser,
+		WithCredentials:  ds.WithCredentials,
+		IsDefault:        ds.IsDefault,
+		JsonData:         ds.JsonData,
+		SecureJsonFields: map[string]bool{},
+		Version:          ds.Version,
+		ReadOnly:         ds.ReadOnly,
 	}
 
 	for k, v := range ds.SecureJsonData {
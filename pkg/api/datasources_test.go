@@ -2,8 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/models"
 
 	"github.com/grafana/grafana/pkg/bus"
@@ -56,3 +61,82 @@ func TestDataSourcesProxy(t *testing.T) {
 		})
 	})
 }
+
+func TestDataSourceVersionDetection(t *testing.T) {
+	Convey("Given an elasticsearch data source", t, func() {
+		block := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("block") != "" {
+				select {
+				case <-block:
+				case <-r.Context().Done():
+				}
+				return
+			}
+
+			user, password, _ := r.BasicAuth()
+			if user != "grafana" || password != "stored" {
+				w.WriteHeader(401)
+				return
+			}
+			w.Write([]byte(`{"version": {"number": "6.4.2"}}`))
+		}))
+
+		Reset(func() {
+			close(block)
+			server.Close()
+		})
+
+		var updated *models.UpdateDataSourceCommand
+		setup := func() {
+			bus.AddHandler("test", func(query *models.GetDataSourceByIdQuery) error {
+				query.Result = &models.DataSource{
+					Id:    query.Id,
+					OrgId: query.OrgId,
+					SecureJsonData: securejsondata.GetEncryptedJsonData(map[string]string{
+						"basicAuthPassword": "stored",
+					}),
+				}
+				return nil
+			})
+			bus.AddHandler("test", func(cmd *models.UpdateDataSourceCommand) error {
+				updated = cmd
+				return nil
+			})
+		}
+
+		update := func(sc *scenarioContext, url string) {
+			sc.handlerFunc = func(c *models.ReqContext) Response {
+				return UpdateDataSource(c, models.UpdateDataSourceCommand{
+					Name:          "es",
+					Type:          models.DS_ES,
+					Url:           url,
+					BasicAuth:     true,
+					BasicAuthUser: "grafana",
+					JsonData:      simplejson.NewFromAny(map[string]interface{}{"esVersion": 5}),
+				})
+			}
+			sc.fakeReqWithParams("GET", sc.url, map[string]string{}).exec()
+		}
+
+		loggedInUserScenario("When updating it without changing the password", "/api/datasources/1", func(sc *scenarioContext) {
+			setup()
+			update(sc, server.URL)
+
+			So(sc.resp.Code, ShouldEqual, 200)
+			So(updated.JsonData.Get("esVersion").MustInt(), ShouldEqual, 60)
+		})
+
+		loggedInUserScenario("When the data source does not respond", "/api/datasources/1", func(sc *scenarioContext) {
+			setup()
+			timeout := dataSourceVersionTimeout
+			dataSourceVersionTimeout = 50 * time.Millisecond
+			defer func() { dataSourceVersionTimeout = timeout }()
+
+			update(sc, server.URL+"/?block=1")
+
+			So(sc.resp.Code, ShouldEqual, 200)
+			So(updated.JsonData.Get("esVersion").MustInt(), ShouldEqual, 5)
+		})
+	})
+}
@@ -14,3 +14,13 @@ type DashboardAclUpdateItem struct {
 	Role       *m.RoleType      `json:"role,omitempty"`
 	Permission m.PermissionType `json:"permission"`
 }
+
+type UpdateDataSourceAclCommand struct {
+	Items []DataSourceAclUpdateItem `json:"items"`
+}
+
+type DataSourceAclUpdateItem struct {
+	UserId     int64              `json:"userId"`
+	TeamId     int64              `json:"teamId"`
+	Permission m.DsPermissionType `json:"permission"`
+}
@@ -8,25 +8,23 @@ import (
 )
 
 type DataSource struct {
-	Id                int64            `json:"id"`
-	OrgId             int64            `json:"orgId"`
-	Name              string           `json:"name"`
-	Type              string           `json:"type"`
-	TypeLogoUrl       string           `json:"typeLogoUrl"`
-	Access            m.DsAccess       `json:"access"`
-	Url               string           `json:"url"`
-	Password          string           `json:"password"`
-	User              string           `json:"user"`
-	Database          string           `json:"database"`
-	BasicAuth         bool             `json:"basicAuth"`
-	BasicAuthUser     string           `json:"basicAuthUser"`
-	BasicAuthPassword string           `json:"basicAuthPassword"`
-	WithCredentials   bool             `json:"withCredentials"`
-	IsDefault         bool             `json:"isDefault"`
-	JsonData          *simplejson.Json `json:"jsonData,omitempty"`
-	SecureJsonFields  map[string]bool  `json:"secureJsonFields"`
-	Version           int              `json:"version"`
-	ReadOnly          bool             `json:"readOnly"`
+	Id               int64            `json:"id"`
+	OrgId            int64            `json:"orgId"`
+	Name             string           `json:"name"`
+	Type             string           `json:"type"`
+	TypeLogoUrl      string           `json:"typeLogoUrl"`
+	Access           m.DsAccess       `json:"access"`
+	Url              string           `json:"url"`
+	User             string           `json:"user"`
+	Database         string           `json:"database"`
+	BasicAuth        bool             `json:"basicAuth"`
+	BasicAuthUser    string           `json:"basicAuthUser"`
+	WithCredentials  bool             `json:"withCredentials"`
+	IsDefault        bool             `json:"isDefault"`
+	JsonData         *simplejson.Json `json:"jsonData,omitempty"`
+	SecureJsonFields map[string]bool  `json:"secureJsonFields"`
+	Version          int              `json:"version"`
+	ReadOnly         bool             `json:"readOnly"`
 }
 
 type DataSourceListItemDTO struct {
@@ -37,7 +35,6 @@ type DataSourceListItemDTO struct {
 	TypeLogoUrl string           `json:"typeLogoUrl"`
 	Access      m.DsAccess       `json:"access"`
 	Url         string           `json:"url"`
-	Password    string           `json:"password"`
 	User        string           `json:"user"`
 	Database    string           `json:"database"`
 	BasicAuth   bool             `json:"basicAuth"`
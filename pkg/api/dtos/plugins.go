@@ -19,22 +19,24 @@ type PluginSetting struct {
 	JsonData      map[string]interface{}      `json:"jsonData"`
 	DefaultNavUrl string                      `json:"defaultNavUrl"`
 
-	LatestVersion string              `json:"latestVersion"`
-	HasUpdate     bool                `json:"hasUpdate"`
-	State         plugins.PluginState `json:"state"`
+	LatestVersion string                        `json:"latestVersion"`
+	HasUpdate     bool                          `json:"hasUpdate"`
+	State         plugins.PluginState           `json:"state"`
+	Signature     plugins.PluginSignatureStatus `json:"signature"`
 }
 
 type PluginListItem struct {
-	Name          string              `json:"name"`
-	Type          string              `json:"type"`
-	Id            string              `json:"id"`
-	Enabled       bool                `json:"enabled"`
-	Pinned        bool                `json:"pinned"`
-	Info          *plugins.PluginInfo `json:"info"`
-	LatestVersion string              `json:"latestVersion"`
-	HasUpdate     bool                `json:"hasUpdate"`
-	DefaultNavUrl string              `json:"defaultNavUrl"`
-	State         plugins.PluginState `json:"state"`
+	Name          string                        `json:"name"`
+	Type          string                        `json:"type"`
+	Id            string                        `json:"id"`
+	Enabled       bool                          `json:"enabled"`
+	Pinned        bool                          `json:"pinned"`
+	Info          *plugins.PluginInfo           `json:"info"`
+	LatestVersion string                        `json:"latestVersion"`
+	HasUpdate     bool                          `json:"hasUpdate"`
+	DefaultNavUrl string                        `json:"defaultNavUrl"`
+	State         plugins.PluginState           `json:"state"`
+	Signature     plugins.PluginSignatureStatus `json:"signature"`
 }
 
 type PluginList []PluginListItem
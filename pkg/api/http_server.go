@@ -27,6 +27,7 @@ import (
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/audit"
 	"github.com/grafana/grafana/pkg/services/cache"
 	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/hooks"
@@ -49,13 +50,15 @@ type HTTPServer struct {
 	streamManager *live.StreamManager
 	httpSrv       *http.Server
 
-	RouteRegister   routing.RouteRegister    `inject:""`
-	Bus             bus.Bus                  `inject:""`
-	RenderService   rendering.Service        `inject:""`
-	Cfg             *setting.Cfg             `inject:""`
-	HooksService    *hooks.HooksService      `inject:""`
-	CacheService    *cache.CacheService      `inject:""`
-	DatasourceCache datasources.CacheService `inject:""`
+	RouteRegister    routing.RouteRegister           `inject:""`
+	Bus              bus.Bus                         `inject:""`
+	RenderService    rendering.Service               `inject:""`
+	Cfg              *setting.Cfg                    `inject:""`
+	HooksService     *hooks.HooksService             `inject:""`
+	CacheService     *cache.CacheService             `inject:""`
+	DatasourceCache  datasources.CacheService        `inject:""`
+	DataSourceHealth *datasources.HealthCheckService `inject:""`
+	AuditService     *audit.AuditService             `inject:""`
 }
 
 func (hs *HTTPServer) Init() error {
@@ -2,11 +2,14 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/audit"
 	"github.com/grafana/grafana/pkg/tsdb"
 	"github.com/grafana/grafana/pkg/tsdb/testdata"
 	"github.com/grafana/grafana/pkg/util"
@@ -25,9 +28,11 @@ func (hs *HTTPServer) QueryMetrics(c *m.ReqContext, reqDto dtos.MetricRequest) R
 		return Error(400, "Query missing datasourceId", nil)
 	}
 
+	start := time.Now()
 	ds, err := hs.DatasourceCache.GetDatasource(datasourceId, c.SignedInUser, c.SkipCache)
 	if err != nil {
 		if err == m.ErrDataSourceAccessDenied {
+			hs.auditQuery(c, &m.DataSource{Id: datasourceId}, reqDto, 403, start)
 			return Error(403, "Access denied to datasource", err)
 		}
 		return Error(500, "Unable to load datasource meta data", err)
@@ -47,6 +52,7 @@ func (hs *HTTPServer) QueryMetrics(c *m.ReqContext, reqDto dtos.MetricRequest) R
 
 	resp, err := tsdb.HandleRequest(c.Req.Context(), ds, request)
 	if err != nil {
+		hs.auditQuery(c, ds, reqDto, 500, start)
 		return Error(500, "Metric request error", err)
 	}
 
@@ -59,9 +65,21 @@ func (hs *HTTPServer) QueryMetrics(c *m.ReqContext, reqDto dtos.MetricRequest) R
 		}
 	}
 
+	hs.auditQuery(c, ds, reqDto, statusCode, start)
 	return JSON(statusCode, &resp)
 }
 
+func (hs *HTTPServer) auditQuery(c *m.ReqContext, ds *m.DataSource, reqDto dtos.MetricRequest, status int, start time.Time) {
+	if !hs.AuditService.Enabled() {
+		return
+	}
+
+	data, _ := json.Marshal(reqDto)
+	body := hs.AuditService.NewBodyRecorder()
+	body.Write(data)
+	hs.AuditService.Log(c, ds, audit.SourceQuery, body, status, time.Since(start))
+}
+
 // GET /api/tsdb/testdata/scenarios
 func GetTestDataScenarios(c *m.ReqContext) Response {
 	result := make([]interface{}, 0)
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/backendplugin"
+	"github.com/grafana/grafana/pkg/plugins/datasource/wrapper"
+	"github.com/grafana/grafana/pkg/services/audit"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+var errResourceBodyTooLarge = errors.New("request body too large")
+
+// /api/plugins/:pluginId/resources/*
+func (hs *HTTPServer) CallPluginResource(c *m.ReqContext) {
+	plugin, exists := plugins.DataSources[c.Params(":pluginId")]
+	if !exists || !plugin.Backend {
+		c.JsonApiErr(404, "未找到插件，没有安装该ID的后端插件", nil)
+		return
+	}
+
+	hs.callPluginResource(c, plugin, nil)
+}
+
+// /api/datasources/:id/resources/*
+func (hs *HTTPServer) CallDataSourceResource(c *m.ReqContext) {
+	start := time.Now()
+
+	dsId := c.ParamsInt64(":id")
+	ds, err := hs.DatasourceCache.GetDatasource(dsId, c.SignedInUser, c.SkipCache)
+	if err != nil {
+		if err == m.ErrDataSourceAccessDenied {
+			c.JsonApiErr(403, "Access denied to datasource", err)
+			hs.AuditService.Log(c, &m.DataSource{Id: dsId}, audit.SourceResource, nil, 403, time.Since(start))
+			return
+		}
+		if err == m.ErrDataSourceNotFound {
+			c.JsonApiErr(404, "没有找到数据源", nil)
+			return
+		}
+		c.JsonApiErr(500, "Unable to load datasource meta data", err)
+		return
+	}
+
+	plugin, exists := plugins.DataSources[ds.Type]
+	if !exists || !plugin.Backend {
+		c.JsonApiErr(404, "数据源没有后端插件", nil)
+		return
+	}
+
+	body := hs.AuditService.NewBodyRecorder()
+	body.Write(hs.callPluginResource(c, plugin, ds))
+	hs.AuditService.Log(c, ds, audit.SourceResource, body, c.Resp.Status(), time.Since(start))
+}
+
+// callPluginResource forwards the request to the backend plugin and returns
+// the body of the request.
+func (hs *HTTPServer) callPluginResource(c *m.ReqContext, plugin *plugins.DataSourcePlugin, ds *m.DataSource) []byte {
+	body, err := readResourceBody(c.Req.Request, setting.PluginResourceMaxBodySize)
+	if err == errResourceBodyTooLarge {
+		c.JsonApiErr(413, "请求体过大", err)
+		return nil
+	}
+	if err != nil {
+		c.JsonApiErr(500, "无法读取请求", err)
+		return nil
+	}
+
+	req := &backendplugin.CallResourceRequest{
+		OrgId:    c.OrgId,
+		PluginId: plugin.Id,
+		Path:     c.Params("*"),
+		Method:   c.Req.Method,
+		Url:      c.Req.URL.String(),
+		Headers:  map[string]*backendplugin.StringList{},
+		Body:     body,
+		User: &backendplugin.User{
+			Login: c.Login,
+			Name:  c.Name,
+			Email: c.Email,
+			Role:  string(c.OrgRole),
+		},
+	}
+
+	for key, values := range c.Req.Header {
+		// the credentials of the user in grafana are not for the plugin
+		if key == "Cookie" || key == "Authorization" {
+			continue
+		}
+		req.Headers[key] = &backendplugin.StringList{Values: values}
+	}
+
+	if ds != nil {
+		dsInfo, err := wrapper.NewDatasourceInfo(ds)
+		if err != nil {
+			c.JsonApiErr(500, "无法读取数据源设置", err)
+			return body
+		}
+		req.Datasource = dsInfo
+	}
+
+	writer := &resourceResponseWriter{resp: c.Resp}
+	err = plugin.CallResource(c.Req.Context(), req, writer)
+
+	switch {
+	case err == backendplugin.ErrNotImplemented:
+		c.JsonApiErr(404, "插件没有资源", nil)
+	case err == plugins.ErrPluginNotRunning:
+		c.JsonApiErr(503, "后端插件没有运行", err)
+	case err != nil && !writer.started:
+		c.JsonApiErr(500, "调用插件资源失败", err)
+	case err != nil:
+		// the response is already sent, it is incomplete
+		hs.log.Error("Failed to stream plugin resource", "plugin", plugin.Id, "path", req.Path, "error", err)
+	case !writer.started:
+		c.Resp.WriteHeader(http.StatusNoContent)
+	}
+
+	return body
+}
+
+// resourceResponseWriter writes the streamed response of a plugin resource,
+// the first part carries the status code and headers.
+type resourceResponseWriter struct {
+	resp    http.ResponseWriter
+	started bool
+}
+
+func (w *resourceResponseWriter) Send(part *backendplugin.CallResourceResponse) error {
+	if !w.started {
+		w.started = true
+
+		for key, values := range part.Headers {
+			w.resp.Header()[key] = values.Values
+		}
+		w.resp.Header().Del("Set-Cookie")
+
+		code := int(part.Code)
+		if code == 0 {
+			code = http.StatusOK
+		}
+		w.resp.WriteHeader(code)
+	}
+
+	if len(part.Body) > 0 {
+		if _, err := w.resp.Write(part.Body); err != nil {
+			return err
+		}
+		if flusher, ok := w.resp.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	return nil
+}
+
+// readResourceBody reads the body of a request for a plugin, the body is sent
+// to the plugin in one message and may be at most max bytes.
+func readResourceBody(req *http.Request, max int64) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	if max > 0 && req.ContentLength > max {
+		return nil, errResourceBodyTooLarge
+	}
+
+	reader := io.Reader(req.Body)
+	if max > 0 {
+		reader = io.LimitReader(req.Body, max+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if max > 0 && int64(len(body)) > max {
+		return nil, errResourceBodyTooLarge
+	}
+
+	return body, nil
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadResourceBody(t *testing.T) {
+	Convey("Reading the body of a plugin resource request", t, func() {
+		Convey("Reads a body up to the max size", func() {
+			req, _ := http.NewRequest("POST", "/api/plugins/test/resources/x", strings.NewReader("12345"))
+			body, err := readResourceBody(req, 5)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, "12345")
+		})
+
+		Convey("Rejects a larger body", func() {
+			req, _ := http.NewRequest("POST", "/api/plugins/test/resources/x", strings.NewReader("123456"))
+			_, err := readResourceBody(req, 5)
+			So(err, ShouldEqual, errResourceBodyTooLarge)
+		})
+
+		Convey("Rejects a larger body without content length", func() {
+			req, _ := http.NewRequest("POST", "/api/plugins/test/resources/x", strings.NewReader("123456"))
+			req.ContentLength = -1
+			_, err := readResourceBody(req, 5)
+			So(err, ShouldEqual, errResourceBodyTooLarge)
+		})
+
+		Convey("Reads any body without max size", func() {
+			req, _ := http.NewRequest("POST", "/api/plugins/test/resources/x", strings.NewReader("123456"))
+			body, err := readResourceBody(req, 0)
+			So(err, ShouldBeNil)
+			So(len(body), ShouldEqual, 6)
+		})
+	})
+}
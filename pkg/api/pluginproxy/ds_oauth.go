@@ -0,0 +1,63 @@
+package pluginproxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/social"
+)
+
+// addOAuthPassThruAuth forwards the OAuth access token of the signed in user
+// to the data source. Expired tokens are refreshed by the OAuth provider of
+// the user and stored again.
+func addOAuthPassThruAuth(c *m.ReqContext, req *http.Request) {
+	authInfoQuery := &m.GetAuthInfoQuery{UserId: c.UserId}
+	if err := bus.Dispatch(authInfoQuery); err != nil {
+		logger.Error("Error fetching oauth information for user", "userid", c.UserId, "username", c.Login, "error", err)
+		return
+	}
+
+	authInfo := authInfoQuery.Result
+
+	// the keys of the social map don't have the oauth_ prefix of the auth modules
+	provider := strings.TrimPrefix(authInfo.AuthModule, "oauth_")
+	connect, ok := social.SocialMap[provider]
+	if !ok || provider == authInfo.AuthModule {
+		logger.Error("Failed to find oauth provider for user", "userid", c.UserId, "username", c.Login, "authModule", authInfo.AuthModule)
+		return
+	}
+
+	persistedToken := &oauth2.Token{
+		AccessToken:  authInfo.OAuthAccessToken,
+		Expiry:       authInfo.OAuthExpiry,
+		RefreshToken: authInfo.OAuthRefreshToken,
+		TokenType:    authInfo.OAuthTokenType,
+	}
+
+	token, err := connect.TokenSource(c.Req.Context(), persistedToken).Token()
+	if err != nil {
+		logger.Error("Failed to retrieve access token from oauth provider", "provider", authInfo.AuthModule, "userid", c.UserId, "username", c.Login, "error", err)
+		return
+	}
+
+	// store the refreshed token
+	if token.AccessToken != persistedToken.AccessToken {
+		updateAuthCommand := &m.UpdateAuthInfoCommand{
+			UserId:     authInfo.UserId,
+			AuthModule: authInfo.AuthModule,
+			AuthId:     authInfo.AuthId,
+			OAuthToken: token,
+		}
+		if err := bus.Dispatch(updateAuthCommand); err != nil {
+			logger.Error("Failed to update access token during token refresh", "userid", c.UserId, "username", c.Login, "error", err)
+		}
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Add("Authorization", fmt.Sprintf("%s %s", token.Type(), token.AccessToken))
+}
@@ -0,0 +1,372 @@
+package pluginproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+
+	"github.com/grafana/grafana/pkg/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+var proxyResponseCache = newResponseCache()
+
+// cachedResponse is a complete response of a data source. It is shared
+// between coalesced requests and cache hits and must not be modified.
+type cachedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r *cachedResponse) writeTo(w http.ResponseWriter) {
+	for key, values := range r.header {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	w.Header().Del("Set-Cookie")
+	w.WriteHeader(r.status)
+	w.Write(r.body)
+}
+
+type coalescedCall struct {
+	done    chan struct{}
+	resp    *cachedResponse
+	cancel  context.CancelFunc
+	callers int
+}
+
+type responseCache struct {
+	cache *gocache.Cache
+	calls map[string]*coalescedCall
+	sync.Mutex
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		cache: gocache.New(gocache.NoExpiration, time.Minute),
+		calls: map[string]*coalescedCall{},
+	}
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	if cached, found := c.cache.Get(key); found {
+		return cached.(*cachedResponse), true
+	}
+	return nil, false
+}
+
+func (c *responseCache) set(key string, resp *cachedResponse, ttl time.Duration) {
+	c.cache.Set(key, resp, ttl)
+}
+
+// do executes fn and returns its response. If coalesce is true, concurrent
+// calls with the same key wait for the first call and share its response.
+// The first call then runs with a context of its own, which is only canceled
+// when all callers waiting for it are gone. The shared response is nil if fn
+// could not record it, or if ctx is done before it is available.
+func (c *responseCache) do(ctx context.Context, key string, coalesce bool, fn func(ctx context.Context) *cachedResponse) (*cachedResponse, bool) {
+	if !coalesce {
+		return fn(ctx), false
+	}
+
+	var callCtx context.Context
+
+	c.Lock()
+	call, found := c.calls[key]
+	if !found {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithCancel(detachedContext{ctx})
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+	}
+	call.callers++
+	c.Unlock()
+
+	if found {
+		select {
+		case <-call.done:
+			c.leave(call)
+			return call.resp, true
+		case <-ctx.Done():
+			c.leave(call)
+			return nil, true
+		}
+	}
+
+	go func() {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+		}
+		c.leave(call)
+	}()
+
+	defer func() {
+		c.Lock()
+		delete(c.calls, key)
+		c.Unlock()
+
+		close(call.done)
+	}()
+
+	call.resp = fn(callCtx)
+	return call.resp, false
+}
+
+// leave removes a caller from the call and cancels the call when it was the
+// last one.
+func (c *responseCache) leave(call *coalescedCall) {
+	c.Lock()
+	defer c.Unlock()
+
+	call.callers--
+	if call.callers == 0 {
+		call.cancel()
+	}
+}
+
+// detachedContext keeps the values of its parent but not its deadline and
+// cancellation, so a coalesced request does not end with the request of the
+// first caller.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// responseRecorder passes the response of the reverse proxy on to the client
+// and records it so it can be shared with coalesced requests and stored in
+// the cache. Responses larger than max are not recorded but still streamed.
+type responseRecorder struct {
+	w        http.ResponseWriter
+	max      int
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+	writeErr error
+}
+
+func newResponseRecorder(w http.ResponseWriter, max int) *responseRecorder {
+	return &responseRecorder{w: w, max: max}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.w.Header()
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+
+	r.status = status
+	r.w.Header().Del("Set-Cookie")
+	r.header = http.Header{}
+	for key, values := range r.w.Header() {
+		r.header[key] = append([]string(nil), values...)
+	}
+	r.w.WriteHeader(status)
+}
+
+// Write keeps recording when the client is gone, coalesced requests may still
+// wait for the response.
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if r.writeErr == nil {
+		_, r.writeErr = r.w.Write(data)
+	}
+
+	if !r.overflow {
+		if r.body.Len()+len(data) > r.max {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(data)
+		}
+	}
+
+	return len(data), nil
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.w.(http.Flusher); ok && r.writeErr == nil {
+		flusher.Flush()
+	}
+}
+
+// result returns the recorded response, or nil if it was too large.
+func (r *responseRecorder) result() *cachedResponse {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.overflow {
+		return nil
+	}
+	return &cachedResponse{status: r.status, header: r.header, body: r.body.Bytes()}
+}
+
+func useResponseCache(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	return setting.DataProxyCoalesceRequests || setting.DataProxyCacheTTL > 0
+}
+
+// serveCachedRequest serves a GET request from the response cache, or proxies
+// it once for all identical concurrent requests and caches the response. The
+// first request streams the response, the coalesced requests get it when it
+// is complete. Responses too large to record are proxied for every request.
+func (proxy *DataSourceProxy) serveCachedRequest(reverseProxy *httputil.ReverseProxy) {
+	key := proxy.getCacheKey()
+	cacheable := setting.DataProxyCacheTTL > 0 && !isNoCacheRequest(proxy.ctx.Req.Request)
+
+	if cacheable {
+		if resp, found := proxyResponseCache.get(key); found {
+			metrics.M_DataSource_ProxyCache_Requests.WithLabelValues("hit").Inc()
+			metrics.M_DataSource_ProxyCache_Saved_Bytes.Add(float64(len(resp.body)))
+			resp.writeTo(proxy.ctx.Resp)
+			return
+		}
+	}
+
+	ctx := proxy.ctx.Req.Context()
+	resp, shared := proxyResponseCache.do(ctx, key, setting.DataProxyCoalesceRequests, func(callCtx context.Context) *cachedResponse {
+		recorder := newResponseRecorder(proxy.ctx.Resp, setting.DataProxyCacheMaxItemSize)
+		reverseProxy.ServeHTTP(recorder, proxy.ctx.Req.WithContext(callCtx))
+		resp := recorder.result()
+
+		if resp != nil && cacheable && resp.status == http.StatusOK {
+			if ttl := getResponseCacheTTL(resp.header, setting.DataProxyCacheTTL); ttl > 0 {
+				proxyResponseCache.set(key, resp, ttl)
+			}
+		}
+
+		return resp
+	})
+
+	if !shared {
+		metrics.M_DataSource_ProxyCache_Requests.WithLabelValues("miss").Inc()
+		return
+	}
+
+	if resp == nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// a recorder without space only streams the response
+		metrics.M_DataSource_ProxyCache_Requests.WithLabelValues("miss").Inc()
+		reverseProxy.ServeHTTP(newResponseRecorder(proxy.ctx.Resp, 0), proxy.ctx.Req.Request)
+		return
+	}
+
+	metrics.M_DataSource_ProxyCache_Requests.WithLabelValues("coalesced").Inc()
+	metrics.M_DataSource_ProxyCache_Saved_Bytes.Add(float64(len(resp.body)))
+	resp.writeTo(proxy.ctx.Resp)
+}
+
+// getCacheKey returns the key of the request in the response cache. Requests
+// only share responses when they hit the same version of the data source with
+// the same path and query, and the user has the same org and role, as the
+// role can select a different plugin route. Requests carrying credentials of
+// the user are only shared by the same user with the same credentials.
+func (proxy *DataSourceProxy) getCacheKey() string {
+	req := proxy.ctx.Req.Request
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n%d\n%s\n%s\n%s\n", proxy.ds.Id, proxy.ds.Version, proxy.proxyPath, req.URL.RawQuery, req.Header.Get("Accept-Encoding"))
+	fmt.Fprintf(hash, "%d\n%s\n", proxy.ctx.OrgId, proxy.ctx.OrgRole)
+
+	credentials := proxy.getUserCredentials()
+	if len(credentials) > 0 {
+		fmt.Fprintf(hash, "%d\n", proxy.ctx.UserId)
+		for _, credential := range credentials {
+			io.WriteString(hash, credential+"\n")
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// getUserCredentials returns the values of the request which authenticate the
+// user against the data source.
+func (proxy *DataSourceProxy) getUserCredentials() []string {
+	var credentials []string
+
+	if proxy.ds.JsonData != nil && proxy.ds.JsonData.Get("oauthPassThru").MustBool() {
+		credentials = append(credentials, "oauthPassThru")
+	}
+
+	if dsAuth := proxy.ctx.Req.Header.Get("X-DS-Authorization"); dsAuth != "" {
+		credentials = append(credentials, dsAuth)
+	}
+
+	if proxy.ds.JsonData != nil {
+		for _, name := range proxy.ds.JsonData.Get("keepCookies").MustStringArray() {
+			if cookie, err := proxy.ctx.Req.Cookie(name); err == nil {
+				credentials = append(credentials, cookie.String())
+			}
+		}
+	}
+
+	return credentials
+}
+
+func isNoCacheRequest(req *http.Request) bool {
+	for _, directive := range strings.Split(req.Header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return true
+		}
+	}
+	return req.Header.Get("Pragma") == "no-cache"
+}
+
+// getResponseCacheTTL returns how long a response may be cached, honoring the
+// Cache-Control and Vary headers of the data source. maxTTL is never exceeded.
+func getResponseCacheTTL(header http.Header, maxTTL time.Duration) time.Duration {
+	for _, vary := range strings.Split(header.Get("Vary"), ",") {
+		vary = strings.TrimSpace(vary)
+		if vary != "" && !strings.EqualFold(vary, "Accept-Encoding") {
+			return 0
+		}
+	}
+
+	ttl := maxTTL
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		switch {
+		case directive == "no-store" || directive == "no-cache" || directive == "private":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil {
+				return 0
+			}
+			if maxAge := time.Duration(seconds) * time.Second; maxAge < ttl {
+				ttl = maxAge
+			}
+		}
+	}
+
+	return ttl
+}
@@ -0,0 +1,246 @@
+package pluginproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	macaron "gopkg.in/macaron.v1"
+
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestDataSourceProxyCache(t *testing.T) {
+	Convey("Data source proxy response cache", t, func() {
+		var requests int32
+		cacheControl := ""
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count := atomic.AddInt32(&requests, 1)
+			if cacheControl != "" {
+				w.Header().Set("Cache-Control", cacheControl)
+			}
+			w.Header().Set("Set-Cookie", "session=secret")
+			fmt.Fprintf(w, "response %d", count)
+		}))
+		defer backend.Close()
+
+		setting.DataProxyCacheTTL = time.Minute
+		setting.DataProxyCacheMaxItemSize = 1024
+		proxyResponseCache = newResponseCache()
+		defer func() {
+			setting.DataProxyCacheTTL = 0
+			proxyResponseCache = newResponseCache()
+		}()
+
+		ds := &m.DataSource{Id: 1, Type: m.DS_GRAPHITE, Url: backend.URL}
+
+		request := func(method string, url string, role m.RoleType) *closeNotifyRecorder {
+			req, _ := http.NewRequest(method, url, nil)
+			recorder := &closeNotifyRecorder{httptest.NewRecorder()}
+			ctx := &m.ReqContext{
+				SignedInUser: &m.SignedInUser{UserId: 1, OrgId: 1, OrgRole: role},
+				Context: &macaron.Context{
+					Req:  macaron.Request{Request: req},
+					Resp: macaron.NewResponseWriter(method, recorder),
+				},
+			}
+
+			NewDataSourceProxy(ds, &plugins.DataSourcePlugin{}, ctx, "render").HandleRequest()
+			return recorder
+		}
+
+		Convey("Should serve identical requests from the cache", func() {
+			first := request("GET", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+			second := request("GET", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+
+			So(first.Body.String(), ShouldEqual, "response 1")
+			So(second.Body.String(), ShouldEqual, "response 1")
+			So(second.Header().Get("Set-Cookie"), ShouldEqual, "")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+		})
+
+		Convey("Should not share responses between queries and roles", func() {
+			request("GET", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+			request("GET", "http://grafana.com/render?target=b", m.ROLE_VIEWER)
+			request("GET", "http://grafana.com/render?target=a", m.ROLE_EDITOR)
+
+			So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+		})
+
+		Convey("Should not cache other methods than GET", func() {
+			request("POST", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+			request("POST", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+
+			So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+		})
+
+		Convey("Should honor no-store of the data source", func() {
+			cacheControl = "no-store"
+			request("GET", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+			request("GET", "http://grafana.com/render?target=a", m.ROLE_VIEWER)
+
+			So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+		})
+	})
+
+	Convey("Response cache TTL", t, func() {
+		ttl := func(cacheControl string, vary string) time.Duration {
+			header := http.Header{}
+			header.Set("Cache-Control", cacheControl)
+			header.Set("Vary", vary)
+			return getResponseCacheTTL(header, time.Minute)
+		}
+
+		So(ttl("", ""), ShouldEqual, time.Minute)
+		So(ttl("public, max-age=10", "Accept-Encoding"), ShouldEqual, 10*time.Second)
+		So(ttl("max-age=3600", ""), ShouldEqual, time.Minute)
+		So(ttl("private, max-age=10", ""), ShouldEqual, 0)
+		So(ttl("no-cache", ""), ShouldEqual, 0)
+		So(ttl("", "Authorization"), ShouldEqual, 0)
+	})
+
+	Convey("Coalescing requests", t, func() {
+		cache := newResponseCache()
+		release := make(chan struct{})
+		var calls int32
+
+		fn := func(ctx context.Context) *cachedResponse {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return &cachedResponse{status: 200, body: []byte("data")}
+		}
+
+		var wg sync.WaitGroup
+		var sharedCount int32
+		bodies := make([]string, 5)
+		for i := range bodies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resp, shared := cache.do(context.Background(), "key", true, fn)
+				bodies[i] = string(resp.body)
+				if shared {
+					atomic.AddInt32(&sharedCount, 1)
+				}
+			}(i)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+		So(atomic.LoadInt32(&sharedCount), ShouldEqual, 4)
+		So(bodies, ShouldResemble, []string{"data", "data", "data", "data", "data"})
+		So(len(cache.calls), ShouldEqual, 0)
+	})
+
+	Convey("Coalesced requests outlive the first caller", t, func() {
+		cache := newResponseCache()
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		fn := func(ctx context.Context) *cachedResponse {
+			close(started)
+			select {
+			case <-release:
+				return &cachedResponse{status: 200, body: []byte("data")}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		firstCtx, cancelFirst := context.WithCancel(context.Background())
+		firstDone := make(chan *cachedResponse)
+		go func() {
+			resp, _ := cache.do(firstCtx, "key", true, fn)
+			firstDone <- resp
+		}()
+		<-started
+
+		waiterDone := make(chan *cachedResponse)
+		go func() {
+			resp, _ := cache.do(context.Background(), "key", true, fn)
+			waiterDone <- resp
+		}()
+
+		for {
+			cache.Lock()
+			callers := cache.calls["key"].callers
+			cache.Unlock()
+			if callers == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		cancelFirst()
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		So(string((<-waiterDone).body), ShouldEqual, "data")
+		So(string((<-firstDone).body), ShouldEqual, "data")
+	})
+
+	Convey("Coalesced calls are canceled when all callers are gone", t, func() {
+		cache := newResponseCache()
+		canceled := make(chan struct{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go cache.do(ctx, "key", true, func(ctx context.Context) *cachedResponse {
+			<-ctx.Done()
+			close(canceled)
+			return nil
+		})
+
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("call was not canceled")
+		}
+	})
+
+	Convey("Response recorder", t, func() {
+		Convey("Should stream and record small responses", func() {
+			w := httptest.NewRecorder()
+			recorder := newResponseRecorder(w, 10)
+			recorder.Header().Set("Set-Cookie", "session=secret")
+			recorder.Write([]byte("data"))
+
+			So(w.Body.String(), ShouldEqual, "data")
+			So(w.Header().Get("Set-Cookie"), ShouldEqual, "")
+			So(string(recorder.result().body), ShouldEqual, "data")
+		})
+
+		Convey("Should stream but not record large responses", func() {
+			w := httptest.NewRecorder()
+			recorder := newResponseRecorder(w, 10)
+			recorder.Write([]byte("0123456789"))
+			recorder.Write([]byte("abc"))
+			recorder.Flush()
+
+			So(w.Body.String(), ShouldEqual, "0123456789abc")
+			So(w.Flushed, ShouldBeTrue)
+			So(recorder.result(), ShouldBeNil)
+		})
+	})
+}
+
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *closeNotifyRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
@@ -2,6 +2,7 @@ package pluginproxy
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -9,13 +10,17 @@ import (
 	"testing"
 	"time"
 
+	"golang.org/x/oauth2"
 	macaron "gopkg.in/macaron.v1"
 
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/securejsondata"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/log"
 	m "github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/social"
 	"github.com/grafana/grafana/pkg/util"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -230,6 +235,69 @@ func TestDSRouteRule(t *testing.T) {
 			})
 		})
 
+		Convey("When proxying a data source with oauth pass-thru", func() {
+			social.SocialMap["generic_oauth"] = &fakeSocialConnector{
+				token: &oauth2.Token{
+					AccessToken:  "refreshed_token",
+					RefreshToken: "refresh_token",
+					TokenType:    "Bearer",
+					Expiry:       time.Now().Add(time.Hour),
+				},
+			}
+			defer delete(social.SocialMap, "generic_oauth")
+
+			bus.AddHandler("test", func(query *m.GetAuthInfoQuery) error {
+				query.Result = &m.UserAuth{
+					UserId:            query.UserId,
+					AuthModule:        "oauth_generic_oauth",
+					OAuthAccessToken:  "expired_token",
+					OAuthRefreshToken: "refresh_token",
+					OAuthTokenType:    "Bearer",
+					OAuthExpiry:       time.Now().Add(-time.Hour),
+				}
+				return nil
+			})
+
+			var updated *m.UpdateAuthInfoCommand
+			bus.AddHandler("test", func(cmd *m.UpdateAuthInfoCommand) error {
+				updated = cmd
+				return nil
+			})
+			defer bus.ClearBusHandlers()
+
+			plugin := &plugins.DataSourcePlugin{}
+			ds := &m.DataSource{
+				Type:     "custom-datasource",
+				Url:      "http://host/root/",
+				JsonData: simplejson.NewFromAny(map[string]interface{}{"oauthPassThru": true}),
+			}
+
+			req, _ := http.NewRequest("GET", "http://localhost/asd", nil)
+			ctx := &m.ReqContext{
+				SignedInUser: &m.SignedInUser{UserId: 1},
+				Context: &macaron.Context{
+					Req: macaron.Request{Request: req},
+				},
+			}
+
+			proxy := NewDataSourceProxy(ds, plugin, ctx, "/path/to/folder/")
+			req, err := http.NewRequest(http.MethodGet, "http://grafana.com/sub", nil)
+			So(err, ShouldBeNil)
+
+			proxy.getDirector()(req)
+
+			Convey("Should forward the refreshed token of the user", func() {
+				So(req.Header.Get("Authorization"), ShouldEqual, "Bearer refreshed_token")
+			})
+
+			Convey("Should store the refreshed token", func() {
+				So(updated, ShouldNotBeNil)
+				So(updated.UserId, ShouldEqual, 1)
+				So(updated.AuthModule, ShouldEqual, "oauth_generic_oauth")
+				So(updated.OAuthToken.AccessToken, ShouldEqual, "refreshed_token")
+			})
+		})
+
 		Convey("When proxying graphite", func() {
 			setting.BuildVersion = "5.3.0"
 			plugin := &plugins.DataSourcePlugin{}
@@ -257,7 +325,9 @@ func TestDSRouteRule(t *testing.T) {
 				Url:      "http://influxdb:8083",
 				Database: "site",
 				User:     "user",
-				Password: "password",
+				SecureJsonData: securejsondata.GetEncryptedJsonData(map[string]string{
+					"password": "password",
+				}),
 			}
 
 			ctx := &m.ReqContext{}
@@ -414,3 +484,12 @@ func newFakeHTTPClient(fakeBody []byte) httpClient {
 		fakeBody: fakeBody,
 	}
 }
+
+type fakeSocialConnector struct {
+	social.SocialConnector
+	token *oauth2.Token
+}
+
+func (c *fakeSocialConnector) TokenSource(ctx context.Context, t *oauth2.Token) oauth2.TokenSource {
+	return oauth2.StaticTokenSource(c.token)
+}
@@ -0,0 +1,151 @@
+package pluginproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+var routeTransportCache = routeTransportCacheType{
+	cache: map[string]*cachedRouteTransport{},
+}
+
+type routeTransportCacheType struct {
+	cache map[string]*cachedRouteTransport
+	sync.Mutex
+}
+
+type cachedRouteTransport struct {
+	updated   time.Time
+	transport *http.Transport
+}
+
+// getDataSourceRouteTransport returns the transport of the data source, or a
+// transport with the client TLS configuration of the route if it has one.
+func getDataSourceRouteTransport(ds *m.DataSource, route *plugins.AppPluginRoute) (*http.Transport, error) {
+	if route == nil || route.TLS == nil {
+		return ds.GetHttpTransport()
+	}
+
+	data := templateData{
+		JsonData:       ds.JsonData.Interface().(map[string]interface{}),
+		SecureJsonData: ds.SecureJsonData.Decrypt(),
+	}
+
+	key := fmt.Sprintf("ds_%d_%s_%s", ds.Id, route.Path, route.Method)
+	return getRouteTransport(key, ds.Updated, route.TLS, data)
+}
+
+// GetAppRouteTransport returns the transport with the client TLS
+// configuration of an app plugin route, or defaultTransport if the route has
+// none.
+func GetAppRouteTransport(orgId int64, appID string, route *plugins.AppPluginRoute, defaultTransport *http.Transport) (*http.Transport, error) {
+	if route.TLS == nil {
+		return defaultTransport, nil
+	}
+
+	query := m.GetPluginSettingByIdQuery{OrgId: orgId, PluginId: appID}
+	if err := bus.Dispatch(&query); err != nil {
+		return nil, err
+	}
+
+	data := templateData{
+		JsonData:       query.Result.JsonData,
+		SecureJsonData: query.Result.SecureJsonData.Decrypt(),
+	}
+
+	key := fmt.Sprintf("app_%d_%s_%s_%s", orgId, appID, route.Path, route.Method)
+	return getRouteTransport(key, query.Result.Updated, route.TLS, data)
+}
+
+func getRouteTransport(key string, updated time.Time, routeTLS *plugins.AppPluginRouteTLS, data templateData) (*http.Transport, error) {
+	routeTransportCache.Lock()
+	defer routeTransportCache.Unlock()
+
+	if cached, found := routeTransportCache.cache[key]; found && cached.updated.Equal(updated) {
+		return cached.transport, nil
+	}
+
+	tlsConfig, err := newRouteTLSConfig(routeTLS, data)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		Proxy:           http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).Dial,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	routeTransportCache.cache[key] = &cachedRouteTransport{
+		updated:   updated,
+		transport: transport,
+	}
+
+	return transport, nil
+}
+
+func newRouteTLSConfig(routeTLS *plugins.AppPluginRouteTLS, data templateData) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: routeTLS.SkipVerify,
+		Renegotiation:      tls.RenegotiateFreelyAsClient,
+	}
+
+	if routeTLS.ServerName != "" {
+		serverName, err := interpolateString(routeTLS.ServerName, data)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = serverName
+	}
+
+	if routeTLS.CACert != "" {
+		caCert, err := interpolateString(routeTLS.CACert, data)
+		if err != nil {
+			return nil, err
+		}
+
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("Failed to parse route TLS CA PEM certificate")
+		}
+		tlsConfig.RootCAs = caPool
+	}
+
+	if routeTLS.ClientCert != "" || routeTLS.ClientKey != "" {
+		clientCert, err := interpolateString(routeTLS.ClientCert, data)
+		if err != nil {
+			return nil, err
+		}
+
+		clientKey, err := interpolateString(routeTLS.ClientKey, data)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load route TLS client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,110 @@
+package pluginproxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/setting"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouteTLS(t *testing.T) {
+	Convey("Plugin route with client TLS", t, func() {
+		setting.SecretKey = "password"
+		cert, key := newTestCertificate()
+
+		route := &plugins.AppPluginRoute{
+			Path: "api/secure",
+			Url:  "https://secure.grafana.com",
+			TLS: &plugins.AppPluginRouteTLS{
+				ServerName: "{{.JsonData.serverName}}",
+				CACert:     "{{.SecureJsonData.caCert}}",
+				ClientCert: "{{.SecureJsonData.clientCert}}",
+				ClientKey:  "{{.SecureJsonData.clientKey}}",
+			},
+		}
+
+		ds := &m.DataSource{
+			Id:       1,
+			Updated:  time.Now(),
+			JsonData: simplejson.NewFromAny(map[string]interface{}{"serverName": "secure.grafana.com"}),
+			SecureJsonData: securejsondata.GetEncryptedJsonData(map[string]string{
+				"caCert":     cert,
+				"clientCert": cert,
+				"clientKey":  key,
+			}),
+		}
+
+		Reset(func() {
+			routeTransportCache.cache = map[string]*cachedRouteTransport{}
+		})
+
+		Convey("Should use the certificates of the route", func() {
+			transport, err := getDataSourceRouteTransport(ds, route)
+			So(err, ShouldBeNil)
+			So(transport.TLSClientConfig.ServerName, ShouldEqual, "secure.grafana.com")
+			So(transport.TLSClientConfig.RootCAs, ShouldNotBeNil)
+			So(len(transport.TLSClientConfig.Certificates), ShouldEqual, 1)
+
+			Convey("Should cache the transport until the data source is updated", func() {
+				cached, err := getDataSourceRouteTransport(ds, route)
+				So(err, ShouldBeNil)
+				So(cached, ShouldEqual, transport)
+
+				ds.Updated = ds.Updated.Add(time.Second)
+				updated, err := getDataSourceRouteTransport(ds, route)
+				So(err, ShouldBeNil)
+				So(updated, ShouldNotEqual, transport)
+			})
+		})
+
+		Convey("Should use the data source transport for routes without TLS", func() {
+			transport, err := getDataSourceRouteTransport(ds, &plugins.AppPluginRoute{Path: "api/public"})
+			So(err, ShouldBeNil)
+			So(len(transport.TLSClientConfig.Certificates), ShouldEqual, 0)
+		})
+
+		Convey("Should fail for invalid certificates", func() {
+			ds.SecureJsonData = securejsondata.GetEncryptedJsonData(map[string]string{
+				"caCert":     cert,
+				"clientCert": cert,
+				"clientKey":  "invalid",
+			})
+
+			_, err := getDataSourceRouteTransport(ds, route)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func newTestCertificate() (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	So(err, ShouldBeNil)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "secure.grafana.com"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	So(err, ShouldBeNil)
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return string(cert), string(keyPem)
+}
@@ -52,6 +52,7 @@ func (hs *HTTPServer) GetPluginList(c *m.ReqContext) Response {
 			HasUpdate:     pluginDef.GrafanaNetHasUpdate,
 			DefaultNavUrl: pluginDef.DefaultNavUrl,
 			State:         pluginDef.State,
+			Signature:     pluginDef.Signature,
 		}
 
 		if pluginSetting, exists := pluginSettingsMap[pluginDef.Id]; exists {
@@ -103,6 +104,7 @@ func GetPluginSettingByID(c *m.ReqContext) Response {
 		LatestVersion: def.GrafanaNetVersion,
 		HasUpdate:     def.GrafanaNetHasUpdate,
 		State:         def.State,
+		Signature:     def.Signature,
 	}
 
 	query := m.GetPluginSettingByIdQuery{PluginId: pluginID, OrgId: c.OrgId}
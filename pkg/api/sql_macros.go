@@ -0,0 +1,77 @@
+package api
+
+import (
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func GetSqlMacros(c *m.ReqContext) Response {
+	query := m.GetSqlMacrosQuery{OrgId: c.OrgId}
+	if err := bus.Dispatch(&query); err != nil {
+		return Error(500, "无法查询SQL宏", err)
+	}
+
+	return JSON(200, query.Result)
+}
+
+func GetSqlMacroById(c *m.ReqContext) Response {
+	query := m.GetSqlMacroByIdQuery{Id: c.ParamsInt64(":id"), OrgId: c.OrgId}
+	if err := bus.Dispatch(&query); err != nil {
+		if err == m.ErrSqlMacroNotFound {
+			return Error(404, "找不到SQL宏", nil)
+		}
+		return Error(500, "无法查询SQL宏", err)
+	}
+
+	return JSON(200, query.Result)
+}
+
+func AddSqlMacro(c *m.ReqContext, cmd m.AddSqlMacroCommand) Response {
+	cmd.OrgId = c.OrgId
+
+	if err := m.ValidateSqlMacro(cmd.Name, cmd.Params, cmd.Templates); err != nil {
+		return Error(400, err.Error(), err)
+	}
+
+	if err := bus.Dispatch(&cmd); err != nil {
+		if err == m.ErrSqlMacroWithSameName {
+			return Error(409, err.Error(), err)
+		}
+		return Error(500, "无法添加SQL宏", err)
+	}
+
+	return JSON(200, cmd.Result)
+}
+
+func UpdateSqlMacro(c *m.ReqContext, cmd m.UpdateSqlMacroCommand) Response {
+	cmd.OrgId = c.OrgId
+	cmd.Id = c.ParamsInt64(":id")
+
+	if err := m.ValidateSqlMacro(cmd.Name, cmd.Params, cmd.Templates); err != nil {
+		return Error(400, err.Error(), err)
+	}
+
+	if err := bus.Dispatch(&cmd); err != nil {
+		switch err {
+		case m.ErrSqlMacroNotFound:
+			return Error(404, "找不到SQL宏", nil)
+		case m.ErrSqlMacroWithSameName:
+			return Error(409, err.Error(), err)
+		}
+		return Error(500, "无法更新SQL宏", err)
+	}
+
+	return JSON(200, cmd.Result)
+}
+
+func DeleteSqlMacro(c *m.ReqContext) Response {
+	cmd := m.DeleteSqlMacroCommand{Id: c.ParamsInt64(":id"), OrgId: c.OrgId}
+	if err := bus.Dispatch(&cmd); err != nil {
+		if err == m.ErrSqlMacroNotFound {
+			return Error(404, "找不到SQL宏", nil)
+		}
+		return Error(500, "无法删除SQL宏", err)
+	}
+
+	return Success("SQL宏删除成功")
+}
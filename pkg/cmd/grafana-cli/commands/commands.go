@@ -8,6 +8,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
 )
@@ -28,6 +29,13 @@ func runDbCommand(command func(commandLine CommandLine) error) func(context *cli
 		engine.Bus = bus.GetBus()
 		engine.Init()
 
+		secretsService := &secrets.SecretsService{Cfg: cfg}
+		if err := secretsService.Init(); err != nil {
+			logger.Errorf("\n%s: ", color.RedString("Error"))
+			logger.Errorf("Failed to initialize secrets: %s\n\n", err)
+			os.Exit(1)
+		}
+
 		if err := command(cmd); err != nil {
 			logger.Errorf("\n%s: ", color.RedString("Error"))
 			logger.Errorf("%s\n\n", err)
@@ -106,6 +114,24 @@ var adminCommands = []cli.Command{
 				Usage: "path to config file",
 			},
 		},
+	}, {
+		Name:   "rotate-secret-key",
+		Usage:  "rotate-secret-key <new secret key (optional)>",
+		Action: runDbCommand(rotateSecretKeyCommand),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "homepath",
+				Usage: "path to grafana install/home path, defaults to working directory",
+			},
+			cli.StringFlag{
+				Name:  "config",
+				Usage: "path to config file",
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "rotate the key even if the address of the grafana server is in use",
+			},
+		},
 	},
 }
 
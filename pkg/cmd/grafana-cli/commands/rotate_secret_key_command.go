@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/services/secrets"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func rotateSecretKeyCommand(c CommandLine) error {
+	newSecretKey := c.Args().First()
+
+	// a running server keeps using the data keys it read, the secrets it
+	// saves during the rotation could not be decrypted afterwards
+	if addr, running := serverRunning(); running && !c.Bool("force") {
+		return fmt.Errorf("Grafana is running on %s, stop it before rotating the key or pass --force if that is another service", addr)
+	}
+
+	count, err := secrets.RotateDataKey(newSecretKey)
+	if err != nil {
+		return fmt.Errorf("Failed to rotate the data key. Error: %v", err)
+	}
+
+	logger.Infof("\n")
+	logger.Infof("Re-encrypted %d secrets with a new data key %s", count, color.GreenString("✔"))
+	if newSecretKey != "" {
+		logger.Infof("\nSet secret_key in the security section of the config to the new secret key before starting grafana\n")
+	}
+
+	return nil
+}
+
+// serverRunning returns true if something listens on the address of the
+// grafana server of the config.
+func serverRunning() (string, bool) {
+	network, addr := "tcp", net.JoinHostPort(setting.HttpAddr, setting.HttpPort)
+	if setting.Protocol == setting.SOCKET {
+		network, addr = "unix", setting.SocketPath
+	} else if ip := net.ParseIP(setting.HttpAddr); setting.HttpAddr == "" || (ip != nil && ip.IsUnspecified()) {
+		addr = net.JoinHostPort("localhost", setting.HttpPort)
+	}
+
+	conn, err := net.DialTimeout(network, addr, time.Second)
+	if err != nil {
+		return addr, false
+	}
+	conn.Close()
+	return addr, true
+}
@@ -2,8 +2,7 @@ package securejsondata
 
 import (
 	"github.com/grafana/grafana/pkg/log"
-	"github.com/grafana/grafana/pkg/setting"
-	"github.com/grafana/grafana/pkg/util"
+	"github.com/grafana/grafana/pkg/services/secrets"
 )
 
 type SecureJsonData map[string][]byte
@@ -11,7 +10,7 @@ type SecureJsonData map[string][]byte
 func (s SecureJsonData) Decrypt() map[string]string {
 	decrypted := make(map[string]string)
 	for key, data := range s {
-		decryptedData, err := util.Decrypt(data, setting.SecretKey)
+		decryptedData, err := secrets.Decrypt(data)
 		if err != nil {
 			log.Fatal(4, err.Error())
 		}
@@ -21,10 +20,25 @@ func (s SecureJsonData) Decrypt() map[string]string {
 	return decrypted
 }
 
+// DecryptedValue returns the decrypted value of a key and true if it is set.
+func (s SecureJsonData) DecryptedValue(key string) (string, bool) {
+	data, exists := s[key]
+	if !exists {
+		return "", false
+	}
+
+	decryptedData, err := secrets.Decrypt(data)
+	if err != nil {
+		log.Fatal(4, "Failed to decrypt secure json data %s: %v", key, err)
+	}
+
+	return string(decryptedData), true
+}
+
 func GetEncryptedJsonData(sjd map[string]string) SecureJsonData {
 	encrypted := make(SecureJsonData)
 	for key, data := range sjd {
-		encryptedData, err := util.Encrypt([]byte(data), setting.SecretKey)
+		encryptedData, err := secrets.Encrypt([]byte(data))
 		if err != nil {
 			log.Fatal(4, err.Error())
 		}
@@ -89,3 +89,8 @@ type UserUpdated struct {
 	Login     string    `json:"login"`
 	Email     string    `json:"email"`
 }
+
+type SqlMacrosChanged struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgId     int64     `json:"org_id"`
+}
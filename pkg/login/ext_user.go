@@ -52,6 +52,7 @@ func UpsertUser(cmd *m.UpsertUserCommand) error {
 				UserId:     cmd.Result.Id,
 				AuthModule: extUser.AuthModule,
 				AuthId:     extUser.AuthId,
+				OAuthToken: extUser.OAuthToken,
 			}
 			if err := bus.Dispatch(cmd2); err != nil {
 				return err
@@ -65,6 +66,19 @@ func UpsertUser(cmd *m.UpsertUserCommand) error {
 		if err != nil {
 			return err
 		}
+
+		// update the oauth token of the auth info linked by the query
+		if extUser.AuthModule != "" && extUser.OAuthToken != nil {
+			err = bus.Dispatch(&m.UpdateAuthInfoCommand{
+				UserId:     cmd.Result.Id,
+				AuthModule: extUser.AuthModule,
+				AuthId:     extUser.AuthId,
+				OAuthToken: extUser.OAuthToken,
+			})
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	err = syncOrgRoles(cmd.Result, extUser)
@@ -46,10 +46,14 @@ var (
 	M_Aws_CloudWatch_ListMetrics         prometheus.Counter
 	M_Aws_CloudWatch_GetMetricData       prometheus.Counter
 	M_DB_DataSource_QueryById            prometheus.Counter
+	M_DataSource_Up                      *prometheus.GaugeVec
+	M_DataSource_ProxyCache_Requests     *prometheus.CounterVec
+	M_DataSource_ProxyCache_Saved_Bytes  prometheus.Counter
 
 	// Timers
-	M_DataSource_ProxyReq_Timer prometheus.Summary
-	M_Alerting_Execution_Time   prometheus.Summary
+	M_DataSource_ProxyReq_Timer        prometheus.Summary
+	M_Alerting_Execution_Time          prometheus.Summary
+	M_DataSource_Health_Check_Duration prometheus.Summary
 
 	// StatTotals
 	M_Alerting_Active_Alerts prometheus.Gauge
@@ -250,6 +254,30 @@ func init() {
 		Namespace: exporterName,
 	})
 
+	M_DataSource_Up = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "datasource_up",
+		Help:      "数据源最近一次健康检查的结果，1表示正常，0表示异常",
+		Namespace: exporterName,
+	}, []string{"org_id", "datasource", "type"})
+
+	M_DataSource_ProxyCache_Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "api_dataproxy_cache_requests_total",
+		Help:      "dataproxy响应缓存的请求计数器，按命中(hit)、合并(coalesced)和未命中(miss)区分",
+		Namespace: exporterName,
+	}, []string{"result"})
+
+	M_DataSource_ProxyCache_Saved_Bytes = newCounterStartingAtZero(prometheus.CounterOpts{
+		Name:      "api_dataproxy_cache_saved_bytes_total",
+		Help:      "dataproxy通过缓存和合并请求节省的响应字节数",
+		Namespace: exporterName,
+	})
+
+	M_DataSource_Health_Check_Duration = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:      "datasource_health_check_duration_milliseconds",
+		Help:      "数据源健康检查持续时间的摘要",
+		Namespace: exporterName,
+	})
+
 	M_DataSource_ProxyReq_Timer = prometheus.NewSummary(prometheus.SummaryOpts{
 		Name:      "api_dataproxy_request_all_milliseconds",
 		Help:      "dataproxy请求持续时间的摘要",
@@ -351,6 +379,10 @@ func initMetricVars() {
 		M_Aws_CloudWatch_ListMetrics,
 		M_Aws_CloudWatch_GetMetricData,
 		M_DB_DataSource_QueryById,
+		M_DataSource_Up,
+		M_DataSource_ProxyCache_Requests,
+		M_DataSource_ProxyCache_Saved_Bytes,
+		M_DataSource_Health_Check_Duration,
 		M_Alerting_Active_Alerts,
 		M_StatTotal_Dashboards,
 		M_StatTotal_Users,
@@ -358,7 +390,9 @@ func initMetricVars() {
 		M_StatTotal_Orgs,
 		M_StatTotal_Playlists,
 		M_Grafana_Version,
-		grafanaBuildVersion)
+		grafanaBuildVersion,
+		newPluginProcessCollector(),
+		newQueryLimiterCollector())
 
 }
 
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pluginProcessCollector collects the state and resource usage of the
+// processes of backend plugins when scraped.
+type pluginProcessCollector struct {
+	up       *prometheus.Desc
+	restarts *prometheus.Desc
+	cpu      *prometheus.Desc
+	memory   *prometheus.Desc
+}
+
+func newPluginProcessCollector() *pluginProcessCollector {
+	labels := []string{"plugin_id"}
+	return &pluginProcessCollector{
+		up: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "plugin_process_up"),
+			"后端插件进程是否在运行，1表示运行，0表示未运行", labels, nil),
+		restarts: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "plugin_process_restarts_total"),
+			"后端插件进程重启的次数", labels, nil),
+		cpu: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "plugin_process_cpu_seconds_total"),
+			"后端插件进程使用的CPU时间(秒)", labels, nil),
+		memory: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "plugin_process_resident_memory_bytes"),
+			"后端插件进程的常驻内存(字节)", labels, nil),
+	}
+}
+
+func (c *pluginProcessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.restarts
+	ch <- c.cpu
+	ch <- c.memory
+}
+
+func (c *pluginProcessCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range plugins.GetProcessStatuses() {
+		up := 0.0
+		if status.State == plugins.ProcessRunning {
+			up = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up, status.PluginId)
+		ch <- prometheus.MustNewConstMetric(c.restarts, prometheus.CounterValue, float64(status.Restarts), status.PluginId)
+
+		if status.State == plugins.ProcessRunning {
+			ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, status.CpuSeconds, status.PluginId)
+			ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(status.ResidentMemoryBytes), status.PluginId)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queryLimiterCollector collects the state of the query limiters of the data
+// sources when scraped.
+type queryLimiterCollector struct {
+	queueDepth *prometheus.Desc
+	inFlight   *prometheus.Desc
+	rejected   *prometheus.Desc
+}
+
+func newQueryLimiterCollector() *queryLimiterCollector {
+	labels := []string{"org_id", "datasource_id"}
+	return &queryLimiterCollector{
+		queueDepth: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "datasource_query_queue_depth"),
+			"每个数据源等待执行的查询数", labels, nil),
+		inFlight: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "datasource_query_in_flight"),
+			"每个数据源正在执行的查询数", labels, nil),
+		rejected: prometheus.NewDesc(prometheus.BuildFQName(exporterName, "", "datasource_query_rejected_total"),
+			"被数据源查询限制拒绝的查询数，按超时(timeout)和取消(canceled)区分", append(labels, "reason"), nil),
+	}
+}
+
+func (c *queryLimiterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queueDepth
+	ch <- c.inFlight
+	ch <- c.rejected
+}
+
+func (c *queryLimiterCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range tsdb.GetQueryLimiterStats() {
+		orgId := strconv.FormatInt(stats.OrgId, 10)
+		dsId := strconv.FormatInt(stats.DataSourceId, 10)
+
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(stats.Queued), orgId, dsId)
+		ch <- prometheus.MustNewConstMetric(c.inFlight, prometheus.GaugeValue, float64(stats.InFlight), orgId, dsId)
+
+		for _, reason := range []string{tsdb.QueryRejectedTimeout, tsdb.QueryRejectedCanceled} {
+			ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(stats.Rejected[reason]), orgId, dsId, reason)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// AuditEvent is a request of a user to a data source, through the data
+// source proxy or the query api.
+type AuditEvent struct {
+	Id             int64     `json:"-"`
+	Created        time.Time `json:"created"`
+	Source         string    `json:"source"`
+	OrgId          int64     `json:"orgId"`
+	UserId         int64     `json:"userId"`
+	UserLogin      string    `json:"userLogin"`
+	RemoteAddr     string    `json:"remoteAddr"`
+	DataSourceId   int64     `json:"dataSourceId"`
+	DataSourceName string    `json:"dataSourceName"`
+	DataSourceType string    `json:"dataSourceType"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	Query          string    `json:"query"`
+	Body           string    `json:"body,omitempty"`
+	BodyHash       string    `json:"bodyHash,omitempty"`
+	Status         int       `json:"status"`
+	DurationMs     int64     `json:"durationMs"`
+}
+
+// ----------------------
+// COMMANDS
+
+type SaveAuditEventsCommand struct {
+	Events []*AuditEvent
+}
+
+type DeleteExpiredAuditEventsCommand struct {
+	OlderThan   time.Time
+	DeletedRows int64
+}
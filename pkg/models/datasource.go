@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/grafana/grafana/pkg/components/securejsondata"
@@ -44,12 +45,12 @@ type DataSource struct {
 	Type              string
 	Access            DsAccess
 	Url               string
-	Password          string
+	Password          string // Deprecated: stored in secure json data, use DecryptedPassword
 	User              string
 	Database          string
 	BasicAuth         bool
 	BasicAuthUser     string
-	BasicAuthPassword string
+	BasicAuthPassword string // Deprecated: stored in secure json data, use DecryptedBasicAuthPassword
 	WithCredentials   bool
 	IsDefault         bool
 	JsonData          *simplejson.Json
@@ -98,6 +99,63 @@ func IsKnownDataSourcePlugin(dsType string) bool {
 	return exists
 }
 
+// CustomHeaders returns the custom http headers configured as
+// httpHeaderName1..n in json data with the values from secure json data.
+func (ds *DataSource) CustomHeaders() map[string]string {
+	headers := make(map[string]string)
+	if ds.JsonData == nil || ds.SecureJsonData == nil {
+		return headers
+	}
+
+	decrypted := ds.SecureJsonData.Decrypt()
+	for index := 1; ; index++ {
+		key := ds.JsonData.Get(fmt.Sprintf("httpHeaderName%d", index)).MustString()
+		if key == "" {
+			break
+		}
+
+		if val, ok := decrypted[fmt.Sprintf("httpHeaderValue%d", index)]; ok {
+			headers[key] = val
+		}
+	}
+
+	return headers
+}
+
+// DecryptedPassword returns the password stored in secure json data.
+func (ds *DataSource) DecryptedPassword() string {
+	password, _ := ds.SecureJsonData.DecryptedValue("password")
+	return password
+}
+
+// DecryptedBasicAuthPassword returns the basic auth password stored in secure
+// json data.
+func (ds *DataSource) DecryptedBasicAuthPassword() string {
+	password, _ := ds.SecureJsonData.DecryptedValue("basicAuthPassword")
+	return password
+}
+
+// SecureJsonDataWithPasswords adds the passwords of the deprecated password
+// and basicAuthPassword fields of the api and provisioning to the secure json
+// data. Empty passwords keep the stored values.
+func SecureJsonDataWithPasswords(secureJsonData map[string]string, password string, basicAuthPassword string) map[string]string {
+	if password == "" && basicAuthPassword == "" {
+		return secureJsonData
+	}
+
+	if secureJsonData == nil {
+		secureJsonData = make(map[string]string)
+	}
+	if password != "" {
+		secureJsonData["password"] = password
+	}
+	if basicAuthPassword != "" {
+		secureJsonData["basicAuthPassword"] = basicAuthPassword
+	}
+
+	return secureJsonData
+}
+
 // ----------------------
 // COMMANDS
 
@@ -197,18 +255,23 @@ type DsPermissionType int
 const (
 	DsPermissionNoAccess DsPermissionType = iota
 	DsPermissionQuery
+	DsPermissionAdmin
 )
 
 func (p DsPermissionType) String() string {
 	names := map[int]string{
+		int(DsPermissionAdmin):    "Admin",
 		int(DsPermissionQuery):    "Query",
 		int(DsPermissionNoAccess): "No Access",
 	}
 	return names[int(p)]
 }
 
+// DatasourcesPermissionFilterQuery returns the data sources the user has the
+// permission for, DsPermissionQuery if Permission is not set.
 type DatasourcesPermissionFilterQuery struct {
 	User        *SignedInUser
 	Datasources []*DataSource
+	Permission  DsPermissionType
 	Result      []*DataSource
 }
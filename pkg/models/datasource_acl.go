@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Typed errors
+var (
+	ErrDataSourceAclInfoMissing       = errors.New("User id and team id cannot both be empty for a data source permission.")
+	ErrDataSourceAclPermissionInvalid = errors.New("Data source permission must be Query or Admin.")
+	ErrDataSourceAclDataSourceEmpty   = errors.New("Data source Id must be greater than zero for a data source permission.")
+	ErrDataSourceAclUserNotInOrg      = errors.New("User of a data source permission is not a member of the organization.")
+	ErrDataSourceAclTeamNotInOrg      = errors.New("Team of a data source permission does not belong to the organization.")
+)
+
+// DataSourceAcl grants a user or a team the permission to query or to
+// administrate a data source. Data sources without acl can be queried by
+// every member of the org.
+type DataSourceAcl struct {
+	Id           int64
+	OrgId        int64
+	DataSourceId int64
+
+	UserId     int64
+	TeamId     int64
+	Permission DsPermissionType
+
+	Created time.Time
+	Updated time.Time
+}
+
+type DataSourceAclInfoDTO struct {
+	OrgId        int64 `json:"-"`
+	DataSourceId int64 `json:"datasourceId"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	UserId         int64            `json:"userId"`
+	UserLogin      string           `json:"userLogin"`
+	UserEmail      string           `json:"userEmail"`
+	UserAvatarUrl  string           `json:"userAvatarUrl"`
+	TeamId         int64            `json:"teamId"`
+	TeamEmail      string           `json:"teamEmail"`
+	TeamAvatarUrl  string           `json:"teamAvatarUrl"`
+	Team           string           `json:"team"`
+	Permission     DsPermissionType `json:"permission"`
+	PermissionName string           `json:"permissionName"`
+}
+
+//
+// COMMANDS
+//
+
+type UpdateDataSourceAclCommand struct {
+	OrgId        int64
+	DataSourceId int64
+	Items        []*DataSourceAcl
+}
+
+//
+// QUERIES
+//
+
+type GetDataSourceAclInfoListQuery struct {
+	OrgId        int64
+	DataSourceId int64
+	Result       []*DataSourceAclInfoDTO
+}
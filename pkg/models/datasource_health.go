@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// DataSourceHealthCheck is the result of a health check of a data source.
+type DataSourceHealthCheck struct {
+	Id           int64     `json:"id"`
+	OrgId        int64     `json:"orgId"`
+	DataSourceId int64     `json:"dataSourceId"`
+	Status       string    `json:"status"`
+	Message      string    `json:"message"`
+	DurationMs   int64     `json:"durationMs"`
+	Created      time.Time `json:"created"`
+}
+
+// ----------------------
+// COMMANDS
+
+type SaveDataSourceHealthCheckCommand struct {
+	OrgId        int64
+	DataSourceId int64
+	Status       string
+	Message      string
+	DurationMs   int64
+
+	Result *DataSourceHealthCheck
+}
+
+type DeleteExpiredDataSourceHealthChecksCommand struct {
+	OlderThan   time.Time
+	DeletedRows int64
+}
+
+// ---------------------
+// QUERIES
+
+// GetDataSourceHealthChecksQuery returns the latest health checks of a data
+// source, newest first.
+type GetDataSourceHealthChecksQuery struct {
+	OrgId        int64
+	DataSourceId int64
+	Limit        int
+
+	Result []*DataSourceHealthCheck
+}
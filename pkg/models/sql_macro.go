@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Typed errors
+var (
+	ErrSqlMacroNotFound       = errors.New("Sql macro not found")
+	ErrSqlMacroWithSameName   = errors.New("A sql macro with the same name already exists")
+	ErrSqlMacroInvalidName    = errors.New("Sql macro name may only contain letters, numbers and underscores")
+	ErrSqlMacroReservedName   = errors.New("Sql macro name is used by a built-in macro")
+	ErrSqlMacroInvalidParam   = errors.New("Sql macro parameter names may only contain letters, numbers and underscores")
+	ErrSqlMacroMissingDialect = errors.New("Sql macro needs a template for at least one data source type")
+)
+
+var sqlMacroIdentifier = regexp.MustCompile(`^[a-zA-Z][_a-zA-Z0-9]*$`)
+
+// builtinSqlMacros are the macros expanded by the sql data sources themselves,
+// referenced as $__name.
+var builtinSqlMacros = map[string]bool{
+	"time":                true,
+	"timeEpoch":           true,
+	"timeFilter":          true,
+	"timeFrom":            true,
+	"timeTo":              true,
+	"timeGroup":           true,
+	"timeGroupAlias":      true,
+	"unixEpochFilter":     true,
+	"unixEpochFrom":       true,
+	"unixEpochTo":         true,
+	"unixEpochGroup":      true,
+	"unixEpochGroupAlias": true,
+	"interval":            true,
+	"interval_ms":         true,
+}
+
+// SqlMacro is an org level macro used in sql queries as $__name(arg1, arg2).
+// Templates holds the sql per data source type, with the parameters
+// referenced as ${param} (a string literal) or ${param:raw}.
+type SqlMacro struct {
+	Id          int64             `json:"id"`
+	OrgId       int64             `json:"-"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Params      []string          `json:"params"`
+	Templates   map[string]string `json:"templates"`
+	Created     time.Time         `json:"created"`
+	Updated     time.Time         `json:"updated"`
+}
+
+// ValidateSqlMacro checks the name, parameters and templates of a macro.
+func ValidateSqlMacro(name string, params []string, templates map[string]string) error {
+	if !sqlMacroIdentifier.MatchString(name) {
+		return ErrSqlMacroInvalidName
+	}
+
+	if builtinSqlMacros[name] {
+		return ErrSqlMacroReservedName
+	}
+
+	for _, param := range params {
+		if !sqlMacroIdentifier.MatchString(param) {
+			return ErrSqlMacroInvalidParam
+		}
+	}
+
+	if len(templates) == 0 {
+		return ErrSqlMacroMissingDialect
+	}
+
+	return nil
+}
+
+//
+// COMMANDS
+//
+
+type AddSqlMacroCommand struct {
+	Name        string            `json:"name" binding:"Required"`
+	Description string            `json:"description"`
+	Params      []string          `json:"params"`
+	Templates   map[string]string `json:"templates" binding:"Required"`
+
+	OrgId  int64 `json:"-"`
+	Result *SqlMacro
+}
+
+type UpdateSqlMacroCommand struct {
+	Id          int64             `json:"-"`
+	Name        string            `json:"name" binding:"Required"`
+	Description string            `json:"description"`
+	Params      []string          `json:"params"`
+	Templates   map[string]string `json:"templates" binding:"Required"`
+
+	OrgId  int64 `json:"-"`
+	Result *SqlMacro
+}
+
+type DeleteSqlMacroCommand struct {
+	Id    int64
+	OrgId int64
+}
+
+//
+// QUERIES
+//
+
+type GetSqlMacrosQuery struct {
+	OrgId  int64
+	Result []*SqlMacro
+}
+
+type GetSqlMacroByIdQuery struct {
+	Id     int64
+	OrgId  int64
+	Result *SqlMacro
+}
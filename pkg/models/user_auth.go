@@ -2,14 +2,22 @@ package models
 
 import (
 	"time"
+
+	"golang.org/x/oauth2"
 )
 
+// UserAuth links a user to an external auth module. The OAuth fields hold
+// the last token of OAuth logins, encrypted in the database.
 type UserAuth struct {
-	Id         int64
-	UserId     int64
-	AuthModule string
-	AuthId     string
-	Created    time.Time
+	Id                int64
+	UserId            int64
+	AuthModule        string
+	AuthId            string
+	Created           time.Time
+	OAuthAccessToken  string
+	OAuthRefreshToken string
+	OAuthTokenType    string
+	OAuthExpiry       time.Time
 }
 
 type ExternalUserInfo struct {
@@ -21,6 +29,7 @@ type ExternalUserInfo struct {
 	Name           string
 	Groups         []string
 	OrgRoles       map[int64]RoleType
+	OAuthToken     *oauth2.Token
 	IsGrafanaAdmin *bool // This is a pointer to know if we should sync this or not (nil = ignore sync)
 }
 
@@ -39,6 +48,14 @@ type SetAuthInfoCommand struct {
 	AuthModule string
 	AuthId     string
 	UserId     int64
+	OAuthToken *oauth2.Token
+}
+
+type UpdateAuthInfoCommand struct {
+	AuthModule string
+	AuthId     string
+	UserId     int64
+	OAuthToken *oauth2.Token
 }
 
 type DeleteAuthInfoCommand struct {
@@ -66,9 +83,12 @@ type GetUserByAuthInfoQuery struct {
 	Result *User
 }
 
+// GetAuthInfoQuery returns the auth info of AuthModule and AuthId, or the
+// latest auth info of UserId.
 type GetAuthInfoQuery struct {
 	AuthModule string
 	AuthId     string
+	UserId     int64
 
 	Result *UserAuth
 }
@@ -30,6 +30,18 @@ type AppPluginRoute struct {
 	Headers      []AppPluginRouteHeader `json:"headers"`
 	TokenAuth    *JwtTokenAuth          `json:"tokenAuth"`
 	JwtTokenAuth *JwtTokenAuth          `json:"jwtTokenAuth"`
+	TLS          *AppPluginRouteTLS     `json:"tls"`
+}
+
+// AppPluginRouteTLS is the client TLS configuration of a route. The
+// certificates and the server name are templates like the route headers,
+// e.g. {{.SecureJsonData.tlsClientCert}}.
+type AppPluginRouteTLS struct {
+	SkipVerify bool   `json:"skipVerify"`
+	ServerName string `json:"serverName"`
+	CACert     string `json:"caCert"`
+	ClientCert string `json:"clientCert"`
+	ClientKey  string `json:"clientKey"`
 }
 
 type AppPluginRouteHeader struct {
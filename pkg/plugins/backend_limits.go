@@ -0,0 +1,22 @@
+// +build !linux
+
+package plugins
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errLimitsNotSupported = errors.New("Process limits of backend plugins are only supported on Linux")
+
+func limitedCommand(pluginId string, executable string, s processSettings) (*exec.Cmd, string, error) {
+	if s.memoryLimit <= 0 && s.cpuLimitPercent <= 0 {
+		return exec.Command(executable), IsolationNone, nil
+	}
+
+	return exec.Command(executable), IsolationNone, errLimitsNotSupported
+}
+
+func processUsage(pid int) (float64, int64, error) {
+	return 0, 0, errLimitsNotSupported
+}
@@ -0,0 +1,148 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/prometheus/procfs"
+)
+
+const (
+	cgroupRoot      = "/sys/fs/cgroup"
+	cgroupCpuPeriod = 100000
+)
+
+// Scripts starting a plugin executable within its limits. The shell applies
+// the limits to itself and then executes the plugin, so the plugin runs
+// limited from its start on. The arguments are passed as positional
+// parameters, they are never part of the script.
+const (
+	cgroupStartScript = `exe="$1"; shift; for procs in "$@"; do echo $$ > "$procs" || exit 1; done; exec "$exe"`
+	rlimitStartScript = `ulimit -v "$2" && exec "$1"`
+)
+
+// limitedCommand returns the command starting the executable of a plugin in
+// a cgroup limiting its memory and cpu. Without access to cgroups the memory
+// is limited with rlimits, the cpu can not be limited then.
+func limitedCommand(pluginId string, executable string, s processSettings) (*exec.Cmd, string, error) {
+	if s.memoryLimit <= 0 && s.cpuLimitPercent <= 0 {
+		return exec.Command(executable), IsolationNone, nil
+	}
+
+	procs, cgroupErr := prepareCgroup(pluginId, s)
+	if cgroupErr == nil {
+		args := append([]string{"-c", cgroupStartScript, "sh", executable}, procs...)
+		return exec.Command("/bin/sh", args...), IsolationCgroup, nil
+	}
+
+	if s.memoryLimit <= 0 {
+		return exec.Command(executable), IsolationNone, fmt.Errorf("Failed to limit cpu with cgroups: %v", cgroupErr)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", rlimitStartScript, "sh", executable, strconv.FormatInt(s.memoryLimit/1024, 10))
+	if s.cpuLimitPercent > 0 {
+		return cmd, IsolationRlimit, fmt.Errorf("Failed to limit cpu with cgroups: %v", cgroupErr)
+	}
+
+	return cmd, IsolationRlimit, nil
+}
+
+// prepareCgroup creates the cgroup of a plugin with its limits and returns
+// the cgroup.procs files the process must be added to.
+func prepareCgroup(pluginId string, s processSettings) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		return prepareCgroupV2(pluginId, s)
+	}
+	return prepareCgroupV1(pluginId, s)
+}
+
+func prepareCgroupV2(pluginId string, s processSettings) ([]string, error) {
+	parent := filepath.Join(cgroupRoot, s.cgroupPath)
+	dir := filepath.Join(parent, pluginId)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := writeCgroupFile(parent, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+		return nil, err
+	}
+
+	memoryMax := "max"
+	if s.memoryLimit > 0 {
+		memoryMax = strconv.FormatInt(s.memoryLimit, 10)
+	}
+	if err := writeCgroupFile(dir, "memory.max", memoryMax); err != nil {
+		return nil, err
+	}
+
+	cpuMax := "max"
+	if s.cpuLimitPercent > 0 {
+		cpuMax = fmt.Sprintf("%d %d", s.cpuLimitPercent*cgroupCpuPeriod/100, cgroupCpuPeriod)
+	}
+	if err := writeCgroupFile(dir, "cpu.max", cpuMax); err != nil {
+		return nil, err
+	}
+
+	return []string{filepath.Join(dir, "cgroup.procs")}, nil
+}
+
+func prepareCgroupV1(pluginId string, s processSettings) ([]string, error) {
+	memoryDir := filepath.Join(cgroupRoot, "memory", s.cgroupPath, pluginId)
+	cpuDir := filepath.Join(cgroupRoot, "cpu", s.cgroupPath, pluginId)
+
+	for _, dir := range []string{memoryDir, cpuDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	memoryLimit := "-1"
+	if s.memoryLimit > 0 {
+		memoryLimit = strconv.FormatInt(s.memoryLimit, 10)
+	}
+	if err := writeCgroupFile(memoryDir, "memory.limit_in_bytes", memoryLimit); err != nil {
+		return nil, err
+	}
+
+	cpuQuota := "-1"
+	if s.cpuLimitPercent > 0 {
+		cpuQuota = strconv.Itoa(s.cpuLimitPercent * cgroupCpuPeriod / 100)
+	}
+	if err := writeCgroupFile(cpuDir, "cpu.cfs_period_us", strconv.Itoa(cgroupCpuPeriod)); err != nil {
+		return nil, err
+	}
+	if err := writeCgroupFile(cpuDir, "cpu.cfs_quota_us", cpuQuota); err != nil {
+		return nil, err
+	}
+
+	return []string{filepath.Join(memoryDir, "cgroup.procs"), filepath.Join(cpuDir, "cgroup.procs")}, nil
+}
+
+func writeCgroupFile(dir string, name string, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// processUsage returns the cpu time in seconds and the resident memory in
+// bytes of a process.
+func processUsage(pid int) (float64, int64, error) {
+	if pid <= 0 {
+		return 0, 0, errors.New("Process is not running")
+	}
+
+	proc, err := procfs.NewProc(pid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	stat, err := proc.NewStat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return stat.CPUTime(), int64(stat.ResidentMemory()), nil
+}
@@ -0,0 +1,54 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackendPluginLimits(t *testing.T) {
+	Convey("When starting a plugin within its limits", t, func() {
+		dir, err := ioutil.TempDir("", "plugin-limits")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		executable := filepath.Join(dir, "plugin with spaces")
+		So(ioutil.WriteFile(executable, []byte("#!/bin/sh\necho $$ $(ulimit -v)\n"), 0755), ShouldBeNil)
+
+		Convey("Should start the plugin unlimited without limits", func() {
+			cmd, isolation, err := limitedCommand("test-ds", executable, processSettings{})
+			So(err, ShouldBeNil)
+			So(isolation, ShouldEqual, IsolationNone)
+			So(cmd.Path, ShouldEqual, executable)
+		})
+
+		Convey("Should add the process to the cgroup before it starts", func() {
+			procs := []string{filepath.Join(dir, "memory.procs"), filepath.Join(dir, "cpu.procs")}
+			out, err := exec.Command("/bin/sh", append([]string{"-c", cgroupStartScript, "sh", executable}, procs...)...).Output()
+			So(err, ShouldBeNil)
+
+			pid := strings.Fields(string(out))[0]
+			for _, path := range procs {
+				content, err := ioutil.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(strings.TrimSpace(string(content)), ShouldEqual, pid)
+			}
+		})
+
+		Convey("Should not start the plugin if it can not be added to the cgroup", func() {
+			err := exec.Command("/bin/sh", "-c", cgroupStartScript, "sh", executable, filepath.Join(dir, "missing", "cgroup.procs")).Run()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Should limit the memory with rlimits before it starts", func() {
+			out, err := exec.Command("/bin/sh", "-c", rlimitStartScript, "sh", executable, "1048576").Output()
+			So(err, ShouldBeNil)
+			So(strings.Fields(string(out))[1], ShouldEqual, "1048576")
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package plugins
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+type PluginProcessState string
+
+const (
+	ProcessStarting   PluginProcessState = "starting"
+	ProcessRunning    PluginProcessState = "running"
+	ProcessRestarting PluginProcessState = "restarting"
+	ProcessFailed     PluginProcessState = "failed"
+	ProcessStopped    PluginProcessState = "stopped"
+
+	IsolationCgroup = "cgroup"
+	IsolationRlimit = "rlimit"
+	IsolationNone   = "none"
+
+	processCheckInterval = time.Second
+)
+
+// PluginProcessStatus is the state of the process of a backend plugin.
+type PluginProcessStatus struct {
+	PluginId            string             `json:"pluginId"`
+	Name                string             `json:"name"`
+	State               PluginProcessState `json:"state"`
+	Pid                 int                `json:"pid"`
+	StartedAt           time.Time          `json:"startedAt"`
+	Restarts            int                `json:"restarts"`
+	NextRestartAt       time.Time          `json:"nextRestartAt"`
+	LastError           string             `json:"lastError"`
+	LastErrorAt         time.Time          `json:"lastErrorAt"`
+	Isolation           string             `json:"isolation"`
+	MemoryLimitBytes    int64              `json:"memoryLimitBytes"`
+	CpuLimitPercent     int                `json:"cpuLimitPercent"`
+	CpuSeconds          float64            `json:"cpuSeconds"`
+	ResidentMemoryBytes int64              `json:"residentMemoryBytes"`
+}
+
+// processSettings configures the supervision and the limits of the process
+// of a backend plugin. The defaults of the [plugins] section are overridden
+// per plugin in its [plugin.<id>] section.
+type processSettings struct {
+	backoffMin         time.Duration
+	backoffMax         time.Duration
+	backoffReset       time.Duration
+	restartMaxAttempts int
+	memoryLimit        int64
+	cpuLimitPercent    int
+	cgroupPath         string
+}
+
+func readProcessSettings(pluginId string) processSettings {
+	sec := setting.Raw.Section("plugins")
+	pluginSec := setting.Raw.Section("plugin." + pluginId)
+
+	s := processSettings{
+		backoffMin:         time.Duration(sec.Key("restart_backoff_min_seconds").MustInt(1)) * time.Second,
+		backoffMax:         time.Duration(sec.Key("restart_backoff_max_seconds").MustInt(300)) * time.Second,
+		backoffReset:       time.Duration(sec.Key("restart_backoff_reset_seconds").MustInt(600)) * time.Second,
+		restartMaxAttempts: sec.Key("restart_max_attempts").MustInt(0),
+		memoryLimit:        int64(sec.Key("backend_memory_limit_mb").MustInt(0)) * 1024 * 1024,
+		cpuLimitPercent:    sec.Key("backend_cpu_limit_percent").MustInt(0),
+		cgroupPath:         sec.Key("cgroup_path").MustString("grafana-plugins"),
+	}
+
+	if pluginSec.HasKey("memory_limit_mb") {
+		s.memoryLimit = int64(pluginSec.Key("memory_limit_mb").MustInt(0)) * 1024 * 1024
+	}
+	if pluginSec.HasKey("cpu_limit_percent") {
+		s.cpuLimitPercent = pluginSec.Key("cpu_limit_percent").MustInt(0)
+	}
+
+	if s.backoffMin <= 0 {
+		s.backoffMin = time.Second
+	}
+	if s.backoffMax < s.backoffMin {
+		s.backoffMax = s.backoffMin
+	}
+
+	return s
+}
+
+// restartBackoff doubles the delay of each restart of a plugin that keeps
+// crashing, up to max.
+type restartBackoff struct {
+	min      time.Duration
+	max      time.Duration
+	current  time.Duration
+	attempts int
+}
+
+func (b *restartBackoff) next() time.Duration {
+	b.attempts++
+
+	if b.current == 0 {
+		b.current = b.min
+	} else {
+		b.current *= 2
+	}
+	if b.current > b.max {
+		b.current = b.max
+	}
+
+	return b.current
+}
+
+func (b *restartBackoff) reset() {
+	b.current = 0
+	b.attempts = 0
+}
+
+// GetProcessStatuses returns the process status of all backend plugins,
+// sorted by plugin id.
+func GetProcessStatuses() []PluginProcessStatus {
+	result := make([]PluginProcessStatus, 0)
+	for _, ds := range DataSources {
+		if ds.Backend {
+			result = append(result, ds.ProcessStatus())
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PluginId < result[j].PluginId
+	})
+
+	return result
+}
@@ -0,0 +1,153 @@
+package plugins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/setting"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/ini.v1"
+)
+
+func TestBackendPluginProcess(t *testing.T) {
+	Convey("When reading process settings", t, func() {
+		setting.Raw = ini.Empty()
+		sec, _ := setting.Raw.NewSection("plugins")
+		sec.NewKey("restart_backoff_max_seconds", "60")
+		sec.NewKey("backend_memory_limit_mb", "256")
+		sec.NewKey("backend_cpu_limit_percent", "50")
+		pluginSec, _ := setting.Raw.NewSection("plugin.test-ds")
+		pluginSec.NewKey("memory_limit_mb", "0")
+
+		Convey("Should use the defaults of all plugins", func() {
+			s := readProcessSettings("other-ds")
+			So(s.backoffMin, ShouldEqual, time.Second)
+			So(s.backoffMax, ShouldEqual, time.Minute)
+			So(s.memoryLimit, ShouldEqual, 256*1024*1024)
+			So(s.cpuLimitPercent, ShouldEqual, 50)
+		})
+
+		Convey("Should override the defaults per plugin", func() {
+			s := readProcessSettings("test-ds")
+			So(s.memoryLimit, ShouldEqual, 0)
+			So(s.cpuLimitPercent, ShouldEqual, 50)
+		})
+	})
+
+	Convey("Restart backoff", t, func() {
+		backoff := &restartBackoff{min: time.Second, max: 5 * time.Second}
+
+		Convey("Should double the delay up to max", func() {
+			So(backoff.next(), ShouldEqual, time.Second)
+			So(backoff.next(), ShouldEqual, 2*time.Second)
+			So(backoff.next(), ShouldEqual, 4*time.Second)
+			So(backoff.next(), ShouldEqual, 5*time.Second)
+			So(backoff.attempts, ShouldEqual, 4)
+		})
+
+		Convey("Should start over when reset", func() {
+			backoff.next()
+			backoff.next()
+			backoff.reset()
+			So(backoff.next(), ShouldEqual, time.Second)
+			So(backoff.attempts, ShouldEqual, 1)
+		})
+	})
+
+	Convey("When the plugin fails to start", t, func() {
+		setting.Raw = ini.Empty()
+		p := &DataSourcePlugin{
+			FrontendPluginBase: FrontendPluginBase{PluginBase: PluginBase{Id: "test-ds", Name: "Test", PluginDir: "testdata"}},
+			Backend:            true,
+			Executable:         "missing-executable",
+		}
+		p.log = log.New("test")
+		p.settings = readProcessSettings(p.Id)
+
+		err := p.spawnSubProcess()
+		So(err, ShouldNotBeNil)
+
+		Convey("Should record the error", func() {
+			status := p.ProcessStatus()
+			So(status.PluginId, ShouldEqual, "test-ds")
+			So(status.LastError, ShouldEqual, err.Error())
+			So(status.Pid, ShouldEqual, 0)
+		})
+
+		Convey("Should not be restarted", func() {
+			So(p.startBackendPlugin(context.Background(), log.New("test")), ShouldNotBeNil)
+			So(p.ProcessStatus().State, ShouldEqual, ProcessFailed)
+
+			_, exited := p.checkExited()
+			So(exited, ShouldBeFalse)
+			So(p.CallResource(context.Background(), nil, nil), ShouldEqual, ErrPluginNotRunning)
+		})
+	})
+
+	Convey("When the plugin fails to restart", t, func() {
+		setting.Raw = ini.Empty()
+		p := &DataSourcePlugin{
+			FrontendPluginBase: FrontendPluginBase{PluginBase: PluginBase{Id: "test-ds", Name: "Test", PluginDir: "testdata"}},
+			Backend:            true,
+			Executable:         "missing-executable",
+		}
+		p.log = log.New("test")
+		p.settings = readProcessSettings(p.Id)
+		backoff := &restartBackoff{min: time.Millisecond, max: time.Millisecond}
+
+		Convey("Should retry until the max attempts", func() {
+			p.settings.restartMaxAttempts = 3
+
+			running, err := p.restartProcess(context.Background(), backoff)
+			So(err, ShouldBeNil)
+			So(running, ShouldBeFalse)
+			So(p.ProcessStatus().Restarts, ShouldEqual, 3)
+			So(p.ProcessStatus().State, ShouldEqual, ProcessFailed)
+		})
+
+		Convey("Should retry until the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			running, err := p.restartProcess(ctx, backoff)
+			So(err == context.DeadlineExceeded, ShouldBeTrue)
+			So(running, ShouldBeFalse)
+			So(p.ProcessStatus().Restarts, ShouldBeGreaterThan, 1)
+		})
+
+		Convey("Should give up right away if the executable is not signed", func() {
+			p.Signature = PluginSignatureValid
+
+			running, err := p.restartProcess(context.Background(), backoff)
+			So(err, ShouldBeNil)
+			So(running, ShouldBeFalse)
+			So(p.ProcessStatus().Restarts, ShouldEqual, 1)
+			So(p.ProcessStatus().State, ShouldEqual, ProcessFailed)
+		})
+	})
+
+	Convey("When the plugin is killed", t, func() {
+		p := &DataSourcePlugin{FrontendPluginBase: FrontendPluginBase{PluginBase: PluginBase{Id: "test-ds"}}}
+		p.log = log.New("test")
+		p.Kill()
+
+		Convey("Should not be restarted", func() {
+			_, exited := p.checkExited()
+			So(exited, ShouldBeFalse)
+			So(p.isStopped(), ShouldBeTrue)
+			So(p.ProcessStatus().State, ShouldEqual, ProcessStopped)
+
+			done := make(chan error)
+			go func() { done <- p.superviseProcess(context.Background()) }()
+
+			select {
+			case err := <-done:
+				So(err, ShouldBeNil)
+			case <-time.After(3 * processCheckInterval):
+				t.Fatal("supervisor did not stop")
+			}
+		})
+	})
+}
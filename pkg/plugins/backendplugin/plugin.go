@@ -0,0 +1,194 @@
+package backendplugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-model/go/datasource"
+	plugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// backend.pb.go is generated from backend.proto with protoc-gen-go v1.2.0,
+// the version of the vendored protobuf runtime. datasource.proto is read from
+// a checkout of grafana-plugin-model.
+//go:generate protoc -I . -I $GOPATH/src/github.com/grafana/grafana-plugin-model backend.proto --go_out=plugins=grpc,Mdatasource.proto=github.com/grafana/grafana-plugin-model/go/datasource:.
+
+// ErrNotImplemented is returned for calls the plugin does not implement.
+// Plugins of protocol version 1 only implement Query.
+var ErrNotImplemented = errors.New("Not implemented by the backend plugin")
+
+// Handshake is the handshake of Grafana and backend data source plugins of
+// both protocol versions.
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "grafana_plugin_type",
+	MagicCookieValue: "datasource",
+}
+
+type CallResourceResponseSender interface {
+	Send(*CallResourceResponse) error
+}
+
+type QueryResultSender interface {
+	Send(*datasource.QueryResult) error
+}
+
+// BackendPlugin is a backend data source plugin of protocol version 2. It
+// keeps the Query call of version 1.
+type BackendPlugin interface {
+	datasource.DatasourcePlugin
+	CheckHealth(ctx context.Context, req *CheckHealthRequest) (*CheckHealthResponse, error)
+	CallResource(ctx context.Context, req *CallResourceRequest, sender CallResourceResponseSender) error
+	QueryStream(ctx context.Context, req *datasource.DatasourceRequest, sender QueryResultSender) error
+}
+
+// Serve serves a backend plugin of protocol version 2, it is called from the
+// main function of the plugin.
+func Serve(pluginID string, p BackendPlugin) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         map[string]plugin.Plugin{pluginID: &BackendPluginImpl{Plugin: p}},
+		GRPCServer:      plugin.DefaultGRPCServer,
+	})
+}
+
+// BackendPluginImpl serves both protocol versions in the plugin and
+// returns a client for both versions in Grafana.
+type BackendPluginImpl struct {
+	plugin.NetRPCUnsupportedPlugin
+	Plugin BackendPlugin
+}
+
+func (p *BackendPluginImpl) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
+	datasource.RegisterDatasourcePluginServer(s, &datasource.GRPCServer{DatasourcePlugin: p.Plugin})
+	RegisterBackendPluginServer(s, &GRPCServer{p.Plugin})
+	return nil
+}
+
+func (p *BackendPluginImpl) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return NewGRPCClient(c), nil
+}
+
+// GRPCClient calls a plugin of either protocol version. Calls of version 2
+// return ErrNotImplemented if the plugin does not implement them, the result
+// is remembered to not call the plugin again.
+type GRPCClient struct {
+	v1 datasource.DatasourcePluginClient
+	v2 BackendPluginClient
+
+	mutex          sync.Mutex
+	notImplemented map[string]bool
+}
+
+func NewGRPCClient(c *grpc.ClientConn) *GRPCClient {
+	return &GRPCClient{
+		v1:             datasource.NewDatasourcePluginClient(c),
+		v2:             NewBackendPluginClient(c),
+		notImplemented: map[string]bool{},
+	}
+}
+
+func (c *GRPCClient) Query(ctx context.Context, req *datasource.DatasourceRequest) (*datasource.DatasourceResponse, error) {
+	return c.v1.Query(ctx, req)
+}
+
+func (c *GRPCClient) CheckHealth(ctx context.Context, req *CheckHealthRequest) (*CheckHealthResponse, error) {
+	if c.isNotImplemented("CheckHealth") {
+		return nil, ErrNotImplemented
+	}
+
+	resp, err := c.v2.CheckHealth(ctx, req)
+	if err != nil {
+		return nil, c.checkNotImplemented("CheckHealth", err)
+	}
+	return resp, nil
+}
+
+func (c *GRPCClient) CallResource(ctx context.Context, req *CallResourceRequest, sender CallResourceResponseSender) error {
+	if c.isNotImplemented("CallResource") {
+		return ErrNotImplemented
+	}
+
+	stream, err := c.v2.CallResource(ctx, req)
+	if err != nil {
+		return c.checkNotImplemented("CallResource", err)
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return c.checkNotImplemented("CallResource", err)
+		}
+		if err := sender.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *GRPCClient) QueryStream(ctx context.Context, req *datasource.DatasourceRequest, sender QueryResultSender) error {
+	if c.isNotImplemented("QueryStream") {
+		return ErrNotImplemented
+	}
+
+	stream, err := c.v2.QueryStream(ctx, req)
+	if err != nil {
+		return c.checkNotImplemented("QueryStream", err)
+	}
+
+	for {
+		result, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return c.checkNotImplemented("QueryStream", err)
+		}
+		if err := sender.Send(result); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *GRPCClient) isNotImplemented(method string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.notImplemented[method]
+}
+
+func (c *GRPCClient) checkNotImplemented(method string, err error) error {
+	if status.Code(err) != codes.Unimplemented {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.notImplemented[method] = true
+	return ErrNotImplemented
+}
+
+// GRPCServer serves the BackendPlugin service with a plugin.
+type GRPCServer struct {
+	BackendPlugin
+}
+
+func (s *GRPCServer) CheckHealth(ctx context.Context, req *CheckHealthRequest) (*CheckHealthResponse, error) {
+	return s.BackendPlugin.CheckHealth(ctx, req)
+}
+
+func (s *GRPCServer) CallResource(req *CallResourceRequest, stream BackendPlugin_CallResourceServer) error {
+	return s.BackendPlugin.CallResource(stream.Context(), req, stream)
+}
+
+func (s *GRPCServer) QueryStream(req *datasource.DatasourceRequest, stream BackendPlugin_QueryStreamServer) error {
+	return s.BackendPlugin.QueryStream(stream.Context(), req, stream)
+}
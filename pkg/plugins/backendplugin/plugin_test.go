@@ -0,0 +1,150 @@
+package backendplugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-model/go/datasource"
+	plugin "github.com/hashicorp/go-plugin"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+)
+
+type fakeBackendPlugin struct {
+	resourceRequest *CallResourceRequest
+}
+
+func (p *fakeBackendPlugin) Query(ctx context.Context, req *datasource.DatasourceRequest) (*datasource.DatasourceResponse, error) {
+	return &datasource.DatasourceResponse{Results: []*datasource.QueryResult{{RefId: "A"}}}, nil
+}
+
+func (p *fakeBackendPlugin) CheckHealth(ctx context.Context, req *CheckHealthRequest) (*CheckHealthResponse, error) {
+	return &CheckHealthResponse{Status: CheckHealthResponse_OK, Message: req.Datasource.Name + " is working"}, nil
+}
+
+func (p *fakeBackendPlugin) CallResource(ctx context.Context, req *CallResourceRequest, sender CallResourceResponseSender) error {
+	p.resourceRequest = req
+	if err := sender.Send(&CallResourceResponse{
+		Code:    201,
+		Headers: map[string]*StringList{"Content-Type": {Values: []string{"text/plain"}}},
+		Body:    []byte("hello "),
+	}); err != nil {
+		return err
+	}
+	return sender.Send(&CallResourceResponse{Body: []byte("world")})
+}
+
+func (p *fakeBackendPlugin) QueryStream(ctx context.Context, req *datasource.DatasourceRequest, sender QueryResultSender) error {
+	for _, query := range req.Queries {
+		for i := 0; i < 2; i++ {
+			if err := sender.Send(&datasource.QueryResult{RefId: query.RefId, Series: []*datasource.TimeSeries{{Name: "series"}}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type resourceRecorder struct {
+	responses []*CallResourceResponse
+}
+
+func (r *resourceRecorder) Send(resp *CallResourceResponse) error {
+	r.responses = append(r.responses, resp)
+	return nil
+}
+
+type queryRecorder struct {
+	results []*datasource.QueryResult
+}
+
+func (r *queryRecorder) Send(result *datasource.QueryResult) error {
+	r.results = append(r.results, result)
+	return nil
+}
+
+func TestBackendPluginProtocol(t *testing.T) {
+	Convey("Backend plugin protocol version 2", t, func() {
+		fake := &fakeBackendPlugin{}
+		conn, server := plugin.TestGRPCConn(t, func(s *grpc.Server) {
+			(&BackendPluginImpl{Plugin: fake}).GRPCServer(nil, s)
+		})
+		defer server.Stop()
+		defer conn.Close()
+
+		client := NewGRPCClient(conn)
+
+		Convey("Should keep the query of version 1", func() {
+			resp, err := client.Query(context.Background(), &datasource.DatasourceRequest{})
+			So(err, ShouldBeNil)
+			So(resp.Results[0].RefId, ShouldEqual, "A")
+		})
+
+		Convey("Should check the health", func() {
+			resp, err := client.CheckHealth(context.Background(), &CheckHealthRequest{Datasource: &datasource.DatasourceInfo{Name: "test"}})
+			So(err, ShouldBeNil)
+			So(resp.Status, ShouldEqual, CheckHealthResponse_OK)
+			So(resp.Message, ShouldEqual, "test is working")
+		})
+
+		Convey("Should stream resources", func() {
+			recorder := &resourceRecorder{}
+			err := client.CallResource(context.Background(), &CallResourceRequest{
+				Path:    "metrics",
+				Method:  "POST",
+				Headers: map[string]*StringList{"Accept": {Values: []string{"text/plain"}}},
+				Body:    []byte("body"),
+				User:    &User{Login: "admin"},
+			}, recorder)
+			So(err, ShouldBeNil)
+
+			So(fake.resourceRequest.Path, ShouldEqual, "metrics")
+			So(fake.resourceRequest.Headers["Accept"].Values, ShouldResemble, []string{"text/plain"})
+			So(string(fake.resourceRequest.Body), ShouldEqual, "body")
+			So(fake.resourceRequest.User.Login, ShouldEqual, "admin")
+
+			So(len(recorder.responses), ShouldEqual, 2)
+			So(recorder.responses[0].Code, ShouldEqual, 201)
+			So(recorder.responses[0].Headers["Content-Type"].Values, ShouldResemble, []string{"text/plain"})
+			So(string(recorder.responses[0].Body)+string(recorder.responses[1].Body), ShouldEqual, "hello world")
+		})
+
+		Convey("Should stream query results", func() {
+			recorder := &queryRecorder{}
+			err := client.QueryStream(context.Background(), &datasource.DatasourceRequest{
+				Queries: []*datasource.Query{{RefId: "A"}, {RefId: "B"}},
+			}, recorder)
+			So(err, ShouldBeNil)
+			So(len(recorder.results), ShouldEqual, 4)
+			So(recorder.results[3].RefId, ShouldEqual, "B")
+		})
+	})
+
+	Convey("Backend plugin protocol version 1", t, func() {
+		conn, server := plugin.TestGRPCConn(t, func(s *grpc.Server) {
+			datasource.RegisterDatasourcePluginServer(s, &datasource.GRPCServer{DatasourcePlugin: &fakeBackendPlugin{}})
+		})
+		defer server.Stop()
+		defer conn.Close()
+
+		client := NewGRPCClient(conn)
+
+		Convey("Should return not implemented for calls of version 2", func() {
+			_, err := client.CheckHealth(context.Background(), &CheckHealthRequest{})
+			So(err, ShouldEqual, ErrNotImplemented)
+			So(client.isNotImplemented("CheckHealth"), ShouldBeTrue)
+
+			err = client.CallResource(context.Background(), &CallResourceRequest{}, &resourceRecorder{})
+			So(err, ShouldEqual, ErrNotImplemented)
+
+			err = client.QueryStream(context.Background(), &datasource.DatasourceRequest{}, &queryRecorder{})
+			So(err, ShouldEqual, ErrNotImplemented)
+		})
+
+		Convey("Should query", func() {
+			resp, err := client.Query(context.Background(), &datasource.DatasourceRequest{})
+			So(err, ShouldBeNil)
+			So(resp.Results[0].RefId, ShouldEqual, "A")
+		})
+	})
+}
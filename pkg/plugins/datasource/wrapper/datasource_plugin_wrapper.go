@@ -2,14 +2,17 @@ package wrapper
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/grafana/grafana-plugin-model/go/datasource"
 	"github.com/grafana/grafana/pkg/components/null"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/log"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins/backendplugin"
 	"github.com/grafana/grafana/pkg/tsdb"
 )
 
@@ -22,22 +25,69 @@ type DatasourcePluginWrapper struct {
 	logger log.Logger
 }
 
-func (tw *DatasourcePluginWrapper) Query(ctx context.Context, ds *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+// NewDatasourceInfo returns the data source as sent to backend plugins.
+func NewDatasourceInfo(ds *models.DataSource) (*datasource.DatasourceInfo, error) {
 	jsonData, err := ds.JsonData.MarshalJSON()
 	if err != nil {
 		return nil, err
 	}
 
-	pbQuery := &datasource.DatasourceRequest{
-		Datasource: &datasource.DatasourceInfo{
-			Name:                    ds.Name,
-			Type:                    ds.Type,
-			Url:                     ds.Url,
-			Id:                      ds.Id,
-			OrgId:                   ds.OrgId,
-			JsonData:                string(jsonData),
-			DecryptedSecureJsonData: ds.SecureJsonData.Decrypt(),
+	return &datasource.DatasourceInfo{
+		Name:                    ds.Name,
+		Type:                    ds.Type,
+		Url:                     ds.Url,
+		Id:                      ds.Id,
+		OrgId:                   ds.OrgId,
+		JsonData:                string(jsonData),
+		DecryptedSecureJsonData: ds.SecureJsonData.Decrypt(),
+	}, nil
+}
+
+// CheckHealth calls the health check of plugins of protocol version 2. As
+// version 1 has no health check, it sends a request without queries to those
+// plugins, which fails if the plugin process is gone or returns an error.
+func (tw *DatasourcePluginWrapper) CheckHealth(ctx context.Context, ds *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	dsInfo, err := NewDatasourceInfo(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend, ok := tw.DatasourcePlugin.(backendplugin.BackendPlugin); ok {
+		resp, err := backend.CheckHealth(ctx, &backendplugin.CheckHealthRequest{Datasource: dsInfo})
+		if err != backendplugin.ErrNotImplemented {
+			if err != nil {
+				return nil, err
+			}
+			return tw.mapHealthCheckResult(resp), nil
+		}
+	}
+
+	now := time.Now()
+	_, err = tw.DatasourcePlugin.Query(ctx, &datasource.DatasourceRequest{
+		Datasource: dsInfo,
+		TimeRange: &datasource.TimeRange{
+			FromRaw:     "now-5m",
+			ToRaw:       "now",
+			FromEpochMs: now.Add(-5*time.Minute).UnixNano() / int64(time.Millisecond),
+			ToEpochMs:   now.UnixNano() / int64(time.Millisecond),
 		},
+		Queries: []*datasource.Query{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tsdb.HealthCheckResult{Status: tsdb.HealthStatusOk, Message: "Plugin is running"}, nil
+}
+
+func (tw *DatasourcePluginWrapper) Query(ctx context.Context, ds *models.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	dsInfo, err := NewDatasourceInfo(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	pbQuery := &datasource.DatasourceRequest{
+		Datasource: dsInfo,
 		TimeRange: &datasource.TimeRange{
 			FromRaw:     query.TimeRange.From,
 			ToRaw:       query.TimeRange.To,
@@ -58,8 +108,7 @@ func (tw *DatasourcePluginWrapper) Query(ctx context.Context, ds *models.DataSou
 		})
 	}
 
-	pbres, err := tw.DatasourcePlugin.Query(ctx, pbQuery)
-
+	pbres, err := tw.query(ctx, pbQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +163,79 @@ func (tw *DatasourcePluginWrapper) Query(ctx context.Context, ds *models.DataSou
 
 	return res, nil
 }
+
+// query streams the results from plugins of protocol version 2 and merges
+// the parts of the results, and falls back to the query of version 1.
+func (tw *DatasourcePluginWrapper) query(ctx context.Context, pbQuery *datasource.DatasourceRequest) (*datasource.DatasourceResponse, error) {
+	if backend, ok := tw.DatasourcePlugin.(backendplugin.BackendPlugin); ok {
+		merger := &queryResultMerger{results: map[string]*datasource.QueryResult{}}
+		err := backend.QueryStream(ctx, pbQuery, merger)
+		if err != backendplugin.ErrNotImplemented {
+			if err != nil {
+				return nil, err
+			}
+			return merger.response(), nil
+		}
+	}
+
+	return tw.DatasourcePlugin.Query(ctx, pbQuery)
+}
+
+func (tw *DatasourcePluginWrapper) mapHealthCheckResult(resp *backendplugin.CheckHealthResponse) *tsdb.HealthCheckResult {
+	result := &tsdb.HealthCheckResult{Message: resp.Message}
+
+	switch resp.Status {
+	case backendplugin.CheckHealthResponse_OK:
+		result.Status = tsdb.HealthStatusOk
+	case backendplugin.CheckHealthResponse_ERROR:
+		result.Status = tsdb.HealthStatusError
+	default:
+		result.Status = tsdb.HealthStatusUnknown
+	}
+
+	if resp.DetailsJson != "" {
+		if err := json.Unmarshal([]byte(resp.DetailsJson), &result.Details); err != nil {
+			tw.logger.Error("Error parsing JSON details of health check: " + err.Error())
+		}
+	}
+
+	return result
+}
+
+// queryResultMerger merges the streamed parts of the query results, in the
+// order of their first part.
+type queryResultMerger struct {
+	refIds  []string
+	results map[string]*datasource.QueryResult
+}
+
+func (m *queryResultMerger) Send(part *datasource.QueryResult) error {
+	result, exists := m.results[part.RefId]
+	if !exists {
+		m.refIds = append(m.refIds, part.RefId)
+		m.results[part.RefId] = part
+		return nil
+	}
+
+	if part.Error != "" {
+		result.Error = part.Error
+	}
+	if part.MetaJson != "" {
+		result.MetaJson = part.MetaJson
+	}
+	result.Series = append(result.Series, part.Series...)
+	result.Tables = append(result.Tables, part.Tables...)
+	return nil
+}
+
+func (m *queryResultMerger) response() *datasource.DatasourceResponse {
+	resp := &datasource.DatasourceResponse{}
+	for _, refId := range m.refIds {
+		resp.Results = append(resp.Results, m.results[refId])
+	}
+	return resp
+}
+
 func (tw *DatasourcePluginWrapper) mapTables(r *datasource.QueryResult) ([]*tsdb.Table, error) {
 	var tables []*tsdb.Table
 	for _, t := range r.GetTables() {
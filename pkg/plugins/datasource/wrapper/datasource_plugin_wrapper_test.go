@@ -1,10 +1,14 @@
 package wrapper
 
 import (
+	"context"
 	"testing"
 
 	"github.com/grafana/grafana-plugin-model/go/datasource"
+	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins/backendplugin"
 	"github.com/grafana/grafana/pkg/tsdb"
 )
 
@@ -107,3 +111,106 @@ func TestMappingRowValue(t *testing.T) {
 		t.Fatalf("Expected %v, was %v", nil, haveNil)
 	}
 }
+
+type fakeBackendPlugin struct {
+	streaming bool
+}
+
+func (p *fakeBackendPlugin) Query(ctx context.Context, req *datasource.DatasourceRequest) (*datasource.DatasourceResponse, error) {
+	return &datasource.DatasourceResponse{Results: []*datasource.QueryResult{{RefId: "A", Series: []*datasource.TimeSeries{{Name: "v1"}}}}}, nil
+}
+
+func (p *fakeBackendPlugin) CheckHealth(ctx context.Context, req *backendplugin.CheckHealthRequest) (*backendplugin.CheckHealthResponse, error) {
+	if !p.streaming {
+		return nil, backendplugin.ErrNotImplemented
+	}
+	return &backendplugin.CheckHealthResponse{Status: backendplugin.CheckHealthResponse_ERROR, Message: "down", DetailsJson: `{"code":1}`}, nil
+}
+
+func (p *fakeBackendPlugin) CallResource(ctx context.Context, req *backendplugin.CallResourceRequest, sender backendplugin.CallResourceResponseSender) error {
+	return backendplugin.ErrNotImplemented
+}
+
+func (p *fakeBackendPlugin) QueryStream(ctx context.Context, req *datasource.DatasourceRequest, sender backendplugin.QueryResultSender) error {
+	if !p.streaming {
+		return backendplugin.ErrNotImplemented
+	}
+
+	parts := []*datasource.QueryResult{
+		{RefId: "B", Series: []*datasource.TimeSeries{{Name: "b1"}}},
+		{RefId: "A", Series: []*datasource.TimeSeries{{Name: "a1"}}},
+		{RefId: "B", Series: []*datasource.TimeSeries{{Name: "b2"}}, Error: "partial"},
+	}
+	for _, part := range parts {
+		if err := sender.Send(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestQuery() (*models.DataSource, *tsdb.TsdbQuery) {
+	ds := &models.DataSource{Name: "test", JsonData: simplejson.New()}
+	query := &tsdb.TsdbQuery{
+		TimeRange: tsdb.NewTimeRange("now-1h", "now"),
+		Queries:   []*tsdb.Query{{RefId: "A", Model: simplejson.New()}},
+	}
+	return ds, query
+}
+
+func TestQueryStream(t *testing.T) {
+	dpw := NewDatasourcePluginWrapper(log.New("test-logger"), &fakeBackendPlugin{streaming: true})
+
+	ds, query := newTestQuery()
+	res, err := dpw.Query(context.Background(), ds, query)
+	if err != nil {
+		t.Fatalf("failed to query. error: %v", err)
+	}
+
+	b := res.Results["B"]
+	if len(b.Series) != 2 || b.Series[0].Name != "b1" || b.Series[1].Name != "b2" {
+		t.Fatalf("Expected the parts of B to be merged, was %v", b.Series)
+	}
+	if b.ErrorString != "partial" {
+		t.Fatalf("Expected error %s, was %s", "partial", b.ErrorString)
+	}
+	if len(res.Results["A"].Series) != 1 {
+		t.Fatalf("Expected one series for A, was %d", len(res.Results["A"].Series))
+	}
+}
+
+func TestQueryFallsBackToVersion1(t *testing.T) {
+	dpw := NewDatasourcePluginWrapper(log.New("test-logger"), &fakeBackendPlugin{})
+
+	ds, query := newTestQuery()
+	res, err := dpw.Query(context.Background(), ds, query)
+	if err != nil {
+		t.Fatalf("failed to query. error: %v", err)
+	}
+
+	if res.Results["A"].Series[0].Name != "v1" {
+		t.Fatalf("Expected the result of the version 1 query, was %v", res.Results["A"].Series)
+	}
+
+	health, err := dpw.CheckHealth(context.Background(), ds)
+	if err != nil || health.Status != tsdb.HealthStatusOk {
+		t.Fatalf("Expected the health check of version 1 to pass, was %v %v", health, err)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	dpw := NewDatasourcePluginWrapper(log.New("test-logger"), &fakeBackendPlugin{streaming: true})
+
+	ds, _ := newTestQuery()
+	health, err := dpw.CheckHealth(context.Background(), ds)
+	if err != nil {
+		t.Fatalf("failed to check health. error: %v", err)
+	}
+
+	if health.Status != tsdb.HealthStatusError || health.Message != "down" {
+		t.Fatalf("Expected status %s with message down, was %s %s", tsdb.HealthStatusError, health.Status, health.Message)
+	}
+	if health.Details["code"] != float64(1) {
+		t.Fatalf("Expected details to be parsed, was %v", health.Details)
+	}
+}
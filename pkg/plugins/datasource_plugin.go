@@ -3,20 +3,26 @@ package plugins
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sync"
 	"time"
 
-	"github.com/grafana/grafana-plugin-model/go/datasource"
 	"github.com/grafana/grafana/pkg/log"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins/backendplugin"
 	"github.com/grafana/grafana/pkg/plugins/datasource/wrapper"
 	"github.com/grafana/grafana/pkg/tsdb"
 	plugin "github.com/hashicorp/go-plugin"
 )
 
+// ErrPluginNotRunning is returned for calls to backend plugins whose process
+// is not running.
+var ErrPluginNotRunning = errors.New("Backend plugin is not running")
+
 // DataSourcePlugin contains all metadata about a datasource plugin
 type DataSourcePlugin struct {
 	FrontendPluginBase
@@ -34,8 +40,14 @@ type DataSourcePlugin struct {
 	Backend    bool   `json:"backend,omitempty"`
 	Executable string `json:"executable,omitempty"`
 
-	log    log.Logger
-	client *plugin.Client
+	log      log.Logger
+	client   *plugin.Client
+	cmd      *exec.Cmd
+	backend  backendplugin.BackendPlugin
+	settings processSettings
+	status   PluginProcessStatus
+	stopped  bool
+	mutex    sync.Mutex
 }
 
 func (p *DataSourcePlugin) Load(decoder *json.Decoder, pluginDir string) error {
@@ -60,76 +72,268 @@ func (p *DataSourcePlugin) Load(decoder *json.Decoder, pluginDir string) error {
 	return nil
 }
 
-var handshakeConfig = plugin.HandshakeConfig{
-	ProtocolVersion:  1,
-	MagicCookieKey:   "grafana_plugin_type",
-	MagicCookieValue: "datasource",
-}
-
 func (p *DataSourcePlugin) startBackendPlugin(ctx context.Context, log log.Logger) error {
 	p.log = log.New("plugin-id", p.Id)
+	p.settings = readProcessSettings(p.Id)
+	p.status = PluginProcessStatus{State: ProcessStarting}
 
-	err := p.spawnSubProcess()
-	if err == nil {
-		go p.restartKilledProcess(ctx)
+	if err := p.spawnSubProcess(); err != nil {
+		p.setState(ProcessFailed, time.Time{})
+		return err
 	}
 
-	return err
+	go p.superviseProcess(ctx)
+	return nil
 }
 
 func (p *DataSourcePlugin) spawnSubProcess() error {
-	cmd := ComposePluginStartCommmand(p.Executable)
-	fullpath := path.Join(p.PluginDir, cmd)
+	fullpath := path.Join(p.PluginDir, ComposePluginStartCommmand(p.Executable))
+	if err := p.VerifyExecutable(fullpath); err != nil {
+		p.recordError(err)
+		return err
+	}
 
-	p.client = plugin.NewClient(&plugin.ClientConfig{
-		HandshakeConfig:  handshakeConfig,
-		Plugins:          map[string]plugin.Plugin{p.Id: &datasource.DatasourcePluginImpl{}},
-		Cmd:              exec.Command(fullpath),
+	// the limits apply from the start of the plugin on
+	cmd, isolation, err := limitedCommand(p.Id, fullpath, p.settings)
+	if err != nil {
+		p.log.Warn("Failed to limit resources of backend plugin", "error", err)
+	}
+
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  backendplugin.Handshake,
+		Plugins:          map[string]plugin.Plugin{p.Id: &backendplugin.BackendPluginImpl{}},
+		Cmd:              cmd,
 		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
 		Logger:           LogWrapper{Logger: p.log},
 	})
 
-	rpcClient, err := p.client.Client()
-	if err != nil {
-		return err
-	}
+	p.mutex.Lock()
+	p.client = client
+	p.cmd = cmd
+	p.mutex.Unlock()
 
-	raw, err := rpcClient.Dispense(p.Id)
+	backend, err := dispenseBackendPlugin(client, p.Id)
 	if err != nil {
+		client.Kill()
+		p.recordError(err)
 		return err
 	}
 
-	plugin := raw.(datasource.DatasourcePlugin)
+	p.mutex.Lock()
+	if p.stopped {
+		p.mutex.Unlock()
+		client.Kill()
+		return ErrPluginNotRunning
+	}
+	p.backend = backend
+	p.status.State = ProcessRunning
+	p.status.Pid = cmd.Process.Pid
+	p.status.StartedAt = time.Now()
+	p.status.NextRestartAt = time.Time{}
+	p.status.Isolation = isolation
+	p.mutex.Unlock()
 
 	tsdb.RegisterTsdbQueryEndpoint(p.Id, func(dsInfo *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
-		return wrapper.NewDatasourcePluginWrapper(p.log, plugin), nil
+		return wrapper.NewDatasourcePluginWrapper(p.log, backend), nil
 	})
 
 	return nil
 }
 
-func (p *DataSourcePlugin) restartKilledProcess(ctx context.Context) error {
-	ticker := time.NewTicker(time.Second * 1)
+func dispenseBackendPlugin(client *plugin.Client, pluginId string) (backendplugin.BackendPlugin, error) {
+	rpcClient, err := client.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := rpcClient.Dispense(pluginId)
+	if err != nil {
+		return nil, err
+	}
+
+	return raw.(backendplugin.BackendPlugin), nil
+}
+
+// CallResource calls a resource of the backend plugin. It returns
+// backendplugin.ErrNotImplemented if the plugin has no resources.
+func (p *DataSourcePlugin) CallResource(ctx context.Context, req *backendplugin.CallResourceRequest, sender backendplugin.CallResourceResponseSender) error {
+	p.mutex.Lock()
+	backend := p.backend
+	p.mutex.Unlock()
+
+	if backend == nil {
+		return ErrPluginNotRunning
+	}
+
+	return backend.CallResource(ctx, req, sender)
+}
+
+// superviseProcess restarts the process of the plugin when it exits. The
+// delay between restarts doubles while the plugin keeps crashing, and is
+// reset once the plugin stays up for restart_backoff_reset_seconds. A plugin
+// that was killed is not restarted.
+func (p *DataSourcePlugin) superviseProcess(ctx context.Context) error {
+	ticker := time.NewTicker(processCheckInterval)
+	defer ticker.Stop()
+
+	backoff := &restartBackoff{min: p.settings.backoffMin, max: p.settings.backoffMax}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			if p.client.Exited() {
-				err := p.spawnSubProcess()
-				p.log.Debug("Spawning new sub process", "name", p.Name, "id", p.Id)
-				if err != nil {
-					p.log.Error("Failed to spawn subprocess")
-				}
-			}
+		}
+
+		if p.isStopped() {
+			return nil
+		}
+
+		uptime, exited := p.checkExited()
+		if !exited {
+			continue
+		}
+
+		if uptime >= p.settings.backoffReset {
+			backoff.reset()
+		}
+
+		running, err := p.restartProcess(ctx, backoff)
+		if !running {
+			return err
 		}
 	}
 }
 
+// restartProcess starts the process of the plugin again after the delay of
+// the backoff. Failed starts are retried like crashes until
+// restart_max_attempts is reached, an executable that does not match the
+// signature of the plugin is not retried. It returns false if the plugin is
+// not restarted anymore.
+func (p *DataSourcePlugin) restartProcess(ctx context.Context, backoff *restartBackoff) (bool, error) {
+	for {
+		if p.isStopped() {
+			return false, nil
+		}
+
+		if p.settings.restartMaxAttempts > 0 && backoff.attempts >= p.settings.restartMaxAttempts {
+			p.log.Error("Backend plugin keeps crashing, giving up restarting it", "attempts", backoff.attempts)
+			p.setState(ProcessFailed, time.Time{})
+			return false, nil
+		}
+
+		delay := backoff.next()
+		p.log.Warn("Backend plugin is not running, restarting it", "delay", delay, "attempt", backoff.attempts)
+		p.setState(ProcessRestarting, time.Now().Add(delay))
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		if p.isStopped() {
+			return false, nil
+		}
+
+		p.mutex.Lock()
+		p.status.Restarts++
+		p.mutex.Unlock()
+
+		p.log.Debug("Spawning new sub process", "name", p.Name, "id", p.Id)
+		err := p.spawnSubProcess()
+		if err == nil {
+			return true, nil
+		}
+
+		if _, ok := err.(*executableSignatureError); ok {
+			p.log.Error("Backend plugin does not match its signature, giving up restarting it", "error", err)
+			p.setState(ProcessFailed, time.Time{})
+			return false, nil
+		}
+
+		p.log.Error("Failed to spawn subprocess", "error", err)
+	}
+}
+
+// checkExited returns true if the running process of the plugin exited, and
+// how long it ran.
+func (p *DataSourcePlugin) checkExited() (time.Duration, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stopped || p.backend == nil {
+		return 0, false
+	}
+	if !p.client.Exited() {
+		return 0, false
+	}
+
+	// the client has waited for the process, its state is set
+	p.status.LastError = "Plugin process exited: " + p.cmd.ProcessState.String()
+	p.status.LastErrorAt = time.Now()
+	p.status.Pid = 0
+	p.backend = nil
+
+	return time.Since(p.status.StartedAt), true
+}
+
+func (p *DataSourcePlugin) isStopped() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.stopped
+}
+
+func (p *DataSourcePlugin) recordError(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.status.LastError = err.Error()
+	p.status.LastErrorAt = time.Now()
+}
+
+func (p *DataSourcePlugin) setState(state PluginProcessState, nextRestartAt time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.status.State = state
+	p.status.NextRestartAt = nextRestartAt
+}
+
+// ProcessStatus returns the state and resource usage of the process of the
+// backend plugin.
+func (p *DataSourcePlugin) ProcessStatus() PluginProcessStatus {
+	p.mutex.Lock()
+	status := p.status
+	p.mutex.Unlock()
+
+	status.PluginId = p.Id
+	status.Name = p.Name
+	status.MemoryLimitBytes = p.settings.memoryLimit
+	status.CpuLimitPercent = p.settings.cpuLimitPercent
+
+	if status.State == ProcessRunning {
+		if cpu, memory, err := processUsage(status.Pid); err == nil {
+			status.CpuSeconds = cpu
+			status.ResidentMemoryBytes = memory
+		}
+	}
+
+	return status
+}
+
 func (p *DataSourcePlugin) Kill() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	if p.client != nil {
 		p.log.Debug("Killing subprocess ", "name", p.Name)
 		p.client.Kill()
 	}
+
+	p.stopped = true
+	p.backend = nil
+	p.status.State = ProcessStopped
+	p.status.Pid = 0
 }
@@ -1,14 +1,20 @@
 package plugins
 
 import (
+	"bytes"
 	"log"
+	"strings"
 
 	glog "github.com/grafana/grafana/pkg/log"
 	hclog "github.com/hashicorp/go-hclog"
 )
 
+// LogWrapper writes the logs of go-plugin and of the backend plugins to the
+// Grafana log. Plugins logging with hclog in JSON format, the default of
+// go-plugin, keep the level and the key value pairs of their log entries.
 type LogWrapper struct {
 	Logger glog.Logger
+	name   string
 }
 
 func (lw LogWrapper) Trace(msg string, args ...interface{}) {
@@ -34,16 +40,58 @@ func (lw LogWrapper) IsWarn() bool  { return true }
 func (lw LogWrapper) IsError() bool { return true }
 
 func (lw LogWrapper) With(args ...interface{}) hclog.Logger {
-	return LogWrapper{Logger: lw.Logger.New(args...)}
+	return LogWrapper{Logger: lw.Logger.New(args...), name: lw.name}
 }
+
+// Named appends the name to the name of the logger, the name is logged as
+// plugin-logger.
 func (lw LogWrapper) Named(name string) hclog.Logger {
-	return LogWrapper{Logger: lw.Logger.New()}
+	if lw.name != "" {
+		name = lw.name + "." + name
+	}
+	return lw.ResetNamed(name)
 }
 
 func (lw LogWrapper) ResetNamed(name string) hclog.Logger {
-	return LogWrapper{Logger: lw.Logger.New()}
+	return LogWrapper{Logger: lw.Logger.New("plugin-logger", name), name: name}
+}
+
+func (lw LogWrapper) StandardLogger(opts *hclog.StandardLoggerOptions) *log.Logger {
+	inferLevels := opts != nil && opts.InferLevels
+	return log.New(&stdLogWriter{logger: lw, inferLevels: inferLevels}, "", 0)
+}
+
+// stdLogWriter writes the lines of a standard logger at info level, or at the
+// level of their [ERROR], [WARN], ... prefix when inferring levels.
+type stdLogWriter struct {
+	logger      LogWrapper
+	inferLevels bool
 }
 
-func (lw LogWrapper) StandardLogger(ops *hclog.StandardLoggerOptions) *log.Logger {
-	return nil
+func (w *stdLogWriter) Write(data []byte) (int, error) {
+	msg := string(bytes.TrimRight(data, " \t\n"))
+
+	if !w.inferLevels {
+		w.logger.Info(msg)
+		return len(data), nil
+	}
+
+	switch {
+	case strings.HasPrefix(msg, "[TRACE]"):
+		w.logger.Trace(strings.TrimSpace(msg[7:]))
+	case strings.HasPrefix(msg, "[DEBUG]"):
+		w.logger.Debug(strings.TrimSpace(msg[7:]))
+	case strings.HasPrefix(msg, "[INFO]"):
+		w.logger.Info(strings.TrimSpace(msg[6:]))
+	case strings.HasPrefix(msg, "[WARN]"):
+		w.logger.Warn(strings.TrimSpace(msg[6:]))
+	case strings.HasPrefix(msg, "[ERROR]"):
+		w.logger.Error(strings.TrimSpace(msg[7:]))
+	case strings.HasPrefix(msg, "[ERR]"):
+		w.logger.Error(strings.TrimSpace(msg[5:]))
+	default:
+		w.logger.Info(msg)
+	}
+
+	return len(data), nil
 }
@@ -55,6 +55,9 @@ type PluginBase struct {
 
 	GrafanaNetVersion   string `json:"-"`
 	GrafanaNetHasUpdate bool   `json:"-"`
+
+	Signature   PluginSignatureStatus `json:"-"`
+	signedFiles map[string]string
 }
 
 func (pb *PluginBase) registerPlugin(pluginDir string) error {
@@ -36,6 +36,7 @@ var (
 type PluginScanner struct {
 	pluginPath string
 	errors     []error
+	signatures map[string]*pluginSignature
 }
 
 type PluginManager struct {
@@ -62,6 +63,10 @@ func (pm *PluginManager) Init() error {
 		"renderer":   RendererPlugin{},
 	}
 
+	if err := readSignatureSettings(); err != nil {
+		return err
+	}
+
 	pm.log.Info("Starting plugin search")
 	scan(path.Join(setting.StaticRootPath, "app/plugins"))
 
@@ -147,6 +152,7 @@ func checkPluginPaths() error {
 func scan(pluginDir string) error {
 	scanner := &PluginScanner{
 		pluginPath: pluginDir,
+		signatures: map[string]*pluginSignature{},
 	}
 
 	if err := util.Walk(pluginDir, true, true, scanner.walker); err != nil {
@@ -212,8 +218,41 @@ func (scanner *PluginScanner) loadPluginJson(pluginJsonFilePath string) error {
 	}
 	loader = reflect.New(reflect.TypeOf(pluginGoType)).Interface().(PluginLoader)
 
+	signature := scanner.verifySignature(pluginCommon.Id, currentDir)
+	if err := checkPluginSignature(pluginCommon.Id, signature); err != nil {
+		return err
+	}
+
 	reader.Seek(0, 0)
-	return loader.Load(jsonParser, currentDir)
+	if err := loader.Load(jsonParser, currentDir); err != nil {
+		return err
+	}
+
+	if plugin, exists := Plugins[pluginCommon.Id]; exists {
+		plugin.Signature = signature.status
+		plugin.signedFiles = signature.signedFiles
+	}
+
+	return nil
+}
+
+// verifySignature verifies the manifest signing the plugin, the manifest of
+// an app is verified once for the app and its included plugins.
+func (scanner *PluginScanner) verifySignature(pluginId string, pluginDir string) *pluginSignature {
+	if setting.StaticRootPath != "" && isInDir(pluginDir, setting.StaticRootPath) {
+		return &pluginSignature{status: PluginSignatureInternal}
+	}
+
+	manifestDir := findManifestDir(pluginDir, scanner.pluginPath)
+	included := manifestDir != filepath.Clean(pluginDir)
+
+	if signature, exists := scanner.signatures[manifestDir]; exists && included {
+		return signature
+	}
+
+	signature := verifyManifest(pluginId, manifestDir, included)
+	scanner.signatures[manifestDir] = signature
+	return signature
 }
 
 func GetPluginMarkdown(pluginId string, name string) ([]byte, error) {
@@ -0,0 +1,261 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+	"golang.org/x/crypto/ed25519"
+)
+
+type PluginSignatureStatus string
+
+const (
+	PluginSignatureInternal PluginSignatureStatus = "internal"
+	PluginSignatureValid    PluginSignatureStatus = "valid"
+	PluginSignatureUnsigned PluginSignatureStatus = "unsigned"
+	PluginSignatureInvalid  PluginSignatureStatus = "invalid"
+	PluginSignatureModified PluginSignatureStatus = "modified"
+
+	manifestFileName = "MANIFEST.json"
+)
+
+var (
+	signingKeys   map[string]ed25519.PublicKey
+	allowUnsigned map[string]bool
+)
+
+// pluginManifest lists the sha256 hashes of all files of a plugin, signed
+// with the ed25519 key keyId.
+type pluginManifest struct {
+	Plugin    string            `json:"plugin"`
+	Version   string            `json:"version"`
+	KeyId     string            `json:"keyId"`
+	Files     map[string]string `json:"files"`
+	Signature string            `json:"signature"`
+}
+
+// payload returns the signed content of the manifest: a line with the plugin
+// id, a line with the version and a line with the hash and path of every
+// file, sorted by path, in the format of sha256sum.
+func (m *pluginManifest) payload() []byte {
+	paths := make([]string, 0, len(m.Files))
+	for path := range m.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n%s\n", m.Plugin, m.Version)
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "%s  %s\n", m.Files[path], path)
+	}
+
+	return buf.Bytes()
+}
+
+// pluginSignature is the result of verifying the manifest of a plugin.
+// signedFiles maps the absolute paths of the signed files to their hashes.
+type pluginSignature struct {
+	status      PluginSignatureStatus
+	signedFiles map[string]string
+	err         error
+}
+
+func readSignatureSettings() error {
+	sec := setting.Raw.Section("plugins")
+
+	signingKeys = map[string]ed25519.PublicKey{}
+	for _, item := range util.SplitString(sec.Key("signing_public_keys").String()) {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid plugin signing key %s, expected <key id>:<base64 ed25519 public key>", item)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("Invalid plugin signing key %s, expected a base64 ed25519 public key", parts[0])
+		}
+
+		signingKeys[parts[0]] = ed25519.PublicKey(key)
+	}
+
+	allowUnsigned = map[string]bool{}
+	for _, pluginId := range util.SplitString(sec.Key("allow_unsigned_plugins").String()) {
+		allowUnsigned[pluginId] = true
+	}
+
+	return nil
+}
+
+// verifyManifest verifies the signature of the manifest in manifestDir and
+// the hashes of all files in the directory. Plugins included in an app are
+// signed by the manifest of the app.
+func verifyManifest(pluginId string, manifestDir string, included bool) *pluginSignature {
+	data, err := ioutil.ReadFile(filepath.Join(manifestDir, manifestFileName))
+	if os.IsNotExist(err) {
+		return &pluginSignature{status: PluginSignatureUnsigned}
+	}
+	if err != nil {
+		return &pluginSignature{status: PluginSignatureInvalid, err: err}
+	}
+
+	manifest := pluginManifest{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return &pluginSignature{status: PluginSignatureInvalid, err: fmt.Errorf("Failed to parse manifest: %v", err)}
+	}
+
+	if !included && manifest.Plugin != pluginId {
+		return &pluginSignature{status: PluginSignatureInvalid, err: fmt.Errorf("Manifest is for plugin %s", manifest.Plugin)}
+	}
+
+	key, exists := signingKeys[manifest.KeyId]
+	if !exists {
+		return &pluginSignature{status: PluginSignatureInvalid, err: fmt.Errorf("Manifest is signed with unknown key %s", manifest.KeyId)}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil || !ed25519.Verify(key, manifest.payload(), signature) {
+		return &pluginSignature{status: PluginSignatureInvalid, err: errors.New("Manifest signature is not valid")}
+	}
+
+	signedFiles := map[string]string{}
+	for path, hash := range manifest.Files {
+		fullpath := filepath.Join(manifestDir, filepath.FromSlash(path))
+		if !strings.HasPrefix(fullpath, filepath.Clean(manifestDir)+string(filepath.Separator)) {
+			return &pluginSignature{status: PluginSignatureInvalid, err: fmt.Errorf("Manifest contains file %s outside of the plugin", path)}
+		}
+
+		fileHash, err := hashFile(fullpath)
+		if err != nil || fileHash != hash {
+			return &pluginSignature{status: PluginSignatureModified, err: fmt.Errorf("File %s does not match the manifest", path)}
+		}
+
+		signedFiles[fullpath] = hash
+	}
+
+	err = filepath.Walk(manifestDir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() || path == filepath.Join(manifestDir, manifestFileName) {
+			return nil
+		}
+		if _, signed := signedFiles[path]; !signed {
+			return fmt.Errorf("File %s is not in the manifest", path)
+		}
+		return nil
+	})
+	if err != nil {
+		return &pluginSignature{status: PluginSignatureModified, err: err}
+	}
+
+	return &pluginSignature{status: PluginSignatureValid, signedFiles: signedFiles}
+}
+
+// findManifestDir returns the directory of the manifest signing the plugin,
+// the plugin directory or, for plugins included in an app, the directory of
+// the app. It returns the plugin directory if there is no manifest.
+func findManifestDir(pluginDir string, scanRoot string) string {
+	root := filepath.Clean(scanRoot)
+	for dir := filepath.Clean(pluginDir); isInDir(dir, root); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, manifestFileName)); err == nil {
+			return dir
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	return filepath.Clean(pluginDir)
+}
+
+// checkPluginSignature returns an error if the plugin must not be loaded.
+// Unsigned plugins are loaded in development mode or if they are allowed in
+// allow_unsigned_plugins.
+func checkPluginSignature(pluginId string, signature *pluginSignature) error {
+	switch signature.status {
+	case PluginSignatureInternal, PluginSignatureValid:
+		return nil
+	case PluginSignatureUnsigned:
+		if allowUnsigned[pluginId] || setting.Env == setting.DEV {
+			plog.Warn("Loading unsigned plugin", "plugin", pluginId)
+			return nil
+		}
+		return fmt.Errorf("Plugin %s is unsigned, add it to allow_unsigned_plugins to load it", pluginId)
+	case PluginSignatureModified:
+		return fmt.Errorf("Plugin %s is modified: %v", pluginId, signature.err)
+	default:
+		return fmt.Errorf("Plugin %s has an invalid signature: %v", pluginId, signature.err)
+	}
+}
+
+// executableSignatureError is returned by VerifyExecutable for executables
+// that are not part of the signed files of the plugin or were modified.
+type executableSignatureError struct {
+	message string
+}
+
+func (e *executableSignatureError) Error() string {
+	return e.message
+}
+
+// VerifyExecutable checks the executable of a signed plugin against its
+// manifest before every start, the executable could be replaced after the
+// plugin was loaded.
+func (pb *PluginBase) VerifyExecutable(fullpath string) error {
+	if pb.Signature != PluginSignatureValid {
+		return nil
+	}
+
+	signedHash, signed := pb.signedFiles[filepath.Clean(fullpath)]
+	if !signed {
+		return &executableSignatureError{fmt.Sprintf("Executable %s of plugin %s is not signed", fullpath, pb.Id)}
+	}
+
+	hash, err := hashFile(fullpath)
+	if err != nil {
+		return err
+	}
+	if hash != signedHash {
+		return &executableSignatureError{fmt.Sprintf("Executable %s of plugin %s does not match its signature", fullpath, pb.Id)}
+	}
+
+	return nil
+}
+
+// isInDir returns true if path is dir or a path inside of it.
+func isInDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,148 @@
+package plugins
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/setting"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/ini.v1"
+)
+
+func writeTestPlugin(dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		So(os.MkdirAll(filepath.Dir(path), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+	}
+}
+
+func signTestPlugin(dir string, pluginId string, keyId string, key ed25519.PrivateKey, files map[string]string) {
+	manifest := pluginManifest{Plugin: pluginId, Version: "1.0.0", KeyId: keyId, Files: map[string]string{}}
+	for name, content := range files {
+		hash := sha256.Sum256([]byte(content))
+		manifest.Files[name] = hex.EncodeToString(hash[:])
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest.payload()))
+
+	data, err := json.Marshal(manifest)
+	So(err, ShouldBeNil)
+	So(ioutil.WriteFile(filepath.Join(dir, manifestFileName), data, 0644), ShouldBeNil)
+}
+
+func TestPluginSignature(t *testing.T) {
+	Convey("When verifying plugin signatures", t, func() {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		So(err, ShouldBeNil)
+
+		setting.Raw = ini.Empty()
+		sec, _ := setting.Raw.NewSection("plugins")
+		sec.NewKey("signing_public_keys", "test:"+base64.StdEncoding.EncodeToString(publicKey))
+		sec.NewKey("allow_unsigned_plugins", "allowed-ds")
+		So(readSignatureSettings(), ShouldBeNil)
+
+		dir, err := ioutil.TempDir("", "plugin-signature")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		files := map[string]string{
+			"plugin.json":         `{"id":"test-ds","type":"datasource"}`,
+			"module.js":           "module",
+			"dist/backend_linux":  "binary",
+			"nested/plugin.json":  `{"id":"nested-panel","type":"panel"}`,
+			"nested/img/logo.svg": "<svg/>",
+		}
+		writeTestPlugin(dir, files)
+
+		Convey("Should verify a signed plugin", func() {
+			signTestPlugin(dir, "test-ds", "test", privateKey, files)
+
+			signature := verifyManifest("test-ds", dir, false)
+			So(signature.err, ShouldBeNil)
+			So(signature.status, ShouldEqual, PluginSignatureValid)
+			So(signature.signedFiles, ShouldContainKey, filepath.Join(dir, "dist", "backend_linux"))
+
+			Convey("Should sign the included plugins with the manifest of the app", func() {
+				So(findManifestDir(filepath.Join(dir, "nested"), dir), ShouldEqual, filepath.Clean(dir))
+				So(verifyManifest("nested-panel", dir, true).status, ShouldEqual, PluginSignatureValid)
+				So(verifyManifest("nested-panel", dir, false).status, ShouldEqual, PluginSignatureInvalid)
+			})
+		})
+
+		Convey("Should detect modified files", func() {
+			signTestPlugin(dir, "test-ds", "test", privateKey, files)
+			writeTestPlugin(dir, map[string]string{"dist/backend_linux": "replaced"})
+
+			signature := verifyManifest("test-ds", dir, false)
+			So(signature.status, ShouldEqual, PluginSignatureModified)
+			So(checkPluginSignature("test-ds", signature), ShouldNotBeNil)
+		})
+
+		Convey("Should detect files missing in the manifest", func() {
+			signTestPlugin(dir, "test-ds", "test", privateKey, files)
+			writeTestPlugin(dir, map[string]string{"dist/backend_darwin": "binary"})
+
+			So(verifyManifest("test-ds", dir, false).status, ShouldEqual, PluginSignatureModified)
+		})
+
+		Convey("Should reject manifests signed with unknown keys", func() {
+			_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+			signTestPlugin(dir, "test-ds", "test", otherKey, files)
+			So(verifyManifest("test-ds", dir, false).status, ShouldEqual, PluginSignatureInvalid)
+
+			signTestPlugin(dir, "test-ds", "other", otherKey, files)
+			So(verifyManifest("test-ds", dir, false).status, ShouldEqual, PluginSignatureInvalid)
+		})
+
+		Convey("Should only load allowed unsigned plugins in production", func() {
+			setting.Env = setting.PROD
+			defer func() { setting.Env = setting.DEV }()
+
+			signature := verifyManifest("test-ds", dir, false)
+			So(signature.status, ShouldEqual, PluginSignatureUnsigned)
+			So(checkPluginSignature("test-ds", signature), ShouldNotBeNil)
+			So(checkPluginSignature("allowed-ds", signature), ShouldBeNil)
+		})
+
+		Convey("Should only treat plugins inside the static root as internal", func() {
+			staticRootPath := setting.StaticRootPath
+			defer func() { setting.StaticRootPath = staticRootPath }()
+			setting.StaticRootPath = filepath.Join(dir, "public")
+
+			scanner := &PluginScanner{pluginPath: dir, signatures: map[string]*pluginSignature{}}
+			So(scanner.verifySignature("test-ds", filepath.Join(dir, "public", "app", "plugins", "test-ds")).status, ShouldEqual, PluginSignatureInternal)
+			So(scanner.verifySignature("test-ds", filepath.Join(dir, "public-plugins", "test-ds")).status, ShouldEqual, PluginSignatureUnsigned)
+		})
+
+		Convey("Should only match paths inside a directory", func() {
+			So(isInDir(dir, dir), ShouldBeTrue)
+			So(isInDir(dir+"-other", dir), ShouldBeFalse)
+			So(isInDir(filepath.Dir(dir), dir), ShouldBeFalse)
+			So(isInDir(filepath.Join(dir, "nested"), dir), ShouldBeTrue)
+		})
+
+		Convey("Should refuse to start a modified executable", func() {
+			signTestPlugin(dir, "test-ds", "test", privateKey, files)
+			signature := verifyManifest("test-ds", dir, false)
+
+			p := &DataSourcePlugin{}
+			p.Id = "test-ds"
+			p.Signature = signature.status
+			p.signedFiles = signature.signedFiles
+
+			executable := filepath.Join(dir, "dist", "backend_linux")
+			So(p.VerifyExecutable(executable), ShouldBeNil)
+
+			writeTestPlugin(dir, map[string]string{"dist/backend_linux": "replaced"})
+			So(p.VerifyExecutable(executable), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/log"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	SourceProxy    = "proxy"
+	SourceQuery    = "query"
+	SourceResource = "resource"
+
+	BodyHash = "hash"
+	BodyFull = "full"
+	BodyNone = "none"
+
+	batchSize     = 100
+	flushInterval = time.Second
+)
+
+func init() {
+	registry.RegisterService(&AuditService{})
+}
+
+// AuditService writes an audit log of the requests of users to data sources
+// to the configured sinks. Events are written in batches in the background,
+// so the requests do not wait for the sinks.
+type AuditService struct {
+	Cfg *setting.Cfg `inject:""`
+
+	log         log.Logger
+	enabled     bool
+	bodyMode    string
+	maxBodySize int
+	retention   time.Duration
+	sinks       []Sink
+	events      chan *m.AuditEvent
+}
+
+func (s *AuditService) Init() error {
+	s.log = log.New("audit")
+
+	sec := s.Cfg.Raw.Section("audit")
+	s.enabled = sec.Key("enabled").MustBool(false)
+	s.bodyMode = sec.Key("body").In(BodyHash, []string{BodyHash, BodyFull, BodyNone})
+	s.maxBodySize = sec.Key("max_body_size_kb").MustInt(64) * 1024
+	s.retention = time.Duration(sec.Key("retention_days").MustInt(90)) * 24 * time.Hour
+
+	if !s.enabled {
+		return nil
+	}
+
+	for _, name := range util.SplitString(sec.Key("sinks").MustString("file")) {
+		factory, exists := sinkFactories[name]
+		if !exists {
+			return fmt.Errorf("Unknown audit sink %s", name)
+		}
+
+		sink, err := factory(s.Cfg, sec)
+		if err != nil {
+			return err
+		}
+		s.sinks = append(s.sinks, sink)
+	}
+
+	s.events = make(chan *m.AuditEvent, sec.Key("queue_size").MustInt(10000))
+	return nil
+}
+
+// Enabled returns true if requests to data sources are audited.
+func (s *AuditService) Enabled() bool {
+	return s.enabled
+}
+
+// Run writes the audited events to the sinks until the context is canceled,
+// then writes the remaining events.
+func (s *AuditService) Run(ctx context.Context) error {
+	if !s.enabled {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	cleanupTicker := time.NewTicker(time.Hour)
+	defer cleanupTicker.Stop()
+
+	batch := make([]*m.AuditEvent, 0, batchSize)
+	for {
+		select {
+		case event := <-s.events:
+			batch = append(batch, event)
+			if len(batch) >= batchSize {
+				batch = s.write(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = s.write(ctx, batch)
+		case <-cleanupTicker.C:
+			s.deleteExpiredEvents()
+		case <-ctx.Done():
+			for len(s.events) > 0 {
+				batch = append(batch, <-s.events)
+			}
+			// the context is canceled, the sinks get a fresh one to write the rest
+			s.write(context.Background(), batch)
+			s.closeSinks()
+			return ctx.Err()
+		}
+	}
+}
+
+// Log audits a request of a user to a data source. The body of the request
+// is stored, hashed or dropped depending on the configuration, body is nil
+// for requests without a body.
+func (s *AuditService) Log(c *m.ReqContext, ds *m.DataSource, source string, body *BodyRecorder, status int, duration time.Duration) {
+	if !s.enabled {
+		return
+	}
+
+	event := &m.AuditEvent{
+		Created:        time.Now(),
+		Source:         source,
+		OrgId:          c.OrgId,
+		UserId:         c.UserId,
+		UserLogin:      c.Login,
+		RemoteAddr:     c.RemoteAddr(),
+		DataSourceId:   ds.Id,
+		DataSourceName: ds.Name,
+		DataSourceType: ds.Type,
+		Method:         c.Req.Method,
+		Path:           c.Req.URL.Path,
+		Query:          c.Req.URL.RawQuery,
+		Status:         status,
+		DurationMs:     int64(duration / time.Millisecond),
+	}
+
+	if body != nil {
+		body.fill(event)
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		s.log.Error("Audit queue is full, dropping event", "user", event.UserLogin, "datasource", event.DataSourceName, "path", event.Path)
+	}
+}
+
+// write writes the batch to all sinks and returns the batch emptied for reuse.
+func (s *AuditService) write(ctx context.Context, batch []*m.AuditEvent) []*m.AuditEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	for _, sink := range s.sinks {
+		if err := sink.Write(ctx, batch); err != nil {
+			s.log.Error("Failed to write audit events", "sink", sink.Name(), "events", len(batch), "error", err)
+		}
+	}
+
+	return make([]*m.AuditEvent, 0, batchSize)
+}
+
+func (s *AuditService) deleteExpiredEvents() {
+	if s.retention <= 0 {
+		return
+	}
+
+	for _, sink := range s.sinks {
+		if _, ok := sink.(*databaseSink); !ok {
+			continue
+		}
+
+		cmd := m.DeleteExpiredAuditEventsCommand{OlderThan: time.Now().Add(-s.retention)}
+		if err := bus.Dispatch(&cmd); err != nil {
+			s.log.Error("Failed to delete expired audit events", "error", err)
+		} else if cmd.DeletedRows > 0 {
+			s.log.Debug("Deleted expired audit events", "rows", cmd.DeletedRows)
+		}
+	}
+}
+
+func (s *AuditService) closeSinks() {
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			s.log.Error("Failed to close audit sink", "sink", sink.Name(), "error", err)
+		}
+	}
+}
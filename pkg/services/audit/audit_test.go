@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func newTestService(settings map[string]string) *AuditService {
+	cfg := setting.NewCfg()
+	sec := cfg.Raw.Section("audit")
+	for key, value := range settings {
+		sec.NewKey(key, value)
+	}
+
+	service := &AuditService{Cfg: cfg}
+	So(service.Init(), ShouldBeNil)
+	return service
+}
+
+func newTestContext() *m.ReqContext {
+	req, _ := http.NewRequest("POST", "http://grafana.com/api/datasources/proxy/1/api/v1/query?time=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	return &m.ReqContext{
+		SignedInUser: &m.SignedInUser{UserId: 2, OrgId: 1, Login: "viewer"},
+		Context: &macaron.Context{
+			Req: macaron.Request{Request: req},
+		},
+	}
+}
+
+func newTestBody(service *AuditService, data string) *BodyRecorder {
+	body := service.NewBodyRecorder()
+	body.Write([]byte(data))
+	return body
+}
+
+func TestAuditService(t *testing.T) {
+	Convey("Audit service", t, func() {
+		ds := &m.DataSource{Id: 1, Name: "prom", Type: m.DS_PROMETHEUS}
+
+		Convey("Should not audit when disabled", func() {
+			service := newTestService(map[string]string{})
+			So(service.Enabled(), ShouldBeFalse)
+
+			service.Log(newTestContext(), ds, SourceProxy, nil, 200, time.Second)
+		})
+
+		Convey("Should reject unknown sinks", func() {
+			cfg := setting.NewCfg()
+			cfg.Raw.Section("audit").NewKey("enabled", "true")
+			cfg.Raw.Section("audit").NewKey("sinks", "unknown")
+
+			service := &AuditService{Cfg: cfg}
+			So(service.Init(), ShouldNotBeNil)
+		})
+
+		Convey("When auditing to the database", func() {
+			bus.ClearBusHandlers()
+			defer bus.ClearBusHandlers()
+
+			var saved []*m.AuditEvent
+			bus.AddHandler("test", func(cmd *m.SaveAuditEventsCommand) error {
+				saved = append(saved, cmd.Events...)
+				return nil
+			})
+
+			service := newTestService(map[string]string{"enabled": "true", "sinks": "database"})
+			service.Log(newTestContext(), ds, SourceProxy, newTestBody(service, "up"), 200, 1500*time.Millisecond)
+			service.write(context.Background(), []*m.AuditEvent{<-service.events})
+
+			So(len(saved), ShouldEqual, 1)
+			event := saved[0]
+
+			Convey("Should store who queried which data source", func() {
+				So(event.Source, ShouldEqual, SourceProxy)
+				So(event.OrgId, ShouldEqual, 1)
+				So(event.UserId, ShouldEqual, 2)
+				So(event.UserLogin, ShouldEqual, "viewer")
+				So(event.RemoteAddr, ShouldEqual, "10.0.0.1")
+				So(event.DataSourceId, ShouldEqual, 1)
+				So(event.DataSourceName, ShouldEqual, "prom")
+				So(event.Method, ShouldEqual, "POST")
+				So(event.Path, ShouldEqual, "/api/datasources/proxy/1/api/v1/query")
+				So(event.Query, ShouldEqual, "time=1")
+				So(event.Status, ShouldEqual, 200)
+				So(event.DurationMs, ShouldEqual, 1500)
+			})
+
+			Convey("Should only store the hash of the body by default", func() {
+				So(event.Body, ShouldEqual, "")
+				So(event.BodyHash, ShouldEqual, "75a288c0d6898c5f7b054590845978a82a3ad79fcce3d43ff68a7501e5a91ee9")
+			})
+		})
+
+		Convey("Should store truncated bodies when configured", func() {
+			service := newTestService(map[string]string{"enabled": "true", "sinks": "database", "body": "full", "max_body_size_kb": "1"})
+			body := service.NewBodyRecorder()
+			streamed, err := ioutil.ReadAll(body.Record(ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 2000)))))
+			So(err, ShouldBeNil)
+			So(len(streamed), ShouldEqual, 2000)
+
+			service.Log(newTestContext(), ds, SourceQuery, body, 200, time.Second)
+
+			event := <-service.events
+			So(len(event.Body), ShouldEqual, 1024)
+			So(event.BodyHash, ShouldEqual, "")
+		})
+
+		Convey("Should hash the whole streamed body", func() {
+			service := newTestService(map[string]string{"enabled": "true", "sinks": "database", "max_body_size_kb": "1"})
+
+			body := service.NewBodyRecorder()
+			_, err := ioutil.ReadAll(body.Record(ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 2000)))))
+			So(err, ShouldBeNil)
+
+			service.Log(newTestContext(), ds, SourceProxy, body, 403, time.Second)
+
+			event := <-service.events
+			So(event.Status, ShouldEqual, 403)
+			hash := sha256.Sum256([]byte(strings.Repeat("a", 2000)))
+			So(event.BodyHash, ShouldEqual, hex.EncodeToString(hash[:]))
+		})
+
+		Convey("Should write json lines to the file sink", func() {
+			dir, err := ioutil.TempDir("", "audit")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "audit.log")
+			service := newTestService(map[string]string{"enabled": "true", "sinks": "file", "file_path": path})
+			service.write(context.Background(), []*m.AuditEvent{{Source: SourceProxy, UserId: 1}, {Source: SourceQuery, UserId: 2}})
+			service.closeSinks()
+
+			content, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			So(len(lines), ShouldEqual, 2)
+
+			var event m.AuditEvent
+			So(json.Unmarshal([]byte(lines[1]), &event), ShouldBeNil)
+			So(event.Source, ShouldEqual, SourceQuery)
+			So(event.UserId, ShouldEqual, 2)
+		})
+
+		Convey("Should post the events to the webhook sink", func() {
+			var received []*m.AuditEvent
+			var user string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user, _, _ = r.BasicAuth()
+				json.NewDecoder(r.Body).Decode(&received)
+			}))
+			defer server.Close()
+
+			service := newTestService(map[string]string{"enabled": "true", "sinks": "webhook", "webhook_url": server.URL, "webhook_user": "audit"})
+			service.write(context.Background(), []*m.AuditEvent{{Source: SourceProxy, UserId: 1}})
+
+			So(len(received), ShouldEqual, 1)
+			So(received[0].UserId, ShouldEqual, 1)
+			So(user, ShouldEqual, "audit")
+		})
+	})
+}
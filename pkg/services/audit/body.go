@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+// BodyRecorder records the body of an audited request while it is passed on
+// to the data source. It hashes the whole body but keeps at most
+// max_body_size_kb of it, so large bodies are never buffered.
+type BodyRecorder struct {
+	mode   string
+	max    int
+	hash   hash.Hash
+	prefix []byte
+	size   int64
+	mutex  sync.Mutex
+}
+
+// NewBodyRecorder returns a recorder for the body of a request, the body is
+// written to it.
+func (s *AuditService) NewBodyRecorder() *BodyRecorder {
+	return &BodyRecorder{mode: s.bodyMode, max: s.maxBodySize, hash: sha256.New()}
+}
+
+func (r *BodyRecorder) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.size += int64(len(p))
+
+	switch r.mode {
+	case BodyHash:
+		r.hash.Write(p)
+	case BodyFull:
+		if free := r.max - len(r.prefix); free > 0 {
+			if len(p) > free {
+				r.prefix = append(r.prefix, p[:free]...)
+			} else {
+				r.prefix = append(r.prefix, p...)
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Record returns a body streaming body and recording it while it is read.
+func (r *BodyRecorder) Record(body io.ReadCloser) io.ReadCloser {
+	return &recordedBody{Reader: io.TeeReader(body, r), Closer: body}
+}
+
+func (r *BodyRecorder) fill(event *m.AuditEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.size == 0 {
+		return
+	}
+
+	switch r.mode {
+	case BodyHash:
+		event.BodyHash = hex.EncodeToString(r.hash.Sum(nil))
+	case BodyFull:
+		event.Body = string(r.prefix)
+	}
+}
+
+type recordedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// Sink is a destination of the audit log.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []*m.AuditEvent) error
+	Close() error
+}
+
+// SinkFactory creates a sink from the audit section of the configuration.
+type SinkFactory func(cfg *setting.Cfg, sec *ini.Section) (Sink, error)
+
+var sinkFactories = map[string]SinkFactory{
+	"file":     newFileSink,
+	"database": newDatabaseSink,
+	"webhook":  newWebhookSink,
+}
+
+// RegisterSink makes a sink available for the sinks setting of the audit
+// section.
+func RegisterSink(name string, factory SinkFactory) {
+	sinkFactories[name] = factory
+}
+
+// fileSink appends the events as json lines to a file.
+type fileSink struct {
+	path string
+	file *os.File
+	sync.Mutex
+}
+
+func newFileSink(cfg *setting.Cfg, sec *ini.Section) (Sink, error) {
+	path := sec.Key("file_path").MustString("audit.log")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.LogsPath, path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open audit log file: %v", err)
+	}
+
+	return &fileSink{path: path, file: file}, nil
+}
+
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+func (s *fileSink) Write(ctx context.Context, events []*m.AuditEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.file.Close()
+}
+
+// databaseSink stores the events in the audit_event table.
+type databaseSink struct{}
+
+func newDatabaseSink(cfg *setting.Cfg, sec *ini.Section) (Sink, error) {
+	return &databaseSink{}, nil
+}
+
+func (s *databaseSink) Name() string {
+	return "database"
+}
+
+func (s *databaseSink) Write(ctx context.Context, events []*m.AuditEvent) error {
+	return bus.Dispatch(&m.SaveAuditEventsCommand{Events: events})
+}
+
+func (s *databaseSink) Close() error {
+	return nil
+}
+
+// webhookSink posts the events as json array to a url.
+type webhookSink struct {
+	url      string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newWebhookSink(cfg *setting.Cfg, sec *ini.Section) (Sink, error) {
+	url := sec.Key("webhook_url").String()
+	if url == "" {
+		return nil, errors.New("Audit webhook sink requires webhook_url")
+	}
+
+	return &webhookSink{
+		url:      url,
+		user:     sec.Key("webhook_user").String(),
+		password: sec.Key("webhook_password").String(),
+		client: &http.Client{
+			Timeout:   time.Duration(sec.Key("webhook_timeout_seconds").MustInt(10)) * time.Second,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Write(ctx context.Context, events []*m.AuditEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Grafana")
+	if s.user != "" {
+		req.Header.Set("Authorization", util.GetBasicAuthHeader(s.user, s.password))
+	}
+
+	resp, err := ctxhttp.Do(ctx, s.client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Audit webhook returned status %d: %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
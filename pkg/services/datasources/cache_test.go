@@ -0,0 +1,49 @@
+package datasources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/cache"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDatasourceCache(t *testing.T) {
+	Convey("Datasource cache", t, func() {
+		testBus := bus.New()
+		testBus.AddHandler(func(query *m.GetDataSourceByIdQuery) error {
+			query.Result = &m.DataSource{Id: query.Id, OrgId: query.OrgId, Name: "test"}
+			return nil
+		})
+
+		dc := &CacheServiceImpl{Bus: testBus, CacheService: cache.New(time.Minute, time.Minute)}
+		user := &m.SignedInUser{OrgId: 1, UserId: 1}
+
+		Convey("Should return data sources without permission handler", func() {
+			ds, err := dc.GetDatasource(1, user, false)
+			So(err, ShouldBeNil)
+			So(ds.Name, ShouldEqual, "test")
+		})
+
+		Convey("Should check the permissions of cached data sources", func() {
+			allowed := true
+			testBus.AddHandler(func(query *m.DatasourcesPermissionFilterQuery) error {
+				query.Result = []*m.DataSource{}
+				if allowed {
+					query.Result = query.Datasources
+				}
+				return nil
+			})
+
+			_, err := dc.GetDatasource(1, user, false)
+			So(err, ShouldBeNil)
+
+			allowed = false
+			ds, err := dc.GetDatasource(1, user, false)
+			So(err, ShouldEqual, m.ErrDataSourceAccessDenied)
+			So(ds, ShouldBeNil)
+		})
+	})
+}
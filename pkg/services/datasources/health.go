@@ -0,0 +1,202 @@
+package datasources
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/metrics"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+func init() {
+	registry.RegisterService(&HealthCheckService{})
+}
+
+// HealthCheckService periodically checks the health of all data sources with
+// a backend, stores the results and exposes them as datasource_up metric.
+type HealthCheckService struct {
+	Cfg *setting.Cfg `inject:""`
+
+	log           log.Logger
+	enabled       bool
+	interval      time.Duration
+	timeout       time.Duration
+	retention     time.Duration
+	maxConcurrent int
+
+	mutex  sync.Mutex
+	labels map[int64][]string
+}
+
+func (s *HealthCheckService) Init() error {
+	s.log = log.New("datasources.health")
+	s.labels = make(map[int64][]string)
+
+	sec := s.Cfg.Raw.Section("datasource_health")
+	s.enabled = sec.Key("enabled").MustBool(true)
+	s.interval = time.Duration(sec.Key("interval_seconds").MustInt(300)) * time.Second
+	s.timeout = time.Duration(sec.Key("timeout_seconds").MustInt(30)) * time.Second
+	s.retention = time.Duration(sec.Key("history_days").MustInt(7)) * 24 * time.Hour
+	s.maxConcurrent = sec.Key("max_concurrent_checks").MustInt(10)
+
+	if s.interval < time.Second {
+		s.interval = time.Second
+	}
+	if s.maxConcurrent < 1 {
+		s.maxConcurrent = 1
+	}
+
+	return nil
+}
+
+// Run checks all data sources at startup and then periodically. The service
+// is not disabled with the background checks, the api checks data sources on
+// demand.
+func (s *HealthCheckService) Run(ctx context.Context) error {
+	if !s.enabled {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.checkAll(ctx)
+		s.deleteExpiredChecks()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// CheckHealth checks the health of a data source, stores the result and
+// updates its metric. It returns tsdb.ErrHealthCheckNotSupported for data
+// sources without backend.
+func (s *HealthCheckService) CheckHealth(ctx context.Context, ds *m.DataSource) (*tsdb.HealthCheckResult, *m.DataSourceHealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := tsdb.CheckHealth(ctx, ds)
+	if err != nil {
+		return nil, nil, err
+	}
+	duration := time.Since(start)
+
+	metrics.M_DataSource_Health_Check_Duration.Observe(float64(duration / time.Millisecond))
+	s.setUp(ds, result.Status == tsdb.HealthStatusOk)
+
+	cmd := m.SaveDataSourceHealthCheckCommand{
+		OrgId:        ds.OrgId,
+		DataSourceId: ds.Id,
+		Status:       string(result.Status),
+		Message:      result.Message,
+		DurationMs:   int64(duration / time.Millisecond),
+	}
+	if err := bus.Dispatch(&cmd); err != nil {
+		return result, nil, err
+	}
+
+	return result, cmd.Result, nil
+}
+
+func (s *HealthCheckService) checkAll(ctx context.Context) {
+	query := m.GetAllDataSourcesQuery{}
+	if err := bus.Dispatch(&query); err != nil {
+		s.log.Error("Failed to get data sources", "error", err)
+		return
+	}
+
+	// at most maxConcurrent data sources are checked at once, each check
+	// times out on its own
+	var wg sync.WaitGroup
+	var checkedMutex sync.Mutex
+	checked := make(map[int64]bool)
+	sem := make(chan struct{}, s.maxConcurrent)
+
+	for _, ds := range query.Result {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(ds *m.DataSource) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result, _, err := s.CheckHealth(ctx, ds)
+			if err == tsdb.ErrHealthCheckNotSupported {
+				return
+			}
+			if err != nil {
+				s.log.Error("Failed to save health check", "datasource", ds.Name, "error", err)
+			}
+			if result != nil && result.Status != tsdb.HealthStatusOk {
+				s.log.Warn("Data source is unhealthy", "datasource", ds.Name, "orgId", ds.OrgId, "message", result.Message)
+			}
+
+			checkedMutex.Lock()
+			checked[ds.Id] = true
+			checkedMutex.Unlock()
+		}(ds)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	// forget the metrics of deleted data sources
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, labels := range s.labels {
+		if !checked[id] {
+			metrics.M_DataSource_Up.DeleteLabelValues(labels...)
+			delete(s.labels, id)
+		}
+	}
+}
+
+func (s *HealthCheckService) setUp(ds *m.DataSource, up bool) {
+	labels := []string{strconv.FormatInt(ds.OrgId, 10), ds.Name, ds.Type}
+
+	s.mutex.Lock()
+	if previous, exists := s.labels[ds.Id]; exists && strings.Join(previous, ",") != strings.Join(labels, ",") {
+		metrics.M_DataSource_Up.DeleteLabelValues(previous...)
+	}
+	s.labels[ds.Id] = labels
+	s.mutex.Unlock()
+
+	value := 0.0
+	if up {
+		value = 1
+	}
+	metrics.M_DataSource_Up.WithLabelValues(labels...).Set(value)
+}
+
+func (s *HealthCheckService) deleteExpiredChecks() {
+	cmd := m.DeleteExpiredDataSourceHealthChecksCommand{OlderThan: time.Now().Add(-s.retention)}
+	if err := bus.Dispatch(&cmd); err != nil {
+		s.log.Error("Failed to delete expired health checks", "error", err)
+		return
+	}
+
+	s.log.Debug("Deleted expired health checks", "rows affected", cmd.DeletedRows)
+}
@@ -0,0 +1,166 @@
+package datasources
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeHealthEndpoint struct {
+	err   error
+	delay time.Duration
+	block bool
+
+	mutex         sync.Mutex
+	running       int
+	maxConcurrent int
+}
+
+func (e *fakeHealthEndpoint) Query(ctx context.Context, ds *m.DataSource, query *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	return &tsdb.Response{}, nil
+}
+
+func (e *fakeHealthEndpoint) CheckHealth(ctx context.Context, ds *m.DataSource) (*tsdb.HealthCheckResult, error) {
+	e.mutex.Lock()
+	e.running++
+	if e.running > e.maxConcurrent {
+		e.maxConcurrent = e.running
+	}
+	e.mutex.Unlock()
+
+	defer func() {
+		e.mutex.Lock()
+		e.running--
+		e.mutex.Unlock()
+	}()
+
+	if e.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	time.Sleep(e.delay)
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &tsdb.HealthCheckResult{Status: tsdb.HealthStatusOk, Message: "ok"}, nil
+}
+
+func TestHealthCheckService(t *testing.T) {
+	Convey("Data source health check service", t, func() {
+		bus.ClearBusHandlers()
+
+		endpoint := &fakeHealthEndpoint{}
+		tsdb.RegisterTsdbQueryEndpoint("health-fake", func(ds *m.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+			return endpoint, nil
+		})
+
+		dataSources := []*m.DataSource{
+			{Id: 1, OrgId: 1, Name: "backend", Type: "health-fake"},
+			{Id: 2, OrgId: 1, Name: "frontend", Type: "frontend-only"},
+		}
+		bus.AddHandler("test", func(query *m.GetAllDataSourcesQuery) error {
+			query.Result = dataSources
+			return nil
+		})
+
+		var savedMutex sync.Mutex
+		saved := []*m.SaveDataSourceHealthCheckCommand{}
+		bus.AddHandler("test", func(cmd *m.SaveDataSourceHealthCheckCommand) error {
+			savedMutex.Lock()
+			defer savedMutex.Unlock()
+			saved = append(saved, cmd)
+			cmd.Result = &m.DataSourceHealthCheck{DataSourceId: cmd.DataSourceId, Status: cmd.Status}
+			return nil
+		})
+
+		service := &HealthCheckService{Cfg: setting.NewCfg()}
+		So(service.Init(), ShouldBeNil)
+
+		Convey("Should check and store data sources with backend", func() {
+			service.checkAll(context.Background())
+
+			So(len(saved), ShouldEqual, 1)
+			So(saved[0].DataSourceId, ShouldEqual, 1)
+			So(saved[0].Status, ShouldEqual, "OK")
+			So(service.labels[1], ShouldResemble, []string{"1", "backend", "health-fake"})
+		})
+
+		Convey("Should store failed checks", func() {
+			endpoint.err = errors.New("connection refused")
+
+			result, check, err := service.CheckHealth(context.Background(), dataSources[0])
+			So(err, ShouldBeNil)
+			So(result.Status, ShouldEqual, tsdb.HealthStatusError)
+			So(check.Status, ShouldEqual, "ERROR")
+			So(saved[0].Message, ShouldEqual, "connection refused")
+		})
+
+		Convey("Should forget metrics of deleted data sources", func() {
+			service.checkAll(context.Background())
+			So(len(service.labels), ShouldEqual, 1)
+
+			dataSources = dataSources[1:]
+			service.checkAll(context.Background())
+			So(len(service.labels), ShouldEqual, 0)
+		})
+
+		Convey("Should check data sources in parallel up to the limit", func() {
+			dataSources = nil
+			for i := int64(1); i <= 5; i++ {
+				dataSources = append(dataSources, &m.DataSource{Id: i, OrgId: 1, Name: "backend", Type: "health-fake"})
+			}
+			endpoint.delay = 20 * time.Millisecond
+			service.maxConcurrent = 2
+
+			service.checkAll(context.Background())
+
+			So(len(saved), ShouldEqual, 5)
+			So(endpoint.maxConcurrent, ShouldEqual, 2)
+		})
+
+		Convey("Should time out checks on their own", func() {
+			endpoint.block = true
+			service.timeout = 20 * time.Millisecond
+
+			service.checkAll(context.Background())
+
+			So(len(saved), ShouldEqual, 1)
+			So(saved[0].Status, ShouldEqual, "ERROR")
+		})
+
+		Convey("Should check data sources at startup", func() {
+			bus.AddHandler("test", func(cmd *m.DeleteExpiredDataSourceHealthChecksCommand) error {
+				return nil
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- service.Run(ctx) }()
+
+			for i := 0; i < 100; i++ {
+				savedMutex.Lock()
+				count := len(saved)
+				savedMutex.Unlock()
+				if count > 0 {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+			So(<-done, ShouldEqual, context.Canceled)
+
+			savedMutex.Lock()
+			defer savedMutex.Unlock()
+			So(len(saved), ShouldEqual, 1)
+		})
+	})
+}
@@ -15,6 +15,9 @@ import (
 func (rs *RenderingService) startPlugin(ctx context.Context) error {
 	cmd := plugins.ComposePluginStartCommmand("plugin_start")
 	fullpath := path.Join(rs.pluginInfo.PluginDir, cmd)
+	if err := rs.pluginInfo.VerifyExecutable(fullpath); err != nil {
+		return err
+	}
 
 	var handshakeConfig = plugin.HandshakeConfig{
 		ProtocolVersion:  1,
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrDataKeyNotFound       = errors.New("Data key not found")
+	ErrNotInitialized        = errors.New("Secrets service is not initialized")
+	ErrInvalidEncryptedValue = errors.New("Invalid encrypted value")
+)
+
+// DataKey is a key encrypting secure json data. It is stored encrypted by
+// the key provider it was created with. The active data key encrypts new
+// values, the others are kept to decrypt existing ones.
+type DataKey struct {
+	Id            int64
+	Provider      string
+	EncryptedData string
+	Active        bool
+	Created       time.Time
+	Updated       time.Time
+}
+
+// ---------------------
+// QUERIES
+
+type GetDataKeyQuery struct {
+	Id     int64
+	Result *DataKey
+}
+
+type GetActiveDataKeyQuery struct {
+	Result *DataKey
+}
+
+type GetDataKeysQuery struct {
+	Result []*DataKey
+}
+
+// ----------------------
+// COMMANDS
+
+// AddDataKeyCommand adds a data key and makes it the active one.
+type AddDataKeyCommand struct {
+	Provider      string
+	EncryptedData string
+
+	Result *DataKey
+}
+
+// DeleteUnusedDataKeysCommand deletes the inactive data keys no encrypted
+// value references. Result is the number of deleted data keys.
+type DeleteUnusedDataKeysCommand struct {
+	Result int
+}
+
+// ReEncryptSecretsCommand replaces every encrypted value of the secure json
+// data of data sources and plugin settings with the value returned by
+// ReEncrypt, in one transaction.
+type ReEncryptSecretsCommand struct {
+	ReEncrypt func(payload []byte) ([]byte, error)
+
+	Result int
+}
@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// KeyProvider encrypts and decrypts data keys, like a key management
+// service. The master key never leaves the provider.
+type KeyProvider interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+type KeyProviderFactory func(cfg *setting.Cfg) (KeyProvider, error)
+
+var keyProviders = map[string]KeyProviderFactory{}
+
+// RegisterKeyProvider registers a key provider that can be selected with
+// encryption_provider in the security section of the config.
+func RegisterKeyProvider(name string, factory KeyProviderFactory) {
+	keyProviders[name] = factory
+}
+
+const SecretKeyProvider = "secret_key"
+
+func init() {
+	RegisterKeyProvider(SecretKeyProvider, newSecretKeyProvider)
+	RegisterKeyProvider("file", newFileKeyProvider)
+	RegisterKeyProvider("vault", newVaultKeyProvider)
+}
+
+// secretKeyProvider encrypts data keys with the secret_key of the config.
+type secretKeyProvider struct {
+	secret string
+}
+
+func newSecretKeyProvider(cfg *setting.Cfg) (KeyProvider, error) {
+	return &secretKeyProvider{secret: setting.SecretKey}, nil
+}
+
+func (p *secretKeyProvider) Encrypt(plaintext []byte) ([]byte, error) {
+	return util.Encrypt(plaintext, p.secret)
+}
+
+func (p *secretKeyProvider) Decrypt(ciphertext []byte) ([]byte, error) {
+	return util.Decrypt(ciphertext, p.secret)
+}
+
+// fileKeyProvider encrypts data keys with a master key read from a file. The
+// SHA-256 hash of the trimmed file content is used as AES-256 key.
+type fileKeyProvider struct {
+	key []byte
+}
+
+func newFileKeyProvider(cfg *setting.Cfg) (KeyProvider, error) {
+	path := cfg.Raw.Section("security.encryption.file").Key("key_file").String()
+	if path == "" {
+		return nil, errors.New("security.encryption.file: key_file is required")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("security.encryption.file: failed to read key file: %v", err)
+	}
+
+	master := strings.TrimSpace(string(content))
+	if master == "" {
+		return nil, fmt.Errorf("security.encryption.file: key file %s is empty", path)
+	}
+
+	key := sha256.Sum256([]byte(master))
+	return &fileKeyProvider{key: key[:]}, nil
+}
+
+func (p *fileKeyProvider) Encrypt(plaintext []byte) ([]byte, error) {
+	return encryptAESGCM(p.key, plaintext)
+}
+
+func (p *fileKeyProvider) Decrypt(ciphertext []byte) ([]byte, error) {
+	return decryptAESGCM(p.key, ciphertext)
+}
+
+// encryptAESGCM encrypts plaintext with a 32 byte key and prepends the nonce.
+func encryptAESGCM(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decryptAESGCM(key []byte, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrInvalidEncryptedValue
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,368 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+func init() {
+	registry.Register(&registry.Descriptor{
+		Name:         "SecretsService",
+		Instance:     &SecretsService{},
+		InitPriority: registry.Medium,
+	})
+}
+
+// envelopePrefix starts values encrypted with a data key, followed by the
+// data key id and another envelopePrefix. Values encrypted with the secret
+// key start with an alphanumeric salt.
+const envelopePrefix = '#'
+
+// activeDataKeyTTL is how long the id of the active data key is used before
+// it is read again, so a server picks up a data key rotated by the cli.
+const activeDataKeyTTL = time.Minute
+
+// service is the initialized secrets service, values are encrypted with the
+// secret key until it is set.
+var service *SecretsService
+
+// SecretsService encrypts secure json data with data keys (envelope
+// encryption). The data keys are encrypted by the configured key provider.
+type SecretsService struct {
+	Cfg *setting.Cfg `inject:""`
+
+	log          log.Logger
+	providerName string
+	mutex        sync.RWMutex
+	providers    map[string]KeyProvider
+	dataKeys     map[int64][]byte
+	activeKeyId  int64
+	activeKeyAt  time.Time
+}
+
+func (s *SecretsService) Init() error {
+	s.log = log.New("secrets")
+	s.providerName = s.Cfg.Raw.Section("security").Key("encryption_provider").MustString(SecretKeyProvider)
+	s.providers = make(map[string]KeyProvider)
+	s.dataKeys = make(map[int64][]byte)
+
+	provider, err := s.provider(s.providerName)
+	if err != nil {
+		return err
+	}
+
+	query := GetActiveDataKeyQuery{}
+	err = bus.Dispatch(&query)
+	if err != nil && err != ErrDataKeyNotFound {
+		return err
+	}
+
+	if err == nil && query.Result.Provider == s.providerName {
+		key, err := s.decryptDataKey(query.Result)
+		if err != nil {
+			return fmt.Errorf("Failed to decrypt active data key: %v", err)
+		}
+		s.activeKeyId = query.Result.Id
+		s.activeKeyAt = time.Now()
+		s.dataKeys[query.Result.Id] = key
+	} else {
+		if _, err := s.createDataKey(s.providerName, provider); err != nil {
+			return err
+		}
+		s.log.Info("Created data key", "id", s.activeKeyId, "provider", s.providerName)
+	}
+
+	service = s
+	return nil
+}
+
+// Encrypt encrypts a value of secure json data.
+func Encrypt(payload []byte) ([]byte, error) {
+	if service == nil {
+		return util.Encrypt(payload, setting.SecretKey)
+	}
+	return service.Encrypt(payload)
+}
+
+// Decrypt decrypts a value of secure json data encrypted with a data key or
+// the secret key.
+func Decrypt(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || payload[0] != envelopePrefix {
+		return util.Decrypt(payload, setting.SecretKey)
+	}
+	if service == nil {
+		return nil, ErrNotInitialized
+	}
+	return service.Decrypt(payload)
+}
+
+// RotateDataKey re-encrypts all secure json data with a new data key. The
+// previous data keys are deactivated, data keys deactivated by an earlier
+// rotation are deleted when no value is encrypted with them any more. The
+// grafana server must not run during the rotation. If secretKey is set the new data key is
+// encrypted with it instead of the secret_key of the config, which must
+// then be changed to it.
+func RotateDataKey(secretKey string) (int, error) {
+	if service == nil {
+		return 0, ErrNotInitialized
+	}
+	return service.RotateDataKey(secretKey)
+}
+
+func (s *SecretsService) Encrypt(payload []byte) ([]byte, error) {
+	id, key, err := s.activeDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptWithDataKey(id, key, payload)
+}
+
+// activeDataKey returns the id and the key of the active data key, the id is
+// read again from the database after activeDataKeyTTL.
+func (s *SecretsService) activeDataKey() (int64, []byte, error) {
+	s.mutex.RLock()
+	id, loaded := s.activeKeyId, s.activeKeyAt
+	s.mutex.RUnlock()
+
+	if time.Since(loaded) >= activeDataKeyTTL {
+		query := GetActiveDataKeyQuery{}
+		if err := bus.Dispatch(&query); err != nil {
+			s.log.Warn("Failed to read the active data key", "error", err)
+		} else {
+			id = query.Result.Id
+			s.mutex.Lock()
+			s.activeKeyId = id
+			s.activeKeyAt = time.Now()
+			s.mutex.Unlock()
+		}
+	}
+
+	key, err := s.dataKey(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to read the active data key: %v", err)
+	}
+
+	return id, key, nil
+}
+
+func encryptWithDataKey(id int64, key []byte, payload []byte) ([]byte, error) {
+	encrypted, err := encryptAESGCM(key, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("%c%d%c", envelopePrefix, id, envelopePrefix)
+	return append([]byte(prefix), encrypted...), nil
+}
+
+func (s *SecretsService) Decrypt(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || payload[0] != envelopePrefix {
+		return util.Decrypt(payload, setting.SecretKey)
+	}
+
+	id, encrypted, err := parseEnvelope(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.dataKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAESGCM(key, encrypted)
+}
+
+// DataKeyId returns the id of the data key a value is encrypted with. It
+// returns false for values encrypted with the secret key.
+func DataKeyId(payload []byte) (int64, bool) {
+	if len(payload) == 0 || payload[0] != envelopePrefix {
+		return 0, false
+	}
+
+	id, _, err := parseEnvelope(payload)
+	return id, err == nil
+}
+
+// parseEnvelope splits a value encrypted with a data key into the id of the
+// data key and the encrypted value.
+func parseEnvelope(payload []byte) (int64, []byte, error) {
+	end := bytes.IndexByte(payload[1:], envelopePrefix)
+	if end < 0 {
+		return 0, nil, ErrInvalidEncryptedValue
+	}
+
+	id, err := strconv.ParseInt(string(payload[1:end+1]), 10, 64)
+	if err != nil {
+		return 0, nil, ErrInvalidEncryptedValue
+	}
+
+	return id, payload[end+2:], nil
+}
+
+func (s *SecretsService) RotateDataKey(secretKey string) (int, error) {
+	provider, err := s.provider(s.providerName)
+	if err != nil {
+		return 0, err
+	}
+
+	if secretKey != "" {
+		if s.providerName != SecretKeyProvider {
+			return 0, fmt.Errorf("A new secret key can only be set with the %s encryption provider", SecretKeyProvider)
+		}
+		provider = &secretKeyProvider{secret: secretKey}
+	}
+
+	// the data keys deactivated by the previous rotation are only deleted
+	// now, a server started in between used the new data key
+	deleteCmd := DeleteUnusedDataKeysCommand{}
+	if err := bus.Dispatch(&deleteCmd); err != nil {
+		return 0, err
+	}
+	if deleteCmd.Result > 0 {
+		s.log.Info("Deleted unused data keys", "count", deleteCmd.Result)
+	}
+
+	// load every data key first, the secrets are re-encrypted in a
+	// transaction that must not read data keys
+	query := GetDataKeysQuery{}
+	if err := bus.Dispatch(&query); err != nil {
+		return 0, err
+	}
+	for _, dataKey := range query.Result {
+		if _, err := s.dataKey(dataKey.Id); err != nil {
+			return 0, fmt.Errorf("Failed to decrypt data key %d: %v", dataKey.Id, err)
+		}
+	}
+
+	id, err := s.createDataKey(s.providerName, provider)
+	if err != nil {
+		return 0, err
+	}
+	key, err := s.dataKey(id)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := ReEncryptSecretsCommand{
+		ReEncrypt: func(payload []byte) ([]byte, error) {
+			decrypted, err := s.Decrypt(payload)
+			if err != nil {
+				return nil, err
+			}
+			return encryptWithDataKey(id, key, decrypted)
+		},
+	}
+	if err := bus.Dispatch(&cmd); err != nil {
+		return 0, err
+	}
+
+	return cmd.Result, nil
+}
+
+// createDataKey adds a new random data key encrypted by provider and makes it
+// the active one.
+func (s *SecretsService) createDataKey(providerName string, provider KeyProvider) (int64, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return 0, err
+	}
+
+	encrypted, err := provider.Encrypt(key)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to encrypt data key: %v", err)
+	}
+
+	cmd := AddDataKeyCommand{
+		Provider:      providerName,
+		EncryptedData: base64.StdEncoding.EncodeToString(encrypted),
+	}
+	if err := bus.Dispatch(&cmd); err != nil {
+		return 0, err
+	}
+
+	s.mutex.Lock()
+	s.dataKeys[cmd.Result.Id] = key
+	s.activeKeyId = cmd.Result.Id
+	s.activeKeyAt = time.Now()
+	s.mutex.Unlock()
+
+	return cmd.Result.Id, nil
+}
+
+func (s *SecretsService) dataKey(id int64) ([]byte, error) {
+	s.mutex.RLock()
+	key, exists := s.dataKeys[id]
+	s.mutex.RUnlock()
+	if exists {
+		return key, nil
+	}
+
+	query := GetDataKeyQuery{Id: id}
+	if err := bus.Dispatch(&query); err != nil {
+		return nil, err
+	}
+
+	key, err := s.decryptDataKey(query.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.dataKeys[id] = key
+	s.mutex.Unlock()
+
+	return key, nil
+}
+
+func (s *SecretsService) decryptDataKey(dataKey *DataKey) ([]byte, error) {
+	provider, err := s.provider(dataKey.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(dataKey.EncryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.Decrypt(encrypted)
+}
+
+func (s *SecretsService) provider(name string) (KeyProvider, error) {
+	s.mutex.RLock()
+	provider, exists := s.providers[name]
+	s.mutex.RUnlock()
+	if exists {
+		return provider, nil
+	}
+
+	factory, exists := keyProviders[name]
+	if !exists {
+		return nil, fmt.Errorf("Unknown encryption provider %s", name)
+	}
+
+	provider, err := factory(s.Cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.providers[name] = provider
+	s.mutex.Unlock()
+
+	return provider, nil
+}
@@ -0,0 +1,363 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeDataKeyStore struct {
+	keys    []*DataKey
+	secrets map[string][]byte
+}
+
+func (s *fakeDataKeyStore) register() {
+	bus.AddHandler("test", func(query *GetDataKeyQuery) error {
+		for _, key := range s.keys {
+			if key.Id == query.Id {
+				query.Result = key
+				return nil
+			}
+		}
+		return ErrDataKeyNotFound
+	})
+
+	bus.AddHandler("test", func(query *GetActiveDataKeyQuery) error {
+		for _, key := range s.keys {
+			if key.Active {
+				query.Result = key
+				return nil
+			}
+		}
+		return ErrDataKeyNotFound
+	})
+
+	bus.AddHandler("test", func(query *GetDataKeysQuery) error {
+		query.Result = s.keys
+		return nil
+	})
+
+	bus.AddHandler("test", func(cmd *AddDataKeyCommand) error {
+		id := int64(1)
+		for _, key := range s.keys {
+			key.Active = false
+			if key.Id >= id {
+				id = key.Id + 1
+			}
+		}
+		cmd.Result = &DataKey{Id: id, Provider: cmd.Provider, EncryptedData: cmd.EncryptedData, Active: true}
+		s.keys = append(s.keys, cmd.Result)
+		return nil
+	})
+
+	bus.AddHandler("test", func(cmd *DeleteUnusedDataKeysCommand) error {
+		used := map[int64]bool{}
+		for _, value := range s.secrets {
+			if id, ok := DataKeyId(value); ok {
+				used[id] = true
+			}
+		}
+
+		keys := []*DataKey{}
+		for _, key := range s.keys {
+			if key.Active || used[key.Id] {
+				keys = append(keys, key)
+			} else {
+				cmd.Result++
+			}
+		}
+		s.keys = keys
+		return nil
+	})
+
+	bus.AddHandler("test", func(cmd *ReEncryptSecretsCommand) error {
+		for name, value := range s.secrets {
+			encrypted, err := cmd.ReEncrypt(value)
+			if err != nil {
+				return err
+			}
+			s.secrets[name] = encrypted
+			cmd.Result++
+		}
+		return nil
+	})
+}
+
+func TestSecretsService(t *testing.T) {
+	Convey("Secrets service", t, func() {
+		bus.ClearBusHandlers()
+		service = nil
+		setting.SecretKey = "secret"
+
+		store := &fakeDataKeyStore{secrets: map[string][]byte{}}
+		store.register()
+
+		Convey("Should encrypt with the secret key before init", func() {
+			encrypted, err := Encrypt([]byte("pwd"))
+			So(err, ShouldBeNil)
+
+			decrypted, err := util.Decrypt(encrypted, "secret")
+			So(err, ShouldBeNil)
+			So(string(decrypted), ShouldEqual, "pwd")
+		})
+
+		Convey("Given an initialized service", func() {
+			legacy, err := util.Encrypt([]byte("legacy"), "secret")
+			So(err, ShouldBeNil)
+
+			s := &SecretsService{Cfg: setting.NewCfg()}
+			So(s.Init(), ShouldBeNil)
+			defer func() { service = nil }()
+
+			So(len(store.keys), ShouldEqual, 1)
+			So(store.keys[0].Provider, ShouldEqual, SecretKeyProvider)
+
+			Convey("Should encrypt with the active data key", func() {
+				encrypted, err := Encrypt([]byte("pwd"))
+				So(err, ShouldBeNil)
+				So(string(encrypted), ShouldStartWith, "#1#")
+
+				decrypted, err := Decrypt(encrypted)
+				So(err, ShouldBeNil)
+				So(string(decrypted), ShouldEqual, "pwd")
+			})
+
+			Convey("Should decrypt values encrypted with the secret key", func() {
+				decrypted, err := Decrypt(legacy)
+				So(err, ShouldBeNil)
+				So(string(decrypted), ShouldEqual, "legacy")
+			})
+
+			Convey("Should decrypt with data keys of the store after restart", func() {
+				encrypted, err := Encrypt([]byte("pwd"))
+				So(err, ShouldBeNil)
+
+				restarted := &SecretsService{Cfg: setting.NewCfg()}
+				So(restarted.Init(), ShouldBeNil)
+				So(len(store.keys), ShouldEqual, 1)
+
+				decrypted, err := restarted.Decrypt(encrypted)
+				So(err, ShouldBeNil)
+				So(string(decrypted), ShouldEqual, "pwd")
+			})
+
+			Convey("Should reject malformed values", func() {
+				_, err := Decrypt([]byte("#abc"))
+				So(err, ShouldEqual, ErrInvalidEncryptedValue)
+
+				_, err = Decrypt([]byte("#9#abc"))
+				So(err, ShouldEqual, ErrDataKeyNotFound)
+			})
+
+			Convey("When rotating the data key", func() {
+				encrypted, err := Encrypt([]byte("pwd"))
+				So(err, ShouldBeNil)
+				store.secrets["envelope"] = encrypted
+				store.secrets["legacy"] = legacy
+
+				count, err := RotateDataKey("")
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 2)
+
+				Convey("Should re-encrypt all secrets with the new data key", func() {
+					So(len(store.keys), ShouldEqual, 2)
+					So(store.keys[1].Id, ShouldEqual, 2)
+					So(store.keys[1].Active, ShouldBeTrue)
+
+					for name, value := range map[string]string{"envelope": "pwd", "legacy": "legacy"} {
+						So(string(store.secrets[name]), ShouldStartWith, "#2#")
+
+						decrypted, err := Decrypt(store.secrets[name])
+						So(err, ShouldBeNil)
+						So(string(decrypted), ShouldEqual, value)
+					}
+				})
+
+				Convey("Should delete the unused previous data key on the next rotation", func() {
+					_, err := RotateDataKey("")
+					So(err, ShouldBeNil)
+
+					So(len(store.keys), ShouldEqual, 2)
+					So(store.keys[0].Id, ShouldEqual, 2)
+					So(store.keys[1].Id, ShouldEqual, 3)
+				})
+
+				Convey("Should encrypt with the new data key in a server started before the rotation", func() {
+					running := &SecretsService{
+						Cfg:         setting.NewCfg(),
+						log:         log.New("secrets"),
+						providers:   map[string]KeyProvider{},
+						dataKeys:    map[int64][]byte{},
+						activeKeyId: 1,
+						activeKeyAt: time.Now().Add(-activeDataKeyTTL),
+					}
+
+					encrypted, err := running.Encrypt([]byte("pwd"))
+					So(err, ShouldBeNil)
+					So(string(encrypted), ShouldStartWith, "#2#")
+				})
+			})
+
+			Convey("When a value is encrypted with the previous data key during the rotation", func() {
+				encrypted, err := Encrypt([]byte("pwd"))
+				So(err, ShouldBeNil)
+
+				bus.AddHandler("test", func(cmd *ReEncryptSecretsCommand) error {
+					store.secrets["written during rotation"] = encrypted
+					return nil
+				})
+
+				_, err = RotateDataKey("")
+				So(err, ShouldBeNil)
+
+				Convey("Should keep the previous data key deactivated", func() {
+					So(len(store.keys), ShouldEqual, 2)
+					So(store.keys[0].Active, ShouldBeFalse)
+					So(store.keys[1].Active, ShouldBeTrue)
+
+					_, err := RotateDataKey("")
+					So(err, ShouldBeNil)
+					So(store.keys[0].Id, ShouldEqual, 1)
+
+					restarted := &SecretsService{Cfg: setting.NewCfg()}
+					So(restarted.Init(), ShouldBeNil)
+
+					decrypted, err := restarted.Decrypt(encrypted)
+					So(err, ShouldBeNil)
+					So(string(decrypted), ShouldEqual, "pwd")
+				})
+			})
+
+			Convey("When rotating the secret key", func() {
+				store.secrets["legacy"] = legacy
+
+				_, err := RotateDataKey("new-secret")
+				So(err, ShouldBeNil)
+
+				Convey("Should encrypt the data key with the new secret key", func() {
+					setting.SecretKey = "new-secret"
+					restarted := &SecretsService{Cfg: setting.NewCfg()}
+					So(restarted.Init(), ShouldBeNil)
+
+					decrypted, err := restarted.Decrypt(store.secrets["legacy"])
+					So(err, ShouldBeNil)
+					So(string(decrypted), ShouldEqual, "legacy")
+				})
+			})
+		})
+
+		Convey("Should fail to init with an unknown provider", func() {
+			cfg := setting.NewCfg()
+			cfg.Raw.Section("security").Key("encryption_provider").SetValue("unknown")
+
+			s := &SecretsService{Cfg: cfg}
+			So(s.Init(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestKeyProviders(t *testing.T) {
+	Convey("File key provider", t, func() {
+		dir, err := ioutil.TempDir("", "secrets")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "master.key")
+		So(ioutil.WriteFile(path, []byte("master key\n"), 0600), ShouldBeNil)
+
+		cfg := setting.NewCfg()
+		cfg.Raw.Section("security.encryption.file").Key("key_file").SetValue(path)
+
+		provider, err := newFileKeyProvider(cfg)
+		So(err, ShouldBeNil)
+
+		encrypted, err := provider.Encrypt([]byte("data key"))
+		So(err, ShouldBeNil)
+
+		decrypted, err := provider.Decrypt(encrypted)
+		So(err, ShouldBeNil)
+		So(string(decrypted), ShouldEqual, "data key")
+
+		Convey("Should fail to decrypt with another key", func() {
+			So(ioutil.WriteFile(path, []byte("other key"), 0600), ShouldBeNil)
+			other, err := newFileKeyProvider(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = other.Decrypt(encrypted)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Should require a key file", func() {
+			_, err := newFileKeyProvider(setting.NewCfg())
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Vault key provider", t, func() {
+		var paths []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			if r.Header.Get("X-Vault-Token") != "token" {
+				w.WriteHeader(403)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+
+			// the fake transit engine "encrypts" by prefixing
+			if strings.HasSuffix(r.URL.Path, "/encrypt/grafana") {
+				w.Write([]byte(`{"data":{"ciphertext":"vault:v1:` + body["plaintext"] + `"}}`))
+				return
+			}
+			plaintext := strings.TrimPrefix(body["ciphertext"], "vault:v1:")
+			w.Write([]byte(`{"data":{"plaintext":"` + plaintext + `"}}`))
+		}))
+		defer server.Close()
+
+		cfg := setting.NewCfg()
+		sec := cfg.Raw.Section("security.encryption.vault")
+		sec.Key("url").SetValue(server.URL + "/")
+		sec.Key("token").SetValue("token")
+
+		provider, err := newVaultKeyProvider(cfg)
+		So(err, ShouldBeNil)
+
+		encrypted, err := provider.Encrypt([]byte("data key"))
+		So(err, ShouldBeNil)
+		So(string(encrypted), ShouldEqual, "vault:v1:"+base64.StdEncoding.EncodeToString([]byte("data key")))
+
+		decrypted, err := provider.Decrypt(encrypted)
+		So(err, ShouldBeNil)
+		So(string(decrypted), ShouldEqual, "data key")
+		So(paths, ShouldResemble, []string{"/v1/transit/encrypt/grafana", "/v1/transit/decrypt/grafana"})
+
+		Convey("Should return vault errors", func() {
+			sec.Key("token").SetValue("wrong")
+			provider, err := newVaultKeyProvider(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = provider.Encrypt([]byte("data key"))
+			So(err.Error(), ShouldEqual, "vault: encrypt failed: permission denied")
+		})
+
+		Convey("Should require url and token", func() {
+			_, err := newVaultKeyProvider(setting.NewCfg())
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// vaultKeyProvider encrypts data keys with the transit secrets engine of
+// HashiCorp Vault, or a server implementing its HTTP API.
+type vaultKeyProvider struct {
+	url       string
+	token     string
+	mount     string
+	keyName   string
+	namespace string
+	client    *http.Client
+}
+
+func newVaultKeyProvider(cfg *setting.Cfg) (KeyProvider, error) {
+	sec := cfg.Raw.Section("security.encryption.vault")
+
+	p := &vaultKeyProvider{
+		url:       strings.TrimSuffix(sec.Key("url").String(), "/"),
+		token:     sec.Key("token").String(),
+		mount:     strings.Trim(sec.Key("mount").MustString("transit"), "/"),
+		keyName:   sec.Key("key_name").MustString("grafana"),
+		namespace: sec.Key("namespace").String(),
+		client:    &http.Client{Timeout: time.Duration(sec.Key("timeout").MustInt(10)) * time.Second},
+	}
+
+	if p.url == "" {
+		return nil, errors.New("security.encryption.vault: url is required")
+	}
+	if p.token == "" {
+		return nil, errors.New("security.encryption.vault: token is required")
+	}
+
+	return p, nil
+}
+
+type vaultResponse struct {
+	Data struct {
+		Ciphertext string `json:"ciphertext"`
+		Plaintext  string `json:"plaintext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (p *vaultKeyProvider) Encrypt(plaintext []byte) ([]byte, error) {
+	res, err := p.post("encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Data.Ciphertext == "" {
+		return nil, errors.New("vault: response without ciphertext")
+	}
+
+	return []byte(res.Data.Ciphertext), nil
+}
+
+func (p *vaultKeyProvider) Decrypt(ciphertext []byte) ([]byte, error) {
+	res, err := p.post("decrypt", map[string]string{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(res.Data.Plaintext)
+}
+
+func (p *vaultKeyProvider) post(operation string, body map[string]string) (*vaultResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", p.url, p.mount, operation, p.keyName)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %v", err)
+	}
+	defer resp.Body.Close()
+
+	res := &vaultResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil && resp.StatusCode/100 == 2 {
+		return nil, fmt.Errorf("vault: failed to read response: %v", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		if len(res.Errors) > 0 {
+			return nil, fmt.Errorf("vault: %s failed: %s", operation, strings.Join(res.Errors, ", "))
+		}
+		return nil, fmt.Errorf("vault: %s failed with status %d", operation, resp.StatusCode)
+	}
+
+	return res, nil
+}
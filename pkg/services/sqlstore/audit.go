@@ -0,0 +1,35 @@
+package sqlstore
+
+import (
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func init() {
+	bus.AddHandler("sql", SaveAuditEvents)
+	bus.AddHandler("sql", DeleteExpiredAuditEvents)
+}
+
+func SaveAuditEvents(cmd *m.SaveAuditEventsCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		for _, event := range cmd.Events {
+			if _, err := sess.Insert(event); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func DeleteExpiredAuditEvents(cmd *m.DeleteExpiredAuditEventsCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		res, err := sess.Exec("DELETE FROM audit_event WHERE created < ?", cmd.OlderThan)
+		if err != nil {
+			return err
+		}
+
+		cmd.DeletedRows, err = res.RowsAffected()
+		return err
+	})
+}
@@ -0,0 +1,34 @@
+package sqlstore
+
+import (
+	"testing"
+	"time"
+
+	m "github.com/grafana/grafana/pkg/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuditEventDataAccess(t *testing.T) {
+	Convey("Testing audit events", t, func() {
+		InitTestDB(t)
+
+		cmd := m.SaveAuditEventsCommand{
+			Events: []*m.AuditEvent{
+				{Created: time.Now().Add(-48 * time.Hour), Source: "proxy", OrgId: 1, UserId: 1, DataSourceId: 1, Method: "GET", Path: "/api/datasources/proxy/1/render", Status: 200},
+				{Created: time.Now(), Source: "query", OrgId: 1, UserId: 1, DataSourceId: 1, Method: "POST", Path: "/api/tsdb/query", BodyHash: "hash", Status: 400},
+			},
+		}
+		So(SaveAuditEvents(&cmd), ShouldBeNil)
+
+		Convey("Should store the events", func() {
+			So(cmd.Events[0].Id, ShouldBeGreaterThan, 0)
+			So(cmd.Events[1].Id, ShouldBeGreaterThan, cmd.Events[0].Id)
+		})
+
+		Convey("Should delete expired events", func() {
+			deleteCmd := m.DeleteExpiredAuditEventsCommand{OlderThan: time.Now().Add(-24 * time.Hour)}
+			So(DeleteExpiredAuditEvents(&deleteCmd), ShouldBeNil)
+			So(deleteCmd.DeletedRows, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,256 @@
+package sqlstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/secrets"
+)
+
+func init() {
+	bus.AddHandler("sql", GetDataKey)
+	bus.AddHandler("sql", GetActiveDataKey)
+	bus.AddHandler("sql", GetDataKeys)
+	bus.AddHandler("sql", AddDataKey)
+	bus.AddHandler("sql", DeleteUnusedDataKeys)
+	bus.AddHandler("sql", ReEncryptSecrets)
+}
+
+func GetDataKey(query *secrets.GetDataKeyQuery) error {
+	dataKey := secrets.DataKey{Id: query.Id}
+	has, err := x.Get(&dataKey)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return secrets.ErrDataKeyNotFound
+	}
+
+	query.Result = &dataKey
+	return nil
+}
+
+func GetActiveDataKey(query *secrets.GetActiveDataKeyQuery) error {
+	dataKey := secrets.DataKey{}
+	has, err := x.Where("active = ?", true).Desc("id").Get(&dataKey)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return secrets.ErrDataKeyNotFound
+	}
+
+	query.Result = &dataKey
+	return nil
+}
+
+func GetDataKeys(query *secrets.GetDataKeysQuery) error {
+	query.Result = make([]*secrets.DataKey, 0)
+	return x.Asc("id").Find(&query.Result)
+}
+
+func AddDataKey(cmd *secrets.AddDataKeyCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		if _, err := sess.Exec("UPDATE data_key SET active = ?, updated = ? WHERE active = ?", false, time.Now(), true); err != nil {
+			return err
+		}
+
+		dataKey := &secrets.DataKey{
+			Provider:      cmd.Provider,
+			EncryptedData: cmd.EncryptedData,
+			Active:        true,
+			Created:       time.Now(),
+			Updated:       time.Now(),
+		}
+
+		if _, err := sess.Insert(dataKey); err != nil {
+			return err
+		}
+
+		cmd.Result = dataKey
+		return nil
+	})
+}
+
+func DeleteUnusedDataKeys(cmd *secrets.DeleteUnusedDataKeysCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		used, err := getUsedDataKeyIds(sess)
+		if err != nil {
+			return err
+		}
+
+		dataKeys := make([]*secrets.DataKey, 0)
+		if err := sess.Where("active = ?", false).Cols("id").Find(&dataKeys); err != nil {
+			return err
+		}
+
+		cmd.Result = 0
+		for _, dataKey := range dataKeys {
+			if used[dataKey.Id] {
+				continue
+			}
+			if _, err := sess.Exec("DELETE FROM data_key WHERE id = ? AND active = ?", dataKey.Id, false); err != nil {
+				return err
+			}
+			cmd.Result++
+		}
+
+		return nil
+	})
+}
+
+// getUsedDataKeyIds returns the ids of the data keys of all encrypted values.
+func getUsedDataKeyIds(sess *DBSession) (map[int64]bool, error) {
+	used := map[int64]bool{}
+	addSecureJsonData := func(data securejsondata.SecureJsonData) {
+		for _, value := range data {
+			if id, ok := secrets.DataKeyId(value); ok {
+				used[id] = true
+			}
+		}
+	}
+
+	dataSources := make([]*m.DataSource, 0)
+	if err := sess.Table("data_source").Cols("id", "secure_json_data").Find(&dataSources); err != nil {
+		return nil, err
+	}
+	for _, ds := range dataSources {
+		addSecureJsonData(ds.SecureJsonData)
+	}
+
+	pluginSettings := make([]*m.PluginSetting, 0)
+	if err := sess.Table("plugin_setting").Cols("id", "secure_json_data").Find(&pluginSettings); err != nil {
+		return nil, err
+	}
+	for _, ps := range pluginSettings {
+		addSecureJsonData(ps.SecureJsonData)
+	}
+
+	userAuths := make([]*m.UserAuth, 0)
+	if err := sess.Table("user_auth").Cols("id", "o_auth_access_token", "o_auth_refresh_token", "o_auth_token_type").Find(&userAuths); err != nil {
+		return nil, err
+	}
+	for _, ua := range userAuths {
+		for _, value := range []string{ua.OAuthAccessToken, ua.OAuthRefreshToken, ua.OAuthTokenType} {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			if id, ok := secrets.DataKeyId(decoded); ok {
+				used[id] = true
+			}
+		}
+	}
+
+	return used, nil
+}
+
+func ReEncryptSecrets(cmd *secrets.ReEncryptSecretsCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		cmd.Result = 0
+
+		dataSources := make([]*m.DataSource, 0)
+		if err := sess.Table("data_source").Cols("id", "secure_json_data").Find(&dataSources); err != nil {
+			return err
+		}
+
+		for _, ds := range dataSources {
+			count, err := reEncryptSecureJsonData(sess, "data_source", ds.Id, ds.SecureJsonData, cmd.ReEncrypt)
+			if err != nil {
+				return err
+			}
+			cmd.Result += count
+		}
+
+		pluginSettings := make([]*m.PluginSetting, 0)
+		if err := sess.Table("plugin_setting").Cols("id", "secure_json_data").Find(&pluginSettings); err != nil {
+			return err
+		}
+
+		for _, ps := range pluginSettings {
+			count, err := reEncryptSecureJsonData(sess, "plugin_setting", ps.Id, ps.SecureJsonData, cmd.ReEncrypt)
+			if err != nil {
+				return err
+			}
+			cmd.Result += count
+		}
+
+		userAuths := make([]*m.UserAuth, 0)
+		if err := sess.Table("user_auth").Cols("id", "o_auth_access_token", "o_auth_refresh_token", "o_auth_token_type").Find(&userAuths); err != nil {
+			return err
+		}
+
+		for _, ua := range userAuths {
+			count, err := reEncryptOAuthToken(sess, ua, cmd.ReEncrypt)
+			if err != nil {
+				return err
+			}
+			cmd.Result += count
+		}
+
+		return nil
+	})
+}
+
+func reEncryptSecureJsonData(sess *DBSession, table string, id int64, data securejsondata.SecureJsonData, reEncrypt func([]byte) ([]byte, error)) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	reEncrypted := make(securejsondata.SecureJsonData)
+	for key, value := range data {
+		encrypted, err := reEncrypt(value)
+		if err != nil {
+			return 0, err
+		}
+		reEncrypted[key] = encrypted
+	}
+
+	content, err := json.Marshal(reEncrypted)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := sess.Exec("UPDATE "+table+" SET secure_json_data = ? WHERE id = ?", string(content), id); err != nil {
+		return 0, err
+	}
+
+	return len(reEncrypted), nil
+}
+
+func reEncryptOAuthToken(sess *DBSession, ua *m.UserAuth, reEncrypt func([]byte) ([]byte, error)) (int, error) {
+	count := 0
+	values := []*string{&ua.OAuthAccessToken, &ua.OAuthRefreshToken, &ua.OAuthTokenType}
+	for _, value := range values {
+		if *value == "" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(*value)
+		if err != nil {
+			return 0, err
+		}
+
+		encrypted, err := reEncrypt(decoded)
+		if err != nil {
+			return 0, err
+		}
+
+		*value = base64.StdEncoding.EncodeToString(encrypted)
+		count++
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	_, err := sess.Exec("UPDATE user_auth SET o_auth_access_token = ?, o_auth_refresh_token = ?, o_auth_token_type = ? WHERE id = ?",
+		ua.OAuthAccessToken, ua.OAuthRefreshToken, ua.OAuthTokenType, ua.Id)
+	return count, err
+}
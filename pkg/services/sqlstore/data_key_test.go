@@ -0,0 +1,101 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/secrets"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDataKeyDataAccess(t *testing.T) {
+	Convey("Testing data keys", t, func() {
+		InitTestDB(t)
+
+		Convey("Should return not found without data keys", func() {
+			err := GetActiveDataKey(&secrets.GetActiveDataKeyQuery{})
+			So(err, ShouldEqual, secrets.ErrDataKeyNotFound)
+		})
+
+		Convey("Given two added data keys", func() {
+			first := secrets.AddDataKeyCommand{Provider: "secret_key", EncryptedData: "first"}
+			So(AddDataKey(&first), ShouldBeNil)
+			second := secrets.AddDataKeyCommand{Provider: "file", EncryptedData: "second"}
+			So(AddDataKey(&second), ShouldBeNil)
+
+			Convey("The last one should be active", func() {
+				query := secrets.GetActiveDataKeyQuery{}
+				So(GetActiveDataKey(&query), ShouldBeNil)
+				So(query.Result.Id, ShouldEqual, second.Result.Id)
+				So(query.Result.Provider, ShouldEqual, "file")
+			})
+
+			Convey("Both can be read by id", func() {
+				query := secrets.GetDataKeyQuery{Id: first.Result.Id}
+				So(GetDataKey(&query), ShouldBeNil)
+				So(query.Result.EncryptedData, ShouldEqual, "first")
+				So(query.Result.Active, ShouldBeFalse)
+			})
+
+			Convey("Can delete the inactive unused ones", func() {
+				cmd := secrets.DeleteUnusedDataKeysCommand{}
+				So(DeleteUnusedDataKeys(&cmd), ShouldBeNil)
+				So(cmd.Result, ShouldEqual, 1)
+
+				query := secrets.GetDataKeysQuery{}
+				So(GetDataKeys(&query), ShouldBeNil)
+				So(len(query.Result), ShouldEqual, 1)
+				So(query.Result[0].Id, ShouldEqual, second.Result.Id)
+			})
+
+			Convey("Should keep inactive data keys still in use", func() {
+				addCmd := m.AddDataSourceCommand{OrgId: 1, Name: "old key", Type: m.DS_INFLUXDB, Access: m.DS_ACCESS_PROXY, Url: "http://test"}
+				So(AddDataSource(&addCmd), ShouldBeNil)
+
+				data, err := json.Marshal(securejsondata.SecureJsonData{"password": []byte(fmt.Sprintf("#%d#encrypted", first.Result.Id))})
+				So(err, ShouldBeNil)
+				_, err = x.Exec("UPDATE data_source SET secure_json_data = ? WHERE id = ?", string(data), addCmd.Result.Id)
+				So(err, ShouldBeNil)
+
+				cmd := secrets.DeleteUnusedDataKeysCommand{}
+				So(DeleteUnusedDataKeys(&cmd), ShouldBeNil)
+				So(cmd.Result, ShouldEqual, 0)
+
+				query := secrets.GetDataKeysQuery{}
+				So(GetDataKeys(&query), ShouldBeNil)
+				So(len(query.Result), ShouldEqual, 2)
+			})
+		})
+
+		Convey("Can re-encrypt secure json data", func() {
+			err := AddDataSource(&m.AddDataSourceCommand{
+				OrgId:          1,
+				Name:           "secure",
+				Type:           m.DS_INFLUXDB,
+				Access:         m.DS_ACCESS_PROXY,
+				Url:            "http://test",
+				SecureJsonData: map[string]string{"password": "pwd", "token": "abc"},
+			})
+			So(err, ShouldBeNil)
+
+			cmd := secrets.ReEncryptSecretsCommand{
+				ReEncrypt: func(payload []byte) ([]byte, error) {
+					decrypted, err := secrets.Decrypt(payload)
+					if err != nil {
+						return nil, err
+					}
+					return secrets.Encrypt([]byte("re-" + string(decrypted)))
+				},
+			}
+			So(ReEncryptSecrets(&cmd), ShouldBeNil)
+			So(cmd.Result, ShouldEqual, 2)
+
+			query := m.GetDataSourceByNameQuery{OrgId: 1, Name: "secure"}
+			So(GetDataSourceByName(&query), ShouldBeNil)
+			So(query.Result.SecureJsonData.Decrypt(), ShouldResemble, map[string]string{"password": "re-pwd", "token": "re-abc"})
+		})
+	})
+}
@@ -68,6 +68,13 @@ func GetAllDataSources(query *m.GetAllDataSourcesQuery) error {
 
 func DeleteDataSourceById(cmd *m.DeleteDataSourceByIdCommand) error {
 	return inTransaction(func(sess *DBSession) error {
+		if _, err := sess.Exec("DELETE FROM data_source_health_check WHERE data_source_id=? and org_id=?", cmd.Id, cmd.OrgId); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM data_source_acl WHERE data_source_id=? and org_id=?", cmd.Id, cmd.OrgId); err != nil {
+			return err
+		}
+
 		var rawSql = "DELETE FROM data_source WHERE id=? and org_id=?"
 		result, err := sess.Exec(rawSql, cmd.Id, cmd.OrgId)
 		affected, _ := result.RowsAffected()
@@ -78,6 +85,13 @@ func DeleteDataSourceById(cmd *m.DeleteDataSourceByIdCommand) error {
 
 func DeleteDataSourceByName(cmd *m.DeleteDataSourceByNameCommand) error {
 	return inTransaction(func(sess *DBSession) error {
+		if _, err := sess.Exec("DELETE FROM data_source_health_check WHERE data_source_id IN (SELECT id FROM data_source WHERE name=? and org_id=?)", cmd.Name, cmd.OrgId); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM data_source_acl WHERE data_source_id IN (SELECT id FROM data_source WHERE name=? and org_id=?)", cmd.Name, cmd.OrgId); err != nil {
+			return err
+		}
+
 		var rawSql = "DELETE FROM data_source WHERE name=? and org_id=?"
 		result, err := sess.Exec(rawSql, cmd.Name, cmd.OrgId)
 		affected, _ := result.RowsAffected()
@@ -96,25 +110,23 @@ func AddDataSource(cmd *m.AddDataSourceCommand) error {
 		}
 
 		ds := &m.DataSource{
-			OrgId:             cmd.OrgId,
-			Name:              cmd.Name,
-			Type:              cmd.Type,
-			Access:            cmd.Access,
-			Url:               cmd.Url,
-			User:              cmd.User,
-			Password:          cmd.Password,
-			Database:          cmd.Database,
-			IsDefault:         cmd.IsDefault,
-			BasicAuth:         cmd.BasicAuth,
-			BasicAuthUser:     cmd.BasicAuthUser,
-			BasicAuthPassword: cmd.BasicAuthPassword,
-			WithCredentials:   cmd.WithCredentials,
-			JsonData:          cmd.JsonData,
-			SecureJsonData:    securejsondata.GetEncryptedJsonData(cmd.SecureJsonData),
-			Created:           time.Now(),
-			Updated:           time.Now(),
-			Version:           1,
-			ReadOnly:          cmd.ReadOnly,
+			OrgId:           cmd.OrgId,
+			Name:            cmd.Name,
+			Type:            cmd.Type,
+			Access:          cmd.Access,
+			Url:             cmd.Url,
+			User:            cmd.User,
+			Database:        cmd.Database,
+			IsDefault:       cmd.IsDefault,
+			BasicAuth:       cmd.BasicAuth,
+			BasicAuthUser:   cmd.BasicAuthUser,
+			WithCredentials: cmd.WithCredentials,
+			JsonData:        cmd.JsonData,
+			SecureJsonData:  securejsondata.GetEncryptedJsonData(m.SecureJsonDataWithPasswords(cmd.SecureJsonData, cmd.Password, cmd.BasicAuthPassword)),
+			Created:         time.Now(),
+			Updated:         time.Now(),
+			Version:         1,
+			ReadOnly:        cmd.ReadOnly,
 		}
 
 		if _, err := sess.Insert(ds); err != nil {
@@ -143,25 +155,23 @@ func updateIsDefaultFlag(ds *m.DataSource, sess *DBSession) error {
 func UpdateDataSource(cmd *m.UpdateDataSourceCommand) error {
 	return inTransaction(func(sess *DBSession) error {
 		ds := &m.DataSource{
-			Id:                cmd.Id,
-			OrgId:             cmd.OrgId,
-			Name:              cmd.Name,
-			Type:              cmd.Type,
-			Access:            cmd.Access,
-			Url:               cmd.Url,
-			User:              cmd.User,
-			Password:          cmd.Password,
-			Database:          cmd.Database,
-			IsDefault:         cmd.IsDefault,
-			BasicAuth:         cmd.BasicAuth,
-			BasicAuthUser:     cmd.BasicAuthUser,
-			BasicAuthPassword: cmd.BasicAuthPassword,
-			WithCredentials:   cmd.WithCredentials,
-			JsonData:          cmd.JsonData,
-			SecureJsonData:    securejsondata.GetEncryptedJsonData(cmd.SecureJsonData),
-			Updated:           time.Now(),
-			ReadOnly:          cmd.ReadOnly,
-			Version:           cmd.Version + 1,
+			Id:              cmd.Id,
+			OrgId:           cmd.OrgId,
+			Name:            cmd.Name,
+			Type:            cmd.Type,
+			Access:          cmd.Access,
+			Url:             cmd.Url,
+			User:            cmd.User,
+			Database:        cmd.Database,
+			IsDefault:       cmd.IsDefault,
+			BasicAuth:       cmd.BasicAuth,
+			BasicAuthUser:   cmd.BasicAuthUser,
+			WithCredentials: cmd.WithCredentials,
+			JsonData:        cmd.JsonData,
+			SecureJsonData:  securejsondata.GetEncryptedJsonData(m.SecureJsonDataWithPasswords(cmd.SecureJsonData, cmd.Password, cmd.BasicAuthPassword)),
+			Updated:         time.Now(),
+			ReadOnly:        cmd.ReadOnly,
+			Version:         cmd.Version + 1,
 		}
 
 		sess.UseBool("is_default")
@@ -0,0 +1,158 @@
+package sqlstore
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func init() {
+	bus.AddHandler("sql", UpdateDataSourceAcl)
+	bus.AddHandler("sql", GetDataSourceAclInfoList)
+	bus.AddHandler("sql", FilterDataSourcesByPermission)
+}
+
+func UpdateDataSourceAcl(cmd *m.UpdateDataSourceAclCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		// delete existing items
+		_, err := sess.Exec("DELETE FROM data_source_acl WHERE data_source_id=? and org_id=?", cmd.DataSourceId, cmd.OrgId)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range cmd.Items {
+			if item.UserId == 0 && item.TeamId == 0 {
+				return m.ErrDataSourceAclInfoMissing
+			}
+
+			if item.Permission != m.DsPermissionQuery && item.Permission != m.DsPermissionAdmin {
+				return m.ErrDataSourceAclPermissionInvalid
+			}
+
+			if item.DataSourceId == 0 {
+				return m.ErrDataSourceAclDataSourceEmpty
+			}
+
+			if item.UserId > 0 {
+				has, err := sess.Where("org_id = ? AND user_id = ?", cmd.OrgId, item.UserId).Get(&m.OrgUser{})
+				if err != nil {
+					return err
+				}
+				if !has {
+					return m.ErrDataSourceAclUserNotInOrg
+				}
+			}
+
+			if item.TeamId > 0 {
+				has, err := sess.Where("org_id = ? AND id = ?", cmd.OrgId, item.TeamId).Get(&m.Team{})
+				if err != nil {
+					return err
+				}
+				if !has {
+					return m.ErrDataSourceAclTeamNotInOrg
+				}
+			}
+
+			sess.Nullable("user_id", "team_id")
+			if _, err := sess.Insert(item); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetDataSourceAclInfoList returns the permissions of a data source with the
+// names of their users and teams.
+func GetDataSourceAclInfoList(query *m.GetDataSourceAclInfoListQuery) error {
+	rawSQL := `
+		SELECT
+			da.org_id,
+			da.data_source_id,
+			da.user_id,
+			da.team_id,
+			da.permission,
+			da.created,
+			da.updated,
+			u.login AS user_login,
+			u.email AS user_email,
+			ug.name AS team,
+			ug.email AS team_email
+		FROM data_source_acl AS da
+			LEFT JOIN ` + dialect.Quote("user") + ` AS u ON u.id = da.user_id
+			LEFT JOIN team ug on ug.id = da.team_id
+		WHERE da.org_id = ? AND da.data_source_id = ?
+		ORDER BY da.id ASC
+		`
+
+	query.Result = make([]*m.DataSourceAclInfoDTO, 0)
+	if err := x.SQL(rawSQL, query.OrgId, query.DataSourceId).Find(&query.Result); err != nil {
+		return err
+	}
+
+	for _, p := range query.Result {
+		p.PermissionName = p.Permission.String()
+	}
+
+	return nil
+}
+
+// FilterDataSourcesByPermission returns the data sources the user has the
+// permission for, through its own or its teams permissions. Data sources
+// without permissions can be queried by every member of the org, org admins
+// have every permission.
+func FilterDataSourcesByPermission(query *m.DatasourcesPermissionFilterQuery) error {
+	if query.User == nil || query.User.OrgRole == m.ROLE_ADMIN || len(query.Datasources) == 0 {
+		query.Result = query.Datasources
+		return nil
+	}
+
+	permission := query.Permission
+	if permission == m.DsPermissionNoAccess {
+		permission = m.DsPermissionQuery
+	}
+
+	params := []interface{}{query.User.OrgId}
+	ids := make([]string, 0, len(query.Datasources))
+	for _, ds := range query.Datasources {
+		ids = append(ids, "?")
+		params = append(params, ds.Id)
+	}
+	params = append(params, query.User.UserId, query.User.UserId, permission)
+
+	rawSQL := `
+		SELECT ds.id FROM data_source AS ds
+		WHERE ds.org_id = ? AND ds.id IN (` + strings.Join(ids, ",") + `) AND (`
+
+	if permission == m.DsPermissionQuery {
+		rawSQL += `NOT EXISTS (SELECT 1 FROM data_source_acl AS da WHERE da.data_source_id = ds.id) OR `
+	}
+
+	rawSQL += `EXISTS (
+				SELECT 1 FROM data_source_acl AS da
+					LEFT JOIN team_member AS tm ON tm.team_id = da.team_id
+				WHERE da.data_source_id = ds.id AND (da.user_id = ? OR tm.user_id = ?) AND da.permission >= ?
+			)
+		)`
+
+	var allowed []int64
+	if err := x.SQL(rawSQL, params...).Find(&allowed); err != nil {
+		return err
+	}
+
+	allowedIds := make(map[int64]bool, len(allowed))
+	for _, id := range allowed {
+		allowedIds[id] = true
+	}
+
+	query.Result = make([]*m.DataSource, 0, len(allowed))
+	for _, ds := range query.Datasources {
+		if allowedIds[ds.Id] {
+			query.Result = append(query.Result, ds)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func TestDataSourceAclDataAccess(t *testing.T) {
+	Convey("Testing data source acl", t, func() {
+		InitTestDB(t)
+
+		var users []*m.SignedInUser
+		for i := 0; i < 3; i++ {
+			userCmd := &m.CreateUserCommand{
+				Email: fmt.Sprint("user", i, "@test.com"),
+				Name:  fmt.Sprint("user", i),
+				Login: fmt.Sprint("loginuser", i),
+			}
+			So(CreateUser(context.Background(), userCmd), ShouldBeNil)
+			users = append(users, &m.SignedInUser{OrgId: 1, UserId: userCmd.Result.Id, OrgRole: m.ROLE_VIEWER})
+		}
+
+		team := m.CreateTeamCommand{OrgId: 1, Name: "team", Email: "team@test.com"}
+		So(CreateTeam(&team), ShouldBeNil)
+		So(AddTeamMember(&m.AddTeamMemberCommand{OrgId: 1, TeamId: team.Result.Id, UserId: users[1].UserId}), ShouldBeNil)
+
+		var dataSources []*m.DataSource
+		for _, name := range []string{"restricted", "open"} {
+			cmd := m.AddDataSourceCommand{OrgId: 1, Name: name, Type: m.DS_GRAPHITE, Access: m.DS_ACCESS_PROXY, Url: "http://test"}
+			So(AddDataSource(&cmd), ShouldBeNil)
+			dataSources = append(dataSources, cmd.Result)
+		}
+		restricted := dataSources[0]
+
+		err := UpdateDataSourceAcl(&m.UpdateDataSourceAclCommand{
+			OrgId:        1,
+			DataSourceId: restricted.Id,
+			Items: []*m.DataSourceAcl{
+				{OrgId: 1, DataSourceId: restricted.Id, UserId: users[0].UserId, Permission: m.DsPermissionQuery, Created: time.Now(), Updated: time.Now()},
+				{OrgId: 1, DataSourceId: restricted.Id, TeamId: team.Result.Id, Permission: m.DsPermissionAdmin, Created: time.Now(), Updated: time.Now()},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		filter := func(user *m.SignedInUser, permission m.DsPermissionType) []string {
+			query := m.DatasourcesPermissionFilterQuery{User: user, Datasources: dataSources, Permission: permission}
+			So(FilterDataSourcesByPermission(&query), ShouldBeNil)
+
+			names := []string{}
+			for _, ds := range query.Result {
+				names = append(names, ds.Name)
+			}
+			return names
+		}
+
+		Convey("Should return the permissions with user and team names", func() {
+			query := m.GetDataSourceAclInfoListQuery{OrgId: 1, DataSourceId: restricted.Id}
+			So(GetDataSourceAclInfoList(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 2)
+
+			So(query.Result[0].UserLogin, ShouldEqual, "loginuser0")
+			So(query.Result[0].PermissionName, ShouldEqual, "Query")
+			So(query.Result[1].Team, ShouldEqual, "team")
+			So(query.Result[1].PermissionName, ShouldEqual, "Admin")
+		})
+
+		Convey("Should allow users with a permission to query", func() {
+			So(filter(users[0], m.DsPermissionQuery), ShouldResemble, []string{"restricted", "open"})
+			So(filter(users[1], m.DsPermissionQuery), ShouldResemble, []string{"restricted", "open"})
+		})
+
+		Convey("Should only allow other users to query data sources without permissions", func() {
+			So(filter(users[2], m.DsPermissionQuery), ShouldResemble, []string{"open"})
+		})
+
+		Convey("Should only allow org admins and users with admin permission to administrate", func() {
+			So(filter(users[0], m.DsPermissionAdmin), ShouldResemble, []string{})
+			So(filter(users[1], m.DsPermissionAdmin), ShouldResemble, []string{"restricted"})
+
+			admin := &m.SignedInUser{OrgId: 1, UserId: users[2].UserId, OrgRole: m.ROLE_ADMIN}
+			So(filter(admin, m.DsPermissionAdmin), ShouldResemble, []string{"restricted", "open"})
+		})
+
+		Convey("Should not allow users of other orgs", func() {
+			other := &m.SignedInUser{OrgId: 2, UserId: users[0].UserId, OrgRole: m.ROLE_VIEWER}
+			So(filter(other, m.DsPermissionQuery), ShouldResemble, []string{})
+		})
+
+		Convey("Should reject invalid permissions", func() {
+			err := UpdateDataSourceAcl(&m.UpdateDataSourceAclCommand{
+				OrgId:        1,
+				DataSourceId: restricted.Id,
+				Items:        []*m.DataSourceAcl{{OrgId: 1, DataSourceId: restricted.Id, UserId: users[2].UserId}},
+			})
+			So(err, ShouldEqual, m.ErrDataSourceAclPermissionInvalid)
+
+			err = UpdateDataSourceAcl(&m.UpdateDataSourceAclCommand{
+				OrgId:        1,
+				DataSourceId: restricted.Id,
+				Items:        []*m.DataSourceAcl{{OrgId: 1, DataSourceId: restricted.Id, Permission: m.DsPermissionQuery}},
+			})
+			So(err, ShouldEqual, m.ErrDataSourceAclInfoMissing)
+		})
+
+		Convey("Should reject users and teams of other orgs", func() {
+			otherTeam := m.CreateTeamCommand{OrgId: 2, Name: "other team", Email: "other@test.com"}
+			So(CreateTeam(&otherTeam), ShouldBeNil)
+
+			err := UpdateDataSourceAcl(&m.UpdateDataSourceAclCommand{
+				OrgId:        1,
+				DataSourceId: restricted.Id,
+				Items:        []*m.DataSourceAcl{{OrgId: 1, DataSourceId: restricted.Id, TeamId: otherTeam.Result.Id, Permission: m.DsPermissionAdmin}},
+			})
+			So(err, ShouldEqual, m.ErrDataSourceAclTeamNotInOrg)
+
+			err = UpdateDataSourceAcl(&m.UpdateDataSourceAclCommand{
+				OrgId:        1,
+				DataSourceId: restricted.Id,
+				Items:        []*m.DataSourceAcl{{OrgId: 1, DataSourceId: restricted.Id, UserId: 9999, Permission: m.DsPermissionQuery}},
+			})
+			So(err, ShouldEqual, m.ErrDataSourceAclUserNotInOrg)
+
+			query := m.GetDataSourceAclInfoListQuery{OrgId: 1, DataSourceId: restricted.Id}
+			So(GetDataSourceAclInfoList(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 2)
+		})
+
+		Convey("Should delete the permissions of deleted teams", func() {
+			So(DeleteTeam(&m.DeleteTeamCommand{OrgId: 1, Id: team.Result.Id}), ShouldBeNil)
+
+			query := m.GetDataSourceAclInfoListQuery{OrgId: 1, DataSourceId: restricted.Id}
+			So(GetDataSourceAclInfoList(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 1)
+		})
+
+		Convey("Should delete the permissions of deleted data sources", func() {
+			So(DeleteDataSourceById(&m.DeleteDataSourceByIdCommand{OrgId: 1, Id: restricted.Id}), ShouldBeNil)
+
+			query := m.GetDataSourceAclInfoListQuery{OrgId: 1, DataSourceId: restricted.Id}
+			So(GetDataSourceAclInfoList(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 0)
+		})
+	})
+}
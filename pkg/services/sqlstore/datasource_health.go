@@ -0,0 +1,60 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func init() {
+	bus.AddHandler("sql", SaveDataSourceHealthCheck)
+	bus.AddHandler("sql", GetDataSourceHealthChecks)
+	bus.AddHandler("sql", DeleteExpiredDataSourceHealthChecks)
+}
+
+func SaveDataSourceHealthCheck(cmd *m.SaveDataSourceHealthCheckCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		check := &m.DataSourceHealthCheck{
+			OrgId:        cmd.OrgId,
+			DataSourceId: cmd.DataSourceId,
+			Status:       cmd.Status,
+			Message:      cmd.Message,
+			DurationMs:   cmd.DurationMs,
+			Created:      time.Now(),
+		}
+
+		if _, err := sess.Insert(check); err != nil {
+			return err
+		}
+
+		cmd.Result = check
+		return nil
+	})
+}
+
+func GetDataSourceHealthChecks(query *m.GetDataSourceHealthChecksQuery) error {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query.Result = make([]*m.DataSourceHealthCheck, 0)
+	return x.Where("org_id = ? AND data_source_id = ?", query.OrgId, query.DataSourceId).
+		Desc("created").
+		Desc("id").
+		Limit(limit).
+		Find(&query.Result)
+}
+
+func DeleteExpiredDataSourceHealthChecks(cmd *m.DeleteExpiredDataSourceHealthChecksCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		res, err := sess.Exec("DELETE FROM data_source_health_check WHERE created < ?", cmd.OlderThan)
+		if err != nil {
+			return err
+		}
+
+		cmd.DeletedRows, err = res.RowsAffected()
+		return err
+	})
+}
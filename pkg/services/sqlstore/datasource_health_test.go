@@ -0,0 +1,56 @@
+package sqlstore
+
+import (
+	"testing"
+	"time"
+
+	m "github.com/grafana/grafana/pkg/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDataSourceHealthCheckDataAccess(t *testing.T) {
+	Convey("Testing data source health checks", t, func() {
+		InitTestDB(t)
+
+		ds := m.AddDataSourceCommand{OrgId: 1, Name: "prom", Type: m.DS_PROMETHEUS, Access: m.DS_ACCESS_PROXY, Url: "http://test"}
+		So(AddDataSource(&ds), ShouldBeNil)
+
+		for _, status := range []string{"OK", "ERROR", "OK"} {
+			So(SaveDataSourceHealthCheck(&m.SaveDataSourceHealthCheckCommand{
+				OrgId:        1,
+				DataSourceId: ds.Result.Id,
+				Status:       status,
+				Message:      status,
+				DurationMs:   10,
+			}), ShouldBeNil)
+		}
+
+		Convey("Should return the latest checks first", func() {
+			query := m.GetDataSourceHealthChecksQuery{OrgId: 1, DataSourceId: ds.Result.Id, Limit: 2}
+			So(GetDataSourceHealthChecks(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 2)
+			So(query.Result[0].Status, ShouldEqual, "OK")
+			So(query.Result[1].Status, ShouldEqual, "ERROR")
+		})
+
+		Convey("Should not return checks of other orgs", func() {
+			query := m.GetDataSourceHealthChecksQuery{OrgId: 2, DataSourceId: ds.Result.Id}
+			So(GetDataSourceHealthChecks(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 0)
+		})
+
+		Convey("Should delete expired checks", func() {
+			cmd := m.DeleteExpiredDataSourceHealthChecksCommand{OlderThan: time.Now().Add(time.Hour)}
+			So(DeleteExpiredDataSourceHealthChecks(&cmd), ShouldBeNil)
+			So(cmd.DeletedRows, ShouldEqual, 3)
+		})
+
+		Convey("Should delete the checks of deleted data sources", func() {
+			So(DeleteDataSourceByName(&m.DeleteDataSourceByNameCommand{OrgId: 1, Name: "prom"}), ShouldBeNil)
+
+			query := m.GetDataSourceHealthChecksQuery{OrgId: 1, DataSourceId: ds.Result.Id}
+			So(GetDataSourceHealthChecks(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 0)
+		})
+	})
+}
@@ -42,6 +42,31 @@ func TestDataAccess(t *testing.T) {
 			So(ds.ReadOnly, ShouldBeTrue)
 		})
 
+		Convey("Can add datasource with deprecated password fields", func() {
+			err := AddDataSource(&m.AddDataSourceCommand{
+				OrgId:             10,
+				Name:              "secure",
+				Type:              m.DS_INFLUXDB,
+				Access:            m.DS_ACCESS_PROXY,
+				Url:               "http://test",
+				Password:          "pwd",
+				BasicAuthPassword: "basic",
+				SecureJsonData:    map[string]string{"token": "abc"},
+			})
+			So(err, ShouldBeNil)
+
+			query := m.GetDataSourceByNameQuery{OrgId: 10, Name: "secure"}
+			err = GetDataSourceByName(&query)
+			So(err, ShouldBeNil)
+
+			ds := query.Result
+			So(ds.Password, ShouldEqual, "")
+			So(ds.BasicAuthPassword, ShouldEqual, "")
+			So(ds.DecryptedPassword(), ShouldEqual, "pwd")
+			So(ds.DecryptedBasicAuthPassword(), ShouldEqual, "basic")
+			So(ds.SecureJsonData.Decrypt()["token"], ShouldEqual, "abc")
+		})
+
 		Convey("Given a datasource", func() {
 			err := AddDataSource(&m.AddDataSourceCommand{
 				OrgId:  10,
@@ -0,0 +1,36 @@
+package migrations
+
+import . "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+
+func addAuditEventMigrations(mg *Migrator) {
+	auditEventV1 := Table{
+		Name: "audit_event",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "created", Type: DB_DateTime, Nullable: false},
+			{Name: "source", Type: DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "org_id", Type: DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: DB_BigInt, Nullable: false},
+			{Name: "user_login", Type: DB_NVarchar, Length: 190, Nullable: true},
+			{Name: "remote_addr", Type: DB_NVarchar, Length: 255, Nullable: true},
+			{Name: "data_source_id", Type: DB_BigInt, Nullable: false},
+			{Name: "data_source_name", Type: DB_NVarchar, Length: 190, Nullable: true},
+			{Name: "data_source_type", Type: DB_NVarchar, Length: 255, Nullable: true},
+			{Name: "method", Type: DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "path", Type: DB_Text, Nullable: true},
+			{Name: "query", Type: DB_Text, Nullable: true},
+			{Name: "body", Type: DB_MediumText, Nullable: true},
+			{Name: "body_hash", Type: DB_NVarchar, Length: 64, Nullable: true},
+			{Name: "status", Type: DB_Int, Nullable: false},
+			{Name: "duration_ms", Type: DB_BigInt, Nullable: false},
+		},
+		Indices: []*Index{
+			{Cols: []string{"org_id", "created"}},
+			{Cols: []string{"org_id", "data_source_id"}},
+			{Cols: []string{"created"}},
+		},
+	}
+
+	mg.AddMigration("create audit_event table v1", NewAddTableMigration(auditEventV1))
+	addTableIndicesMigrations(mg, "v1", auditEventV1)
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import . "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+
+func addDataKeyMigrations(mg *Migrator) {
+	dataKeyV1 := Table{
+		Name: "data_key",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "provider", Type: DB_NVarchar, Length: 50, Nullable: false},
+			{Name: "encrypted_data", Type: DB_Text, Nullable: false},
+			{Name: "active", Type: DB_Bool, Nullable: false},
+			{Name: "created", Type: DB_DateTime, Nullable: false},
+			{Name: "updated", Type: DB_DateTime, Nullable: false},
+		},
+		Indices: []*Index{
+			{Cols: []string{"active"}},
+		},
+	}
+
+	mg.AddMigration("create data_key table v1", NewAddTableMigration(dataKeyV1))
+	addTableIndicesMigrations(mg, "v1", dataKeyV1)
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import . "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+
+func addDataSourceAclMigrations(mg *Migrator) {
+	dataSourceAclV1 := Table{
+		Name: "data_source_acl",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: DB_BigInt, Nullable: false},
+			{Name: "data_source_id", Type: DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: DB_BigInt, Nullable: true},
+			{Name: "team_id", Type: DB_BigInt, Nullable: true},
+			{Name: "permission", Type: DB_SmallInt, Nullable: false},
+			{Name: "created", Type: DB_DateTime, Nullable: false},
+			{Name: "updated", Type: DB_DateTime, Nullable: false},
+		},
+		Indices: []*Index{
+			{Cols: []string{"data_source_id"}},
+			{Cols: []string{"data_source_id", "user_id"}, Type: UniqueIndex},
+			{Cols: []string{"data_source_id", "team_id"}, Type: UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create data_source_acl table v1", NewAddTableMigration(dataSourceAclV1))
+	addTableIndicesMigrations(mg, "v1", dataSourceAclV1)
+}
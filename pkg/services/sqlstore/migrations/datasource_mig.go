@@ -1,6 +1,15 @@
 package migrations
 
-import . "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-xorm/xorm"
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	. "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
 
 func addDataSourceMigration(mg *Migrator) {
 	var tableV1 = Table{
@@ -127,4 +136,70 @@ func addDataSourceMigration(mg *Migrator) {
 	mg.AddMigration("Add read_only data column", NewAddColumnMigration(tableV2, &Column{
 		Name: "read_only", Type: DB_Bool, Nullable: true,
 	}))
+
+	mg.AddMigration("Move data source passwords to secure json data", &MoveDataSourcePasswordsMigration{})
+}
+
+// MoveDataSourcePasswordsMigration encrypts the plain text password and
+// basic_auth_password columns into secure_json_data and clears them. Values
+// already in secure json data are kept.
+type MoveDataSourcePasswordsMigration struct {
+	MigrationBase
+}
+
+func (m *MoveDataSourcePasswordsMigration) Sql(dialect Dialect) string {
+	return "code migration"
+}
+
+type tempDataSourcePasswordsDTO struct {
+	Id                int64
+	Password          string
+	BasicAuthPassword string
+	SecureJsonData    string
+}
+
+func (m *MoveDataSourcePasswordsMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	dataSources := make([]*tempDataSourcePasswordsDTO, 0)
+
+	err := sess.SQL("SELECT id, password, basic_auth_password, secure_json_data FROM data_source WHERE password <> '' OR basic_auth_password <> ''").Find(&dataSources)
+	if err != nil {
+		return err
+	}
+
+	for _, ds := range dataSources {
+		secureJsonData := securejsondata.SecureJsonData{}
+		if ds.SecureJsonData != "" {
+			if err := json.Unmarshal([]byte(ds.SecureJsonData), &secureJsonData); err != nil {
+				return fmt.Errorf("failed to read secure json data of data source %d: %v", ds.Id, err)
+			}
+		}
+
+		passwords := map[string]string{
+			"password":          ds.Password,
+			"basicAuthPassword": ds.BasicAuthPassword,
+		}
+		for key, password := range passwords {
+			if _, exists := secureJsonData[key]; exists || password == "" {
+				continue
+			}
+
+			encrypted, err := util.Encrypt([]byte(password), setting.SecretKey)
+			if err != nil {
+				return err
+			}
+			secureJsonData[key] = encrypted
+		}
+
+		data, err := json.Marshal(secureJsonData)
+		if err != nil {
+			return err
+		}
+
+		_, err = sess.Exec("UPDATE data_source SET secure_json_data = ?, password = '', basic_auth_password = '' WHERE id = ?", string(data), ds.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -31,6 +31,11 @@ func AddMigrations(mg *Migrator) {
 	addTagMigration(mg)
 	addLoginAttemptMigrations(mg)
 	addUserAuthMigrations(mg)
+	addSqlMacroMigrations(mg)
+	addDataKeyMigrations(mg)
+	addDataSourceHealthCheckMigrations(mg)
+	addDataSourceAclMigrations(mg)
+	addAuditEventMigrations(mg)
 }
 
 func addMigrationLogMigrations(mg *Migrator) {
@@ -194,6 +194,9 @@ func DeleteOrg(cmd *m.DeleteOrgCommand) error {
 			"DELETE FROM dashboard WHERE org_id = ?",
 			"DELETE FROM api_key WHERE org_id = ?",
 			"DELETE FROM data_source WHERE org_id = ?",
+			"DELETE FROM data_source_health_check WHERE org_id = ?",
+			"DELETE FROM data_source_acl WHERE org_id = ?",
+			"DELETE FROM sql_macro WHERE org_id = ?",
 			"DELETE FROM org_user WHERE org_id = ?",
 			"DELETE FROM org WHERE id = ?",
 			"DELETE FROM temp_user WHERE org_id = ?",
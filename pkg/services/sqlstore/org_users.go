@@ -147,6 +147,7 @@ func RemoveOrgUser(cmd *m.RemoveOrgUserCommand) error {
 		deletes := []string{
 			"DELETE FROM org_user WHERE org_id=? and user_id=?",
 			"DELETE FROM dashboard_acl WHERE org_id=? and user_id = ?",
+			"DELETE FROM data_source_acl WHERE org_id=? and user_id = ?",
 			"DELETE FROM team_member WHERE org_id=? and user_id = ?",
 		}
 
@@ -0,0 +1,135 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func init() {
+	bus.AddHandler("sql", GetSqlMacros)
+	bus.AddHandler("sql", GetSqlMacroById)
+	bus.AddHandler("sql", AddSqlMacro)
+	bus.AddHandler("sql", UpdateSqlMacro)
+	bus.AddHandler("sql", DeleteSqlMacro)
+}
+
+func GetSqlMacros(query *m.GetSqlMacrosQuery) error {
+	query.Result = make([]*m.SqlMacro, 0)
+	return x.Where("org_id = ?", query.OrgId).Asc("name").Find(&query.Result)
+}
+
+func GetSqlMacroById(query *m.GetSqlMacroByIdQuery) error {
+	macro := m.SqlMacro{Id: query.Id, OrgId: query.OrgId}
+	has, err := x.Get(&macro)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return m.ErrSqlMacroNotFound
+	}
+
+	query.Result = &macro
+	return nil
+}
+
+func AddSqlMacro(cmd *m.AddSqlMacroCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		existing := m.SqlMacro{OrgId: cmd.OrgId, Name: cmd.Name}
+		has, err := sess.Get(&existing)
+		if err != nil {
+			return err
+		}
+
+		if has {
+			return m.ErrSqlMacroWithSameName
+		}
+
+		macro := &m.SqlMacro{
+			OrgId:       cmd.OrgId,
+			Name:        cmd.Name,
+			Description: cmd.Description,
+			Params:      cmd.Params,
+			Templates:   cmd.Templates,
+			Created:     time.Now(),
+			Updated:     time.Now(),
+		}
+
+		if _, err := sess.Insert(macro); err != nil {
+			return err
+		}
+
+		sess.publishAfterCommit(&events.SqlMacrosChanged{
+			Timestamp: macro.Updated,
+			OrgId:     cmd.OrgId,
+		})
+
+		cmd.Result = macro
+		return nil
+	})
+}
+
+func UpdateSqlMacro(cmd *m.UpdateSqlMacroCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		current := m.SqlMacro{Id: cmd.Id, OrgId: cmd.OrgId}
+		has, err := sess.Get(&current)
+		if err != nil {
+			return err
+		}
+
+		if !has {
+			return m.ErrSqlMacroNotFound
+		}
+
+		sameName := m.SqlMacro{OrgId: cmd.OrgId, Name: cmd.Name}
+		has, err = sess.Get(&sameName)
+		if err != nil {
+			return err
+		}
+
+		if has && sameName.Id != current.Id {
+			return m.ErrSqlMacroWithSameName
+		}
+
+		current.Name = cmd.Name
+		current.Description = cmd.Description
+		current.Params = cmd.Params
+		current.Templates = cmd.Templates
+		current.Updated = time.Now()
+
+		if _, err := sess.ID(current.Id).AllCols().Update(&current); err != nil {
+			return err
+		}
+
+		sess.publishAfterCommit(&events.SqlMacrosChanged{
+			Timestamp: current.Updated,
+			OrgId:     cmd.OrgId,
+		})
+
+		cmd.Result = &current
+		return nil
+	})
+}
+
+func DeleteSqlMacro(cmd *m.DeleteSqlMacroCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		res, err := sess.Exec("DELETE FROM sql_macro WHERE id = ? AND org_id = ?", cmd.Id, cmd.OrgId)
+		if err != nil {
+			return err
+		}
+
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return m.ErrSqlMacroNotFound
+		}
+
+		sess.publishAfterCommit(&events.SqlMacrosChanged{
+			Timestamp: time.Now(),
+			OrgId:     cmd.OrgId,
+		})
+
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package sqlstore
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	m "github.com/grafana/grafana/pkg/models"
+)
+
+func TestSqlMacroDataAccess(t *testing.T) {
+	Convey("Testing sql macro data access", t, func() {
+		InitTestDB(t)
+
+		cmd := m.AddSqlMacroCommand{
+			OrgId:     1,
+			Name:      "tenantFilter",
+			Params:    []string{"column"},
+			Templates: map[string]string{"mysql": "${column} = 'acme'", "postgres": "${column} = 'acme'"},
+		}
+		So(AddSqlMacro(&cmd), ShouldBeNil)
+		So(cmd.Result.Id, ShouldBeGreaterThan, 0)
+
+		Convey("Can list macros of the org", func() {
+			query := m.GetSqlMacrosQuery{OrgId: 1}
+			So(GetSqlMacros(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 1)
+			So(query.Result[0].Params, ShouldResemble, []string{"column"})
+			So(query.Result[0].Templates["postgres"], ShouldEqual, "${column} = 'acme'")
+
+			other := m.GetSqlMacrosQuery{OrgId: 2}
+			So(GetSqlMacros(&other), ShouldBeNil)
+			So(len(other.Result), ShouldEqual, 0)
+		})
+
+		Convey("Cannot add macro with the same name", func() {
+			err := AddSqlMacro(&m.AddSqlMacroCommand{OrgId: 1, Name: "tenantFilter", Templates: map[string]string{"mysql": "1 = 1"}})
+			So(err, ShouldEqual, m.ErrSqlMacroWithSameName)
+		})
+
+		Convey("Can update macro", func() {
+			update := m.UpdateSqlMacroCommand{
+				Id:        cmd.Result.Id,
+				OrgId:     1,
+				Name:      "tenantFilter",
+				Params:    []string{"column", "tenant"},
+				Templates: map[string]string{"mysql": "${column} = ${tenant}"},
+			}
+			So(UpdateSqlMacro(&update), ShouldBeNil)
+
+			query := m.GetSqlMacroByIdQuery{Id: cmd.Result.Id, OrgId: 1}
+			So(GetSqlMacroById(&query), ShouldBeNil)
+			So(query.Result.Params, ShouldResemble, []string{"column", "tenant"})
+			So(query.Result.Templates, ShouldResemble, map[string]string{"mysql": "${column} = ${tenant}"})
+		})
+
+		Convey("Cannot get or delete macro of another org", func() {
+			So(GetSqlMacroById(&m.GetSqlMacroByIdQuery{Id: cmd.Result.Id, OrgId: 2}), ShouldEqual, m.ErrSqlMacroNotFound)
+			So(DeleteSqlMacro(&m.DeleteSqlMacroCommand{Id: cmd.Result.Id, OrgId: 2}), ShouldEqual, m.ErrSqlMacroNotFound)
+		})
+
+		Convey("Can delete macro", func() {
+			So(DeleteSqlMacro(&m.DeleteSqlMacroCommand{Id: cmd.Result.Id, OrgId: 1}), ShouldBeNil)
+
+			query := m.GetSqlMacrosQuery{OrgId: 1}
+			So(GetSqlMacros(&query), ShouldBeNil)
+			So(len(query.Result), ShouldEqual, 0)
+		})
+	})
+}
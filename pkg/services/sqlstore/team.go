@@ -101,6 +101,7 @@ func DeleteTeam(cmd *m.DeleteTeamCommand) error {
 			"DELETE FROM team_member WHERE org_id=? and team_id = ?",
 			"DELETE FROM team WHERE org_id=? and id = ?",
 			"DELETE FROM dashboard_acl WHERE org_id=? and team_id = ?",
+			"DELETE FROM data_source_acl WHERE org_id=? and team_id = ?",
 		}
 
 		for _, sql := range deletes {
@@ -1,16 +1,20 @@
 package sqlstore
 
 import (
+	"encoding/base64"
 	"time"
 
 	"github.com/grafana/grafana/pkg/bus"
 	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/secrets"
+	"golang.org/x/oauth2"
 )
 
 func init() {
 	bus.AddHandler("sql", GetUserByAuthInfo)
 	bus.AddHandler("sql", GetAuthInfo)
 	bus.AddHandler("sql", SetAuthInfo)
+	bus.AddHandler("sql", UpdateAuthInfo)
 	bus.AddHandler("sql", DeleteAuthInfo)
 }
 
@@ -113,8 +117,9 @@ func GetAuthInfo(query *m.GetAuthInfoQuery) error {
 	userAuth := &m.UserAuth{
 		AuthModule: query.AuthModule,
 		AuthId:     query.AuthId,
+		UserId:     query.UserId,
 	}
-	has, err := x.Get(userAuth)
+	has, err := x.Desc("created").Get(userAuth)
 	if err != nil {
 		return err
 	}
@@ -122,6 +127,16 @@ func GetAuthInfo(query *m.GetAuthInfoQuery) error {
 		return m.ErrUserNotFound
 	}
 
+	if userAuth.OAuthAccessToken, err = decryptAndDecode(userAuth.OAuthAccessToken); err != nil {
+		return err
+	}
+	if userAuth.OAuthRefreshToken, err = decryptAndDecode(userAuth.OAuthRefreshToken); err != nil {
+		return err
+	}
+	if userAuth.OAuthTokenType, err = decryptAndDecode(userAuth.OAuthTokenType); err != nil {
+		return err
+	}
+
 	query.Result = userAuth
 	return nil
 }
@@ -135,14 +150,84 @@ func SetAuthInfo(cmd *m.SetAuthInfoCommand) error {
 			Created:    time.Now(),
 		}
 
+		if err := setOAuthToken(authUser, cmd.OAuthToken); err != nil {
+			return err
+		}
+
 		_, err := sess.Insert(authUser)
 		return err
 	})
 }
 
+// UpdateAuthInfo updates the OAuth token of the auth info.
+func UpdateAuthInfo(cmd *m.UpdateAuthInfoCommand) error {
+	return inTransaction(func(sess *DBSession) error {
+		authUser := &m.UserAuth{}
+		if err := setOAuthToken(authUser, cmd.OAuthToken); err != nil {
+			return err
+		}
+
+		_, err := sess.Where("user_id = ? AND auth_module = ? AND auth_id = ?", cmd.UserId, cmd.AuthModule, cmd.AuthId).
+			Cols("o_auth_access_token", "o_auth_refresh_token", "o_auth_token_type", "o_auth_expiry").
+			Update(authUser)
+		return err
+	})
+}
+
+func setOAuthToken(authUser *m.UserAuth, token *oauth2.Token) error {
+	if token == nil {
+		return nil
+	}
+
+	var err error
+	if authUser.OAuthAccessToken, err = encryptAndEncode(token.AccessToken); err != nil {
+		return err
+	}
+	if authUser.OAuthRefreshToken, err = encryptAndEncode(token.RefreshToken); err != nil {
+		return err
+	}
+	if authUser.OAuthTokenType, err = encryptAndEncode(token.TokenType); err != nil {
+		return err
+	}
+	authUser.OAuthExpiry = token.Expiry
+
+	return nil
+}
+
 func DeleteAuthInfo(cmd *m.DeleteAuthInfoCommand) error {
 	return inTransaction(func(sess *DBSession) error {
-		_, err := sess.Delete(cmd.UserAuth)
+		_, err := sess.ID(cmd.UserAuth.Id).Delete(&m.UserAuth{})
 		return err
 	})
 }
+
+func encryptAndEncode(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	encrypted, err := secrets.Encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+func decryptAndDecode(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	decrypted, err := secrets.Decrypt(decoded)
+	if err != nil {
+		return "", err
+	}
+
+	return string(decrypted), nil
+}
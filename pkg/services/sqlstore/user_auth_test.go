@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
 
 	m "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
 )
 
 func TestUserAuth(t *testing.T) {
@@ -126,5 +129,47 @@ func TestUserAuth(t *testing.T) {
 			So(err, ShouldEqual, m.ErrUserNotFound)
 			So(query.Result, ShouldBeNil)
 		})
+
+		Convey("Can set and update the oauth token of an auth info", func() {
+			setting.SecretKey = "password"
+
+			query := &m.GetUserByAuthInfoQuery{Login: "loginuser0"}
+			So(GetUserByAuthInfo(query), ShouldBeNil)
+			userId := query.Result.Id
+
+			token := &oauth2.Token{
+				AccessToken:  "access_token",
+				RefreshToken: "refresh_token",
+				TokenType:    "Bearer",
+				Expiry:       time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+			}
+			err = SetAuthInfo(&m.SetAuthInfoCommand{UserId: userId, AuthModule: "oauth_generic_oauth", AuthId: "id", OAuthToken: token})
+			So(err, ShouldBeNil)
+
+			// tokens are stored encrypted
+			stored := &m.UserAuth{UserId: userId}
+			_, err = x.Get(stored)
+			So(err, ShouldBeNil)
+			So(stored.OAuthAccessToken, ShouldNotEqual, "")
+			So(stored.OAuthAccessToken, ShouldNotEqual, "access_token")
+
+			authQuery := &m.GetAuthInfoQuery{UserId: userId}
+			So(GetAuthInfo(authQuery), ShouldBeNil)
+			So(authQuery.Result.OAuthAccessToken, ShouldEqual, "access_token")
+			So(authQuery.Result.OAuthRefreshToken, ShouldEqual, "refresh_token")
+			So(authQuery.Result.OAuthTokenType, ShouldEqual, "Bearer")
+			So(authQuery.Result.OAuthExpiry.Unix(), ShouldEqual, token.Expiry.Unix())
+
+			token.AccessToken = "refreshed_token"
+			err = UpdateAuthInfo(&m.UpdateAuthInfoCommand{UserId: userId, AuthModule: "oauth_generic_oauth", AuthId: "id", OAuthToken: token})
+			So(err, ShouldBeNil)
+
+			So(GetAuthInfo(authQuery), ShouldBeNil)
+			So(authQuery.Result.OAuthAccessToken, ShouldEqual, "refreshed_token")
+
+			// auth info with tokens can be deleted
+			So(DeleteAuthInfo(&m.DeleteAuthInfoCommand{UserAuth: authQuery.Result}), ShouldBeNil)
+			So(GetAuthInfo(&m.GetAuthInfoQuery{UserId: userId}), ShouldEqual, m.ErrUserNotFound)
+		})
 	})
 }
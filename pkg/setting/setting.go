@@ -81,6 +81,16 @@ var (
 	EnableGzip         bool
 	EnforceDomain      bool
 
+	// Data proxy response cache
+	DataProxyCoalesceRequests bool
+	DataProxyCacheTTL         time.Duration
+	DataProxyCacheMaxItemSize int
+
+	// SQLite data source
+	SqliteAllowedPaths  []string
+	SqliteAllowWrite    bool
+	GrafanaDatabasePath string
+
 	// Security settings.
 	SecretKey                        string
 	LogInRememberDays                int
@@ -139,7 +149,8 @@ var (
 	BasicAuthEnabled bool
 
 	// Plugin settings
-	PluginAppsSkipVerifyTLS bool
+	PluginAppsSkipVerifyTLS   bool
+	PluginResourceMaxBodySize int64
 
 	// Session settings.
 	SessionOptions         session.Options
@@ -582,6 +593,20 @@ func (cfg *Cfg) Load(args *CommandLineArgs) error {
 	// read data proxy settings
 	dataproxy := iniFile.Section("dataproxy")
 	DataProxyLogging = dataproxy.Key("logging").MustBool(false)
+	DataProxyCoalesceRequests = dataproxy.Key("coalesce_requests").MustBool(false)
+	DataProxyCacheTTL = time.Duration(dataproxy.Key("cache_ttl_seconds").MustInt(0)) * time.Second
+	DataProxyCacheMaxItemSize = dataproxy.Key("cache_max_item_size_kb").MustInt(1024) * 1024
+
+	// read sqlite data source settings
+	sqlite := iniFile.Section("sqlite")
+	SqliteAllowedPaths = []string{}
+	for _, allowedPath := range strings.Split(sqlite.Key("allowed_paths").String(), ",") {
+		if allowedPath = strings.TrimSpace(allowedPath); allowedPath != "" {
+			SqliteAllowedPaths = append(SqliteAllowedPaths, makeAbsolute(allowedPath, HomePath))
+		}
+	}
+	SqliteAllowWrite = sqlite.Key("allow_write").MustBool(false)
+	GrafanaDatabasePath = makeAbsolute(iniFile.Section("database").Key("path").MustString("data/grafana.db"), cfg.DataPath)
 
 	// read security settings
 	security := iniFile.Section("security")
@@ -663,6 +688,7 @@ func (cfg *Cfg) Load(args *CommandLineArgs) error {
 
 	// global plugin settings
 	PluginAppsSkipVerifyTLS = iniFile.Section("plugins").Key("app_tls_skip_verify_insecure").MustBool(false)
+	PluginResourceMaxBodySize = iniFile.Section("plugins").Key("resource_max_body_size_kb").MustInt64(10240) * 1024
 
 	// Rendering
 	renderSec := iniFile.Section("rendering")
@@ -0,0 +1,149 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-xorm/core"
+	_ "github.com/kshvakov/clickhouse"
+
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+func init() {
+	core.RegisterDriver("clickhouse", &clickhouseDriver{})
+	tsdb.RegisterTsdbQueryEndpoint("clickhouse", newClickhouseQueryEndpoint)
+}
+
+// clickhouseDriver makes the clickhouse sql driver known to xorm. The query
+// endpoint only executes raw sql so the mysql dialect is used for the engine.
+type clickhouseDriver struct{}
+
+func (d *clickhouseDriver) Parse(driverName, dataSourceName string) (*core.Uri, error) {
+	u, err := url.Parse(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.Uri{DbType: core.MYSQL, DbName: u.Query().Get("database")}, nil
+}
+
+func newClickhouseQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+	logger := log.New("tsdb.clickhouse")
+
+	cnnstr, err := generateConnectionString(datasource)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("getEngine", "connection", cnnstr)
+
+	config := tsdb.SqlQueryEndpointConfiguration{
+		DriverName:        "clickhouse",
+		ConnectionString:  cnnstr,
+		Datasource:        datasource,
+		TimeColumnNames:   []string{"time", "time_sec"},
+		MetricColumnTypes: []string{"String", "FixedString", "Nullable(String)", "LowCardinality(String)", "Enum8", "Enum16"},
+		ReadOnlyMode:      tsdb.SqlReadOnlyCheck,
+	}
+
+	rowTransformer := clickhouseRowTransformer{
+		log: logger,
+	}
+
+	return tsdb.NewSqlQueryEndpoint(&config, &rowTransformer, newClickhouseMacroEngine(), logger)
+}
+
+// generateConnectionString returns the dsn of the native protocol driver. The
+// url may be given without scheme, the http interface of clickhouse is not
+// supported and lz4 compression is not available in the vendored driver.
+func generateConnectionString(datasource *models.DataSource) (string, error) {
+	rawUrl := datasource.Url
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "tcp://" + rawUrl
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "tcp" {
+		return "", fmt.Errorf("clickhouse url scheme %s is not supported, use the native protocol address, e.g. tcp://localhost:9000", u.Scheme)
+	}
+
+	params := u.Query()
+	if _, ok := params["compress"]; ok {
+		return "", fmt.Errorf("clickhouse option compress is not supported")
+	}
+
+	params.Set("database", datasource.Database)
+	params.Set("username", datasource.User)
+	params.Set("password", datasource.DecryptedPassword())
+	params.Set("read_timeout", fmt.Sprintf("%d", datasource.JsonData.Get("readTimeout").MustInt(30)))
+
+	if datasource.JsonData.Get("secure").MustBool(false) {
+		params.Set("secure", "true")
+		params.Set("skip_verify", fmt.Sprintf("%t", datasource.JsonData.Get("tlsSkipVerify").MustBool(false)))
+	}
+
+	return fmt.Sprintf("tcp://%s?%s", u.Host, params.Encode()), nil
+}
+
+type clickhouseRowTransformer struct {
+	log log.Logger
+}
+
+func (t *clickhouseRowTransformer) Transform(columnTypes []*sql.ColumnType, rows *core.Rows) (tsdb.RowValues, error) {
+	values := make([]interface{}, len(columnTypes))
+	valuePtrs := make([]interface{}, len(columnTypes))
+
+	for i := range columnTypes {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	for i := range columnTypes {
+		switch value := values[i].(type) {
+		case []byte:
+			values[i] = string(value)
+		case int32:
+			if scale, ok := decimalScale(columnTypes[i].DatabaseTypeName()); ok {
+				values[i] = float64(value) / math.Pow10(scale)
+			}
+		case int64:
+			if scale, ok := decimalScale(columnTypes[i].DatabaseTypeName()); ok {
+				values[i] = float64(value) / math.Pow10(scale)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+var decimalTypeRegex = regexp.MustCompile(`^(?:Nullable\()?Decimal(?:32|64)?\((?:\d+,\s*)?(\d+)\)`)
+
+// decimalScale returns the scale of a Decimal column type. Decimals are
+// returned by the driver as integers multiplied by 10^scale.
+func decimalScale(columnType string) (int, bool) {
+	match := decimalTypeRegex.FindStringSubmatch(columnType)
+	if match == nil {
+		return 0, false
+	}
+
+	scale, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return scale, true
+}
@@ -0,0 +1,205 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-xorm/core"
+	"github.com/go-xorm/xorm"
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeDriver returns the same result set for every query, with the column
+// types reported by the clickhouse driver.
+type fakeDriver struct {
+	columns []string
+	types   []string
+	rows    [][]driver.Value
+	queries []string
+}
+
+type fakeConn struct{ driver *fakeDriver }
+type fakeStmt struct {
+	driver *fakeDriver
+	query  string
+}
+type fakeRows struct {
+	driver *fakeDriver
+	pos    int
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{driver: d}, nil }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{driver: c.driver, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("not supported") }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("not supported")
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.queries = append(s.driver.queries, s.query)
+	return &fakeRows{driver: s.driver}, nil
+}
+
+func (r *fakeRows) Columns() []string                       { return r.driver.columns }
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return r.driver.types[i] }
+func (r *fakeRows) Close() error                            { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.driver.rows) {
+		return io.EOF
+	}
+	copy(dest, r.driver.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+var fake = &fakeDriver{}
+
+func init() {
+	sql.Register("clickhouse-fake", fake)
+	core.RegisterDriver("clickhouse-fake", &clickhouseDriver{})
+}
+
+func TestClickhouse(t *testing.T) {
+	Convey("Clickhouse", t, func() {
+		origXormEngine := tsdb.NewXormEngine
+		tsdb.NewXormEngine = func(d, c string) (*xorm.Engine, error) {
+			return xorm.NewEngine("clickhouse-fake", c)
+		}
+
+		Reset(func() {
+			tsdb.NewXormEngine = origXormEngine
+		})
+
+		datasource := &models.DataSource{
+			Id:       time.Now().UnixNano(),
+			Url:      "tcp://localhost:9000",
+			Database: "analytics",
+			User:     "grafana",
+			JsonData: simplejson.New(),
+		}
+
+		endpoint, err := newClickhouseQueryEndpoint(datasource)
+		So(err, ShouldBeNil)
+
+		fromStart := time.Date(2018, 3, 15, 13, 0, 0, 0, time.UTC)
+		timeRange := &tsdb.TimeRange{
+			From: fmt.Sprintf("%v", fromStart.Unix()*1000),
+			To:   fmt.Sprintf("%v", fromStart.Add(10*time.Minute).Unix()*1000),
+		}
+
+		query := func(model map[string]interface{}) *tsdb.QueryResult {
+			resp, err := endpoint.Query(context.Background(), datasource, &tsdb.TsdbQuery{
+				TimeRange: timeRange,
+				Queries: []*tsdb.Query{
+					{
+						DataSource: datasource,
+						Model:      simplejson.NewFromAny(model),
+						RefId:      "A",
+					},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			queryResult := resp.Results["A"]
+			So(queryResult.Error, ShouldBeNil)
+			return queryResult
+		}
+
+		Convey("When doing a time series query", func() {
+			fake.columns = []string{"time", "metric", "value"}
+			fake.types = []string{"UInt32", "LowCardinality(String)", "UInt64"}
+			fake.rows = [][]driver.Value{
+				{uint32(fromStart.Unix()), "a", uint64(1)},
+				{uint32(fromStart.Unix()), "b", uint64(2)},
+				{uint32(fromStart.Add(5 * time.Minute).Unix()), "a", uint64(3)},
+			}
+
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT $__timeGroupAlias(ts, '5m'), host AS metric, count() AS value FROM events WHERE $__timeFilter(ts) GROUP BY time, metric ORDER BY time",
+				"format": "time_series",
+			})
+
+			So(fake.queries[len(fake.queries)-1], ShouldEqual, fmt.Sprintf(
+				"SELECT intDiv(toUInt32(ts), 300) * 300 AS \"time\", host AS metric, count() AS value FROM events WHERE ts >= toDateTime(%d) AND ts <= toDateTime(%d) GROUP BY time, metric ORDER BY time",
+				fromStart.Unix(), fromStart.Add(10*time.Minute).Unix()))
+
+			So(len(queryResult.Series), ShouldEqual, 2)
+			So(queryResult.Series[0].Name, ShouldEqual, "a")
+			So(len(queryResult.Series[0].Points), ShouldEqual, 2)
+			So(queryResult.Series[0].Points[1][0].Float64, ShouldEqual, 3)
+			So(queryResult.Series[0].Points[1][1].Float64, ShouldEqual, float64(fromStart.Add(5*time.Minute).Unix()*1000))
+		})
+
+		Convey("When doing a table query with decimal and datetime columns", func() {
+			fake.columns = []string{"time", "name", "price"}
+			fake.types = []string{"DateTime", "String", "Decimal(9, 2)"}
+			fake.rows = [][]driver.Value{
+				{fromStart, []byte("book"), int32(1250)},
+			}
+
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT ts AS time, name, price FROM orders",
+				"format": "table",
+			})
+
+			So(len(queryResult.Tables), ShouldEqual, 1)
+			row := queryResult.Tables[0].Rows[0]
+			So(row[0], ShouldEqual, float64(fromStart.Unix()*1000))
+			So(row[1], ShouldEqual, "book")
+			So(row[2], ShouldEqual, 12.5)
+		})
+	})
+
+	Convey("Clickhouse connection string", t, func() {
+		datasource := &models.DataSource{
+			Url:      "tcp://localhost:9000/",
+			Database: "analytics",
+			User:     "grafana",
+			JsonData: simplejson.New(),
+			SecureJsonData: securejsondata.GetEncryptedJsonData(map[string]string{
+				"password": "secret",
+			}),
+		}
+
+		Convey("Should use the native protocol address", func() {
+			cnnstr, err := generateConnectionString(datasource)
+			So(err, ShouldBeNil)
+			So(cnnstr, ShouldEqual, "tcp://localhost:9000?database=analytics&password=secret&read_timeout=30&username=grafana")
+		})
+
+		Convey("Should accept an address without scheme", func() {
+			datasource.Url = "localhost:9000"
+			cnnstr, err := generateConnectionString(datasource)
+			So(err, ShouldBeNil)
+			So(cnnstr, ShouldEqual, "tcp://localhost:9000?database=analytics&password=secret&read_timeout=30&username=grafana")
+		})
+
+		Convey("Should reject http urls", func() {
+			datasource.Url = "http://localhost:8123/"
+			_, err := generateConnectionString(datasource)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "tcp://localhost:9000")
+		})
+
+		Convey("Should reject the compress option", func() {
+			datasource.Url = "tcp://localhost:9000?compress=true"
+			_, err := generateConnectionString(datasource)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
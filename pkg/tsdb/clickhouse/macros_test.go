@@ -0,0 +1,69 @@
+package clickhouse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMacroEngine(t *testing.T) {
+	Convey("MacroEngine", t, func() {
+		engine := &clickhouseMacroEngine{}
+		query := &tsdb.Query{Model: simplejson.New()}
+
+		Convey("Given a time range between 2018-04-12 00:00 and 2018-04-12 00:05", func() {
+			from := time.Date(2018, 4, 12, 18, 0, 0, 0, time.UTC)
+			to := from.Add(5 * time.Minute)
+			timeRange := tsdb.NewFakeTimeRange("5m", "now", to)
+
+			Convey("interpolate __timeGroup function", func() {
+				sql, err := engine.Interpolate(query, timeRange, "GROUP BY $__timeGroup(time_column,'5m')")
+				So(err, ShouldBeNil)
+				sql2, err := engine.Interpolate(query, timeRange, "GROUP BY $__timeGroupAlias(time_column , '5m')")
+				So(err, ShouldBeNil)
+
+				So(sql, ShouldEqual, "GROUP BY intDiv(toUInt32(time_column), 300) * 300")
+				So(sql2, ShouldEqual, sql+" AS \"time\"")
+			})
+
+			Convey("interpolate __timeGroup function with fill", func() {
+				_, err := engine.Interpolate(query, timeRange, "GROUP BY $__timeGroup(time_column,'5m', previous)")
+				So(err, ShouldBeNil)
+
+				So(query.Model.Get("fill").MustBool(), ShouldBeTrue)
+				So(query.Model.Get("fillMode").MustString(), ShouldEqual, "previous")
+				So(query.Model.Get("fillInterval").MustFloat64(), ShouldEqual, 300)
+			})
+
+			Convey("interpolate __timeFilter function", func() {
+				sql, err := engine.Interpolate(query, timeRange, "WHERE $__timeFilter(time_column)")
+				So(err, ShouldBeNil)
+
+				So(sql, ShouldEqual, fmt.Sprintf("WHERE time_column >= toDateTime(%d) AND time_column <= toDateTime(%d)", from.Unix(), to.Unix()))
+			})
+
+			Convey("interpolate __timeFrom function", func() {
+				sql, err := engine.Interpolate(query, timeRange, "select $__timeFrom()")
+				So(err, ShouldBeNil)
+
+				So(sql, ShouldEqual, fmt.Sprintf("select toDateTime(%d)", from.Unix()))
+			})
+
+			Convey("interpolate __unixEpochGroup function", func() {
+				sql, err := engine.Interpolate(query, timeRange, "GROUP BY $__unixEpochGroup(epoch,'5m')")
+				So(err, ShouldBeNil)
+
+				So(sql, ShouldEqual, "GROUP BY intDiv(epoch, 300) * 300")
+			})
+
+			Convey("returns an error for unknown macros", func() {
+				_, err := engine.Interpolate(query, timeRange, "select $__unknown(time_column)")
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
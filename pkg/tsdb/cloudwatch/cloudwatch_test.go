@@ -2,6 +2,8 @@ package cloudwatch
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/grafana/grafana/pkg/tsdb"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/grafana/grafana/pkg/components/null"
 	"github.com/grafana/grafana/pkg/components/simplejson"
@@ -18,7 +21,7 @@ import (
 func TestCloudWatch(t *testing.T) {
 	Convey("CloudWatch", t, func() {
 
-		Convey("executeQuery", func() {
+		Convey("executeTimeSeriesQuery", func() {
 			e := &CloudWatchExecutor{
 				DataSource: &models.DataSource{
 					JsonData: simplejson.New(),
@@ -26,12 +29,12 @@ func TestCloudWatch(t *testing.T) {
 			}
 
 			Convey("End time before start time should result in error", func() {
-				_, err := e.executeQuery(context.Background(), &CloudWatchQuery{}, &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("now-1h", "now-2h")})
+				_, err := e.executeTimeSeriesQuery(context.Background(), &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("now-1h", "now-2h")})
 				So(err.Error(), ShouldEqual, "Invalid time range: Start time must be before end time")
 			})
 
 			Convey("End time equals start time should result in error", func() {
-				_, err := e.executeQuery(context.Background(), &CloudWatchQuery{}, &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("now-1h", "now-1h")})
+				_, err := e.executeTimeSeriesQuery(context.Background(), &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("now-1h", "now-1h")})
 				So(err.Error(), ShouldEqual, "Invalid time range: Start time must be before end time")
 			})
 		})
@@ -82,21 +85,6 @@ func TestCloudWatch(t *testing.T) {
 
 		Convey("can parse cloudwatch response", func() {
 			timestamp := time.Unix(0, 0)
-			resp := &cloudwatch.GetMetricStatisticsOutput{
-				Label: aws.String("TargetResponseTime"),
-				Datapoints: []*cloudwatch.Datapoint{
-					{
-						Timestamp: aws.Time(timestamp),
-						Average:   aws.Float64(10.0),
-						Maximum:   aws.Float64(20.0),
-						ExtendedStatistics: map[string]*float64{
-							"p50.00": aws.Float64(30.0),
-							"p90.00": aws.Float64(40.0),
-						},
-						Unit: aws.String("Seconds"),
-					},
-				},
-			}
 			query := &CloudWatchQuery{
 				Region:     "us-east-1",
 				Namespace:  "AWS/ApplicationELB",
@@ -111,99 +99,295 @@ func TestCloudWatch(t *testing.T) {
 						Value: aws.String("tg"),
 					},
 				},
-				Statistics:         []*string{aws.String("Average"), aws.String("Maximum")},
-				ExtendedStatistics: []*string{aws.String("p50.00"), aws.String("p90.00")},
-				Period:             60,
-				Alias:              "{{namespace}}_{{metric}}_{{stat}}",
+				Statistics: []*string{aws.String("Average")},
+				Period:     60,
+				Alias:      "{{namespace}}_{{metric}}_{{stat}}",
+			}
+			result := &cloudwatch.MetricDataResult{
+				Id:         aws.String("queryA"),
+				Label:      aws.String("TargetResponseTime"),
+				StatusCode: aws.String("Complete"),
+				Timestamps: []*time.Time{aws.Time(timestamp)},
+				Values:     []*float64{aws.Float64(10.0)},
 			}
 
-			queryRes, err := parseResponse(resp, query)
-			So(err, ShouldBeNil)
-			So(queryRes.Series[0].Name, ShouldEqual, "AWS/ApplicationELB_TargetResponseTime_Average")
-			So(queryRes.Series[0].Tags["LoadBalancer"], ShouldEqual, "lb")
-			So(queryRes.Series[0].Tags["TargetGroup"], ShouldEqual, "tg")
-			So(queryRes.Series[0].Points[0][0].String(), ShouldEqual, null.FloatFrom(10.0).String())
-			So(queryRes.Series[1].Points[0][0].String(), ShouldEqual, null.FloatFrom(20.0).String())
-			So(queryRes.Series[2].Points[0][0].String(), ShouldEqual, null.FloatFrom(30.0).String())
-			So(queryRes.Series[3].Points[0][0].String(), ShouldEqual, null.FloatFrom(40.0).String())
-			So(queryRes.Meta.Get("unit").MustString(), ShouldEqual, "s")
+			series := parseMetricDataResult(result, &metricDataQuery{Id: "queryA", Stat: "Average", Query: query})
+			So(series.Name, ShouldEqual, "AWS/ApplicationELB_TargetResponseTime_Average")
+			So(series.Tags["LoadBalancer"], ShouldEqual, "lb")
+			So(series.Tags["TargetGroup"], ShouldEqual, "tg")
+			So(series.Points[0][0].String(), ShouldEqual, null.FloatFrom(10.0).String())
 		})
 
 		Convey("terminate gap of data points", func() {
 			timestamp := time.Unix(0, 0)
-			resp := &cloudwatch.GetMetricStatisticsOutput{
-				Label: aws.String("TargetResponseTime"),
-				Datapoints: []*cloudwatch.Datapoint{
-					{
-						Timestamp: aws.Time(timestamp),
-						Average:   aws.Float64(10.0),
-						Maximum:   aws.Float64(20.0),
-						ExtendedStatistics: map[string]*float64{
-							"p50.00": aws.Float64(30.0),
-							"p90.00": aws.Float64(40.0),
-						},
-						Unit: aws.String("Seconds"),
-					},
-					{
-						Timestamp: aws.Time(timestamp.Add(60 * time.Second)),
-						Average:   aws.Float64(20.0),
-						Maximum:   aws.Float64(30.0),
-						ExtendedStatistics: map[string]*float64{
-							"p50.00": aws.Float64(40.0),
-							"p90.00": aws.Float64(50.0),
-						},
-						Unit: aws.String("Seconds"),
-					},
-					{
-						Timestamp: aws.Time(timestamp.Add(180 * time.Second)),
-						Average:   aws.Float64(30.0),
-						Maximum:   aws.Float64(40.0),
-						ExtendedStatistics: map[string]*float64{
-							"p50.00": aws.Float64(50.0),
-							"p90.00": aws.Float64(60.0),
-						},
-						Unit: aws.String("Seconds"),
-					},
-				},
-			}
 			query := &CloudWatchQuery{
 				Region:     "us-east-1",
 				Namespace:  "AWS/ApplicationELB",
 				MetricName: "TargetResponseTime",
-				Dimensions: []*cloudwatch.Dimension{
+				Statistics: []*string{aws.String("Average")},
+				Period:     60,
+				Alias:      "{{namespace}}_{{metric}}_{{stat}}",
+			}
+			result := &cloudwatch.MetricDataResult{
+				Id:         aws.String("queryA"),
+				StatusCode: aws.String("Complete"),
+				Timestamps: []*time.Time{
+					aws.Time(timestamp.Add(180 * time.Second)),
+					aws.Time(timestamp),
+					aws.Time(timestamp.Add(60 * time.Second)),
+				},
+				Values: []*float64{aws.Float64(30.0), aws.Float64(10.0), aws.Float64(20.0)},
+			}
+
+			series := parseMetricDataResult(result, &metricDataQuery{Id: "queryA", Stat: "Average", Query: query})
+			So(len(series.Points), ShouldEqual, 4)
+			So(series.Points[0][0].String(), ShouldEqual, null.FloatFrom(10.0).String())
+			So(series.Points[1][0].String(), ShouldEqual, null.FloatFrom(20.0).String())
+			So(series.Points[2][0].String(), ShouldEqual, null.FloatFromPtr(nil).String())
+			So(series.Points[2][1].Float64, ShouldEqual, 120000)
+			So(series.Points[3][0].String(), ShouldEqual, null.FloatFrom(30.0).String())
+		})
+
+		Convey("formats aliases", func() {
+			query := &CloudWatchQuery{
+				Region:     "us-east-1",
+				Namespace:  "AWS/EC2",
+				MetricName: "CPUUtilization",
+				Period:     300,
+				Id:         "cpu",
+				Alias:      "{{InstanceId}} {{stat}} {{period}} {{label}}",
+			}
+
+			So(formatAlias(query, "Maximum", map[string]string{"InstanceId": "i-1"}, "CPU"), ShouldEqual, "i-1 Maximum 300 CPU")
+			So(formatAlias(query, "Maximum", map[string]string{}, ""), ShouldEqual, "{{InstanceId}} Maximum 300 cpu")
+		})
+
+		Convey("metric math", func() {
+			newQuery := func(refId string, model map[string]interface{}) *CloudWatchQuery {
+				model["region"] = "us-east-1"
+				if _, ok := model["expression"]; !ok {
+					model["namespace"] = "AWS/EC2"
+					model["metricName"] = "CPUUtilization"
+					model["statistics"] = []interface{}{"Average"}
+				}
+				query, err := parseQuery(simplejson.NewFromAny(model))
+				So(err, ShouldBeNil)
+				query.RefId = refId
+				return query
+			}
+
+			Convey("finds the ids referenced by an expression", func() {
+				refs := expressionReferences(`SUM(METRICS('m')) / m1 * 100 + FILL(m_2, 0) + m1`)
+				So(refs, ShouldResemble, []string{"m1", "m_2"})
+			})
+
+			Convey("expressions need an id", func() {
+				_, err := buildMetricDataBatches([]*CloudWatchQuery{
+					newQuery("A", map[string]interface{}{"expression": "m1 * 2"}),
+				})
+				So(err, ShouldNotBeNil)
+				So(err.(*queryError).RefId, ShouldEqual, "A")
+				So(err.Error(), ShouldEqual, "Invalid query: id should be set if using expression")
+			})
+
+			Convey("expressions can only reference known ids", func() {
+				_, err := buildMetricDataBatches([]*CloudWatchQuery{
+					newQuery("A", map[string]interface{}{"id": "m1"}),
+					newQuery("B", map[string]interface{}{"id": "e1", "expression": "m1 + m2"}),
+				})
+				So(err, ShouldNotBeNil)
+				So(err.(*queryError).RefId, ShouldEqual, "B")
+				So(err.Error(), ShouldEqual, "Invalid query: expression of e1 references unknown id m2")
+			})
+
+			Convey("hidden queries are returned", func() {
+				So(newQuery("A", map[string]interface{}{"id": "m1", "hide": true}).ReturnData, ShouldBeTrue)
+				So(newQuery("B", map[string]interface{}{}).ReturnData, ShouldBeTrue)
+				So(newQuery("C", map[string]interface{}{"id": "m2", "returnData": false}).ReturnData, ShouldBeFalse)
+			})
+
+			Convey("expressions are kept in the batch of the metrics they reference", func() {
+				queries := []*CloudWatchQuery{}
+				for i := 0; i < 99; i++ {
+					queries = append(queries, newQuery(fmt.Sprintf("Q%d", i), map[string]interface{}{}))
+				}
+				queries = append(queries, newQuery("M", map[string]interface{}{"id": "m1", "hide": true}))
+				queries = append(queries, newQuery("E", map[string]interface{}{"id": "e1", "expression": "m1 * 2"}))
+
+				batches, err := buildMetricDataBatches(queries)
+				So(err, ShouldBeNil)
+				So(len(batches), ShouldEqual, 2)
+				So(len(batches[0]), ShouldEqual, 99)
+				So(len(batches[1]), ShouldEqual, 2)
+				So(batches[1][0].Id, ShouldEqual, "m1")
+				So(batches[1][1].Id, ShouldEqual, "e1")
+			})
+		})
+
+		Convey("executes queries with GetMetricData", func() {
+			fake := &fakeCloudWatchClient{}
+			e := &CloudWatchExecutor{cloudwatchSvc: fake}
+			ds := &models.DataSource{JsonData: simplejson.New()}
+
+			queries := []*tsdb.Query{}
+			for i := 0; i < 150; i++ {
+				queries = append(queries, &tsdb.Query{
+					RefId: fmt.Sprintf("A%d", i),
+					Model: simplejson.NewFromAny(map[string]interface{}{
+						"region":     "us-east-1",
+						"namespace":  "AWS/EC2",
+						"metricName": "CPUUtilization",
+						"dimensions": map[string]interface{}{"InstanceId": fmt.Sprintf("i-%d", i)},
+						"statistics": []interface{}{"Average"},
+						"alias":      "{{InstanceId}}",
+					}),
+				})
+			}
+			queries = append(queries, &tsdb.Query{
+				RefId: "B",
+				Model: simplejson.NewFromAny(map[string]interface{}{
+					"region":     "us-east-1",
+					"namespace":  "AWS/EC2",
+					"metricName": "NetworkIn",
+					"statistics": []interface{}{"Sum"},
+					"alias":      "${PROP('MetricName')} {{stat}}",
+				}),
+			})
+
+			res, err := e.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("now-1h", "now"),
+				Queries:   queries,
+			})
+			So(err, ShouldBeNil)
+
+			So(len(fake.inputs), ShouldEqual, 2)
+			total := 0
+			for _, input := range fake.inputs {
+				So(len(input.MetricDataQueries), ShouldBeLessThanOrEqualTo, maxMetricDataQueries)
+				total += len(input.MetricDataQueries)
+			}
+			So(total, ShouldEqual, 151)
+
+			So(len(res.Results), ShouldEqual, 151)
+			So(res.Results["A7"].Series[0].Name, ShouldEqual, "i-7")
+			So(res.Results["A7"].Series[0].Tags["InstanceId"], ShouldEqual, "i-7")
+			So(res.Results["B"].Series[0].Name, ShouldEqual, "${PROP('MetricName')} Sum")
+		})
+
+		Convey("executes statistics and extended statistics with GetMetricData", func() {
+			results := map[string][]*cloudwatch.MetricDataResult{}
+			for i, value := range []float64{10, 20, 30, 40} {
+				id := fmt.Sprintf("queryA_%d", i)
+				results[id] = []*cloudwatch.MetricDataResult{{
+					Id:         aws.String(id),
+					Label:      aws.String("TargetResponseTime"),
+					StatusCode: aws.String("Complete"),
+					Timestamps: []*time.Time{aws.Time(time.Unix(0, 0))},
+					Values:     []*float64{aws.Float64(value)},
+				}}
+			}
+			fake := &fakeCloudWatchClient{results: results}
+			e := &CloudWatchExecutor{cloudwatchSvc: fake}
+			ds := &models.DataSource{JsonData: simplejson.New()}
+
+			res, err := e.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("now-1h", "now"),
+				Queries: []*tsdb.Query{
 					{
-						Name:  aws.String("LoadBalancer"),
-						Value: aws.String("lb"),
+						RefId: "A",
+						Model: simplejson.NewFromAny(map[string]interface{}{
+							"region":     "us-east-1",
+							"namespace":  "AWS/ApplicationELB",
+							"metricName": "TargetResponseTime",
+							"statistics": []interface{}{"Average", "Maximum", "p50.00", "p90.00"},
+							"alias":      "{{metric}}_{{stat}}",
+						}),
 					},
-					{
-						Name:  aws.String("TargetGroup"),
-						Value: aws.String("tg"),
+				},
+			})
+			So(err, ShouldBeNil)
+
+			So(len(fake.inputs), ShouldEqual, 1)
+			stats := []string{}
+			for _, mdq := range fake.inputs[0].MetricDataQueries {
+				stats = append(stats, *mdq.MetricStat.Stat)
+			}
+			So(stats, ShouldResemble, []string{"Average", "Maximum", "p50.00", "p90.00"})
+
+			series := res.Results["A"].Series
+			So(len(series), ShouldEqual, 4)
+			for i, stat := range stats {
+				So(series[i].Name, ShouldEqual, "TargetResponseTime_"+stat)
+				So(series[i].Points[0][0].String(), ShouldEqual, null.FloatFrom(float64(i+1)*10).String())
+			}
+		})
+
+		Convey("returns the series of a search expression", func() {
+			fake := &fakeCloudWatchClient{
+				results: map[string][]*cloudwatch.MetricDataResult{
+					"e1": {
+						{Id: aws.String("e1"), Label: aws.String("i-1"), StatusCode: aws.String("Complete")},
+						{Id: aws.String("e1"), Label: aws.String("i-2"), StatusCode: aws.String("Complete")},
 					},
 				},
-				Statistics:         []*string{aws.String("Average"), aws.String("Maximum")},
-				ExtendedStatistics: []*string{aws.String("p50.00"), aws.String("p90.00")},
-				Period:             60,
-				Alias:              "{{namespace}}_{{metric}}_{{stat}}",
 			}
+			e := &CloudWatchExecutor{cloudwatchSvc: fake}
+			ds := &models.DataSource{JsonData: simplejson.New()}
 
-			queryRes, err := parseResponse(resp, query)
+			res, err := e.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("now-1h", "now"),
+				Queries: []*tsdb.Query{
+					{
+						RefId: "A",
+						Model: simplejson.NewFromAny(map[string]interface{}{
+							"region":     "us-east-1",
+							"id":         "e1",
+							"expression": `SEARCH('{AWS/EC2,InstanceId} MetricName="CPUUtilization"', 'Average', 300)`,
+						}),
+					},
+				},
+			})
 			So(err, ShouldBeNil)
-			So(queryRes.Series[0].Points[0][0].String(), ShouldEqual, null.FloatFrom(10.0).String())
-			So(queryRes.Series[1].Points[0][0].String(), ShouldEqual, null.FloatFrom(20.0).String())
-			So(queryRes.Series[2].Points[0][0].String(), ShouldEqual, null.FloatFrom(30.0).String())
-			So(queryRes.Series[3].Points[0][0].String(), ShouldEqual, null.FloatFrom(40.0).String())
-			So(queryRes.Series[0].Points[1][0].String(), ShouldEqual, null.FloatFrom(20.0).String())
-			So(queryRes.Series[1].Points[1][0].String(), ShouldEqual, null.FloatFrom(30.0).String())
-			So(queryRes.Series[2].Points[1][0].String(), ShouldEqual, null.FloatFrom(40.0).String())
-			So(queryRes.Series[3].Points[1][0].String(), ShouldEqual, null.FloatFrom(50.0).String())
-			So(queryRes.Series[0].Points[2][0].String(), ShouldEqual, null.FloatFromPtr(nil).String())
-			So(queryRes.Series[1].Points[2][0].String(), ShouldEqual, null.FloatFromPtr(nil).String())
-			So(queryRes.Series[2].Points[2][0].String(), ShouldEqual, null.FloatFromPtr(nil).String())
-			So(queryRes.Series[3].Points[2][0].String(), ShouldEqual, null.FloatFromPtr(nil).String())
-			So(queryRes.Series[0].Points[3][0].String(), ShouldEqual, null.FloatFrom(30.0).String())
-			So(queryRes.Series[1].Points[3][0].String(), ShouldEqual, null.FloatFrom(40.0).String())
-			So(queryRes.Series[2].Points[3][0].String(), ShouldEqual, null.FloatFrom(50.0).String())
-			So(queryRes.Series[3].Points[3][0].String(), ShouldEqual, null.FloatFrom(60.0).String())
+
+			So(len(res.Results["A"].Series), ShouldEqual, 2)
+			So(res.Results["A"].Series[0].Name, ShouldEqual, "i-1")
+			So(res.Results["A"].Series[1].Name, ShouldEqual, "i-2")
 		})
 	})
 }
+
+type fakeCloudWatchClient struct {
+	cloudWatchAPI
+
+	mu      sync.Mutex
+	inputs  []*cloudwatch.GetMetricDataInput
+	results map[string][]*cloudwatch.MetricDataResult
+}
+
+func (c *fakeCloudWatchClient) GetMetricDataWithContext(ctx aws.Context, input *cloudwatch.GetMetricDataInput, opts ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	c.mu.Lock()
+	c.inputs = append(c.inputs, input)
+	c.mu.Unlock()
+
+	output := &cloudwatch.GetMetricDataOutput{}
+	for _, mdq := range input.MetricDataQueries {
+		if results, ok := c.results[*mdq.Id]; ok {
+			output.MetricDataResults = append(output.MetricDataResults, results...)
+			continue
+		}
+
+		label := mdq.Label
+		if label == nil && mdq.MetricStat != nil {
+			label = mdq.MetricStat.Metric.MetricName
+		}
+		output.MetricDataResults = append(output.MetricDataResults, &cloudwatch.MetricDataResult{
+			Id:         mdq.Id,
+			Label:      label,
+			StatusCode: aws.String("Complete"),
+			Timestamps: []*time.Time{input.StartTime},
+			Values:     []*float64{aws.Float64(1)},
+		})
+	}
+
+	return output, nil
+}
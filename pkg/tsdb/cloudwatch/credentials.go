@@ -26,8 +26,23 @@ type cache struct {
 var awsCredentialCache = make(map[string]cache)
 var credentialCacheLock sync.RWMutex
 
+type stsAPI interface {
+	AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+}
+
+var newSTSClient = func(cfg *aws.Config) (stsAPI, error) {
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sts.New(sess, cfg), nil
+}
+
 func GetCredentials(dsInfo *DatasourceInfo) (*credentials.Credentials, error) {
-	cacheKey := dsInfo.AccessKey + ":" + dsInfo.Profile + ":" + dsInfo.AssumeRoleArn
+	cacheKey := dsInfo.AccessKey + ":" + dsInfo.Profile + ":" + dsInfo.AssumeRoleArn + ":" + dsInfo.ExternalId
+	for _, role := range dsInfo.AssumeRoleChain {
+		cacheKey += ":" + role.RoleArn + ":" + role.ExternalId
+	}
 	credentialCacheLock.RLock()
 	if _, ok := awsCredentialCache[cacheKey]; ok {
 		if awsCredentialCache[cacheKey].expiration != nil &&
@@ -44,12 +59,6 @@ func GetCredentials(dsInfo *DatasourceInfo) (*credentials.Credentials, error) {
 	sessionToken := ""
 	var expiration *time.Time = nil
 	if dsInfo.AuthType == "arn" && strings.Index(dsInfo.AssumeRoleArn, "arn:aws:iam:") == 0 {
-		params := &sts.AssumeRoleInput{
-			RoleArn:         aws.String(dsInfo.AssumeRoleArn),
-			RoleSessionName: aws.String("GrafanaSession"),
-			DurationSeconds: aws.Int64(900),
-		}
-
 		stsSess, err := session.NewSession()
 		if err != nil {
 			return nil, err
@@ -60,25 +69,43 @@ func GetCredentials(dsInfo *DatasourceInfo) (*credentials.Credentials, error) {
 				&credentials.SharedCredentialsProvider{Filename: "", Profile: dsInfo.Profile},
 				remoteCredProvider(stsSess),
 			})
-		stsConfig := &aws.Config{
-			Region:      aws.String(dsInfo.Region),
-			Credentials: stsCreds,
-		}
 
-		sess, err := session.NewSession(stsConfig)
-		if err != nil {
-			return nil, err
-		}
-		svc := sts.New(sess, stsConfig)
-		resp, err := svc.AssumeRole(params)
-		if err != nil {
-			return nil, err
-		}
-		if resp.Credentials != nil {
+		// every role of the chain is assumed with the credentials of the previous one
+		roles := append([]AssumeRole{{RoleArn: dsInfo.AssumeRoleArn, ExternalId: dsInfo.ExternalId}}, dsInfo.AssumeRoleChain...)
+		for _, role := range roles {
+			if strings.Index(role.RoleArn, "arn:") != 0 {
+				return nil, fmt.Errorf("Invalid role ARN in assume role chain: %s", role.RoleArn)
+			}
+
+			params := &sts.AssumeRoleInput{
+				RoleArn:         aws.String(role.RoleArn),
+				RoleSessionName: aws.String("GrafanaSession"),
+				DurationSeconds: aws.Int64(900),
+			}
+			if role.ExternalId != "" {
+				params.ExternalId = aws.String(role.ExternalId)
+			}
+
+			svc, err := newSTSClient(&aws.Config{
+				Region:      aws.String(dsInfo.Region),
+				Credentials: stsCreds,
+			})
+			if err != nil {
+				return nil, err
+			}
+			resp, err := svc.AssumeRole(params)
+			if err != nil {
+				return nil, err
+			}
+			if resp.Credentials == nil {
+				return nil, fmt.Errorf("No credentials returned when assuming role %s", role.RoleArn)
+			}
+
 			accessKeyId = *resp.Credentials.AccessKeyId
 			secretAccessKey = *resp.Credentials.SecretAccessKey
 			sessionToken = *resp.Credentials.SessionToken
 			expiration = resp.Credentials.Expiration
+			stsCreds = credentials.NewStaticCredentials(accessKeyId, secretAccessKey, sessionToken)
 		}
 	} else {
 		now := time.Now()
@@ -148,6 +175,21 @@ func (e *CloudWatchExecutor) getDsInfo(region string) *DatasourceInfo {
 
 	authType := e.DataSource.JsonData.Get("authType").MustString()
 	assumeRoleArn := e.DataSource.JsonData.Get("assumeRoleArn").MustString()
+	externalId := e.DataSource.JsonData.Get("externalId").MustString()
+
+	// assumeRoleChain lists the roles assumed after assumeRoleArn, either as
+	// ARNs or as objects with roleArn and externalId
+	assumeRoleChain := []AssumeRole{}
+	for _, item := range e.DataSource.JsonData.Get("assumeRoleChain").MustArray() {
+		switch role := item.(type) {
+		case string:
+			assumeRoleChain = append(assumeRoleChain, AssumeRole{RoleArn: role})
+		case map[string]interface{}:
+			roleArn, _ := role["roleArn"].(string)
+			roleExternalId, _ := role["externalId"].(string)
+			assumeRoleChain = append(assumeRoleChain, AssumeRole{RoleArn: roleArn, ExternalId: roleExternalId})
+		}
+	}
 	accessKey := ""
 	secretKey := ""
 	for key, value := range e.DataSource.SecureJsonData.Decrypt() {
@@ -164,6 +206,7 @@ func (e *CloudWatchExecutor) getDsInfo(region string) *DatasourceInfo {
 		Profile:       e.DataSource.Database,
 		AuthType:      authType,
 		AssumeRoleArn: assumeRoleArn,
+		ExternalId:    externalId,
 		AccessKey:     accessKey,
 		SecretKey:     secretKey,
 	}
@@ -184,7 +227,11 @@ func (e *CloudWatchExecutor) getAwsConfig(dsInfo *DatasourceInfo) (*aws.Config,
 	return cfg, nil
 }
 
-func (e *CloudWatchExecutor) getClient(region string) (*cloudwatch.CloudWatch, error) {
+func (e *CloudWatchExecutor) getClient(region string) (cloudWatchAPI, error) {
+	if e.cloudwatchSvc != nil {
+		return e.cloudwatchSvc, nil
+	}
+
 	datasourceInfo := e.getDsInfo(region)
 	cfg, err := e.getAwsConfig(datasourceInfo)
 	if err != nil {
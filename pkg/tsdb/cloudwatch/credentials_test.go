@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/credentials/endpointcreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -41,3 +44,79 @@ func TestDefaultEC2RoleProvider(t *testing.T) {
 		So(ok, ShouldBeTrue)
 	})
 }
+
+type fakeSTSClient struct {
+	inputs  *[]*sts.AssumeRoleInput
+	configs *[]*aws.Config
+}
+
+func (c fakeSTSClient) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	*c.inputs = append(*c.inputs, input)
+	n := len(*c.inputs)
+	return &sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(fmt.Sprintf("key%d", n)),
+			SecretAccessKey: aws.String(fmt.Sprintf("secret%d", n)),
+			SessionToken:    aws.String(fmt.Sprintf("token%d", n)),
+			Expiration:      aws.Time(time.Now().Add(15 * time.Minute)),
+		},
+	}, nil
+}
+
+func TestAssumeRoleChain(t *testing.T) {
+	Convey("Assuming a chain of roles", t, func() {
+		inputs := []*sts.AssumeRoleInput{}
+		configs := []*aws.Config{}
+
+		origNewSTSClient := newSTSClient
+		newSTSClient = func(cfg *aws.Config) (stsAPI, error) {
+			configs = append(configs, cfg)
+			return fakeSTSClient{inputs: &inputs, configs: &configs}, nil
+		}
+		defer func() { newSTSClient = origNewSTSClient }()
+
+		creds, err := GetCredentials(&DatasourceInfo{
+			AuthType:      "arn",
+			Region:        "us-east-1",
+			AccessKey:     "chain-test",
+			AssumeRoleArn: "arn:aws:iam::111111111111:role/first",
+			ExternalId:    "first-external-id",
+			AssumeRoleChain: []AssumeRole{
+				{RoleArn: "arn:aws:iam::222222222222:role/second", ExternalId: "second-external-id"},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		So(len(inputs), ShouldEqual, 2)
+		So(*inputs[0].RoleArn, ShouldEqual, "arn:aws:iam::111111111111:role/first")
+		So(*inputs[0].ExternalId, ShouldEqual, "first-external-id")
+		So(*inputs[1].RoleArn, ShouldEqual, "arn:aws:iam::222222222222:role/second")
+		So(*inputs[1].ExternalId, ShouldEqual, "second-external-id")
+
+		previous, err := configs[1].Credentials.Get()
+		So(err, ShouldBeNil)
+		So(previous.AccessKeyID, ShouldEqual, "key1")
+
+		value, err := creds.Get()
+		So(err, ShouldBeNil)
+		So(value.AccessKeyID, ShouldEqual, "key2")
+		So(value.SessionToken, ShouldEqual, "token2")
+	})
+
+	Convey("Assuming a chain with an invalid role", t, func() {
+		origNewSTSClient := newSTSClient
+		newSTSClient = func(cfg *aws.Config) (stsAPI, error) {
+			return fakeSTSClient{inputs: &[]*sts.AssumeRoleInput{}}, nil
+		}
+		defer func() { newSTSClient = origNewSTSClient }()
+
+		_, err := GetCredentials(&DatasourceInfo{
+			AuthType:        "arn",
+			Region:          "us-east-1",
+			AccessKey:       "chain-invalid-test",
+			AssumeRoleArn:   "arn:aws:iam::111111111111:role/first",
+			AssumeRoleChain: []AssumeRole{{RoleArn: "second"}},
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,370 @@
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// maxMetricDataQueries is the maximum number of metrics and expressions
+// CloudWatch accepts in a single GetMetricData call.
+const maxMetricDataQueries = 100
+
+var (
+	validMetricDataId  = regexp.MustCompile(`^[a-z][a-zA-Z0-9_]*$`)
+	expressionIdent    = regexp.MustCompile(`'[^']*'|"[^"]*"|\b[a-z][a-zA-Z0-9_]*\b`)
+	invalidIdCharacter = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// metricDataQuery is a single metric or expression of a GetMetricData call.
+// A query with several statistics is sent as one metric per statistic.
+type metricDataQuery struct {
+	Id    string
+	Stat  string
+	Query *CloudWatchQuery
+}
+
+type metricDataBatch []*metricDataQuery
+
+func (b metricDataBatch) refIds() []string {
+	seen := make(map[string]bool)
+	refIds := make([]string, 0)
+	for _, mdq := range b {
+		if !seen[mdq.Query.RefId] {
+			seen[mdq.Query.RefId] = true
+			refIds = append(refIds, mdq.Query.RefId)
+		}
+	}
+	return refIds
+}
+
+// queryError is returned for a query that cannot be sent to CloudWatch.
+type queryError struct {
+	RefId string
+	Err   error
+}
+
+func (e *queryError) Error() string {
+	return e.Err.Error()
+}
+
+// buildMetricDataBatches splits the queries of a region into batches that fit
+// in one GetMetricData call. Expressions are kept in the same batch as the
+// metrics they reference.
+func buildMetricDataBatches(queries []*CloudWatchQuery) ([]metricDataBatch, error) {
+	byId := make(map[string]*metricDataQuery)
+	ordered := make([]*metricDataQuery, 0)
+
+	for _, query := range queries {
+		mdqs, err := expandQuery(query)
+		if err != nil {
+			return nil, &queryError{RefId: query.RefId, Err: err}
+		}
+
+		for _, mdq := range mdqs {
+			if _, exists := byId[mdq.Id]; exists {
+				return nil, &queryError{RefId: query.RefId, Err: fmt.Errorf("Invalid query: duplicate id %s", mdq.Id)}
+			}
+			byId[mdq.Id] = mdq
+			ordered = append(ordered, mdq)
+		}
+	}
+
+	// group the metrics referenced by expressions
+	parent := make(map[string]string)
+	var find func(id string) string
+	find = func(id string) string {
+		if p, ok := parent[id]; ok && p != id {
+			parent[id] = find(p)
+			return parent[id]
+		}
+		return id
+	}
+
+	for _, mdq := range ordered {
+		if mdq.Query.Expression == "" {
+			continue
+		}
+
+		for _, ref := range expressionReferences(mdq.Query.Expression) {
+			if _, ok := byId[ref]; !ok {
+				return nil, &queryError{RefId: mdq.Query.RefId, Err: fmt.Errorf("Invalid query: expression of %s references unknown id %s", mdq.Id, ref)}
+			}
+			if ref == mdq.Id {
+				return nil, &queryError{RefId: mdq.Query.RefId, Err: fmt.Errorf("Invalid query: expression of %s references itself", mdq.Id)}
+			}
+			parent[find(ref)] = find(mdq.Id)
+		}
+	}
+
+	groups := make(map[string]metricDataBatch)
+	roots := make([]string, 0)
+	for _, mdq := range ordered {
+		root := find(mdq.Id)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], mdq)
+	}
+
+	batches := make([]metricDataBatch, 0)
+	var current metricDataBatch
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) > maxMetricDataQueries {
+			return nil, &queryError{RefId: group[0].Query.RefId, Err: fmt.Errorf("Invalid query: metric math expressions reference more than %d metrics", maxMetricDataQueries)}
+		}
+
+		if len(current)+len(group) > maxMetricDataQueries {
+			batches = append(batches, current)
+			current = nil
+		}
+		current = append(current, group...)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+
+	return batches, nil
+}
+
+func expandQuery(query *CloudWatchQuery) ([]*metricDataQuery, error) {
+	if query.Id != "" && !validMetricDataId.MatchString(query.Id) {
+		return nil, fmt.Errorf("Invalid query: id %s must start with a lowercase letter and contain only letters, numbers and underscores", query.Id)
+	}
+
+	if query.Expression != "" {
+		if query.Id == "" {
+			return nil, errors.New("Invalid query: id should be set if using expression")
+		}
+		return []*metricDataQuery{{Id: query.Id, Query: query}}, nil
+	}
+
+	stats := append(aws.StringValueSlice(query.Statistics), aws.StringValueSlice(query.ExtendedStatistics)...)
+	if len(stats) == 0 {
+		return nil, errors.New("Invalid query: at least one statistic is required")
+	}
+
+	if query.Id != "" {
+		if len(stats) != 1 {
+			return nil, errors.New("Statistics count should be 1")
+		}
+		return []*metricDataQuery{{Id: query.Id, Stat: stats[0], Query: query}}, nil
+	}
+
+	baseId := "query" + invalidIdCharacter.ReplaceAllString(query.RefId, "_")
+	result := make([]*metricDataQuery, 0, len(stats))
+	for i, stat := range stats {
+		id := baseId
+		if len(stats) > 1 {
+			id = fmt.Sprintf("%s_%d", baseId, i)
+		}
+		result = append(result, &metricDataQuery{Id: id, Stat: stat, Query: query})
+	}
+
+	return result, nil
+}
+
+// expressionReferences returns the ids used in a metric math expression.
+// Functions are upper case and ids must start with a lower case letter, so
+// every lower case identifier outside of a string is a reference.
+func expressionReferences(expression string) []string {
+	seen := make(map[string]bool)
+	refs := make([]string, 0)
+	for _, ident := range expressionIdent.FindAllString(expression, -1) {
+		if strings.HasPrefix(ident, "'") || strings.HasPrefix(ident, `"`) || seen[ident] {
+			continue
+		}
+		seen[ident] = true
+		refs = append(refs, ident)
+	}
+
+	return refs
+}
+
+func hasDynamicLabel(query *CloudWatchQuery) bool {
+	return strings.Contains(query.Alias, "${")
+}
+
+func (mdq *metricDataQuery) toMetricDataQuery() *cloudwatch.MetricDataQuery {
+	query := mdq.Query
+	result := &cloudwatch.MetricDataQuery{
+		Id:         aws.String(mdq.Id),
+		ReturnData: aws.Bool(query.ReturnData),
+	}
+
+	if hasDynamicLabel(query) {
+		result.Label = aws.String(formatAlias(query, mdq.Stat, dimensionTags(query), "${LABEL}"))
+	}
+
+	if query.Expression != "" {
+		result.Expression = aws.String(query.Expression)
+		return result
+	}
+
+	result.MetricStat = &cloudwatch.MetricStat{
+		Metric: &cloudwatch.Metric{
+			Namespace:  aws.String(query.Namespace),
+			MetricName: aws.String(query.MetricName),
+			Dimensions: query.Dimensions,
+		},
+		Period: aws.Int64(int64(query.Period)),
+		Stat:   aws.String(mdq.Stat),
+	}
+
+	return result
+}
+
+func (e *CloudWatchExecutor) executeGetMetricDataBatch(ctx context.Context, region string, batch metricDataBatch, startTime time.Time, endTime time.Time) ([]*tsdb.QueryResult, error) {
+	client, err := e.getClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(startTime),
+		EndTime:   aws.Time(endTime),
+		ScanBy:    aws.String("TimestampAscending"),
+	}
+
+	byId := make(map[string]*metricDataQuery)
+	for _, mdq := range batch {
+		// 1 minutes resolution metrics is stored for 15 days, 15 * 24 * 60 = 21600
+		if mdq.Query.HighResolution && mdq.Query.Period > 0 && (((endTime.Unix() - startTime.Unix()) / int64(mdq.Query.Period)) > 21600) {
+			return nil, errors.New("too long query period")
+		}
+
+		byId[mdq.Id] = mdq
+		params.MetricDataQueries = append(params.MetricDataQueries, mdq.toMetricDataQuery())
+	}
+
+	if setting.Env == setting.DEV {
+		plog.Debug("CloudWatch GetMetricData", "raw query", params)
+	}
+
+	// results of a SEARCH expression share the id and differ by label
+	type resultKey struct {
+		id    string
+		label string
+	}
+	keys := make([]resultKey, 0)
+	mdr := make(map[resultKey]*cloudwatch.MetricDataResult)
+	for {
+		resp, err := client.GetMetricDataWithContext(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		metrics.M_Aws_CloudWatch_GetMetricData.Add(float64(len(params.MetricDataQueries)))
+
+		for _, r := range resp.MetricDataResults {
+			key := resultKey{id: aws.StringValue(r.Id), label: aws.StringValue(r.Label)}
+			if existing, ok := mdr[key]; ok {
+				existing.Timestamps = append(existing.Timestamps, r.Timestamps...)
+				existing.Values = append(existing.Values, r.Values...)
+				existing.StatusCode = r.StatusCode
+			} else {
+				mdr[key] = r
+				keys = append(keys, key)
+			}
+		}
+
+		if resp.NextToken == nil || *resp.NextToken == "" {
+			break
+		}
+		params.NextToken = resp.NextToken
+	}
+
+	queryResults := make(map[string]*tsdb.QueryResult)
+	queryResponses := make([]*tsdb.QueryResult, 0)
+	for _, key := range keys {
+		r := mdr[key]
+		mdq, ok := byId[key.id]
+		if !ok {
+			continue
+		}
+
+		queryRes, ok := queryResults[mdq.Query.RefId]
+		if !ok {
+			queryRes = tsdb.NewQueryResult()
+			queryRes.RefId = mdq.Query.RefId
+			queryRes.Meta = simplejson.New()
+			queryResults[mdq.Query.RefId] = queryRes
+			queryResponses = append(queryResponses, queryRes)
+		}
+
+		if status := aws.StringValue(r.StatusCode); status != "Complete" {
+			queryRes.Error = fmt.Errorf("Part of query is failed: %s", status)
+			continue
+		}
+
+		queryRes.Series = append(queryRes.Series, parseMetricDataResult(r, mdq))
+	}
+
+	return queryResponses, nil
+}
+
+// parseMetricDataResult converts a result to a time series. Missing periods
+// of metrics are filled with null points so graphs show the gaps.
+func parseMetricDataResult(r *cloudwatch.MetricDataResult, mdq *metricDataQuery) *tsdb.TimeSeries {
+	query := mdq.Query
+	series := &tsdb.TimeSeries{
+		Tags:   dimensionTags(query),
+		Points: make([]tsdb.TimePoint, 0),
+	}
+
+	if hasDynamicLabel(query) {
+		series.Name = aws.StringValue(r.Label)
+	} else {
+		series.Name = formatAlias(query, mdq.Stat, series.Tags, aws.StringValue(r.Label))
+	}
+
+	indexes := make([]int, 0, len(r.Timestamps))
+	for i := range r.Timestamps {
+		if i < len(r.Values) && r.Timestamps[i] != nil && r.Values[i] != nil {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return r.Timestamps[indexes[i]].Before(*r.Timestamps[indexes[j]])
+	})
+
+	period := time.Duration(query.Period) * time.Second
+	var last *time.Time
+	for _, i := range indexes {
+		timestamp := *r.Timestamps[i]
+
+		// terminate gap of data points
+		if last != nil && query.Expression == "" && period > 0 {
+			next := last.Add(period)
+			for timestamp.After(next) {
+				series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFromPtr(nil), float64(next.Unix()*1000)))
+				next = next.Add(period)
+			}
+		}
+		last = &timestamp
+
+		series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFrom(*r.Values[i]), float64(timestamp.Unix()*1000)))
+	}
+
+	return series
+}
+
+func dimensionTags(query *CloudWatchQuery) map[string]string {
+	tags := map[string]string{}
+	for _, d := range query.Dimensions {
+		tags[*d.Name] = *d.Value
+	}
+	return tags
+}
@@ -64,19 +64,18 @@ var NewClient = func(ctx context.Context, ds *models.DataSource, timeRange *tsdb
 
 	clientLog.Debug("Creating new client", "version", version, "timeField", timeField, "indices", strings.Join(indices, ", "))
 
-	switch version {
-	case 2, 5, 56, 60:
-		return &baseClientImpl{
-			ctx:       ctx,
-			ds:        ds,
-			version:   version,
-			timeField: timeField,
-			indices:   indices,
-			timeRange: timeRange,
-		}, nil
+	if !IsSupportedVersion(version) {
+		return nil, fmt.Errorf("elasticsearch version=%d is not supported", version)
 	}
 
-	return nil, fmt.Errorf("elasticsearch version=%d is not supported", version)
+	return &baseClientImpl{
+		ctx:       ctx,
+		ds:        ds,
+		version:   version,
+		timeField: timeField,
+		indices:   indices,
+		timeRange: timeRange,
+	}, nil
 }
 
 type baseClientImpl struct {
@@ -172,12 +171,12 @@ func (c *baseClientImpl) executeRequest(method, uriPath string, body []byte) (*h
 
 	if c.ds.BasicAuth {
 		clientLog.Debug("Request configured to use basic authentication")
-		req.SetBasicAuth(c.ds.BasicAuthUser, c.ds.BasicAuthPassword)
+		req.SetBasicAuth(c.ds.BasicAuthUser, c.ds.DecryptedBasicAuthPassword())
 	}
 
 	if !c.ds.BasicAuth && c.ds.User != "" {
 		clientLog.Debug("Request configured to use basic authentication")
-		req.SetBasicAuth(c.ds.User, c.ds.Password)
+		req.SetBasicAuth(c.ds.User, c.ds.DecryptedPassword())
 	}
 
 	httpClient, err := newDatasourceHttpClient(c.ds)
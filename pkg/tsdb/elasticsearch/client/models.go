@@ -45,6 +45,40 @@ type SearchResponseHits struct {
 	Total int64
 }
 
+// UnmarshalJSON decodes search response hits. Elasticsearch 7 returns the
+// total as an object with a value and relation instead of a number.
+func (h *SearchResponseHits) UnmarshalJSON(b []byte) error {
+	var hits struct {
+		Hits  []map[string]interface{} `json:"hits"`
+		Total json.RawMessage          `json:"total"`
+	}
+
+	if err := json.Unmarshal(b, &hits); err != nil {
+		return err
+	}
+
+	h.Hits = hits.Hits
+	h.Total = 0
+
+	if len(hits.Total) == 0 || string(hits.Total) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(hits.Total, &h.Total); err == nil {
+		return nil
+	}
+
+	var total struct {
+		Value int64 `json:"value"`
+	}
+	if err := json.Unmarshal(hits.Total, &total); err != nil {
+		return err
+	}
+	h.Total = total.Value
+
+	return nil
+}
+
 // SearchResponse represents a search response
 type SearchResponse struct {
 	Error        map[string]interface{} `json:"error"`
@@ -141,6 +175,12 @@ type RangeFilter struct {
 // DateFormatEpochMS represents a date format of epoch milliseconds (epoch_millis)
 const DateFormatEpochMS = "epoch_millis"
 
+// Sort orders
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
 // MarshalJSON returns the JSON encoding of the query string filter.
 func (f *RangeFilter) MarshalJSON() ([]byte, error) {
 	root := map[string]map[string]map[string]interface{}{
@@ -276,8 +316,11 @@ type MetricAggregation struct {
 
 // MarshalJSON returns the JSON encoding of the metric aggregation
 func (a *MetricAggregation) MarshalJSON() ([]byte, error) {
-	root := map[string]interface{}{
-		"field": a.Field,
+	root := map[string]interface{}{}
+
+	// top_hits and scripted_metric aggregations don't take a field
+	if a.Field != "" {
+		root["field"] = a.Field
 	}
 
 	for k, v := range a.Settings {
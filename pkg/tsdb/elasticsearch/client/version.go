@@ -0,0 +1,111 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// supportedVersions are the esVersion values accepted in the data source json data
+var supportedVersions = []int{2, 5, 56, 60, 70}
+
+// IsSupportedVersion returns true if the esVersion value is supported
+func IsSupportedVersion(version int) bool {
+	for _, v := range supportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseVersion converts an elasticsearch version string such as 6.4.2 to the
+// esVersion value stored in the data source json data.
+func ParseVersion(number string) (int, error) {
+	parts := strings.Split(number, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid elasticsearch version %q", number)
+	}
+
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+
+	switch {
+	case major == 2:
+		return 2, nil
+	case major == 5 && minor >= 6:
+		return 56, nil
+	case major == 5:
+		return 5, nil
+	case major == 6:
+		return 60, nil
+	case major == 7:
+		return 70, nil
+	}
+
+	return 0, fmt.Errorf("elasticsearch version %s is not supported", number)
+}
+
+type rootResponse struct {
+	Version struct {
+		Number string `json:"number"`
+	} `json:"version"`
+}
+
+// DetectVersion queries the root endpoint of the cluster and returns the
+// esVersion value matching the cluster version.
+func DetectVersion(ctx context.Context, ds *models.DataSource) (int, error) {
+	u, err := url.Parse(ds.Url)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("User-Agent", "Grafana")
+
+	if ds.BasicAuth {
+		req.SetBasicAuth(ds.BasicAuthUser, ds.DecryptedBasicAuthPassword())
+	}
+
+	if !ds.BasicAuth && ds.User != "" {
+		req.SetBasicAuth(ds.User, ds.DecryptedPassword())
+	}
+
+	httpClient, err := newDatasourceHttpClient(ds)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("failed to detect elasticsearch version, status code %d", res.StatusCode)
+	}
+
+	var root rootResponse
+	if err := json.NewDecoder(res.Body).Decode(&root); err != nil {
+		return 0, err
+	}
+
+	clientLog.Debug("Detected elasticsearch version", "version", root.Version.Number)
+
+	return ParseVersion(root.Version.Number)
+}
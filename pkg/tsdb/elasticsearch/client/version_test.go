@@ -0,0 +1,89 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVersion(t *testing.T) {
+	Convey("Test elasticsearch version", t, func() {
+		Convey("ParseVersion", func() {
+			versions := map[string]int{
+				"2.4.6":  2,
+				"5.1.2":  5,
+				"5.6.16": 56,
+				"6.8.0":  60,
+				"7.4.2":  70,
+			}
+
+			for number, expected := range versions {
+				version, err := ParseVersion(number)
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, expected)
+			}
+
+			_, err := ParseVersion("1.7.0")
+			So(err, ShouldNotBeNil)
+
+			_, err = ParseVersion("abc")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("DetectVersion", func() {
+			status := http.StatusOK
+			var req *http.Request
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				req = r
+				rw.WriteHeader(status)
+				rw.Write([]byte(`{"name": "node-1", "version": {"number": "7.2.0"}, "tagline": "You Know, for Search"}`))
+			}))
+			defer ts.Close()
+
+			ds := &models.DataSource{
+				Url:           ts.URL,
+				BasicAuth:     true,
+				BasicAuthUser: "user",
+			}
+
+			Convey("Should return version of cluster", func() {
+				version, err := DetectVersion(context.Background(), ds)
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, 70)
+				So(req.URL.Path, ShouldEqual, "/")
+
+				user, _, ok := req.BasicAuth()
+				So(ok, ShouldBeTrue)
+				So(user, ShouldEqual, "user")
+			})
+
+			Convey("Should return error on failed request", func() {
+				status = http.StatusUnauthorized
+				_, err := DetectVersion(context.Background(), ds)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("Search response hits", func() {
+			Convey("Should decode total as number", func() {
+				var hits SearchResponseHits
+				err := json.Unmarshal([]byte(`{"total": 10, "hits": [{"_id": "1"}]}`), &hits)
+				So(err, ShouldBeNil)
+				So(hits.Total, ShouldEqual, 10)
+				So(hits.Hits, ShouldHaveLength, 1)
+			})
+
+			Convey("Should decode total as object", func() {
+				var hits SearchResponseHits
+				err := json.Unmarshal([]byte(`{"total": {"value": 10000, "relation": "gte"}, "hits": []}`), &hits)
+				So(err, ShouldBeNil)
+				So(hits.Total, ShouldEqual, 10000)
+			})
+		})
+	})
+}
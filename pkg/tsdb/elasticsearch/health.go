@@ -0,0 +1,23 @@
+package elasticsearch
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/grafana/grafana/pkg/tsdb/elasticsearch/client"
+)
+
+// CheckHealth requests the version of the Elasticsearch cluster.
+func (e *ElasticsearchExecutor) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	version, err := es.DetectVersion(ctx, dsInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tsdb.HealthCheckResult{
+		Status:  tsdb.HealthStatusOk,
+		Message: "Data source is working",
+		Details: map[string]interface{}{"esVersion": version},
+	}, nil
+}
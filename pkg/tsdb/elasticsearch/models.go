@@ -35,17 +35,20 @@ type MetricAgg struct {
 }
 
 var metricAggType = map[string]string{
-	"count":          "Count",
-	"avg":            "Average",
-	"sum":            "Sum",
-	"max":            "Max",
-	"min":            "Min",
-	"extended_stats": "Extended Stats",
-	"percentiles":    "Percentiles",
-	"cardinality":    "Unique Count",
-	"moving_avg":     "Moving Average",
-	"derivative":     "Derivative",
-	"raw_document":   "Raw Document",
+	"count":           "Count",
+	"avg":             "Average",
+	"sum":             "Sum",
+	"max":             "Max",
+	"min":             "Min",
+	"extended_stats":  "Extended Stats",
+	"percentiles":     "Percentiles",
+	"cardinality":     "Unique Count",
+	"moving_avg":      "Moving Average",
+	"derivative":      "Derivative",
+	"raw_document":    "Raw Document",
+	"logs":            "Logs",
+	"top_hits":        "Top Hits",
+	"scripted_metric": "Scripted Metric",
 }
 
 var extendedStats = map[string]string{
@@ -64,6 +67,10 @@ var pipelineAggType = map[string]string{
 	"derivative": "derivative",
 }
 
+func isDocumentQuery(metricType string) bool {
+	return metricType == rawDocumentType || metricType == logsType
+}
+
 func isPipelineAgg(metricType string) bool {
 	if _, ok := pipelineAggType[metricType]; ok {
 		return true
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/grafana/grafana/pkg/components/null"
 	"github.com/grafana/grafana/pkg/components/simplejson"
@@ -15,9 +16,13 @@ import (
 
 const (
 	// Metric types
-	countType         = "count"
-	percentilesType   = "percentiles"
-	extendedStatsType = "extended_stats"
+	countType          = "count"
+	percentilesType    = "percentiles"
+	extendedStatsType  = "extended_stats"
+	topHitsType        = "top_hits"
+	scriptedMetricType = "scripted_metric"
+	rawDocumentType    = "raw_document"
+	logsType           = "logs"
 	// Bucket types
 	dateHistType    = "date_histogram"
 	histogramType   = "histogram"
@@ -54,6 +59,13 @@ func (rp *responseParser) getTimeSeries() (*tsdb.Response, error) {
 			continue
 		}
 
+		if len(target.BucketAggs) == 0 && len(target.Metrics) > 0 && isDocumentQuery(target.Metrics[0].Type) {
+			queryRes := tsdb.NewQueryResult()
+			queryRes.Tables = append(queryRes.Tables, rp.processHits(res.Hits, target))
+			result.Results[target.RefID] = queryRes
+			continue
+		}
+
 		queryRes := tsdb.NewQueryResult()
 		props := make(map[string]string)
 		table := tsdb.Table{
@@ -250,6 +262,26 @@ func (rp *responseParser) processMetrics(esAgg *simplejson.Json, target *Query,
 				}
 				*series = append(*series, &newSeries)
 			}
+		case topHitsType:
+			buckets := esAgg.Get("buckets").MustArray()
+
+			for _, field := range metric.Settings.Get("fields").MustStringArray() {
+				newSeries := tsdb.TimeSeries{
+					Tags: make(map[string]string),
+				}
+				for k, v := range props {
+					newSeries.Tags[k] = v
+				}
+				newSeries.Tags["metric"] = topHitsType
+				newSeries.Tags["field"] = field
+
+				for _, v := range buckets {
+					bucket := simplejson.NewFromAny(v)
+					key := castToNullFloat(bucket.Get("key"))
+					newSeries.Points = append(newSeries.Points, tsdb.TimePoint{getTopHitValue(bucket, metric, field), key})
+				}
+				*series = append(*series, &newSeries)
+			}
 		default:
 			newSeries := tsdb.TimeSeries{
 				Tags: make(map[string]string),
@@ -352,6 +384,10 @@ func (rp *responseParser) processAggregationDocs(esAgg *simplejson.Json, aggDef
 					addMetricValue(&values, rp.getMetricName(metric.Type), value)
 					break
 				}
+			case topHitsType:
+				for _, field := range metric.Settings.Get("fields").MustStringArray() {
+					addMetricValue(&values, rp.getMetricName(metric.Type)+" "+field, getTopHitValue(bucket, metric, field))
+				}
 			default:
 				metricName := rp.getMetricName(metric.Type)
 				otherMetrics := make([]*MetricAgg, 0)
@@ -376,6 +412,130 @@ func (rp *responseParser) processAggregationDocs(esAgg *simplejson.Json, aggDef
 	return nil
 }
 
+// processHits converts the documents of a raw document or logs query to a
+// table. The time field comes first, followed by the log line for logs
+// queries and all other document fields in alphabetical order.
+func (rp *responseParser) processHits(hits *es.SearchResponseHits, target *Query) *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: make([]tsdb.TableColumn, 0),
+		Rows:    make([]tsdb.RowValues, 0),
+	}
+
+	if hits == nil {
+		return table
+	}
+
+	metric := target.Metrics[0]
+	leadingColumns := []string{target.TimeField}
+	if metric.Type == logsType {
+		leadingColumns = append(leadingColumns, metric.Settings.Get("messageField").MustString("message"))
+	}
+
+	docs := make([]map[string]interface{}, 0, len(hits.Hits))
+	fieldNames := make(map[string]bool)
+	for _, hit := range hits.Hits {
+		doc := make(map[string]interface{})
+		for _, key := range []string{"_id", "_index", "_type"} {
+			if value, ok := hit[key]; ok {
+				doc[key] = value
+			}
+		}
+
+		if source, ok := hit["_source"].(map[string]interface{}); ok {
+			flattenDocument(source, "", doc)
+		}
+
+		// doc value fields are returned as arrays and override the source
+		if fields, ok := hit["fields"].(map[string]interface{}); ok {
+			for key, value := range fields {
+				if values, ok := value.([]interface{}); ok && len(values) > 0 {
+					doc[key] = values[0]
+				}
+			}
+		}
+
+		for key := range doc {
+			fieldNames[key] = true
+		}
+		docs = append(docs, doc)
+	}
+
+	columns := append([]string{}, leadingColumns...)
+	otherColumns := make([]string, 0)
+	for name := range fieldNames {
+		isLeading := false
+		for _, c := range leadingColumns {
+			if c == name {
+				isLeading = true
+				break
+			}
+		}
+		if !isLeading {
+			otherColumns = append(otherColumns, name)
+		}
+	}
+	sort.Strings(otherColumns)
+	columns = append(columns, otherColumns...)
+
+	for _, c := range columns {
+		table.Columns = append(table.Columns, tsdb.TableColumn{Text: c})
+	}
+
+	for _, doc := range docs {
+		values := make(tsdb.RowValues, 0, len(columns))
+		values = append(values, parseDocumentTime(doc[target.TimeField]))
+		for _, c := range columns[1:] {
+			values = append(values, doc[c])
+		}
+		table.Rows = append(table.Rows, values)
+	}
+
+	return table
+}
+
+// flattenDocument flattens nested objects of a document to dot separated keys
+func flattenDocument(source map[string]interface{}, prefix string, doc map[string]interface{}) {
+	for key, value := range source {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenDocument(nested, key, doc)
+			continue
+		}
+
+		doc[key] = value
+	}
+}
+
+// parseDocumentTime returns the time as epoch milliseconds when possible
+func parseDocumentTime(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return float64(t.UnixNano() / int64(time.Millisecond))
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+
+	return value
+}
+
+func getTopHitValue(bucket *simplejson.Json, metric *MetricAgg, field string) null.Float {
+	hits := bucket.GetPath(metric.ID, "hits", "hits").MustArray()
+	if len(hits) == 0 {
+		return null.NewFloat(0, false)
+	}
+
+	source := simplejson.NewFromAny(hits[0]).Get("_source")
+	return castToNullFloat(source.GetPath(strings.Split(field, ".")...))
+}
+
 func (rp *responseParser) trimDatapoints(series *tsdb.TimeSeriesSlice, target *Query) {
 	var histogram *BucketAgg
 	for _, bucketAgg := range target.BucketAggs {
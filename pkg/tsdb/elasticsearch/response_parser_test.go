@@ -787,58 +787,192 @@ func TestResponseParser(t *testing.T) {
 			So(rows[0][2].(null.Float).Float64, ShouldEqual, 3000)
 		})
 
-		// Convey("Raw documents query", func() {
-		// 	targets := map[string]string{
-		// 		"A": `{
-		// 			"timeField": "@timestamp",
-		// 			"metrics": [{ "type": "raw_document", "id": "1" }]
-		// 		}`,
-		// 	}
-		// 	response := `{
-		//     "responses": [
-		//       {
-		//         "hits": {
-		//           "total": 100,
-		//           "hits": [
-		//             {
-		//               "_id": "1",
-		//               "_type": "type",
-		//               "_index": "index",
-		//               "_source": { "sourceProp": "asd" },
-		//               "fields": { "fieldProp": "field" }
-		//             },
-		//             {
-		//               "_source": { "sourceProp": "asd2" },
-		//               "fields": { "fieldProp": "field2" }
-		//             }
-		//           ]
-		//         }
-		//       }
-		//     ]
-		// 	}`
-		// 	rp, err := newResponseParserForTest(targets, response)
-		// 	So(err, ShouldBeNil)
-		// 	result, err := rp.getTimeSeries()
-		// 	So(err, ShouldBeNil)
-		// 	So(result.Results, ShouldHaveLength, 1)
-
-		// 	queryRes := result.Results["A"]
-		// 	So(queryRes, ShouldNotBeNil)
-		// 	So(queryRes.Tables, ShouldHaveLength, 1)
-
-		// 	rows := queryRes.Tables[0].Rows
-		// 	So(rows, ShouldHaveLength, 1)
-		// 	cols := queryRes.Tables[0].Columns
-		// 	So(cols, ShouldHaveLength, 3)
-
-		// 	So(cols[0].Text, ShouldEqual, "host")
-		// 	So(cols[1].Text, ShouldEqual, "Average test")
-		// 	So(cols[2].Text, ShouldEqual, "Average test2")
-
-		// 	So(rows[0][0].(string), ShouldEqual, "server-1")
-		// 	So(rows[0][1].(null.Float).Float64, ShouldEqual, 1000)
-		// 	So(rows[0][2].(null.Float).Float64, ShouldEqual, 3000)
-		// })
+		Convey("Raw documents query", func() {
+			targets := map[string]string{
+				"A": `{
+					"timeField": "@timestamp",
+					"metrics": [{ "type": "raw_document", "id": "1" }]
+				}`,
+			}
+			response := `{
+        "responses": [
+          {
+            "hits": {
+              "total": 100,
+              "hits": [
+                {
+                  "_id": "1",
+                  "_type": "type",
+                  "_index": "index",
+                  "_source": { "sourceProp": "asd", "nested": { "prop": 1 } },
+                  "fields": { "@timestamp": [1526406600000] }
+                },
+                {
+                  "_id": "2",
+                  "_type": "type",
+                  "_index": "index",
+                  "_source": { "sourceProp": "asd2", "@timestamp": "2018-05-15T17:51:00Z" }
+                }
+              ]
+            }
+          }
+        ]
+			}`
+			rp, err := newResponseParserForTest(targets, response)
+			So(err, ShouldBeNil)
+			result, err := rp.getTimeSeries()
+			So(err, ShouldBeNil)
+			So(result.Results, ShouldHaveLength, 1)
+
+			queryRes := result.Results["A"]
+			So(queryRes, ShouldNotBeNil)
+			So(queryRes.Series, ShouldHaveLength, 0)
+			So(queryRes.Tables, ShouldHaveLength, 1)
+
+			cols := queryRes.Tables[0].Columns
+			So(cols, ShouldHaveLength, 6)
+			So(cols[0].Text, ShouldEqual, "@timestamp")
+			So(cols[1].Text, ShouldEqual, "_id")
+			So(cols[2].Text, ShouldEqual, "_index")
+			So(cols[3].Text, ShouldEqual, "_type")
+			So(cols[4].Text, ShouldEqual, "nested.prop")
+			So(cols[5].Text, ShouldEqual, "sourceProp")
+
+			rows := queryRes.Tables[0].Rows
+			So(rows, ShouldHaveLength, 2)
+			So(rows[0][0], ShouldEqual, float64(1526406600000))
+			So(rows[0][4], ShouldEqual, float64(1))
+			So(rows[0][5], ShouldEqual, "asd")
+			So(rows[1][0], ShouldEqual, float64(1526406660000))
+			So(rows[1][4], ShouldBeNil)
+		})
+
+		Convey("Logs query", func() {
+			targets := map[string]string{
+				"A": `{
+					"timeField": "@timestamp",
+					"metrics": [{ "type": "logs", "id": "1", "settings": { "messageField": "msg" } }]
+				}`,
+			}
+			response := `{
+        "responses": [
+          {
+            "hits": {
+              "total": 1,
+              "hits": [
+                {
+                  "_id": "1",
+                  "_index": "logs",
+                  "_source": { "msg": "hello", "level": "info", "@timestamp": "2018-05-15T17:51:00Z" }
+                }
+              ]
+            }
+          }
+        ]
+			}`
+			rp, err := newResponseParserForTest(targets, response)
+			So(err, ShouldBeNil)
+			result, err := rp.getTimeSeries()
+			So(err, ShouldBeNil)
+
+			table := result.Results["A"].Tables[0]
+			So(table.Columns, ShouldHaveLength, 5)
+			So(table.Columns[0].Text, ShouldEqual, "@timestamp")
+			So(table.Columns[1].Text, ShouldEqual, "msg")
+			So(table.Columns[4].Text, ShouldEqual, "level")
+			So(table.Rows[0][1], ShouldEqual, "hello")
+		})
+
+		Convey("Top hits in date histogram", func() {
+			targets := map[string]string{
+				"A": `{
+					"timeField": "@timestamp",
+					"metrics": [{ "type": "top_hits", "id": "1", "settings": { "fields": ["value", "nested.value"] } }],
+          "bucketAggs": [{ "type": "date_histogram", "field": "@timestamp", "id": "2" }]
+				}`,
+			}
+			response := `{
+        "responses": [
+          {
+            "aggregations": {
+              "2": {
+                "buckets": [
+                  {
+                    "1": { "hits": { "hits": [{ "_source": { "value": 5, "nested": { "value": 6 } } }] } },
+                    "doc_count": 10,
+                    "key": 1000
+                  },
+                  {
+                    "1": { "hits": { "hits": [] } },
+                    "doc_count": 0,
+                    "key": 2000
+                  }
+                ]
+              }
+            }
+          }
+        ]
+			}`
+			rp, err := newResponseParserForTest(targets, response)
+			So(err, ShouldBeNil)
+			result, err := rp.getTimeSeries()
+			So(err, ShouldBeNil)
+
+			queryRes := result.Results["A"]
+			So(queryRes.Series, ShouldHaveLength, 2)
+
+			series := queryRes.Series[0]
+			So(series.Name, ShouldEqual, "Top Hits value")
+			So(series.Points, ShouldHaveLength, 2)
+			So(series.Points[0][0].Float64, ShouldEqual, 5)
+			So(series.Points[1][0].Valid, ShouldBeFalse)
+
+			series = queryRes.Series[1]
+			So(series.Name, ShouldEqual, "Top Hits nested.value")
+			So(series.Points[0][0].Float64, ShouldEqual, 6)
+		})
+
+		Convey("Top hits and scripted metric in terms table", func() {
+			targets := map[string]string{
+				"A": `{
+					"timeField": "@timestamp",
+					"metrics": [
+						{ "type": "top_hits", "id": "1", "settings": { "fields": ["value"] } },
+						{ "type": "scripted_metric", "id": "3" }
+					],
+          "bucketAggs": [{ "type": "terms", "field": "host", "id": "2" }]
+				}`,
+			}
+			response := `{
+        "responses": [
+          {
+            "aggregations": {
+              "2": {
+                "buckets": [
+                  {
+                    "1": { "hits": { "hits": [{ "_source": { "value": 42 } }] } },
+                    "3": { "value": 7 },
+                    "key": "server-1",
+                    "doc_count": 369
+                  }
+                ]
+              }
+            }
+          }
+        ]
+			}`
+			rp, err := newResponseParserForTest(targets, response)
+			So(err, ShouldBeNil)
+			result, err := rp.getTimeSeries()
+			So(err, ShouldBeNil)
+
+			table := result.Results["A"].Tables[0]
+			So(table.Columns, ShouldHaveLength, 3)
+			So(table.Columns[1].Text, ShouldEqual, "Top Hits value")
+			So(table.Columns[2].Text, ShouldEqual, "Scripted Metric")
+			So(table.Rows[0][1].(null.Float).Float64, ShouldEqual, 42)
+			So(table.Rows[0][2].(null.Float).Float64, ShouldEqual, 7)
+		})
 	})
 }
 
@@ -55,7 +55,7 @@ func (e *timeSeriesQuery) execute() (*tsdb.Response, error) {
 		}
 
 		if len(q.BucketAggs) == 0 {
-			if len(q.Metrics) == 0 || q.Metrics[0].Type != "raw_document" {
+			if len(q.Metrics) == 0 || !isDocumentQuery(q.Metrics[0].Type) {
 				result.Results[q.RefID] = &tsdb.QueryResult{
 					RefId:       q.RefID,
 					Error:       fmt.Errorf("invalid query, missing metrics and aggregations"),
@@ -63,10 +63,7 @@ func (e *timeSeriesQuery) execute() (*tsdb.Response, error) {
 				}
 				continue
 			}
-			metric := q.Metrics[0]
-			b.Size(metric.Settings.Get("size").MustInt(500))
-			b.SortDesc("@timestamp", "boolean")
-			b.AddDocValueField("@timestamp")
+			addDocumentQuery(b, q.Metrics[0], e.client.GetTimeField())
 			continue
 		}
 
@@ -101,6 +98,14 @@ func (e *timeSeriesQuery) execute() (*tsdb.Response, error) {
 				} else {
 					continue
 				}
+			} else if m.Type == topHitsType {
+				aggBuilder.Metric(m.ID, m.Type, "", func(a *es.MetricAggregation) {
+					a.Settings = topHitsSettings(m, e.client.GetTimeField())
+				})
+			} else if m.Type == scriptedMetricType {
+				aggBuilder.Metric(m.ID, m.Type, "", func(a *es.MetricAggregation) {
+					a.Settings = m.Settings.MustMap()
+				})
 			} else {
 				aggBuilder.Metric(m.ID, m.Type, m.Field, func(a *es.MetricAggregation) {
 					a.Settings = m.Settings.MustMap()
@@ -123,6 +128,67 @@ func (e *timeSeriesQuery) execute() (*tsdb.Response, error) {
 	return rp.getTimeSeries()
 }
 
+const (
+	defaultDocumentQuerySize = 500
+	// maxDocumentQuerySize is the default max_result_window of an index
+	maxDocumentQuerySize = 10000
+)
+
+// addDocumentQuery sets up a raw document or logs query which returns the
+// matching documents sorted by time instead of aggregations.
+func addDocumentQuery(b *es.SearchRequestBuilder, metric *MetricAgg, timeField string) {
+	sizeSetting := "size"
+	if metric.Type == logsType {
+		sizeSetting = "limit"
+	}
+
+	size := getIntSetting(metric.Settings, sizeSetting, defaultDocumentQuerySize)
+	if size <= 0 {
+		size = defaultDocumentQuerySize
+	}
+	if size > maxDocumentQuerySize {
+		size = maxDocumentQuerySize
+	}
+	b.Size(size)
+
+	sortField := metric.Settings.Get("sortField").MustString(timeField)
+	b.Sort(metric.Settings.Get("order").MustString(es.SortOrderDesc), sortField, "boolean")
+	b.AddDocValueField(timeField)
+}
+
+func topHitsSettings(metric *MetricAgg, timeField string) map[string]interface{} {
+	orderBy := metric.Settings.Get("orderBy").MustString(timeField)
+	order := metric.Settings.Get("order").MustString(es.SortOrderDesc)
+
+	settings := map[string]interface{}{
+		"size": getIntSetting(metric.Settings, "size", 1),
+		"sort": []map[string]interface{}{
+			{orderBy: map[string]string{"order": order}},
+		},
+	}
+
+	if fields := metric.Settings.Get("fields").MustStringArray(); len(fields) > 0 {
+		settings["_source"] = map[string]interface{}{"includes": fields}
+	}
+
+	return settings
+}
+
+// getIntSetting reads an int setting which may be stored as string by the query editor
+func getIntSetting(settings *simplejson.Json, key string, defaultValue int) int {
+	if value, err := settings.Get(key).Int(); err == nil {
+		return value
+	}
+
+	if value, err := settings.Get(key).String(); err == nil {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+
+	return defaultValue
+}
+
 func addDateHistogramAgg(aggBuilder es.AggBuilder, bucketAgg *BucketAgg, timeFrom, timeTo string) es.AggBuilder {
 	aggBuilder.DateHistogram(bucketAgg.ID, bucketAgg.Field, func(a *es.DateHistogramAgg, b es.AggBuilder) {
 		a.Interval = bucketAgg.Settings.Get("interval").MustString("auto")
@@ -302,6 +302,68 @@ func TestExecuteTimeSeriesQuery(t *testing.T) {
 			So(sr.Size, ShouldEqual, 1337)
 		})
 
+		Convey("With raw document metric size as string and ascending order", func() {
+			c := newFakeClient(5)
+			_, err := executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "raw_document", "settings": { "size": "50", "order": "asc" }	}]
+			}`, from, to, 15*time.Second)
+			So(err, ShouldBeNil)
+			sr := c.multisearchRequests[0].Requests[0]
+
+			So(sr.Size, ShouldEqual, 50)
+			So(sr.Sort["@timestamp"], ShouldResemble, map[string]string{"order": "asc", "unmapped_type": "boolean"})
+		})
+
+		Convey("With logs metric limit above max size", func() {
+			c := newFakeClient(5)
+			_, err := executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "logs", "settings": { "limit": 50000 }	}]
+			}`, from, to, 15*time.Second)
+			So(err, ShouldBeNil)
+			sr := c.multisearchRequests[0].Requests[0]
+
+			So(sr.Size, ShouldEqual, 10000)
+			So(sr.Sort["@timestamp"], ShouldResemble, map[string]string{"order": "desc", "unmapped_type": "boolean"})
+			So(sr.CustomProps["docvalue_fields"], ShouldResemble, []string{"@timestamp"})
+		})
+
+		Convey("With top hits and scripted metric", func() {
+			c := newFakeClient(5)
+			_, err := executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [{ "type": "date_histogram", "field": "@timestamp", "id": "2" }],
+				"metrics": [
+					{ "id": "1", "type": "top_hits", "field": "ignored", "settings": { "size": "3", "order": "asc", "fields": ["value"] } },
+					{ "id": "3", "type": "scripted_metric", "field": "ignored", "settings": { "map_script": "state.x = 1" } }
+				]
+			}`, from, to, 15*time.Second)
+			So(err, ShouldBeNil)
+			sr := c.multisearchRequests[0].Requests[0]
+
+			firstLevel := sr.Aggs[0]
+			topHits := firstLevel.Aggregation.Aggs[0]
+			So(topHits.Key, ShouldEqual, "1")
+			So(topHits.Aggregation.Type, ShouldEqual, "top_hits")
+
+			topHitsAgg := topHits.Aggregation.Aggregation.(*es.MetricAggregation)
+			So(topHitsAgg.Field, ShouldEqual, "")
+			So(topHitsAgg.Settings["size"], ShouldEqual, 3)
+			So(topHitsAgg.Settings["sort"], ShouldResemble, []map[string]interface{}{
+				{"@timestamp": map[string]string{"order": "asc"}},
+			})
+			So(topHitsAgg.Settings["_source"], ShouldResemble, map[string]interface{}{"includes": []string{"value"}})
+
+			scripted := firstLevel.Aggregation.Aggs[1]
+			So(scripted.Aggregation.Type, ShouldEqual, "scripted_metric")
+			scriptedAgg := scripted.Aggregation.Aggregation.(*es.MetricAggregation)
+			So(scriptedAgg.Field, ShouldEqual, "")
+			So(scriptedAgg.Settings["map_script"], ShouldEqual, "state.x = 1")
+		})
+
 		Convey("With date histogram agg", func() {
 			c := newFakeClient(5)
 			_, err := executeTsdbQuery(c, `{
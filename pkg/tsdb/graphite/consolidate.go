@@ -0,0 +1,96 @@
+package graphite
+
+import (
+	"math"
+	"regexp"
+
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+var consolidateByRegex = regexp.MustCompile(`consolidateBy\(.*,\s*['"](\w+)['"]\s*\)`)
+
+// consolidationFunc returns the function set with consolidateBy in the target
+// or average, the graphite default.
+func consolidationFunc(target string) string {
+	match := consolidateByRegex.FindStringSubmatch(target)
+	if match == nil {
+		return "average"
+	}
+
+	return match[1]
+}
+
+// consolidate reduces the points of a series to at most maxDataPoints the same
+// way graphite does: consecutive points are aggregated in buckets of
+// ceil(len(points) / maxDataPoints) values using the timestamp of the first
+// point. Graphite consolidates itself, this handles older versions and
+// functions that return more points than requested.
+func consolidate(points tsdb.TimeSeriesPoints, maxDataPoints int64, fn string) tsdb.TimeSeriesPoints {
+	if maxDataPoints <= 0 || int64(len(points)) <= maxDataPoints {
+		return points
+	}
+
+	valuesPerPoint := int(math.Ceil(float64(len(points)) / float64(maxDataPoints)))
+	result := make(tsdb.TimeSeriesPoints, 0, maxDataPoints)
+
+	for i := 0; i < len(points); i += valuesPerPoint {
+		end := i + valuesPerPoint
+		if end > len(points) {
+			end = len(points)
+		}
+
+		result = append(result, tsdb.TimePoint{aggregate(points[i:end], fn), points[i][1]})
+	}
+
+	return result
+}
+
+func aggregate(points tsdb.TimeSeriesPoints, fn string) null.Float {
+	values := make([]float64, 0, len(points))
+	for _, point := range points {
+		if point[0].Valid {
+			values = append(values, point[0].Float64)
+		}
+	}
+
+	switch fn {
+	case "first":
+		if points[0][0].Valid {
+			return points[0][0]
+		}
+		return null.FloatFromPtr(nil)
+	case "last":
+		if points[len(points)-1][0].Valid {
+			return points[len(points)-1][0]
+		}
+		return null.FloatFromPtr(nil)
+	}
+
+	if len(values) == 0 {
+		return null.FloatFromPtr(nil)
+	}
+
+	result := values[0]
+	switch fn {
+	case "sum":
+		for _, v := range values[1:] {
+			result += v
+		}
+	case "min":
+		for _, v := range values[1:] {
+			result = math.Min(result, v)
+		}
+	case "max":
+		for _, v := range values[1:] {
+			result = math.Max(result, v)
+		}
+	default:
+		for _, v := range values[1:] {
+			result += v
+		}
+		result = result / float64(len(values))
+	}
+
+	return null.FloatFrom(result)
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/context/ctxhttp"
@@ -34,41 +35,72 @@ func init() {
 	tsdb.RegisterTsdbQueryEndpoint("graphite", NewGraphiteExecutor)
 }
 
+const defaultMaxDataPoints = 500
+
 func (e *GraphiteExecutor) Query(ctx context.Context, dsInfo *models.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
-	result := &tsdb.Response{}
+	result := &tsdb.Response{
+		Results: make(map[string]*tsdb.QueryResult),
+	}
 
 	from := "-" + formatTimeRange(tsdbQuery.TimeRange.From)
 	until := formatTimeRange(tsdbQuery.TimeRange.To)
-	var target string
 
-	formData := url.Values{
-		"from":          []string{from},
-		"until":         []string{until},
-		"format":        []string{"json"},
-		"maxDataPoints": []string{"500"},
+	targets, err := resolveTargets(tsdbQuery.Queries)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
 	}
 
 	for _, query := range tsdbQuery.Queries {
-		glog.Info("graphite", "query", query.Model)
-		if fullTarget, err := query.Model.Get("targetFull").String(); err == nil {
-			target = fixIntervalFormat(fullTarget)
-		} else {
-			target = fixIntervalFormat(query.Model.Get("target").MustString())
+		var queryRes *tsdb.QueryResult
+		switch queryType := query.Model.Get("queryType").MustString(queryTypeRender); queryType {
+		case queryTypeRender:
+			queryRes, err = e.render(ctx, httpClient, dsInfo, query, targets[query.RefId], from, until)
+		case queryTypeTags, queryTypeTagValues, queryTypeFindSeries:
+			queryRes, err = e.queryTags(ctx, httpClient, dsInfo, query, queryType, from, until)
+		default:
+			err = fmt.Errorf("Unsupported query type: %s", queryType)
 		}
-	}
 
-	formData["target"] = []string{target}
+		if err != nil {
+			queryRes = tsdb.NewQueryResult()
+			queryRes.Error = err
+		}
 
-	if setting.Env == setting.DEV {
-		glog.Debug("Graphite request", "params", formData)
+		queryRes.RefId = query.RefId
+		result.Results[query.RefId] = queryRes
 	}
 
-	req, err := e.createRequest(dsInfo, formData)
-	if err != nil {
+	return result, nil
+}
+
+func (e *GraphiteExecutor) render(ctx context.Context, httpClient *http.Client, dsInfo *models.DataSource, query *tsdb.Query, target string, from string, until string) (*tsdb.QueryResult, error) {
+	if err := validateTarget(target); err != nil {
 		return nil, err
 	}
 
-	httpClient, err := dsInfo.GetHttpClient()
+	maxDataPoints := query.MaxDataPoints
+	if maxDataPoints <= 0 {
+		maxDataPoints = query.Model.Get("maxDataPoints").MustInt64(defaultMaxDataPoints)
+	}
+
+	formData := url.Values{
+		"from":          []string{from},
+		"until":         []string{until},
+		"format":        []string{"json"},
+		"maxDataPoints": []string{strconv.FormatInt(maxDataPoints, 10)},
+		"target":        []string{target},
+	}
+
+	if setting.Env == setting.DEV {
+		glog.Debug("Graphite request", "params", formData)
+	}
+
+	req, err := e.createRequest(dsInfo, "render", formData)
 	if err != nil {
 		return nil, err
 	}
@@ -97,13 +129,14 @@ func (e *GraphiteExecutor) Query(ctx context.Context, dsInfo *models.DataSource,
 		return nil, err
 	}
 
-	result.Results = make(map[string]*tsdb.QueryResult)
 	queryRes := tsdb.NewQueryResult()
+	consolidateFunc := consolidationFunc(target)
 
 	for _, series := range data {
 		queryRes.Series = append(queryRes.Series, &tsdb.TimeSeries{
 			Name:   series.Target,
-			Points: series.DataPoints,
+			Tags:   series.Tags,
+			Points: consolidate(series.DataPoints, maxDataPoints, consolidateFunc),
 		})
 
 		if setting.Env == setting.DEV {
@@ -111,8 +144,7 @@ func (e *GraphiteExecutor) Query(ctx context.Context, dsInfo *models.DataSource,
 		}
 	}
 
-	result.Results["A"] = queryRes
-	return result, nil
+	return queryRes, nil
 }
 
 func (e *GraphiteExecutor) parseResponse(res *http.Response) ([]TargetResponseDTO, error) {
@@ -137,9 +169,9 @@ func (e *GraphiteExecutor) parseResponse(res *http.Response) ([]TargetResponseDT
 	return data, nil
 }
 
-func (e *GraphiteExecutor) createRequest(dsInfo *models.DataSource, data url.Values) (*http.Request, error) {
+func (e *GraphiteExecutor) createRequest(dsInfo *models.DataSource, endpoint string, data url.Values) (*http.Request, error) {
 	u, _ := url.Parse(dsInfo.Url)
-	u.Path = path.Join(u.Path, "render")
+	u.Path = path.Join(u.Path, endpoint)
 
 	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
 	if err != nil {
@@ -149,7 +181,7 @@ func (e *GraphiteExecutor) createRequest(dsInfo *models.DataSource, data url.Val
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	if dsInfo.BasicAuth {
-		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.BasicAuthPassword)
+		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.DecryptedBasicAuthPassword())
 	}
 
 	return req, err
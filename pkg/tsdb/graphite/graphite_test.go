@@ -1,8 +1,18 @@
 package graphite
 
 import (
-	. "github.com/smartystreets/goconvey/convey"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestGraphiteFunctions(t *testing.T) {
@@ -59,3 +69,159 @@ func TestGraphiteFunctions(t *testing.T) {
 
 	})
 }
+
+func TestGraphiteTargets(t *testing.T) {
+	Convey("Testing Graphite target references", t, func() {
+		query := func(refID string, model map[string]interface{}) *tsdb.Query {
+			return &tsdb.Query{RefId: refID, Model: simplejson.NewFromAny(model)}
+		}
+
+		Convey("resolves nested references", func() {
+			targets, err := resolveTargets([]*tsdb.Query{
+				query("A", map[string]interface{}{"target": "app.*.requests"}),
+				query("B", map[string]interface{}{"target": "sumSeries(#A)"}),
+				query("C", map[string]interface{}{"target": "asPercent(#A, #B)"}),
+			})
+			So(err, ShouldBeNil)
+			So(targets["B"], ShouldEqual, "sumSeries(app.*.requests)")
+			So(targets["C"], ShouldEqual, "asPercent(app.*.requests, sumSeries(app.*.requests))")
+		})
+
+		Convey("prefers targetFull", func() {
+			targets, err := resolveTargets([]*tsdb.Query{
+				query("A", map[string]interface{}{"target": "sumSeries(#B)", "targetFull": "sumSeries(app.*.count)"}),
+			})
+			So(err, ShouldBeNil)
+			So(targets["A"], ShouldEqual, "sumSeries(app.*.count)")
+		})
+
+		Convey("leaves unknown references as they are", func() {
+			targets, err := resolveTargets([]*tsdb.Query{
+				query("A", map[string]interface{}{"target": "sumSeries(#B)"}),
+				query("C", map[string]interface{}{"target": "alias(#A, '#Z')"}),
+			})
+			So(err, ShouldBeNil)
+			So(targets["A"], ShouldEqual, "sumSeries(#B)")
+			So(targets["C"], ShouldEqual, "alias(sumSeries(#B), '#Z')")
+		})
+
+		Convey("only resolves single letter references", func() {
+			targets, err := resolveTargets([]*tsdb.Query{
+				query("A", map[string]interface{}{"target": "app.count"}),
+				query("B", map[string]interface{}{"target": "sumSeries(#AB)"}),
+			})
+			So(err, ShouldBeNil)
+			So(targets["B"], ShouldEqual, "sumSeries(app.countB)")
+		})
+
+		Convey("returns an error for circular references", func() {
+			_, err := resolveTargets([]*tsdb.Query{
+				query("A", map[string]interface{}{"target": "sumSeries(#B)"}),
+				query("B", map[string]interface{}{"target": "sumSeries(#A)"}),
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Testing Graphite target validation", t, func() {
+		So(validateTarget("aliasByNode(app.*.count, 1)"), ShouldBeNil)
+		So(validateTarget("alias(app.count, 'a (b')"), ShouldBeNil)
+		So(validateTarget("aliasByNode(app.*.count, 1"), ShouldNotBeNil)
+		So(validateTarget("alias(app.count, 'a)"), ShouldNotBeNil)
+		So(validateTarget("sumSeries(seriesByTag('name=cpu', 'host!=a'))"), ShouldBeNil)
+		So(validateTarget("seriesByTag('host!=a')"), ShouldNotBeNil)
+		So(validateTarget("seriesByTag('host')"), ShouldNotBeNil)
+		So(validateTarget("seriesByTag('name=cpu', 'host=~(a|b),c')"), ShouldBeNil)
+		So(validateTarget(`seriesByTag("name=~cpu\.(user|system)", 'host!=a')`), ShouldBeNil)
+		So(validateTarget("seriesByTag('name=cpu', 'label=it\\'s (x)')"), ShouldBeNil)
+		So(validateTarget("seriesByTag('name=cpu', $tags)"), ShouldBeNil)
+		So(validateTarget("aliasByTags(seriesByTag('name=cpu'), 'host')"), ShouldBeNil)
+		So(validateTarget("mySeriesByTag('host')"), ShouldBeNil)
+		So(validateTarget("seriesByTag('name=cpu', 'host')"), ShouldNotBeNil)
+		So(validateTagExpressions([]string{"name=cpu", "host=~(a|b),c"}), ShouldBeNil)
+		So(validateTagExpressions([]string{"host!=a"}), ShouldNotBeNil)
+	})
+}
+
+func TestGraphiteConsolidation(t *testing.T) {
+	Convey("Testing Graphite consolidation", t, func() {
+		points := tsdb.TimeSeriesPoints{}
+		for i := 0; i < 10; i++ {
+			points = append(points, tsdb.NewTimePoint(null.FloatFrom(float64(i)), float64(i*1000)))
+		}
+		points[1][0] = null.FloatFromPtr(nil)
+
+		Convey("keeps series within maxDataPoints", func() {
+			So(consolidate(points, 10, "average"), ShouldResemble, points)
+		})
+
+		Convey("averages buckets by default", func() {
+			result := consolidate(points, 4, consolidationFunc("app.*.count"))
+			So(len(result), ShouldEqual, 4)
+			So(result[0][0].Float64, ShouldEqual, 1)
+			So(result[0][1].Float64, ShouldEqual, 0)
+			So(result[1][0].Float64, ShouldEqual, 4)
+			So(result[1][1].Float64, ShouldEqual, 3000)
+			So(result[3][0].Float64, ShouldEqual, 9)
+		})
+
+		Convey("uses the consolidateBy function", func() {
+			result := consolidate(points, 5, consolidationFunc("consolidateBy(app.*.count, 'max')"))
+			So(len(result), ShouldEqual, 5)
+			So(result[0][0].Float64, ShouldEqual, 0)
+			So(result[1][0].Float64, ShouldEqual, 3)
+
+			result = consolidate(points, 5, consolidationFunc(`consolidateBy(app.*.count, "sum")`))
+			So(result[4][0].Float64, ShouldEqual, 17)
+		})
+	})
+}
+
+func TestGraphiteExecutor(t *testing.T) {
+	Convey("Testing Graphite executor", t, func() {
+		var requests []url.Values
+		var paths []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			requests = append(requests, r.Form)
+			paths = append(paths, r.URL.Path)
+
+			if r.URL.Path == "/tags/autoComplete/tags" {
+				w.Write([]byte(`["host", "name"]`))
+				return
+			}
+			w.Write([]byte(`[{"target": "` + r.Form.Get("target") + `", "tags": {"name": "cpu"}, "datapoints": [[1, 1521118800], [null, 1521118860]]}]`))
+		}))
+		defer server.Close()
+
+		dsInfo := &models.DataSource{Url: server.URL, JsonData: simplejson.New(), Updated: time.Now()}
+		executor := &GraphiteExecutor{}
+
+		resp, err := executor.Query(context.Background(), dsInfo, &tsdb.TsdbQuery{
+			TimeRange: tsdb.NewTimeRange("1h", "now"),
+			Queries: []*tsdb.Query{
+				{RefId: "A", Model: simplejson.NewFromAny(map[string]interface{}{"target": "seriesByTag('name=cpu')", "hide": true})},
+				{RefId: "B", MaxDataPoints: 100, Model: simplejson.NewFromAny(map[string]interface{}{"target": "sumSeries(#A)"})},
+				{RefId: "C", Model: simplejson.NewFromAny(map[string]interface{}{"queryType": "tags", "tagPrefix": "h"})},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		// hidden targets are queried too, alerts may use them
+		So(len(requests), ShouldEqual, 3)
+		So(paths[0], ShouldEqual, "/render")
+		So(requests[0].Get("target"), ShouldEqual, "seriesByTag('name=cpu')")
+		So(paths[1], ShouldEqual, "/render")
+		So(requests[1].Get("target"), ShouldEqual, "sumSeries(seriesByTag('name=cpu'))")
+		So(requests[1].Get("maxDataPoints"), ShouldEqual, "100")
+		So(requests[1].Get("from"), ShouldEqual, "-1h")
+		So(paths[2], ShouldEqual, "/tags/autoComplete/tags")
+		So(requests[2].Get("tagPrefix"), ShouldEqual, "h")
+
+		So(len(resp.Results["A"].Series), ShouldEqual, 1)
+		So(len(resp.Results["B"].Series), ShouldEqual, 1)
+		So(resp.Results["B"].Series[0].Tags["name"], ShouldEqual, "cpu")
+		So(resp.Results["B"].Series[0].Points[1][0].Valid, ShouldBeFalse)
+		So(resp.Results["C"].Tables[0].Rows, ShouldResemble, []tsdb.RowValues{{"host"}, {"name"}})
+	})
+}
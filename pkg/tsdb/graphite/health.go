@@ -0,0 +1,28 @@
+package graphite
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// CheckHealth runs a metrics find query for the top level metrics.
+func (e *GraphiteExecutor) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "metrics/find")
+	u.RawQuery = url.Values{"query": []string{"*"}}.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return tsdb.CheckHttpHealth(ctx, dsInfo, req)
+}
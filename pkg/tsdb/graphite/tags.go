@@ -0,0 +1,94 @@
+package graphite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// queryTags queries the graphite tags api. Tag names and values are used by
+// template variables, find_series returns the series matching seriesByTag
+// expressions.
+func (e *GraphiteExecutor) queryTags(ctx context.Context, httpClient *http.Client, dsInfo *models.DataSource, query *tsdb.Query, queryType string, from string, until string) (*tsdb.QueryResult, error) {
+	params := url.Values{}
+	for _, expr := range query.Model.Get("expressions").MustStringArray() {
+		params.Add("expr", expr)
+	}
+
+	var endpoint string
+	switch queryType {
+	case queryTypeTags:
+		endpoint = "tags/autoComplete/tags"
+		params.Set("tagPrefix", query.Model.Get("tagPrefix").MustString())
+	case queryTypeTagValues:
+		tag := query.Model.Get("tag").MustString()
+		if tag == "" {
+			return nil, fmt.Errorf("Query of type %s requires a tag", queryType)
+		}
+		endpoint = "tags/autoComplete/values"
+		params.Set("tag", tag)
+		params.Set("valuePrefix", query.Model.Get("valuePrefix").MustString())
+	case queryTypeFindSeries:
+		if len(params["expr"]) == 0 {
+			return nil, fmt.Errorf("Query of type %s requires tag expressions", queryType)
+		}
+		if err := validateTagExpressions(params["expr"]); err != nil {
+			return nil, err
+		}
+		endpoint = "tags/findSeries"
+	}
+
+	if limit := query.Model.Get("limit").MustInt(0); limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	params.Set("from", from)
+	params.Set("until", until)
+
+	req, err := e.createRequest(dsInfo, endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode/100 != 2 {
+		glog.Info("Request failed", "status", res.Status, "body", string(body))
+		return nil, fmt.Errorf("Request failed status: %v", res.Status)
+	}
+
+	var values []string
+	if err := json.Unmarshal(body, &values); err != nil {
+		glog.Info("Failed to unmarshal graphite response", "error", err, "status", res.Status, "body", string(body))
+		return nil, err
+	}
+
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{{Text: "text"}},
+		Rows:    make([]tsdb.RowValues, 0, len(values)),
+	}
+	for _, value := range values {
+		table.Rows = append(table.Rows, tsdb.RowValues{value})
+	}
+
+	queryRes := tsdb.NewQueryResult()
+	queryRes.Tables = append(queryRes.Tables, table)
+
+	return queryRes, nil
+}
@@ -0,0 +1,211 @@
+package graphite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+const seriesByTagCall = "seriesByTag("
+
+var (
+	// targetRefRegex matches references to other queries like the frontend
+	targetRefRegex = regexp.MustCompile(`#([A-Z])`)
+	tagExprRegex   = regexp.MustCompile(`^[^=!~]+(=|!=|=~|!=~)(.*)$`)
+)
+
+// resolveTargets returns the target of every query with references to other
+// queries, like #A, replaced by the referenced target. References to unknown
+// queries are left as they are, like the frontend does. The frontend sends
+// the resolved target as targetFull, which is used when present.
+func resolveTargets(queries []*tsdb.Query) (map[string]string, error) {
+	raw := make(map[string]string, len(queries))
+	for _, query := range queries {
+		if fullTarget, err := query.Model.Get("targetFull").String(); err == nil {
+			raw[query.RefId] = fixIntervalFormat(fullTarget)
+		} else {
+			raw[query.RefId] = fixIntervalFormat(query.Model.Get("target").MustString())
+		}
+	}
+
+	resolved := make(map[string]string, len(queries))
+	for refID := range raw {
+		target, err := resolveTarget(refID, raw, resolved, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		resolved[refID] = target
+	}
+
+	return resolved, nil
+}
+
+func resolveTarget(refID string, raw map[string]string, resolved map[string]string, visiting map[string]bool) (string, error) {
+	if target, ok := resolved[refID]; ok {
+		return target, nil
+	}
+
+	if visiting[refID] {
+		return "", fmt.Errorf("Circular reference to target #%s", refID)
+	}
+	visiting[refID] = true
+	defer delete(visiting, refID)
+
+	var resolveErr error
+	target := targetRefRegex.ReplaceAllStringFunc(raw[refID], func(match string) string {
+		ref := match[1:]
+		if _, ok := raw[ref]; !ok {
+			return match
+		}
+
+		nested, err := resolveTarget(ref, raw, resolved, visiting)
+		if err != nil && resolveErr == nil {
+			resolveErr = err
+		}
+		return nested
+	})
+
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+
+	return target, nil
+}
+
+// validateTarget checks that quotes and parentheses of a target are balanced
+// and that seriesByTag calls contain valid tag expressions, so invalid
+// targets are reported before they are sent to graphite.
+func validateTarget(target string) error {
+	if strings.TrimSpace(target) == "" {
+		return fmt.Errorf("Query target is empty")
+	}
+
+	depth := 0
+	var quote byte
+	var calls [][]string
+	for i := 0; i < len(target); i++ {
+		c := target[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("Unexpected ) at position %d in target %s", i, target)
+			}
+		case strings.HasPrefix(target[i:], seriesByTagCall) && (i == 0 || !isNameChar(target[i-1])):
+			calls = append(calls, parseArguments(target[i+len(seriesByTagCall):]))
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("Unterminated string in target %s", target)
+	}
+
+	if depth != 0 {
+		return fmt.Errorf("Missing ) in target %s", target)
+	}
+
+	for _, args := range calls {
+		exprs, ok := unquoteArguments(args)
+		if !ok {
+			// not string literals, like template variables, graphite checks them
+			continue
+		}
+		if err := validateTagExpressions(exprs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseArguments returns the arguments of a function call up to the closing
+// parenthesis, respecting quotes and nested calls.
+func parseArguments(call string) []string {
+	var args []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(call); i++ {
+		c := call[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')' || (c == ',' && depth == 0):
+			args = append(args, strings.TrimSpace(call[start:i]))
+			if c == ')' {
+				return args
+			}
+			start = i + 1
+		}
+	}
+
+	return append(args, strings.TrimSpace(call[start:]))
+}
+
+func validateTagExpressions(exprs []string) error {
+	hasMatch := false
+	for _, expr := range exprs {
+		match := tagExprRegex.FindStringSubmatch(expr)
+		if match == nil {
+			return fmt.Errorf("Invalid seriesByTag expression %q", expr)
+		}
+
+		if (match[1] == "=" || match[1] == "=~") && match[2] != "" {
+			hasMatch = true
+		}
+	}
+
+	if !hasMatch {
+		return fmt.Errorf("seriesByTag requires at least one expression that matches a non-empty value")
+	}
+
+	return nil
+}
+
+// unquoteArguments returns the values of string arguments, it returns false
+// if an argument is not a string.
+func unquoteArguments(args []string) ([]string, bool) {
+	values := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') || arg[len(arg)-1] != arg[0] {
+			return nil, false
+		}
+
+		var value strings.Builder
+		for i := 1; i < len(arg)-1; i++ {
+			if arg[i] == '\\' && i+1 < len(arg)-1 {
+				i++
+			}
+			value.WriteByte(arg[i])
+		}
+		values = append(values, value.String())
+	}
+
+	return values, true
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
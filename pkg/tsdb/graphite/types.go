@@ -2,7 +2,15 @@ package graphite
 
 import "github.com/grafana/grafana/pkg/tsdb"
 
+const (
+	queryTypeRender     = "render"
+	queryTypeTags       = "tags"
+	queryTypeTagValues  = "tag_values"
+	queryTypeFindSeries = "find_series"
+)
+
 type TargetResponseDTO struct {
 	Target     string                `json:"target"`
+	Tags       map[string]string     `json:"tags"`
 	DataPoints tsdb.TimeSeriesPoints `json:"datapoints"`
 }
@@ -0,0 +1,91 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var ErrHealthCheckNotSupported = errors.New("Health check is not supported by this data source")
+
+type HealthStatus string
+
+const (
+	HealthStatusOk      HealthStatus = "OK"
+	HealthStatusError   HealthStatus = "ERROR"
+	HealthStatusUnknown HealthStatus = "UNKNOWN"
+)
+
+type HealthCheckResult struct {
+	Status  HealthStatus           `json:"status"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// TsdbHealthChecker is implemented by query endpoints that can check if
+// their data source is reachable and working.
+type TsdbHealthChecker interface {
+	CheckHealth(ctx context.Context, ds *models.DataSource) (*HealthCheckResult, error)
+}
+
+// CheckHealth checks the health of a data source with its query endpoint.
+// Errors of the check are returned as result with the error status, only
+// ErrHealthCheckNotSupported is returned as error.
+func CheckHealth(ctx context.Context, ds *models.DataSource) (*HealthCheckResult, error) {
+	endpoint, err := getTsdbQueryEndpointFor(ds)
+	if err != nil {
+		if _, exists := registry[ds.Type]; !exists {
+			return nil, ErrHealthCheckNotSupported
+		}
+		return &HealthCheckResult{Status: HealthStatusError, Message: err.Error()}, nil
+	}
+
+	checker, ok := endpoint.(TsdbHealthChecker)
+	if !ok {
+		return nil, ErrHealthCheckNotSupported
+	}
+
+	result, err := checker.CheckHealth(ctx, ds)
+	if err != nil {
+		return &HealthCheckResult{Status: HealthStatusError, Message: err.Error()}, nil
+	}
+
+	return result, nil
+}
+
+// CheckHttpHealth sends a request with the http client, basic auth and
+// custom headers of the data source and fails for responses without a 2xx
+// status code.
+func CheckHttpHealth(ctx context.Context, ds *models.DataSource, req *http.Request) (*HealthCheckResult, error) {
+	client, err := ds.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if ds.BasicAuth {
+		req.SetBasicAuth(ds.BasicAuthUser, ds.DecryptedBasicAuthPassword())
+	}
+	for name, value := range ds.CustomHeaders() {
+		req.Header.Set(name, value)
+	}
+
+	res, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("%s returned %s: %s", req.URL.Path, res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return &HealthCheckResult{Status: HealthStatusOk, Message: "Data source is working"}, nil
+}
@@ -0,0 +1,102 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeHealthChecker struct {
+	FakeExecutor
+	err error
+}
+
+func (e *fakeHealthChecker) CheckHealth(ctx context.Context, ds *models.DataSource) (*HealthCheckResult, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &HealthCheckResult{Status: HealthStatusOk, Message: "ok"}, nil
+}
+
+func TestCheckHealth(t *testing.T) {
+	Convey("Checking the health of a data source", t, func() {
+		checker := &fakeHealthChecker{}
+		RegisterTsdbQueryEndpoint("health-test", func(ds *models.DataSource) (TsdbQueryEndpoint, error) {
+			return checker, nil
+		})
+		RegisterTsdbQueryEndpoint("no-health-test", func(ds *models.DataSource) (TsdbQueryEndpoint, error) {
+			return NewFakeExecutor(ds)
+		})
+
+		Convey("Should return the result of the endpoint", func() {
+			result, err := CheckHealth(context.Background(), &models.DataSource{Type: "health-test"})
+			So(err, ShouldBeNil)
+			So(result.Status, ShouldEqual, HealthStatusOk)
+		})
+
+		Convey("Should return errors of the endpoint as error status", func() {
+			checker.err = errors.New("connection refused")
+
+			result, err := CheckHealth(context.Background(), &models.DataSource{Type: "health-test"})
+			So(err, ShouldBeNil)
+			So(result.Status, ShouldEqual, HealthStatusError)
+			So(result.Message, ShouldEqual, "connection refused")
+		})
+
+		Convey("Should not be supported by endpoints without health check", func() {
+			_, err := CheckHealth(context.Background(), &models.DataSource{Type: "no-health-test"})
+			So(err, ShouldEqual, ErrHealthCheckNotSupported)
+		})
+
+		Convey("Should not be supported by data sources without endpoint", func() {
+			_, err := CheckHealth(context.Background(), &models.DataSource{Type: "frontend-only"})
+			So(err, ShouldEqual, ErrHealthCheckNotSupported)
+		})
+	})
+
+	Convey("Checking the health of a http data source", t, func() {
+		var header http.Header
+		status := 200
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			w.WriteHeader(status)
+			w.Write([]byte("overloaded"))
+		}))
+		defer server.Close()
+
+		ds := &models.DataSource{
+			Id:            1,
+			Url:           server.URL,
+			BasicAuth:     true,
+			BasicAuthUser: "user",
+			JsonData:      simplejson.NewFromAny(map[string]interface{}{"httpHeaderName1": "X-Team"}),
+			SecureJsonData: securejsondata.GetEncryptedJsonData(map[string]string{
+				"basicAuthPassword": "pwd",
+				"httpHeaderValue1":  "a-team",
+			}),
+		}
+
+		Convey("Should send basic auth and custom headers", func() {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/ping", nil)
+			result, err := CheckHttpHealth(context.Background(), ds, req)
+			So(err, ShouldBeNil)
+			So(result.Status, ShouldEqual, HealthStatusOk)
+			So(header.Get("Authorization"), ShouldStartWith, "Basic ")
+			So(header.Get("X-Team"), ShouldEqual, "a-team")
+		})
+
+		Convey("Should fail for error responses", func() {
+			status = 503
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/ping", nil)
+			_, err := CheckHttpHealth(context.Background(), ds, req)
+			So(err.Error(), ShouldEqual, "/ping returned 503 Service Unavailable: overloaded")
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// maxResponseSize bounds the size of a response read into memory.
+const maxResponseSize = 50 * 1024 * 1024
+
+type HttpApiExecutor struct {
+	intervalCalculator tsdb.IntervalCalculator
+}
+
+func NewHttpApiExecutor(datasource *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+	return &HttpApiExecutor{
+		intervalCalculator: tsdb.NewIntervalCalculator(nil),
+	}, nil
+}
+
+var (
+	glog log.Logger
+)
+
+func init() {
+	glog = log.New("tsdb.httpapi")
+	tsdb.RegisterTsdbQueryEndpoint("httpapi", NewHttpApiExecutor)
+}
+
+func (e *HttpApiExecutor) Query(ctx context.Context, dsInfo *models.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	result := &tsdb.Response{
+		Results: make(map[string]*tsdb.QueryResult),
+	}
+
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range tsdbQuery.Queries {
+		queryRes, err := e.executeQuery(ctx, httpClient, dsInfo, q, tsdbQuery.TimeRange)
+		if err != nil {
+			queryRes = tsdb.NewQueryResult()
+			queryRes.Error = err
+		}
+
+		queryRes.RefId = q.RefId
+		result.Results[q.RefId] = queryRes
+	}
+
+	return result, nil
+}
+
+func (e *HttpApiExecutor) executeQuery(ctx context.Context, httpClient *http.Client, dsInfo *models.DataSource, q *tsdb.Query, timeRange *tsdb.TimeRange) (*tsdb.QueryResult, error) {
+	query, err := parseQuery(q.RefId, q.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	minInterval, err := tsdb.GetIntervalFrom(dsInfo, q.Model, time.Millisecond*1)
+	if err != nil {
+		return nil, err
+	}
+	interval := e.intervalCalculator.Calculate(timeRange, minInterval)
+
+	req, err := createRequest(dsInfo, query, timeRange, interval, q.Model.Get("maxDataPoints").MustInt64(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if setting.Env == setting.DEV {
+		glog.Debug("Http api request", "url", req.URL.String())
+	}
+
+	res, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		glog.Info("Request failed", "status", res.Status, "body", string(body))
+		return nil, fmt.Errorf("Request failed status: %v", res.Status)
+	}
+
+	return parseResponse(io.LimitReader(res.Body, maxResponseSize), query)
+}
+
+func createRequest(dsInfo *models.DataSource, query *Query, timeRange *tsdb.TimeRange, interval tsdb.Interval, maxDataPoints int64) (*http.Request, error) {
+	replacer := newVariableReplacer(timeRange, interval, maxDataPoints)
+
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Path != "" {
+		// the request is sent with the credentials of the data source, the
+		// path of a query must not leave the path of the data source
+		basePath := path.Clean("/" + u.Path)
+		queryPath := path.Join(basePath, replacer.Replace(query.Path))
+		if queryPath != basePath && !strings.HasPrefix(queryPath, strings.TrimSuffix(basePath, "/")+"/") {
+			return nil, fmt.Errorf("Path %s is outside of the url of the data source", query.Path)
+		}
+		u.Path = queryPath
+	}
+
+	params := u.Query()
+	timeFormat := dsInfo.JsonData.Get("timeFormat").MustString("ms")
+	if name := dsInfo.JsonData.Get("fromParam").MustString("from"); name != "" {
+		params.Set(name, formatTime(timeRange.MustGetFrom(), timeFormat))
+	}
+	if name := dsInfo.JsonData.Get("toParam").MustString("to"); name != "" {
+		params.Set(name, formatTime(timeRange.MustGetTo(), timeFormat))
+	}
+	if name := dsInfo.JsonData.Get("intervalParam").MustString("interval"); name != "" {
+		params.Set(name, interval.Text)
+	}
+	for key, value := range query.Params {
+		params.Set(key, replacer.Replace(value))
+	}
+	u.RawQuery = params.Encode()
+
+	var body io.Reader
+	if query.Method == "POST" {
+		body = strings.NewReader(replacer.Replace(query.Body))
+	}
+
+	req, err := http.NewRequest(query.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "Grafana")
+	if query.ResponseFormat == responseFormatCSV {
+		req.Header.Set("Accept", "text/csv")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	if query.Method == "POST" {
+		req.Header.Set("Content-Type", dsInfo.JsonData.Get("contentType").MustString("application/json"))
+	}
+
+	allowedHeaders := map[string]bool{}
+	for _, name := range dsInfo.JsonData.Get("allowedQueryHeaders").MustStringArray() {
+		allowedHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+	for key, value := range query.Headers {
+		if !allowedHeaders[http.CanonicalHeaderKey(key)] {
+			return nil, fmt.Errorf("Header %s is not allowed by the data source", key)
+		}
+		req.Header.Set(key, replacer.Replace(value))
+	}
+
+	if dsInfo.BasicAuth {
+		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.DecryptedBasicAuthPassword())
+	}
+
+	for key, value := range dsInfo.CustomHeaders() {
+		req.Header.Set(key, value)
+	}
+
+	return req, nil
+}
+
+func newVariableReplacer(timeRange *tsdb.TimeRange, interval tsdb.Interval, maxDataPoints int64) *strings.Replacer {
+	return strings.NewReplacer(
+		"$__from", strconv.FormatInt(timeRange.GetFromAsMsEpoch(), 10),
+		"$__to", strconv.FormatInt(timeRange.GetToAsMsEpoch(), 10),
+		"$__interval_ms", strconv.FormatInt(interval.Milliseconds(), 10),
+		"$__interval", interval.Text,
+		"$__maxDataPoints", strconv.FormatInt(maxDataPoints, 10),
+	)
+}
+
+func formatTime(t time.Time, format string) string {
+	switch format {
+	case "s":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "rfc3339":
+		return t.UTC().Format(time.RFC3339)
+	default:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+}
@@ -0,0 +1,186 @@
+package httpapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/securejsondata"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHttpApi(t *testing.T) {
+	Convey("Http api executor", t, func() {
+		var lastRequest *http.Request
+		var lastBody string
+		responseBody := ""
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			lastRequest = r
+			lastBody = string(body)
+			w.Write([]byte(responseBody))
+		}))
+
+		Reset(func() {
+			server.Close()
+		})
+
+		dsInfo := &models.DataSource{
+			Id:            1,
+			Url:           server.URL + "/api",
+			BasicAuth:     true,
+			BasicAuthUser: "user",
+			JsonData: simplejson.NewFromAny(map[string]interface{}{
+				"httpHeaderName1":     "X-Api-Key",
+				"allowedQueryHeaders": []interface{}{"X-Tenant"},
+			}),
+			SecureJsonData: securejsondata.GetEncryptedJsonData(map[string]string{
+				"httpHeaderValue1": "secret",
+			}),
+			Updated: time.Now(),
+		}
+
+		executor, _ := NewHttpApiExecutor(dsInfo)
+		timeRange := tsdb.NewTimeRange("1521118800000", "1521119400000")
+
+		query := func(model map[string]interface{}) *tsdb.QueryResult {
+			res, err := executor.Query(context.Background(), dsInfo, &tsdb.TsdbQuery{
+				TimeRange: timeRange,
+				Queries: []*tsdb.Query{
+					{RefId: "A", Model: simplejson.NewFromAny(model)},
+				},
+			})
+			So(err, ShouldBeNil)
+			return res.Results["A"]
+		}
+
+		Convey("When querying a json endpoint", func() {
+			responseBody = `{"data": [
+				{"ts": 1521118800, "host": "a", "load": 1.5, "mem": "10"},
+				{"ts": 1521118860, "host": "a", "load": 2.5, "mem": "20"},
+				{"ts": 1521118800, "host": "b", "load": 3, "mem": null}
+			]}`
+
+			result := query(map[string]interface{}{
+				"path":     "metrics/$__interval",
+				"params":   map[string]interface{}{"step": "$__interval_ms"},
+				"rootPath": "$.data",
+				"columns": []interface{}{
+					map[string]interface{}{"selector": "ts", "type": "time"},
+					map[string]interface{}{"selector": "host", "type": "string"},
+					map[string]interface{}{"selector": "load", "type": "number"},
+					map[string]interface{}{"selector": "mem", "text": "memory", "type": "number"},
+				},
+			})
+			So(result.Error, ShouldBeNil)
+
+			Convey("should send the time range, interval and auth", func() {
+				So(lastRequest.Method, ShouldEqual, "GET")
+				So(lastRequest.URL.Path, ShouldEqual, "/api/metrics/500ms")
+				So(lastRequest.URL.Query().Get("from"), ShouldEqual, "1521118800000")
+				So(lastRequest.URL.Query().Get("to"), ShouldEqual, "1521119400000")
+				So(lastRequest.URL.Query().Get("interval"), ShouldEqual, "500ms")
+				So(lastRequest.URL.Query().Get("step"), ShouldEqual, "500")
+				So(lastRequest.Header.Get("X-Api-Key"), ShouldEqual, "secret")
+				user, _, ok := lastRequest.BasicAuth()
+				So(ok, ShouldBeTrue)
+				So(user, ShouldEqual, "user")
+			})
+
+			Convey("should return a series per host and value column", func() {
+				So(len(result.Series), ShouldEqual, 4)
+				So(result.Series[0].Name, ShouldEqual, "a load")
+				So(result.Series[0].Tags["host"], ShouldEqual, "a")
+				So(len(result.Series[0].Points), ShouldEqual, 2)
+				So(result.Series[0].Points[1][0].Float64, ShouldEqual, 2.5)
+				So(result.Series[0].Points[1][1].Float64, ShouldEqual, 1521118860000)
+				So(result.Series[1].Name, ShouldEqual, "a memory")
+				So(result.Series[1].Points[0][0].Float64, ShouldEqual, 10)
+				So(result.Series[3].Name, ShouldEqual, "b memory")
+				So(result.Series[3].Points[0][0].Valid, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the path of a query leaves the url of the data source", func() {
+			result := query(map[string]interface{}{
+				"path": "../admin/users",
+			})
+			So(result.Error, ShouldNotBeNil)
+			So(lastRequest, ShouldBeNil)
+		})
+
+		Convey("When a query sets headers", func() {
+			responseBody = `[]`
+
+			Convey("should send allowed headers", func() {
+				result := query(map[string]interface{}{
+					"headers": map[string]interface{}{"x-tenant": "team-a"},
+					"format":  "table",
+				})
+				So(result.Error, ShouldBeNil)
+				So(lastRequest.Header.Get("X-Tenant"), ShouldEqual, "team-a")
+			})
+
+			Convey("should reject other headers", func() {
+				result := query(map[string]interface{}{
+					"headers": map[string]interface{}{"Authorization": "Bearer other"},
+				})
+				So(result.Error, ShouldNotBeNil)
+				So(lastRequest, ShouldBeNil)
+			})
+		})
+
+		Convey("When querying a json endpoint as table without columns", func() {
+			responseBody = `[{"time": "2018-03-15T13:00:00Z", "name": "a", "value": 1}]`
+
+			result := query(map[string]interface{}{
+				"method": "POST",
+				"body":   `{"from": $__from}`,
+				"format": "table",
+			})
+			So(result.Error, ShouldBeNil)
+
+			So(lastRequest.Method, ShouldEqual, "POST")
+			So(lastBody, ShouldEqual, `{"from": 1521118800000}`)
+
+			So(len(result.Tables), ShouldEqual, 1)
+			So(result.Tables[0].Columns[0].Text, ShouldEqual, "name")
+			So(result.Tables[0].Columns[1].Text, ShouldEqual, "time")
+			So(result.Tables[0].Rows[0], ShouldResemble, tsdb.RowValues{"a", float64(1521118800000), float64(1)})
+		})
+
+		Convey("When querying a csv endpoint", func() {
+			responseBody = "time,host,value\n1521118800000,a,1\n1521118860000,a,2\n"
+
+			result := query(map[string]interface{}{
+				"responseFormat": "csv",
+			})
+			So(result.Error, ShouldBeNil)
+
+			So(lastRequest.Header.Get("Accept"), ShouldEqual, "text/csv")
+			So(len(result.Series), ShouldEqual, 1)
+			So(result.Series[0].Name, ShouldEqual, "a")
+			So(len(result.Series[0].Points), ShouldEqual, 2)
+			So(result.Series[0].Points[1][0].Float64, ShouldEqual, 2)
+		})
+
+		Convey("When a csv column does not exist", func() {
+			responseBody = "time,value\n1521118800000,1\n"
+
+			result := query(map[string]interface{}{
+				"responseFormat": "csv",
+				"columns": []interface{}{
+					map[string]interface{}{"selector": "missing"},
+				},
+			})
+			So(result.Error, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,181 @@
+package httpapi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. The supported subset is the
+// root ($), child (.name and ['name']), index ([n]), wildcard (.* and [*])
+// and recursive descent (..name) selectors.
+type jsonPath []pathSegment
+
+type pathSegment struct {
+	name      string
+	index     int
+	wildcard  bool
+	recursive bool
+	isIndex   bool
+}
+
+func compileJSONPath(expr string) (jsonPath, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" || expr == "$" {
+		return jsonPath{}, nil
+	}
+
+	if strings.HasPrefix(expr, "$") {
+		expr = expr[1:]
+	} else if !strings.HasPrefix(expr, ".") && !strings.HasPrefix(expr, "[") {
+		// selectors relative to a row may omit the leading dot
+		expr = "." + expr
+	}
+
+	path := jsonPath{}
+	for len(expr) > 0 {
+		recursive := false
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			recursive = true
+			expr = expr[2:]
+		case expr[0] == '.':
+			expr = expr[1:]
+		case expr[0] == '[':
+		default:
+			return nil, fmt.Errorf("Invalid JSONPath near %q", expr)
+		}
+
+		if len(expr) > 0 && expr[0] == '[' {
+			end := strings.Index(expr, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("Invalid JSONPath, missing ] near %q", expr)
+			}
+
+			segment, err := parseBracket(expr[1:end])
+			if err != nil {
+				return nil, err
+			}
+			segment.recursive = recursive
+			path = append(path, segment)
+			expr = expr[end+1:]
+			continue
+		}
+
+		end := strings.IndexAny(expr, ".[")
+		if end < 0 {
+			end = len(expr)
+		}
+
+		name := expr[:end]
+		if name == "" {
+			return nil, fmt.Errorf("Invalid JSONPath, empty name near %q", expr)
+		}
+
+		path = append(path, pathSegment{name: name, wildcard: name == "*", recursive: recursive})
+		expr = expr[end:]
+	}
+
+	return path, nil
+}
+
+func parseBracket(content string) (pathSegment, error) {
+	content = strings.TrimSpace(content)
+	if content == "*" {
+		return pathSegment{wildcard: true}, nil
+	}
+
+	if len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0] {
+		return pathSegment{name: content[1 : len(content)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return pathSegment{}, fmt.Errorf("Invalid JSONPath index %q", content)
+	}
+
+	return pathSegment{index: index, isIndex: true}, nil
+}
+
+// Find returns all values matched by the expression.
+func (p jsonPath) Find(data interface{}) []interface{} {
+	current := []interface{}{data}
+	for _, segment := range p {
+		next := []interface{}{}
+		for _, value := range current {
+			if segment.recursive {
+				for _, v := range descendants(value) {
+					next = append(next, segment.apply(v)...)
+				}
+			} else {
+				next = append(next, segment.apply(value)...)
+			}
+		}
+		current = next
+	}
+
+	return current
+}
+
+func (s pathSegment) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			result := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				result = append(result, v[key])
+			}
+			return result
+		}
+
+		if child, ok := v[s.name]; ok && !s.isIndex {
+			return []interface{}{child}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return v
+		}
+
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []interface{}{v[index]}
+			}
+		}
+	}
+
+	return nil
+}
+
+// descendants returns the value and all values nested in it.
+func descendants(value interface{}) []interface{} {
+	result := []interface{}{value}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			result = append(result, descendants(v[key])...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			result = append(result, descendants(child)...)
+		}
+	}
+
+	return result
+}
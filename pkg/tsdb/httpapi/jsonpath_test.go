@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONPath(t *testing.T) {
+	Convey("JSONPath", t, func() {
+		var data interface{}
+		err := json.Unmarshal([]byte(`{
+			"status": "ok",
+			"data": {
+				"items": [
+					{"name": "a", "stats": {"value": 1}},
+					{"name": "b", "stats": {"value": 2}}
+				]
+			}
+		}`), &data)
+		So(err, ShouldBeNil)
+
+		find := func(expr string) []interface{} {
+			path, err := compileJSONPath(expr)
+			So(err, ShouldBeNil)
+			return path.Find(data)
+		}
+
+		Convey("root returns the document", func() {
+			So(find("$"), ShouldResemble, []interface{}{data})
+		})
+
+		Convey("child and index selectors", func() {
+			So(find("$.status"), ShouldResemble, []interface{}{"ok"})
+			So(find("$.data.items[1].name"), ShouldResemble, []interface{}{"b"})
+			So(find("$['data']['items'][-1]['name']"), ShouldResemble, []interface{}{"b"})
+		})
+
+		Convey("wildcard selectors", func() {
+			So(find("$.data.items[*].name"), ShouldResemble, []interface{}{"a", "b"})
+			So(find("$.data.items[0].*"), ShouldResemble, []interface{}{"a", map[string]interface{}{"value": float64(1)}})
+		})
+
+		Convey("recursive descent", func() {
+			So(find("$..value"), ShouldResemble, []interface{}{float64(1), float64(2)})
+		})
+
+		Convey("relative selectors", func() {
+			path, err := compileJSONPath("stats.value")
+			So(err, ShouldBeNil)
+			So(path.Find(map[string]interface{}{"stats": map[string]interface{}{"value": "x"}}), ShouldResemble, []interface{}{"x"})
+		})
+
+		Convey("missing values return no match", func() {
+			So(len(find("$.data.missing")), ShouldEqual, 0)
+			So(len(find("$.data.items[5]")), ShouldEqual, 0)
+		})
+
+		Convey("invalid expressions return an error", func() {
+			_, err := compileJSONPath("$.data[")
+			So(err, ShouldNotBeNil)
+			_, err = compileJSONPath("$.data[abc]")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
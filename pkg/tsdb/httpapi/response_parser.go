@@ -0,0 +1,347 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+var timeColumnNames = map[string]bool{
+	"time":      true,
+	"timestamp": true,
+	"ts":        true,
+	"date":      true,
+}
+
+// parseResponse extracts the columns of the query from a json or csv
+// response and converts them to time series or a table.
+func parseResponse(body io.Reader, query *Query) (*tsdb.QueryResult, error) {
+	var table *tsdb.Table
+	var columns []Column
+	var err error
+
+	switch query.ResponseFormat {
+	case responseFormatCSV:
+		table, columns, err = parseCSV(body, query.Columns)
+	default:
+		table, columns, err = parseJSON(body, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queryRes := tsdb.NewQueryResult()
+	if query.ResultFormat == resultFormatTable {
+		queryRes.Tables = append(queryRes.Tables, table)
+		return queryRes, nil
+	}
+
+	series, err := tableToTimeSeries(table, columns)
+	if err != nil {
+		return nil, err
+	}
+	queryRes.Series = series
+
+	return queryRes, nil
+}
+
+func parseJSON(body io.Reader, query *Query) (*tsdb.Table, []Column, error) {
+	var data interface{}
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse json response: %v", err)
+	}
+
+	rootPath, err := compileJSONPath(query.RootPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := rootPath.Find(data)
+	if len(rows) == 1 {
+		if array, ok := rows[0].([]interface{}); ok {
+			rows = array
+		}
+	}
+
+	columns := query.Columns
+	if len(columns) == 0 {
+		columns = inferJSONColumns(rows)
+	}
+
+	paths := make([]jsonPath, len(columns))
+	for i, column := range columns {
+		if paths[i], err = compileJSONPath(column.Selector); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	table := newTable(columns)
+	for _, row := range rows {
+		values := make(tsdb.RowValues, len(columns))
+		for i, column := range columns {
+			if matches := paths[i].Find(row); len(matches) > 0 {
+				values[i] = convertValue(column, matches[0])
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+
+	return table, columns, nil
+}
+
+// inferJSONColumns uses the scalar fields of the first row as columns.
+func inferJSONColumns(rows []interface{}) []Column {
+	columns := []Column{}
+	if len(rows) == 0 {
+		return columns
+	}
+
+	fields, ok := rows[0].(map[string]interface{})
+	if !ok {
+		return []Column{{Selector: "$", Text: "value", Type: inferType("value", rows[0])}}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch fields[key].(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		columns = append(columns, Column{Selector: "['" + key + "']", Text: key, Type: inferType(key, fields[key])})
+	}
+
+	return columns
+}
+
+func parseCSV(body io.Reader, definedColumns []Column) (*tsdb.Table, []Column, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse csv response: %v", err)
+	}
+
+	if len(records) == 0 {
+		return newTable(definedColumns), definedColumns, nil
+	}
+
+	header := records[0]
+	records = records[1:]
+
+	columns := definedColumns
+	if len(columns) == 0 {
+		for i, name := range header {
+			var sample interface{}
+			if len(records) > 0 && i < len(records[0]) {
+				sample = records[0][i]
+			}
+			columns = append(columns, Column{Selector: name, Text: name, Type: inferType(name, sample)})
+		}
+	}
+
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = -1
+		for j, name := range header {
+			if name == column.Selector {
+				indexes[i] = j
+				break
+			}
+		}
+
+		if indexes[i] < 0 {
+			return nil, nil, fmt.Errorf("Column %s not found in csv header", column.Selector)
+		}
+	}
+
+	table := newTable(columns)
+	for _, record := range records {
+		values := make(tsdb.RowValues, len(columns))
+		for i, column := range columns {
+			if indexes[i] < len(record) {
+				values[i] = convertValue(column, record[indexes[i]])
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+
+	return table, columns, nil
+}
+
+func newTable(columns []Column) *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: make([]tsdb.TableColumn, 0, len(columns)),
+		Rows:    make([]tsdb.RowValues, 0),
+	}
+
+	for _, column := range columns {
+		table.Columns = append(table.Columns, tsdb.TableColumn{Text: column.Text})
+	}
+
+	return table
+}
+
+func inferType(name string, sample interface{}) string {
+	if timeColumnNames[strings.ToLower(name)] {
+		return columnTypeTime
+	}
+
+	switch v := sample.(type) {
+	case float64:
+		return columnTypeNumber
+	case string:
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return columnTypeNumber
+		}
+	}
+
+	return columnTypeString
+}
+
+func convertValue(column Column, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch column.Type {
+	case columnTypeTime:
+		return convertTime(value, column.TimeFormat)
+	case columnTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v
+		case bool:
+			if v {
+				return float64(1)
+			}
+			return float64(0)
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f
+			}
+		}
+		return nil
+	default:
+		switch v := value.(type) {
+		case string:
+			return v
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(v)
+			return string(b)
+		default:
+			return fmt.Sprintf("%v", v)
+		}
+	}
+}
+
+// convertTime returns the time in epoch milliseconds. Numbers are treated as
+// epoch timestamps in seconds, milliseconds or nanoseconds.
+func convertTime(value interface{}, format string) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return tsdb.EpochPrecisionToMs(v)
+	case string:
+		v = strings.TrimSpace(v)
+		if format != "" {
+			t, err := time.Parse(format, v)
+			if err != nil {
+				return nil
+			}
+			return float64(t.UnixNano() / int64(time.Millisecond))
+		}
+
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return tsdb.EpochPrecisionToMs(f)
+		}
+
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return float64(t.UnixNano() / int64(time.Millisecond))
+		}
+	}
+
+	return nil
+}
+
+// tableToTimeSeries creates a series per number column and combination of
+// string column values. The string values are used as series name and tags.
+func tableToTimeSeries(table *tsdb.Table, columns []Column) (tsdb.TimeSeriesSlice, error) {
+	timeIndex := -1
+	valueIndexes := []int{}
+	tagIndexes := []int{}
+
+	for i, column := range columns {
+		switch column.Type {
+		case columnTypeTime:
+			if timeIndex == -1 {
+				timeIndex = i
+			}
+		case columnTypeNumber:
+			valueIndexes = append(valueIndexes, i)
+		default:
+			tagIndexes = append(tagIndexes, i)
+		}
+	}
+
+	if timeIndex == -1 {
+		return nil, fmt.Errorf("Found no column of type time")
+	}
+
+	seriesByName := map[string]*tsdb.TimeSeries{}
+	result := tsdb.TimeSeriesSlice{}
+
+	for _, row := range table.Rows {
+		timestamp, ok := row[timeIndex].(float64)
+		if !ok {
+			continue
+		}
+
+		tags := map[string]string{}
+		tagValues := []string{}
+		for _, i := range tagIndexes {
+			if value, ok := row[i].(string); ok {
+				tags[columns[i].Text] = value
+				tagValues = append(tagValues, value)
+			}
+		}
+
+		for _, i := range valueIndexes {
+			name := columns[i].Text
+			if len(tagValues) > 0 {
+				name = strings.Join(tagValues, " ")
+				if len(valueIndexes) > 1 {
+					name += " " + columns[i].Text
+				}
+			}
+
+			series, exists := seriesByName[name]
+			if !exists {
+				series = &tsdb.TimeSeries{Name: name, Tags: tags, Points: tsdb.TimeSeriesPoints{}}
+				seriesByName[name] = series
+				result = append(result, series)
+			}
+
+			value := null.FloatFromPtr(nil)
+			if f, ok := row[i].(float64); ok {
+				value = null.FloatFrom(f)
+			}
+			series.Points = append(series.Points, tsdb.NewTimePoint(value, timestamp))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package httpapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+const (
+	columnTypeTime   = "time"
+	columnTypeNumber = "number"
+	columnTypeString = "string"
+
+	responseFormatJSON = "json"
+	responseFormatCSV  = "csv"
+
+	resultFormatTimeSeries = "time_series"
+	resultFormatTable      = "table"
+)
+
+// Column maps a JSONPath expression, relative to a row, or a csv header to
+// a result column.
+type Column struct {
+	Selector   string
+	Text       string
+	Type       string
+	TimeFormat string
+}
+
+// Query is a http api query parsed from the query model.
+type Query struct {
+	RefId          string
+	Method         string
+	Path           string
+	Params         map[string]string
+	Headers        map[string]string
+	Body           string
+	ResponseFormat string
+	ResultFormat   string
+	RootPath       string
+	Columns        []Column
+}
+
+func parseQuery(refID string, model *simplejson.Json) (*Query, error) {
+	query := &Query{
+		RefId:          refID,
+		Method:         strings.ToUpper(model.Get("method").MustString("GET")),
+		Path:           model.Get("path").MustString(),
+		Params:         map[string]string{},
+		Headers:        map[string]string{},
+		Body:           model.Get("body").MustString(),
+		ResponseFormat: model.Get("responseFormat").MustString(responseFormatJSON),
+		ResultFormat:   model.Get("format").MustString(resultFormatTimeSeries),
+		RootPath:       model.Get("rootPath").MustString("$"),
+	}
+
+	if query.Method != "GET" && query.Method != "POST" {
+		return nil, fmt.Errorf("Unsupported method %s", query.Method)
+	}
+
+	if query.ResponseFormat != responseFormatJSON && query.ResponseFormat != responseFormatCSV {
+		return nil, fmt.Errorf("Unsupported response format %s", query.ResponseFormat)
+	}
+
+	for key, value := range model.Get("params").MustMap() {
+		query.Params[key] = fmt.Sprintf("%v", value)
+	}
+
+	for key, value := range model.Get("headers").MustMap() {
+		query.Headers[key] = fmt.Sprintf("%v", value)
+	}
+
+	for _, c := range model.Get("columns").MustArray() {
+		column := simplejson.NewFromAny(c)
+		selector := column.Get("selector").MustString()
+		if selector == "" {
+			return nil, fmt.Errorf("Column selector is missing")
+		}
+
+		columnType := column.Get("type").MustString(columnTypeString)
+		switch columnType {
+		case columnTypeTime, columnTypeNumber, columnTypeString:
+		default:
+			return nil, fmt.Errorf("Unsupported column type %s", columnType)
+		}
+
+		query.Columns = append(query.Columns, Column{
+			Selector:   selector,
+			Text:       column.Get("text").MustString(selector),
+			Type:       columnType,
+			TimeFormat: column.Get("timeFormat").MustString(),
+		})
+	}
+
+	return query, nil
+}
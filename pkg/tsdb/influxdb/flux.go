@@ -0,0 +1,196 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// FluxQuery is a Flux query with the variables of the request resolved.
+type FluxQuery struct {
+	RefId        string
+	RawQuery     string
+	ResultFormat string
+	Interval     tsdb.Interval
+}
+
+type fluxRequest struct {
+	Query   string      `json:"query"`
+	Type    string      `json:"type"`
+	Dialect fluxDialect `json:"dialect"`
+}
+
+type fluxDialect struct {
+	Header      bool     `json:"header"`
+	Delimiter   string   `json:"delimiter"`
+	Annotations []string `json:"annotations"`
+}
+
+// isFluxMode returns true if the data source is configured to use Flux
+// instead of InfluxQL.
+func isFluxMode(dsInfo *models.DataSource) bool {
+	if dsInfo.JsonData == nil {
+		return false
+	}
+
+	return strings.ToLower(dsInfo.JsonData.Get("mode").MustString("")) == "flux"
+}
+
+func (e *InfluxDBExecutor) queryFlux(ctx context.Context, dsInfo *models.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	result := &tsdb.Response{
+		Results: make(map[string]*tsdb.QueryResult),
+	}
+
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range tsdbQuery.Queries {
+		query, err := parseFluxQuery(dsInfo, q, tsdbQuery.TimeRange)
+		if err != nil {
+			return nil, err
+		}
+
+		if setting.Env == setting.DEV {
+			glog.Debug("Influxdb flux query", "raw query", query.RawQuery)
+		}
+
+		queryRes, err := e.executeFluxQuery(ctx, httpClient, dsInfo, query)
+		if err != nil {
+			queryRes = tsdb.NewQueryResult()
+			queryRes.Error = err
+		}
+
+		queryRes.RefId = query.RefId
+		result.Results[query.RefId] = queryRes
+	}
+
+	return result, nil
+}
+
+func (e *InfluxDBExecutor) executeFluxQuery(ctx context.Context, httpClient *http.Client, dsInfo *models.DataSource, query *FluxQuery) (*tsdb.QueryResult, error) {
+	req, err := createFluxRequest(dsInfo, query.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Influxdb returned statuscode invalid status code: %v, %s", resp.Status, parseFluxError(body))
+	}
+
+	return parseFluxResponse(resp.Body, query)
+}
+
+// parseFluxQuery replaces the v.timeRangeStart, v.timeRangeStop,
+// v.windowPeriod, v.defaultBucket and v.organization variables in the query.
+func parseFluxQuery(dsInfo *models.DataSource, query *tsdb.Query, timeRange *tsdb.TimeRange) (*FluxQuery, error) {
+	rawQuery, err := query.Model.Get("query").String()
+	if err != nil {
+		return nil, fmt.Errorf("Flux query is missing")
+	}
+
+	minInterval, err := tsdb.GetIntervalFrom(dsInfo, query.Model, time.Millisecond*1)
+	if err != nil {
+		return nil, err
+	}
+
+	calculator := tsdb.NewIntervalCalculator(&tsdb.IntervalOptions{})
+	interval := calculator.Calculate(timeRange, minInterval)
+
+	from, err := timeRange.ParseFrom()
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := timeRange.ParseTo()
+	if err != nil {
+		return nil, err
+	}
+
+	replacer := strings.NewReplacer(
+		"v.timeRangeStart", from.UTC().Format(time.RFC3339Nano),
+		"v.timeRangeStop", to.UTC().Format(time.RFC3339Nano),
+		"v.windowPeriod", strconv.FormatInt(interval.Milliseconds(), 10)+"ms",
+		"v.defaultBucket", strconv.Quote(dsInfo.JsonData.Get("defaultBucket").MustString(dsInfo.Database)),
+		"v.organization", strconv.Quote(dsInfo.JsonData.Get("organization").MustString("")),
+		"$__interval_ms", strconv.FormatInt(interval.Milliseconds(), 10),
+		"$__interval", interval.Text,
+	)
+
+	return &FluxQuery{
+		RefId:        query.RefId,
+		RawQuery:     replacer.Replace(rawQuery),
+		ResultFormat: query.Model.Get("resultFormat").MustString("time_series"),
+		Interval:     interval,
+	}, nil
+}
+
+func createFluxRequest(dsInfo *models.DataSource, query string) (*http.Request, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "api/v2/query")
+
+	params := u.Query()
+	if org := dsInfo.JsonData.Get("organization").MustString(""); org != "" {
+		params.Set("org", org)
+	}
+	u.RawQuery = params.Encode()
+
+	body, err := json.Marshal(&fluxRequest{
+		Query: query,
+		Type:  "flux",
+		Dialect: fluxDialect{
+			Header:      true,
+			Delimiter:   ",",
+			Annotations: []string{"datatype", "group", "default"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "Grafana")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/csv")
+
+	if token := dsInfo.SecureJsonData.Decrypt()["token"]; token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	} else if dsInfo.BasicAuth {
+		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.DecryptedBasicAuthPassword())
+	} else if dsInfo.User != "" {
+		// InfluxDB 1.7+ accepts username:password as token on the v2 api
+		req.Header.Set("Authorization", "Token "+dsInfo.User+":"+dsInfo.DecryptedPassword())
+	}
+
+	glog.Debug("Influxdb flux request", "url", req.URL.String())
+	return req, nil
+}
@@ -0,0 +1,350 @@
+package influxdb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// fluxTable is a single table of an annotated csv flux response.
+type fluxTable struct {
+	columns   []string
+	datatypes []string
+	group     []bool
+	defaults  []string
+	rows      [][]string
+}
+
+// fluxIgnoredTags are the flux columns that are never used as series tags.
+var fluxIgnoredTags = map[string]bool{
+	"":             true,
+	"result":       true,
+	"table":        true,
+	"_start":       true,
+	"_stop":        true,
+	"_time":        true,
+	"_value":       true,
+	"_field":       true,
+	"_measurement": true,
+}
+
+func parseFluxError(body []byte) string {
+	var resp struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return strings.TrimSpace(string(body))
+	}
+
+	if resp.Message != "" {
+		return resp.Message
+	}
+
+	return resp.Error
+}
+
+// parseFluxResponse converts an annotated csv flux response into time series
+// or tables, depending on the result format of the query.
+func parseFluxResponse(body io.Reader, query *FluxQuery) (*tsdb.QueryResult, error) {
+	tables, err := readFluxTables(body)
+	if err != nil {
+		return nil, err
+	}
+
+	queryRes := tsdb.NewQueryResult()
+	for _, table := range tables {
+		if query.ResultFormat != "table" && table.isTimeSeries() {
+			queryRes.Series = append(queryRes.Series, table.toTimeSeries())
+		} else {
+			queryRes.Tables = append(queryRes.Tables, table.toTable())
+		}
+	}
+
+	return queryRes, nil
+}
+
+// readFluxTables reads the annotated csv. Every annotation block starts a new
+// result schema and the rows of a block are split by the table column.
+func readFluxTables(body io.Reader) ([]*fluxTable, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	tables := []*fluxTable{}
+	var schema *fluxTable
+	var current *fluxTable
+	currentID := ""
+	inAnnotations := false
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read flux response: %v", err)
+		}
+
+		if len(record) == 0 || (len(record) == 1 && record[0] == "") {
+			// an empty line separates result blocks
+			schema = nil
+			current = nil
+			continue
+		}
+
+		if strings.HasPrefix(record[0], "#") {
+			if !inAnnotations {
+				schema = &fluxTable{}
+				current = nil
+				inAnnotations = true
+			}
+
+			values := record[1:]
+			switch record[0] {
+			case "#datatype":
+				schema.datatypes = values
+			case "#group":
+				schema.group = make([]bool, len(values))
+				for i, v := range values {
+					schema.group[i] = v == "true"
+				}
+			case "#default":
+				schema.defaults = values
+			}
+			continue
+		}
+		inAnnotations = false
+
+		if schema == nil {
+			schema = &fluxTable{}
+		}
+
+		if schema.columns == nil {
+			schema.columns = record[1:]
+			if len(schema.columns) == 2 && schema.columns[0] == "error" && schema.columns[1] == "reference" {
+				return nil, readFluxErrorRecord(reader)
+			}
+			continue
+		}
+
+		values := record[1:]
+		id := schema.value(values, "table")
+		if current == nil || id != currentID {
+			current = &fluxTable{
+				columns:   schema.columns,
+				datatypes: schema.datatypes,
+				group:     schema.group,
+				defaults:  schema.defaults,
+			}
+			currentID = id
+			tables = append(tables, current)
+		}
+		current.rows = append(current.rows, values)
+	}
+
+	return tables, nil
+}
+
+func readFluxErrorRecord(reader *csv.Reader) error {
+	record, err := reader.Read()
+	if err != nil || len(record) < 2 {
+		return fmt.Errorf("Flux query failed")
+	}
+
+	return fmt.Errorf("Flux query failed: %s", record[1])
+}
+
+func (t *fluxTable) index(column string) int {
+	for i, c := range t.columns {
+		if c == column {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (t *fluxTable) value(row []string, column string) string {
+	i := t.index(column)
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+
+	if row[i] == "" && i < len(t.defaults) {
+		return t.defaults[i]
+	}
+
+	return row[i]
+}
+
+func (t *fluxTable) datatype(i int) string {
+	if i < len(t.datatypes) {
+		return t.datatypes[i]
+	}
+
+	return "string"
+}
+
+func (t *fluxTable) isTimeSeries() bool {
+	timeIdx := t.index("_time")
+	valueIdx := t.index("_value")
+	if timeIdx < 0 || valueIdx < 0 {
+		return false
+	}
+
+	switch t.datatype(valueIdx) {
+	case "double", "long", "unsignedLong":
+		return true
+	}
+
+	return false
+}
+
+// tags returns the string columns of the group key, which are the same for
+// every row of the table.
+func (t *fluxTable) tags() map[string]string {
+	tags := map[string]string{}
+	if len(t.rows) == 0 {
+		return tags
+	}
+
+	for i, column := range t.columns {
+		if fluxIgnoredTags[column] || i >= len(t.group) || !t.group[i] || t.datatype(i) != "string" {
+			continue
+		}
+		tags[column] = t.value(t.rows[0], column)
+	}
+
+	return tags
+}
+
+func (t *fluxTable) seriesName(tags map[string]string) string {
+	name := []string{}
+	if len(t.rows) > 0 {
+		if measurement := t.value(t.rows[0], "_measurement"); measurement != "" {
+			name = append(name, measurement)
+		}
+		if field := t.value(t.rows[0], "_field"); field != "" {
+			name = append(name, field)
+		}
+	}
+
+	if len(name) == 0 {
+		name = append(name, "_value")
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagText := []string{}
+	for _, k := range keys {
+		tagText = append(tagText, fmt.Sprintf("%s: %s", k, tags[k]))
+	}
+
+	if len(tagText) > 0 {
+		return fmt.Sprintf("%s { %s }", strings.Join(name, "."), strings.Join(tagText, " "))
+	}
+
+	return strings.Join(name, ".")
+}
+
+func (t *fluxTable) toTimeSeries() *tsdb.TimeSeries {
+	tags := t.tags()
+	series := &tsdb.TimeSeries{
+		Name:   t.seriesName(tags),
+		Tags:   tags,
+		Points: tsdb.TimeSeriesPoints{},
+	}
+
+	valueIdx := t.index("_value")
+	timeIdx := t.index("_time")
+	for _, row := range t.rows {
+		timestamp, ok := parseFluxValue(t.datatype(timeIdx), t.value(row, "_time")).(float64)
+		if !ok {
+			continue
+		}
+
+		value := null.FloatFromPtr(nil)
+		if v, ok := parseFluxValue(t.datatype(valueIdx), t.value(row, "_value")).(float64); ok {
+			value = null.FloatFrom(v)
+		}
+
+		series.Points = append(series.Points, tsdb.NewTimePoint(value, timestamp))
+	}
+
+	return series
+}
+
+func (t *fluxTable) toTable() *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{},
+		Rows:    []tsdb.RowValues{},
+	}
+
+	indexes := []int{}
+	for i, column := range t.columns {
+		if column == "" || column == "result" || column == "table" {
+			continue
+		}
+		indexes = append(indexes, i)
+		table.Columns = append(table.Columns, tsdb.TableColumn{Text: column})
+	}
+
+	for _, row := range t.rows {
+		values := tsdb.RowValues{}
+		for _, i := range indexes {
+			values = append(values, parseFluxValue(t.datatype(i), t.value(row, t.columns[i])))
+		}
+		table.Rows = append(table.Rows, values)
+	}
+
+	return table
+}
+
+// parseFluxValue converts a csv value to its flux datatype. Times are
+// returned as epoch milliseconds.
+func parseFluxValue(datatype string, value string) interface{} {
+	if value == "" && datatype != "string" {
+		return nil
+	}
+
+	switch datatype {
+	case "double":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	case "long":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return float64(v)
+		}
+	case "unsignedLong":
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return float64(v)
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v
+		}
+	case "dateTime:RFC3339", "dateTime:RFC3339Nano":
+		if v, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return float64(v.UnixNano() / int64(time.Millisecond))
+		}
+	default:
+		return value
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package influxdb
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const fluxSeriesResponse = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string
+#group,false,false,true,true,false,false,true,true,true
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,host
+,,0,2018-10-01T00:00:00Z,2018-10-01T01:00:00Z,2018-10-01T00:00:10Z,1.5,usage_idle,cpu,server1
+,,0,2018-10-01T00:00:00Z,2018-10-01T01:00:00Z,2018-10-01T00:00:20Z,,usage_idle,cpu,server1
+,,1,2018-10-01T00:00:00Z,2018-10-01T01:00:00Z,2018-10-01T00:00:10Z,3,usage_idle,cpu,server2
+
+#datatype,string,long,string,boolean
+#group,false,false,true,false
+#default,_result,,,
+,result,table,host,up
+,,0,server1,true
+`
+
+func TestInfluxdbFluxResponseParser(t *testing.T) {
+	Convey("Influxdb flux response parser", t, func() {
+		Convey("can parse annotated csv as time series and tables", func() {
+			result, err := parseFluxResponse(strings.NewReader(fluxSeriesResponse), &FluxQuery{ResultFormat: "time_series"})
+			So(err, ShouldBeNil)
+
+			So(len(result.Series), ShouldEqual, 2)
+			So(result.Series[0].Name, ShouldEqual, "cpu.usage_idle { host: server1 }")
+			So(result.Series[0].Tags["host"], ShouldEqual, "server1")
+			So(len(result.Series[0].Points), ShouldEqual, 2)
+			So(result.Series[0].Points[0][0].Float64, ShouldEqual, 1.5)
+			So(result.Series[0].Points[0][1].Float64, ShouldEqual, 1538352010000)
+			So(result.Series[0].Points[1][0].Valid, ShouldBeFalse)
+			So(result.Series[1].Tags["host"], ShouldEqual, "server2")
+
+			So(len(result.Tables), ShouldEqual, 1)
+			So(len(result.Tables[0].Columns), ShouldEqual, 2)
+			So(result.Tables[0].Columns[0].Text, ShouldEqual, "host")
+			So(result.Tables[0].Rows[0][0], ShouldEqual, "server1")
+			So(result.Tables[0].Rows[0][1], ShouldEqual, true)
+		})
+
+		Convey("returns tables when the result format is table", func() {
+			result, err := parseFluxResponse(strings.NewReader(fluxSeriesResponse), &FluxQuery{ResultFormat: "table"})
+			So(err, ShouldBeNil)
+
+			So(len(result.Series), ShouldEqual, 0)
+			So(len(result.Tables), ShouldEqual, 3)
+			So(result.Tables[0].Columns[2].Text, ShouldEqual, "_time")
+			So(result.Tables[0].Rows[0][2], ShouldEqual, float64(1538352010000))
+		})
+
+		Convey("returns the error of an error response", func() {
+			response := "#datatype,string,long\n#group,true,true\n#default,,\n,error,reference\n,\"failed to compile\",897\n"
+			_, err := parseFluxResponse(strings.NewReader(response), &FluxQuery{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "failed to compile")
+		})
+	})
+}
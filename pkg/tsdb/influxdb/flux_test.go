@@ -0,0 +1,61 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInfluxdbFlux(t *testing.T) {
+	Convey("Influxdb flux", t, func() {
+		dsInfo := &models.DataSource{
+			Url:      "http://localhost:8086",
+			Database: "telegraf",
+			JsonData: simplejson.NewFromAny(map[string]interface{}{
+				"mode":         "flux",
+				"organization": "grafana",
+			}),
+		}
+
+		Convey("is enabled by the data source mode", func() {
+			So(isFluxMode(dsInfo), ShouldBeTrue)
+			So(isFluxMode(&models.DataSource{JsonData: simplejson.New()}), ShouldBeFalse)
+		})
+
+		Convey("replaces the flux variables", func() {
+			query := &tsdb.Query{
+				RefId: "A",
+				Model: simplejson.NewFromAny(map[string]interface{}{
+					"query": `from(bucket: v.defaultBucket) |> range(start: v.timeRangeStart, stop: v.timeRangeStop) |> aggregateWindow(every: v.windowPeriod, fn: mean)`,
+				}),
+			}
+			timeRange := tsdb.NewTimeRange("1538352000000", "1538355600000")
+
+			fluxQuery, err := parseFluxQuery(dsInfo, query, timeRange)
+			So(err, ShouldBeNil)
+			So(fluxQuery.RefId, ShouldEqual, "A")
+			So(fluxQuery.ResultFormat, ShouldEqual, "time_series")
+			So(fluxQuery.RawQuery, ShouldEqual, `from(bucket: "telegraf") |> range(start: 2018-10-01T00:00:00Z, stop: 2018-10-01T01:00:00Z) |> aggregateWindow(every: 2000ms, fn: mean)`)
+		})
+
+		Convey("creates a query request with the flux dialect", func() {
+			req, err := createFluxRequest(dsInfo, "buckets()")
+			So(err, ShouldBeNil)
+			So(req.Method, ShouldEqual, "POST")
+			So(req.URL.String(), ShouldEqual, "http://localhost:8086/api/v2/query?org=grafana")
+			So(req.Header.Get("Accept"), ShouldEqual, "application/csv")
+
+			body, _ := ioutil.ReadAll(req.Body)
+			request := &fluxRequest{}
+			So(json.Unmarshal(body, request), ShouldBeNil)
+			So(request.Query, ShouldEqual, "buckets()")
+			So(request.Type, ShouldEqual, "flux")
+			So(len(request.Dialect.Annotations), ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,32 @@
+package influxdb
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// CheckHealth pings the InfluxDB server.
+func (e *InfluxDBExecutor) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "ping")
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "Grafana")
+	if !dsInfo.BasicAuth && dsInfo.User != "" {
+		req.SetBasicAuth(dsInfo.User, dsInfo.DecryptedPassword())
+	}
+
+	return tsdb.CheckHttpHealth(ctx, dsInfo, req)
+}
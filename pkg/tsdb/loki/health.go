@@ -0,0 +1,23 @@
+package loki
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// CheckHealth requests the label names of the Loki server.
+func (e *LokiExecutor) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := e.get(ctx, httpClient, dsInfo, "/loki/api/v1/labels", url.Values{}); err != nil {
+		return nil, err
+	}
+
+	return &tsdb.HealthCheckResult{Status: tsdb.HealthStatusOk, Message: "Data source is working"}, nil
+}
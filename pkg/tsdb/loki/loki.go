@@ -0,0 +1,267 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+type LokiExecutor struct{}
+
+func NewLokiExecutor(dsInfo *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+	return &LokiExecutor{}, nil
+}
+
+var (
+	plog               log.Logger
+	legendFormat       *regexp.Regexp
+	intervalCalculator tsdb.IntervalCalculator
+)
+
+func init() {
+	plog = log.New("tsdb.loki")
+	tsdb.RegisterTsdbQueryEndpoint("loki", NewLokiExecutor)
+	legendFormat = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+	intervalCalculator = tsdb.NewIntervalCalculator(&tsdb.IntervalOptions{MinInterval: time.Second * 1})
+}
+
+const (
+	defaultMaxLines = 1000
+	// safeResolution is the maximum number of points Loki returns per series.
+	safeResolution = 11000
+)
+
+func (e *LokiExecutor) Query(ctx context.Context, dsInfo *models.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	result := &tsdb.Response{
+		Results: map[string]*tsdb.QueryResult{},
+	}
+
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := parseQuery(dsInfo, tsdbQuery.Queries, tsdbQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, query := range queries {
+		if setting.Env == setting.DEV {
+			plog.Debug("Loki query", "expr", query.Expr, "type", query.QueryType)
+		}
+
+		queryResult, err := e.executeQuery(ctx, httpClient, dsInfo, query)
+		if err != nil {
+			queryResult = tsdb.NewQueryResult()
+			queryResult.Error = err
+		}
+
+		queryResult.RefId = query.RefId
+		result.Results[query.RefId] = queryResult
+	}
+
+	return result, nil
+}
+
+func (e *LokiExecutor) executeQuery(ctx context.Context, httpClient *http.Client, dsInfo *models.DataSource, query *LokiQuery) (*tsdb.QueryResult, error) {
+	params := url.Values{}
+	params.Set("start", strconv.FormatInt(query.Start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(query.End.UnixNano(), 10))
+
+	var endpoint string
+	switch query.QueryType {
+	case queryTypeRange:
+		endpoint = "/loki/api/v1/query_range"
+		params.Set("query", query.Expr)
+		params.Set("limit", strconv.FormatInt(query.Limit, 10))
+		params.Set("direction", query.Direction)
+		params.Set("step", strconv.FormatFloat(query.Step.Seconds(), 'f', -1, 64))
+	case queryTypeInstant:
+		endpoint = "/loki/api/v1/query"
+		params = url.Values{}
+		params.Set("query", query.Expr)
+		params.Set("limit", strconv.FormatInt(query.Limit, 10))
+		params.Set("direction", query.Direction)
+		params.Set("time", strconv.FormatInt(query.End.UnixNano(), 10))
+	case queryTypeLabelNames:
+		endpoint = "/loki/api/v1/labels"
+	case queryTypeLabelValues:
+		endpoint = "/loki/api/v1/label/" + url.PathEscape(query.Label) + "/values"
+	}
+
+	data, err := e.get(ctx, httpClient, dsInfo, endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.QueryType == queryTypeLabelNames || query.QueryType == queryTypeLabelValues {
+		var values []string
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+
+		queryResult := tsdb.NewQueryResult()
+		queryResult.Tables = append(queryResult.Tables, newTextTable(values))
+		return queryResult, nil
+	}
+
+	var qd queryData
+	if err := json.Unmarshal(data, &qd); err != nil {
+		return nil, err
+	}
+
+	return parseResponse(&qd, query)
+}
+
+func (e *LokiExecutor) get(ctx context.Context, httpClient *http.Client, dsInfo *models.DataSource, endpoint string, params url.Values) (json.RawMessage, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, endpoint)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "Grafana")
+	if dsInfo.BasicAuth {
+		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.DecryptedBasicAuthPassword())
+	}
+	for key, value := range dsInfo.CustomHeaders() {
+		req.Header.Set(key, value)
+	}
+
+	res, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response lokiResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		// loki returns plain text errors for invalid queries
+		if res.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("Loki request failed, status: %s, %s", res.Status, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("Failed to parse response from Loki: %v", err)
+	}
+
+	if response.Status == "error" || res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("Loki request failed, status: %s, %s", res.Status, response.Error)
+	}
+
+	return response.Data, nil
+}
+
+func parseQuery(dsInfo *models.DataSource, queries []*tsdb.Query, queryContext *tsdb.TsdbQuery) ([]*LokiQuery, error) {
+	qs := []*LokiQuery{}
+	for _, queryModel := range queries {
+		queryType := queryModel.Model.Get("queryType").MustString(queryTypeRange)
+		if queryModel.Model.Get("instant").MustBool(false) {
+			queryType = queryTypeInstant
+		}
+
+		expr := queryModel.Model.Get("expr").MustString("")
+		switch queryType {
+		case queryTypeRange, queryTypeInstant:
+			if expr == "" {
+				return nil, fmt.Errorf("Query %s is missing an expression", queryModel.RefId)
+			}
+		case queryTypeLabelNames:
+		case queryTypeLabelValues:
+			if queryModel.Model.Get("label").MustString("") == "" {
+				return nil, fmt.Errorf("Query of type %s requires a label", queryType)
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported query type: %s", queryType)
+		}
+
+		start, err := queryContext.TimeRange.ParseFrom()
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := queryContext.TimeRange.ParseTo()
+		if err != nil {
+			return nil, err
+		}
+
+		dsInterval, err := tsdb.GetIntervalFrom(dsInfo, queryModel.Model, time.Second*1)
+		if err != nil {
+			return nil, err
+		}
+
+		interval := intervalCalculator.Calculate(queryContext.TimeRange, dsInterval)
+		step := adjustStep(interval.Value, dsInterval, end.Sub(start))
+
+		expr = strings.Replace(expr, "$__interval_ms", strconv.FormatInt(int64(step/time.Millisecond), 10), -1)
+		expr = strings.Replace(expr, "$__interval", strconv.FormatInt(int64(step/time.Second), 10)+"s", -1)
+
+		direction := queryModel.Model.Get("direction").MustString("backward")
+		if direction != "backward" && direction != "forward" {
+			return nil, fmt.Errorf("Unsupported direction: %s", direction)
+		}
+
+		limit := queryModel.Model.Get("maxLines").MustInt64(dsInfo.JsonData.Get("maxLines").MustInt64(defaultMaxLines))
+		if limit <= 0 {
+			limit = defaultMaxLines
+		}
+
+		qs = append(qs, &LokiQuery{
+			Expr:         expr,
+			RefId:        queryModel.RefId,
+			QueryType:    queryType,
+			LegendFormat: queryModel.Model.Get("legendFormat").MustString(""),
+			Label:        queryModel.Model.Get("label").MustString(""),
+			Direction:    direction,
+			Limit:        limit,
+			Step:         step,
+			Start:        start,
+			End:          end,
+		})
+	}
+
+	return qs, nil
+}
+
+// adjustStep keeps the number of points per series below the maximum
+// resolution supported by Loki.
+func adjustStep(interval time.Duration, minInterval time.Duration, queryRange time.Duration) time.Duration {
+	step := interval
+	if step != 0 && queryRange.Seconds()/step.Seconds() > safeResolution {
+		step = time.Duration(int64(queryRange.Seconds()/safeResolution)+1) * time.Second
+	}
+
+	if step < minInterval {
+		step = minInterval
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+
+	return step
+}
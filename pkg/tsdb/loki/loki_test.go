@@ -0,0 +1,132 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoki(t *testing.T) {
+	Convey("Loki", t, func() {
+		var lastRequest *http.Request
+		responses := map[string]string{
+			"/loki/api/v1/query_range": `{"status": "success", "data": {"resultType": "streams", "result": [
+				{"stream": {"job": "app"}, "values": [["1521118860000000000", "second"], ["1521118800000000000", "first"]]},
+				{"stream": {"job": "db"}, "values": [["1521118830000000000", "db line"]]}
+			]}}`,
+			"/loki/api/v1/query": `{"status": "success", "data": {"resultType": "vector", "result": [
+				{"metric": {"job": "app"}, "value": [1521119400, "4"]}
+			]}}`,
+			"/loki/api/v1/labels":              `{"status": "success", "data": ["job", "level"]}`,
+			"/loki/api/v1/label/job/values":    `{"status": "success", "data": ["app", "db"]}`,
+			"/loki/api/v1/label/broken/values": `parse error`,
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lastRequest = r
+			body, ok := responses[r.URL.Path]
+			if !ok || body == "parse error" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("parse error at line 1"))
+				return
+			}
+			w.Write([]byte(body))
+		}))
+
+		Reset(func() {
+			server.Close()
+		})
+
+		dsInfo := &models.DataSource{
+			Id:       1,
+			Url:      server.URL,
+			JsonData: simplejson.New(),
+			Updated:  time.Now(),
+		}
+		executor, _ := NewLokiExecutor(dsInfo)
+		timeRange := tsdb.NewTimeRange("1521118800000", "1521119400000")
+
+		query := func(model map[string]interface{}) *tsdb.QueryResult {
+			res, err := executor.Query(context.Background(), dsInfo, &tsdb.TsdbQuery{
+				TimeRange: timeRange,
+				Queries:   []*tsdb.Query{{RefId: "A", Model: simplejson.NewFromAny(model)}},
+			})
+			So(err, ShouldBeNil)
+			return res.Results["A"]
+		}
+
+		Convey("log queries return lines as a table", func() {
+			result := query(map[string]interface{}{"expr": `{job=~"app|db"}`, "maxLines": 10})
+			So(result.Error, ShouldBeNil)
+
+			So(lastRequest.URL.Query().Get("query"), ShouldEqual, `{job=~"app|db"}`)
+			So(lastRequest.URL.Query().Get("limit"), ShouldEqual, "10")
+			So(lastRequest.URL.Query().Get("direction"), ShouldEqual, "backward")
+			So(lastRequest.URL.Query().Get("start"), ShouldEqual, "1521118800000000000")
+
+			So(len(result.Tables), ShouldEqual, 1)
+			table := result.Tables[0]
+			So(len(table.Rows), ShouldEqual, 3)
+			So(table.Rows[0], ShouldResemble, tsdb.RowValues{float64(1521118860000), "second", `{job="app"}`})
+			So(table.Rows[1][1], ShouldEqual, "db line")
+			So(table.Rows[2][1], ShouldEqual, "first")
+		})
+
+		Convey("metric queries return time series", func() {
+			responses["/loki/api/v1/query_range"] = `{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"job": "app"}, "values": [[1521118800, "1.5"], [1521118860, "2"]]}
+			]}}`
+
+			result := query(map[string]interface{}{"expr": `rate({job="app"}[$__interval])`, "legendFormat": "{{job}} rate"})
+			So(result.Error, ShouldBeNil)
+
+			So(lastRequest.URL.Query().Get("query"), ShouldEqual, `rate({job="app"}[1s])`)
+			So(lastRequest.URL.Query().Get("step"), ShouldEqual, "1")
+			So(len(result.Series), ShouldEqual, 1)
+			So(result.Series[0].Name, ShouldEqual, "app rate")
+			So(result.Series[0].Tags["job"], ShouldEqual, "app")
+			So(result.Series[0].Points[1][0].Float64, ShouldEqual, 2)
+			So(result.Series[0].Points[1][1].Float64, ShouldEqual, 1521118860000)
+		})
+
+		Convey("instant metric queries return a point per series", func() {
+			result := query(map[string]interface{}{"expr": `count_over_time({job="app"}[5m])`, "queryType": "instant"})
+			So(result.Error, ShouldBeNil)
+
+			So(lastRequest.URL.Query().Get("time"), ShouldEqual, "1521119400000000000")
+			So(len(result.Series), ShouldEqual, 1)
+			So(result.Series[0].Points[0][0].Float64, ShouldEqual, 4)
+		})
+
+		Convey("label lookups return text tables", func() {
+			result := query(map[string]interface{}{"queryType": "label_names"})
+			So(result.Error, ShouldBeNil)
+			So(result.Tables[0].Rows, ShouldResemble, []tsdb.RowValues{{"job"}, {"level"}})
+
+			result = query(map[string]interface{}{"queryType": "label_values", "label": "job"})
+			So(result.Error, ShouldBeNil)
+			So(result.Tables[0].Rows, ShouldResemble, []tsdb.RowValues{{"app"}, {"db"}})
+		})
+
+		Convey("errors are returned on the query result", func() {
+			result := query(map[string]interface{}{"queryType": "label_values", "label": "broken"})
+			So(result.Error, ShouldNotBeNil)
+			So(result.Error.Error(), ShouldContainSubstring, "parse error at line 1")
+		})
+
+		Convey("label value queries require a label", func() {
+			_, err := executor.Query(context.Background(), dsInfo, &tsdb.TsdbQuery{
+				TimeRange: timeRange,
+				Queries:   []*tsdb.Query{{RefId: "A", Model: simplejson.NewFromAny(map[string]interface{}{"queryType": "label_values"})}},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,150 @@
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/prometheus/common/model"
+)
+
+// parseResponse converts log streams to a table of log lines and metric
+// query results to time series.
+func parseResponse(data *queryData, query *LokiQuery) (*tsdb.QueryResult, error) {
+	queryRes := tsdb.NewQueryResult()
+
+	switch data.ResultType {
+	case resultTypeStreams:
+		var streams []stream
+		if err := json.Unmarshal(data.Result, &streams); err != nil {
+			return nil, err
+		}
+
+		table, err := streamsToTable(streams, query.Direction)
+		if err != nil {
+			return nil, err
+		}
+		queryRes.Tables = append(queryRes.Tables, table)
+	case resultTypeMatrix:
+		var matrix model.Matrix
+		if err := json.Unmarshal(data.Result, &matrix); err != nil {
+			return nil, err
+		}
+
+		for _, v := range matrix {
+			series := &tsdb.TimeSeries{
+				Name:   formatLegend(v.Metric, query),
+				Tags:   labelsToTags(v.Metric),
+				Points: make([]tsdb.TimePoint, 0, len(v.Values)),
+			}
+
+			for _, k := range v.Values {
+				series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFrom(float64(k.Value)), float64(k.Timestamp.Unix()*1000)))
+			}
+
+			queryRes.Series = append(queryRes.Series, series)
+		}
+	case resultTypeVector:
+		var vector model.Vector
+		if err := json.Unmarshal(data.Result, &vector); err != nil {
+			return nil, err
+		}
+
+		for _, v := range vector {
+			queryRes.Series = append(queryRes.Series, &tsdb.TimeSeries{
+				Name:   formatLegend(v.Metric, query),
+				Tags:   labelsToTags(v.Metric),
+				Points: tsdb.TimeSeriesPoints{tsdb.NewTimePoint(null.FloatFrom(float64(v.Value)), float64(v.Timestamp.Unix()*1000))},
+			})
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported result type: %s", data.ResultType)
+	}
+
+	return queryRes, nil
+}
+
+type logLine struct {
+	timestamp int64
+	line      string
+	labels    string
+}
+
+// streamsToTable merges the lines of all streams into a single table with
+// the columns time (epoch ms), line and labels, ordered by the direction.
+func streamsToTable(streams []stream, direction string) (*tsdb.Table, error) {
+	lines := []logLine{}
+	for _, s := range streams {
+		labels := s.Stream.String()
+		for _, value := range s.Values {
+			ts, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid log line timestamp %q", value[0])
+			}
+			lines = append(lines, logLine{timestamp: ts, line: value[1], labels: labels})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		if direction == "forward" {
+			return lines[i].timestamp < lines[j].timestamp
+		}
+		return lines[i].timestamp > lines[j].timestamp
+	})
+
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{{Text: "time"}, {Text: "line"}, {Text: "labels"}},
+		Rows:    make([]tsdb.RowValues, 0, len(lines)),
+	}
+
+	for _, l := range lines {
+		table.Rows = append(table.Rows, tsdb.RowValues{float64(l.timestamp / 1e6), l.line, l.labels})
+	}
+
+	return table, nil
+}
+
+func formatLegend(metric model.Metric, query *LokiQuery) string {
+	if query.LegendFormat == "" {
+		return metric.String()
+	}
+
+	result := legendFormat.ReplaceAllFunc([]byte(query.LegendFormat), func(in []byte) []byte {
+		labelName := strings.Replace(string(in), "{{", "", 1)
+		labelName = strings.Replace(labelName, "}}", "", 1)
+		labelName = strings.TrimSpace(labelName)
+		if val, exists := metric[model.LabelName(labelName)]; exists {
+			return []byte(val)
+		}
+
+		return in
+	})
+
+	return string(result)
+}
+
+func labelsToTags(metric model.Metric) map[string]string {
+	tags := make(map[string]string, len(metric))
+	for k, v := range metric {
+		tags[string(k)] = string(v)
+	}
+
+	return tags
+}
+
+func newTextTable(values []string) *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{{Text: "text"}},
+		Rows:    []tsdb.RowValues{},
+	}
+
+	for _, value := range values {
+		table.Rows = append(table.Rows, tsdb.RowValues{value})
+	}
+
+	return table
+}
@@ -0,0 +1,51 @@
+package loki
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	queryTypeRange       = "range"
+	queryTypeInstant     = "instant"
+	queryTypeLabelNames  = "label_names"
+	queryTypeLabelValues = "label_values"
+
+	resultTypeStreams = "streams"
+	resultTypeMatrix  = "matrix"
+	resultTypeVector  = "vector"
+)
+
+type LokiQuery struct {
+	Expr         string
+	RefId        string
+	QueryType    string
+	LegendFormat string
+	Label        string
+	Direction    string
+	Limit        int64
+	Step         time.Duration
+	Start        time.Time
+	End          time.Time
+}
+
+type lokiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+type queryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// stream is a set of log lines with the same labels. Values are pairs of a
+// nanosecond timestamp and the log line, both encoded as strings.
+type stream struct {
+	Stream model.LabelSet `json:"stream"`
+	Values [][2]string    `json:"values"`
+}
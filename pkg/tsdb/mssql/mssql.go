@@ -28,6 +28,7 @@ func newMssqlQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndpoin
 		ConnectionString:  cnnstr,
 		Datasource:        datasource,
 		MetricColumnTypes: []string{"VARCHAR", "CHAR", "NVARCHAR", "NCHAR"},
+		ReadOnlyMode:      tsdb.SqlRollbackTransaction,
 	}
 
 	rowTransformer := mssqlRowTransformer{
@@ -38,13 +39,7 @@ func newMssqlQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndpoin
 }
 
 func generateConnectionString(datasource *models.DataSource) string {
-	password := ""
-	for key, value := range datasource.SecureJsonData.Decrypt() {
-		if key == "password" {
-			password = value
-			break
-		}
-	}
+	password := datasource.DecryptedPassword()
 
 	hostParts := strings.Split(datasource.Url, ":")
 	if len(hostParts) < 2 {
@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/go-xorm/core"
@@ -27,7 +29,7 @@ func newMysqlQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndpoin
 	}
 	cnnstr := fmt.Sprintf("%s:%s@%s(%s)/%s?collation=utf8mb4_unicode_ci&parseTime=true&loc=UTC&allowNativePasswords=true",
 		datasource.User,
-		datasource.Password,
+		datasource.DecryptedPassword(),
 		protocol,
 		datasource.Url,
 		datasource.Database,
@@ -40,6 +42,7 @@ func newMysqlQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndpoin
 		Datasource:        datasource,
 		TimeColumnNames:   []string{"time", "time_sec"},
 		MetricColumnTypes: []string{"CHAR", "VARCHAR", "TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT"},
+		StatementTimeout:  statementTimeout,
 	}
 
 	rowTransformer := mysqlRowTransformer{
@@ -111,3 +114,19 @@ func (t *mysqlRowTransformer) Transform(columnTypes []*sql.ColumnType, rows *cor
 
 	return values, nil
 }
+
+var leadingSelect = regexp.MustCompile(`(?i)^[\s(]*select\b`)
+
+// statementTimeout limits the execution time of a query with the
+// MAX_EXECUTION_TIME optimizer hint, supported by MySQL 5.7.8 and later. The
+// hint only applies to the statement, unlike a session setting it does not
+// stay on the pooled connection. Queries not starting with SELECT are only
+// canceled by the query context.
+func statementTimeout(rawSQL string, timeout time.Duration) string {
+	loc := leadingSelect.FindStringIndex(rawSQL)
+	if loc == nil {
+		return rawSQL
+	}
+
+	return fmt.Sprintf("%s /*+ MAX_EXECUTION_TIME(%d) */%s", rawSQL[:loc[1]], timeout/time.Millisecond, rawSQL[loc[1]:])
+}
@@ -1062,3 +1062,17 @@ func genTimeRangeByInterval(from time.Time, duration time.Duration, interval tim
 
 	return timeRange
 }
+
+func TestStatementTimeout(t *testing.T) {
+	Convey("Statement timeout", t, func() {
+		Convey("Adds the hint after the leading select", func() {
+			So(statementTimeout("SELECT 1", 30*time.Second), ShouldEqual, "SELECT /*+ MAX_EXECUTION_TIME(30000) */ 1")
+			So(statementTimeout(" (select a from t) union (select b from t)", time.Second), ShouldEqual, " (select /*+ MAX_EXECUTION_TIME(1000) */ a from t) union (select b from t)")
+		})
+
+		Convey("Leaves other queries unchanged", func() {
+			So(statementTimeout("SHOW TABLES", time.Second), ShouldEqual, "SHOW TABLES")
+			So(statementTimeout("selection", time.Second), ShouldEqual, "selection")
+		})
+	})
+}
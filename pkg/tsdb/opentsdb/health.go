@@ -0,0 +1,27 @@
+package opentsdb
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+// CheckHealth requests the version of the OpenTSDB server.
+func (e *OpenTsdbExecutor) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "api/version")
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return tsdb.CheckHttpHealth(ctx, dsInfo, req)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -15,6 +17,7 @@ import (
 	"net/url"
 
 	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/log"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/setting"
@@ -38,16 +41,32 @@ func init() {
 }
 
 func (e *OpenTsdbExecutor) Query(ctx context.Context, dsInfo *models.DataSource, queryContext *tsdb.TsdbQuery) (*tsdb.Response, error) {
-	result := &tsdb.Response{}
+	result := &tsdb.Response{
+		Results: make(map[string]*tsdb.QueryResult),
+	}
 
 	var tsdbQuery OpenTsdbQuery
 
 	tsdbQuery.Start = queryContext.TimeRange.GetFromAsMsEpoch()
 	tsdbQuery.End = queryContext.TimeRange.GetToAsMsEpoch()
+	tsdbQuery.MsResolution = dsInfo.JsonData.Get("tsdbResolution").MustInt(1) == 2
+	tsdbQuery.ShowQuery = dsInfo.JsonData.Get("tsdbVersion").MustInt(1) == 3
 
+	// all queries with a metric are sent in one request, hidden ones too as
+	// alerts may use them. queries holds the query of every metric in the
+	// request
+	queries := []*tsdb.Query{}
 	for _, query := range queryContext.Queries {
-		metric := e.buildMetric(query)
-		tsdbQuery.Queries = append(tsdbQuery.Queries, metric)
+		if query.Model.Get("metric").MustString() == "" {
+			continue
+		}
+
+		queries = append(queries, query)
+		tsdbQuery.Queries = append(tsdbQuery.Queries, e.buildMetric(query))
+	}
+
+	if len(queries) == 0 {
+		return result, nil
 	}
 
 	if setting.Env == setting.DEV {
@@ -69,7 +88,7 @@ func (e *OpenTsdbExecutor) Query(ctx context.Context, dsInfo *models.DataSource,
 		return nil, err
 	}
 
-	queryResult, err := e.parseResponse(tsdbQuery, res)
+	queryResult, err := e.parseResponse(tsdbQuery, queries, res)
 	if err != nil {
 		return nil, err
 	}
@@ -96,16 +115,19 @@ func (e *OpenTsdbExecutor) createRequest(dsInfo *models.DataSource, data OpenTsd
 
 	req.Header.Set("Content-Type", "application/json")
 	if dsInfo.BasicAuth {
-		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.BasicAuthPassword)
+		req.SetBasicAuth(dsInfo.BasicAuthUser, dsInfo.DecryptedBasicAuthPassword())
 	}
 
 	return req, err
 }
 
-func (e *OpenTsdbExecutor) parseResponse(query OpenTsdbQuery, res *http.Response) (map[string]*tsdb.QueryResult, error) {
-
+func (e *OpenTsdbExecutor) parseResponse(query OpenTsdbQuery, queries []*tsdb.Query, res *http.Response) (map[string]*tsdb.QueryResult, error) {
 	queryResults := make(map[string]*tsdb.QueryResult)
-	queryRes := tsdb.NewQueryResult()
+	for _, q := range queries {
+		queryRes := tsdb.NewQueryResult()
+		queryRes.RefId = q.RefId
+		queryResults[q.RefId] = queryRes
+	}
 
 	body, err := ioutil.ReadAll(res.Body)
 	defer res.Body.Close()
@@ -125,9 +147,17 @@ func (e *OpenTsdbExecutor) parseResponse(query OpenTsdbQuery, res *http.Response
 		return nil, err
 	}
 
+	groupByTags := groupByTags(query.Queries)
+
 	for _, val := range data {
+		index := mapMetricToQuery(val, query.Queries)
+		if index == -1 {
+			index = 0
+		}
+
 		series := tsdb.TimeSeries{
-			Name: val.Metric,
+			Name: createMetricLabel(val, queries[index], groupByTags),
+			Tags: val.Tags,
 		}
 
 		for timeString, value := range val.DataPoints {
@@ -136,37 +166,155 @@ func (e *OpenTsdbExecutor) parseResponse(query OpenTsdbQuery, res *http.Response
 				plog.Info("Failed to unmarshal opentsdb timestamp", "timestamp", timeString)
 				return nil, err
 			}
+			if !query.MsResolution {
+				timestamp *= 1000
+			}
 			series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFrom(value), timestamp))
 		}
 
-		queryRes.Series = append(queryRes.Series, &series)
+		sort.Slice(series.Points, func(i, j int) bool {
+			return series.Points[i][1].Float64 < series.Points[j][1].Float64
+		})
+
+		queryResults[queries[index].RefId].Series = append(queryResults[queries[index].RefId].Series, &series)
 	}
 
-	queryResults["A"] = queryRes
 	return queryResults, nil
 }
 
+// mapMetricToQuery returns the index of the query that produced the metric,
+// from the query returned with showQuery or by matching the metric name and
+// tags like the frontend does. An index out of range of the queries is
+// ignored.
+func mapMetricToQuery(metric OpenTsdbResponse, queries []map[string]interface{}) int {
+	if metric.Query != nil && metric.Query.Index >= 0 && metric.Query.Index < len(queries) {
+		return metric.Query.Index
+	}
+
+	for i, query := range queries {
+		if query["metric"] != metric.Metric {
+			continue
+		}
+
+		if _, ok := query["filters"]; ok {
+			return i
+		}
+
+		tags, _ := query["tags"].(map[string]interface{})
+		matches := true
+		for tagKey, tagValue := range tags {
+			value := fmt.Sprintf("%v", tagValue)
+			if value == "*" {
+				continue
+			}
+
+			found := false
+			for _, v := range strings.Split(value, "|") {
+				if v == metric.Tags[tagKey] {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// groupByTags returns the tag keys used in the filters or tags of the queries.
+func groupByTags(queries []map[string]interface{}) map[string]bool {
+	result := map[string]bool{}
+	for _, query := range queries {
+		if filters, ok := query["filters"].([]interface{}); ok {
+			for _, f := range filters {
+				if filter, ok := f.(map[string]interface{}); ok {
+					if tagk, ok := filter["tagk"].(string); ok {
+						result[tagk] = true
+					}
+				}
+			}
+		} else if tags, ok := query["tags"].(map[string]interface{}); ok {
+			for key := range tags {
+				result[key] = true
+			}
+		}
+	}
+
+	return result
+}
+
+var aliasTagRegex = regexp.MustCompile(`\$tag_(\w+)|\[\[tag_(\w+)\]\]`)
+
+func createMetricLabel(metric OpenTsdbResponse, query *tsdb.Query, groupByTags map[string]bool) string {
+	if alias := query.Model.Get("alias").MustString(); alias != "" {
+		return aliasTagRegex.ReplaceAllStringFunc(alias, func(match string) string {
+			groups := aliasTagRegex.FindStringSubmatch(match)
+			key := groups[1]
+			if key == "" {
+				key = groups[2]
+			}
+
+			if value, ok := metric.Tags[key]; ok {
+				return value
+			}
+			return match
+		})
+	}
+
+	keys := []string{}
+	for key := range metric.Tags {
+		if groupByTags[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return metric.Metric
+	}
+	sort.Strings(keys)
+
+	tagData := []string{}
+	for _, key := range keys {
+		tagData = append(tagData, key+"="+metric.Tags[key])
+	}
+
+	return metric.Metric + "{" + strings.Join(tagData, ", ") + "}"
+}
+
 func (e *OpenTsdbExecutor) buildMetric(query *tsdb.Query) map[string]interface{} {
 
 	metric := make(map[string]interface{})
 
 	// Setting metric and aggregator
 	metric["metric"] = query.Model.Get("metric").MustString()
-	metric["aggregator"] = query.Model.Get("aggregator").MustString()
+	metric["aggregator"] = query.Model.Get("aggregator").MustString("avg")
 
 	// Setting downsampling options
 	disableDownsampling := query.Model.Get("disableDownsampling").MustBool()
 	if !disableDownsampling {
 		downsampleInterval := query.Model.Get("downsampleInterval").MustString()
 		if downsampleInterval == "" {
-			downsampleInterval = "1m" //default value for blank
+			downsampleInterval = defaultDownsampleInterval(query)
 		}
-		downsample := downsampleInterval + "-" + query.Model.Get("downsampleAggregator").MustString()
-		if query.Model.Get("downsampleFillPolicy").MustString() != "none" {
-			metric["downsample"] = downsample + "-" + query.Model.Get("downsampleFillPolicy").MustString()
-		} else {
-			metric["downsample"] = downsample
+		if fractionalSeconds.MatchString(downsampleInterval) {
+			seconds, _ := strconv.ParseFloat(strings.TrimSuffix(downsampleInterval, "s"), 64)
+			downsampleInterval = strconv.FormatFloat(seconds*1000, 'f', -1, 64) + "ms"
+		}
+
+		downsample := downsampleInterval + "-" + query.Model.Get("downsampleAggregator").MustString("avg")
+		if fillPolicy := query.Model.Get("downsampleFillPolicy").MustString(); fillPolicy != "" && fillPolicy != "none" {
+			downsample += "-" + fillPolicy
 		}
+		metric["downsample"] = downsample
 	}
 
 	// Setting rate options
@@ -176,35 +324,74 @@ func (e *OpenTsdbExecutor) buildMetric(query *tsdb.Query) map[string]interface{}
 		rateOptions := make(map[string]interface{})
 		rateOptions["counter"] = query.Model.Get("isCounter").MustBool()
 
-		counterMax, counterMaxCheck := query.Model.CheckGet("counterMax")
+		counterMax, counterMaxCheck := parseNumber(query.Model, "counterMax")
 		if counterMaxCheck {
-			rateOptions["counterMax"] = counterMax.MustFloat64()
+			rateOptions["counterMax"] = counterMax
 		}
 
-		resetValue, resetValueCheck := query.Model.CheckGet("counterResetValue")
+		resetValue, resetValueCheck := parseNumber(query.Model, "counterResetValue")
 		if resetValueCheck {
-			rateOptions["resetValue"] = resetValue.MustFloat64()
+			rateOptions["resetValue"] = resetValue
 		}
 
-		if !counterMaxCheck && (!resetValueCheck || resetValue.MustFloat64() == 0) {
+		if !counterMaxCheck && (!resetValueCheck || resetValue == 0) {
 			rateOptions["dropResets"] = true
 		}
 
 		metric["rateOptions"] = rateOptions
 	}
 
-	// Setting tags
-	tags, tagsCheck := query.Model.CheckGet("tags")
-	if tagsCheck && len(tags.MustMap()) > 0 {
-		metric["tags"] = tags.MustMap()
-	}
-
-	// Setting filters
+	// Setting filters, tags are ignored when filters are used
 	filters, filtersCheck := query.Model.CheckGet("filters")
 	if filtersCheck && len(filters.MustArray()) > 0 {
 		metric["filters"] = filters.MustArray()
+	} else if tags, tagsCheck := query.Model.CheckGet("tags"); tagsCheck && len(tags.MustMap()) > 0 {
+		metric["tags"] = tags.MustMap()
+	}
+
+	if query.Model.Get("explicitTags").MustBool() {
+		metric["explicitTags"] = true
 	}
 
 	return metric
 
 }
+
+var fractionalSeconds = regexp.MustCompile(`^[0-9]*\.[0-9]+s$`)
+
+// defaultDownsampleInterval uses the interval of the panel like the frontend.
+func defaultDownsampleInterval(query *tsdb.Query) string {
+	if query.IntervalMs <= 0 {
+		return "1m"
+	}
+
+	if query.IntervalMs%1000 != 0 {
+		return fmt.Sprintf("%dms", query.IntervalMs)
+	}
+
+	return fmt.Sprintf("%ds", query.IntervalMs/1000)
+}
+
+// parseNumber reads a number the query editor stores either as number or string.
+func parseNumber(model *simplejson.Json, key string) (float64, bool) {
+	value, ok := model.CheckGet(key)
+	if !ok {
+		return 0, false
+	}
+
+	if f, err := value.Float64(); err == nil {
+		return f, true
+	}
+
+	s := strings.TrimSpace(value.MustString())
+	if s == "" {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return f, true
+}
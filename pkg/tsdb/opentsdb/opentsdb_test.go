@@ -1,9 +1,16 @@
 package opentsdb
 
 import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/tsdb"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -172,5 +179,215 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			So(metric["rateOptions"].(map[string]interface{})["resetValue"], ShouldEqual, 60)
 		})
 
+		Convey("Build metric with filters, fill policy and explicit tags", func() {
+
+			query := &tsdb.Query{
+				Model: simplejson.New(),
+			}
+
+			query.Model.Set("metric", "cpu.average.percent")
+			query.Model.Set("downsampleInterval", ".5s")
+			query.Model.Set("downsampleAggregator", "max")
+			query.Model.Set("downsampleFillPolicy", "none")
+			query.Model.Set("explicitTags", true)
+			query.Model.Set("tags", map[string]interface{}{"env": "prod"})
+			query.Model.Set("filters", []interface{}{
+				map[string]interface{}{"type": "wildcard", "tagk": "host", "filter": "*", "groupBy": true},
+			})
+
+			metric := exec.buildMetric(query)
+
+			So(metric["aggregator"], ShouldEqual, "avg")
+			So(metric["downsample"], ShouldEqual, "500ms-max")
+			So(metric["explicitTags"], ShouldEqual, true)
+			So(metric["tags"], ShouldBeNil)
+			So(len(metric["filters"].([]interface{})), ShouldEqual, 1)
+		})
+
+		Convey("Build metric uses the query interval for downsampling", func() {
+
+			query := &tsdb.Query{
+				Model:      simplejson.New(),
+				IntervalMs: 30000,
+			}
+
+			query.Model.Set("metric", "cpu.average.percent")
+
+			metric := exec.buildMetric(query)
+
+			So(metric["downsample"], ShouldEqual, "30s-avg")
+		})
+
+		Convey("Build metric with rate options as strings", func() {
+
+			query := &tsdb.Query{
+				Model: simplejson.New(),
+			}
+
+			query.Model.Set("metric", "cpu.average.percent")
+			query.Model.Set("disableDownsampling", true)
+			query.Model.Set("shouldComputeRate", true)
+			query.Model.Set("isCounter", true)
+			query.Model.Set("counterMax", "1000")
+			query.Model.Set("counterResetValue", "")
+
+			metric := exec.buildMetric(query)
+			rateOptions := metric["rateOptions"].(map[string]interface{})
+
+			So(len(rateOptions), ShouldEqual, 2)
+			So(rateOptions["counterMax"], ShouldEqual, 1000)
+		})
+
+		Convey("Query with multiple metrics", func() {
+			var sent OpenTsdbQuery
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				json.Unmarshal(body, &sent)
+
+				w.Write([]byte(`[
+					{"metric": "cpu", "tags": {"host": "b"}, "dps": {"1500000060": 2, "1500000000": 1}},
+					{"metric": "mem", "tags": {"host": "a"}, "dps": {"1500000000": 3}},
+					{"metric": "cpu", "tags": {"host": "a"}, "dps": {"1500000000": 4}}
+				]`))
+			}))
+			defer server.Close()
+
+			ds := &models.DataSource{
+				Id:       1,
+				Url:      server.URL,
+				JsonData: simplejson.NewFromAny(map[string]interface{}{"tsdbVersion": 1}),
+				Updated:  time.Now(),
+			}
+
+			queryA := &tsdb.Query{RefId: "A", Model: simplejson.NewFromAny(map[string]interface{}{
+				"metric": "cpu",
+				"tags":   map[string]interface{}{"host": "*"},
+			})}
+			queryB := &tsdb.Query{RefId: "B", Model: simplejson.NewFromAny(map[string]interface{}{
+				"metric": "mem",
+				"alias":  "memory $tag_host",
+			})}
+			queryC := &tsdb.Query{RefId: "C", Model: simplejson.NewFromAny(map[string]interface{}{
+				"aggregator": "sum",
+			})}
+
+			res, err := exec.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("1500000000000", "1500000600000"),
+				Queries:   []*tsdb.Query{queryA, queryB, queryC},
+			})
+			So(err, ShouldBeNil)
+
+			So(len(sent.Queries), ShouldEqual, 2)
+			So(sent.MsResolution, ShouldBeFalse)
+
+			So(len(res.Results), ShouldEqual, 2)
+
+			seriesA := res.Results["A"].Series
+			So(len(seriesA), ShouldEqual, 2)
+			So(seriesA[0].Name, ShouldEqual, "cpu{host=b}")
+			So(seriesA[0].Points[0][1].Float64, ShouldEqual, 1500000000000)
+			So(seriesA[0].Points[1][1].Float64, ShouldEqual, 1500000060000)
+			So(seriesA[1].Name, ShouldEqual, "cpu{host=a}")
+
+			seriesB := res.Results["B"].Series
+			So(len(seriesB), ShouldEqual, 1)
+			So(seriesB[0].Name, ShouldEqual, "memory a")
+		})
+
+		Convey("Query returns data for hidden queries", func() {
+			var sent OpenTsdbQuery
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				json.Unmarshal(body, &sent)
+
+				w.Write([]byte(`[{"metric": "cpu", "tags": {"host": "a"}, "dps": {"1500000000": 4}}]`))
+			}))
+			defer server.Close()
+
+			ds := &models.DataSource{
+				Id:       1,
+				Url:      server.URL,
+				JsonData: simplejson.NewFromAny(map[string]interface{}{"tsdbVersion": 1}),
+				Updated:  time.Now(),
+			}
+
+			query := &tsdb.Query{RefId: "A", Model: simplejson.NewFromAny(map[string]interface{}{
+				"metric": "cpu",
+				"hide":   true,
+			})}
+
+			res, err := exec.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("1500000000000", "1500000600000"),
+				Queries:   []*tsdb.Query{query},
+			})
+			So(err, ShouldBeNil)
+
+			So(len(sent.Queries), ShouldEqual, 1)
+			So(len(res.Results["A"].Series), ShouldEqual, 1)
+			So(res.Results["A"].Series[0].Name, ShouldEqual, "cpu")
+		})
+
+		Convey("Query maps results by query index with showQuery", func() {
+			var sent OpenTsdbQuery
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				json.Unmarshal(body, &sent)
+
+				w.Write([]byte(`[
+					{"metric": "cpu", "tags": {}, "dps": {"1500000000000": 1}, "query": {"index": 1}}
+				]`))
+			}))
+			defer server.Close()
+
+			ds := &models.DataSource{
+				Id:       2,
+				Url:      server.URL,
+				JsonData: simplejson.NewFromAny(map[string]interface{}{"tsdbVersion": 3, "tsdbResolution": 2}),
+				Updated:  time.Now(),
+			}
+
+			res, err := exec.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("1500000000000", "1500000600000"),
+				Queries: []*tsdb.Query{
+					{RefId: "A", Model: simplejson.NewFromAny(map[string]interface{}{"metric": "cpu"})},
+					{RefId: "B", Model: simplejson.NewFromAny(map[string]interface{}{"metric": "cpu"})},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			So(sent.ShowQuery, ShouldBeTrue)
+			So(sent.MsResolution, ShouldBeTrue)
+			So(len(res.Results["A"].Series), ShouldEqual, 0)
+			So(len(res.Results["B"].Series), ShouldEqual, 1)
+			So(res.Results["B"].Series[0].Points[0][1].Float64, ShouldEqual, 1500000000000)
+		})
+
+		Convey("Query maps results with an out of range index by metric name", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[
+					{"metric": "memory", "tags": {}, "dps": {"1500000000000": 1}, "query": {"index": 5}}
+				]`))
+			}))
+			defer server.Close()
+
+			ds := &models.DataSource{
+				Id:       3,
+				Url:      server.URL,
+				JsonData: simplejson.NewFromAny(map[string]interface{}{"tsdbVersion": 3}),
+				Updated:  time.Now(),
+			}
+
+			res, err := exec.Query(context.Background(), ds, &tsdb.TsdbQuery{
+				TimeRange: tsdb.NewTimeRange("1500000000000", "1500000600000"),
+				Queries: []*tsdb.Query{
+					{RefId: "A", Model: simplejson.NewFromAny(map[string]interface{}{"metric": "cpu"})},
+					{RefId: "B", Model: simplejson.NewFromAny(map[string]interface{}{"metric": "memory"})},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			So(len(res.Results["A"].Series), ShouldEqual, 0)
+			So(len(res.Results["B"].Series), ShouldEqual, 1)
+		})
 	})
 }
@@ -1,12 +1,22 @@
 package opentsdb
 
 type OpenTsdbQuery struct {
-	Start   int64                    `json:"start"`
-	End     int64                    `json:"end"`
-	Queries []map[string]interface{} `json:"queries"`
+	Start        int64                    `json:"start"`
+	End          int64                    `json:"end"`
+	Queries      []map[string]interface{} `json:"queries"`
+	MsResolution bool                     `json:"msResolution"`
+	ShowQuery    bool                     `json:"showQuery,omitempty"`
 }
 
 type OpenTsdbResponse struct {
 	Metric     string             `json:"metric"`
+	Tags       map[string]string  `json:"tags"`
 	DataPoints map[string]float64 `json:"dps"`
+	Query      *OpenTsdbSubQuery  `json:"query,omitempty"`
+}
+
+// OpenTsdbSubQuery is the query returned with showQuery, its index maps the
+// result to the query that produced it.
+type OpenTsdbSubQuery struct {
+	Index int `json:"index"`
 }
@@ -2,8 +2,10 @@ package postgres
 
 import (
 	"database/sql"
+	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-xorm/core"
 	"github.com/grafana/grafana/pkg/log"
@@ -22,10 +24,11 @@ func newPostgresQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndp
 	logger.Debug("getEngine", "connection", cnnstr)
 
 	config := tsdb.SqlQueryEndpointConfiguration{
-		DriverName:        "postgres",
-		ConnectionString:  cnnstr,
-		Datasource:        datasource,
-		MetricColumnTypes: []string{"UNKNOWN", "TEXT", "VARCHAR", "CHAR"},
+		DriverName:          "postgres",
+		ConnectionString:    cnnstr,
+		Datasource:          datasource,
+		MetricColumnTypes:   []string{"UNKNOWN", "TEXT", "VARCHAR", "CHAR"},
+		StatementTimeoutSql: statementTimeoutSql,
 	}
 
 	rowTransformer := postgresRowTransformer{
@@ -38,13 +41,7 @@ func newPostgresQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndp
 }
 
 func generateConnectionString(datasource *models.DataSource) string {
-	password := ""
-	for key, value := range datasource.SecureJsonData.Decrypt() {
-		if key == "password" {
-			password = value
-			break
-		}
-	}
+	password := datasource.DecryptedPassword()
 
 	sslmode := datasource.JsonData.Get("sslmode").MustString("verify-full")
 	u := &url.URL{
@@ -96,3 +93,9 @@ func (t *postgresRowTransformer) Transform(columnTypes []*sql.ColumnType, rows *
 
 	return values, nil
 }
+
+// statementTimeoutSql limits the execution time of the statements of the
+// current transaction.
+func statementTimeoutSql(timeout time.Duration) string {
+	return fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout/time.Millisecond)
+}
@@ -0,0 +1,24 @@
+package prometheus
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// CheckHealth evaluates a constant expression with the query API.
+func (e *PrometheusExecutor) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*tsdb.HealthCheckResult, error) {
+	client, err := e.getClient(dsInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := apiv1.NewAPI(client).Query(ctx, "1+1", time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &tsdb.HealthCheckResult{Status: tsdb.HealthStatusOk, Message: "Data source is working"}, nil
+}
@@ -0,0 +1,292 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb"
+	api "github.com/prometheus/client_golang/api"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	epLabels         = "/api/v1/labels"
+	epLabelValues    = "/api/v1/label/:name/values"
+	epSeries         = "/api/v1/series"
+	epMetadata       = "/api/v1/metadata"
+	epQueryExemplars = "/api/v1/query_exemplars"
+)
+
+// metadataClient queries the Prometheus endpoints that are not covered by
+// the vendored v1 api: label names, series, metric metadata and exemplars.
+type metadataClient struct {
+	client api.Client
+}
+
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+type metricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+type exemplarsResult struct {
+	SeriesLabels model.LabelSet `json:"seriesLabels"`
+	Exemplars    []struct {
+		Labels    model.LabelSet `json:"labels"`
+		Value     string         `json:"value"`
+		Timestamp float64        `json:"timestamp"`
+	} `json:"exemplars"`
+}
+
+func (c *metadataClient) get(ctx context.Context, ep string, args map[string]string, params url.Values, v interface{}) error {
+	u := c.client.URL(ep, args)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, body, err := c.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	var result apiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("Failed to parse response from %s, status code: %d", ep, resp.StatusCode)
+	}
+
+	if result.Status == "error" {
+		return fmt.Errorf("%s: %s", result.ErrorType, result.Error)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Request to %s failed, status code: %d", ep, resp.StatusCode)
+	}
+
+	return json.Unmarshal(result.Data, v)
+}
+
+func timeRangeParams(query *PrometheusQuery) url.Values {
+	params := url.Values{}
+	params.Set("start", formatTime(query.Start))
+	params.Set("end", formatTime(query.End))
+
+	return params
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+// Query executes the label, series and metadata lookups used by template variables.
+func (c *metadataClient) Query(ctx context.Context, query *PrometheusQuery) (*tsdb.QueryResult, error) {
+	queryRes := tsdb.NewQueryResult()
+
+	switch query.QueryType {
+	case queryTypeLabelNames:
+		var names []string
+		if err := c.get(ctx, epLabels, nil, timeRangeParams(query), &names); err != nil {
+			return nil, err
+		}
+		queryRes.Tables = append(queryRes.Tables, newTextTable(names))
+	case queryTypeLabelValues:
+		if query.Label == "" {
+			return nil, fmt.Errorf("Query of type %s requires a label", query.QueryType)
+		}
+
+		values, err := c.labelValues(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		queryRes.Tables = append(queryRes.Tables, newTextTable(values))
+	case queryTypeSeries:
+		series, err := c.series(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		queryRes.Tables = append(queryRes.Tables, seriesToTable(series))
+	case queryTypeMetadata:
+		params := url.Values{}
+		if query.Expr != "" {
+			params.Set("metric", query.Expr)
+		}
+
+		metadata := map[string][]metricMetadata{}
+		if err := c.get(ctx, epMetadata, nil, params, &metadata); err != nil {
+			return nil, err
+		}
+		queryRes.Tables = append(queryRes.Tables, metadataToTable(metadata))
+	default:
+		return nil, fmt.Errorf("Unsupported query type: %s", query.QueryType)
+	}
+
+	return queryRes, nil
+}
+
+func (c *metadataClient) labelValues(ctx context.Context, query *PrometheusQuery) ([]string, error) {
+	if len(query.Match) == 0 {
+		var values []string
+		err := c.get(ctx, epLabelValues, map[string]string{"name": query.Label}, timeRangeParams(query), &values)
+		return values, err
+	}
+
+	series, err := c.series(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	values := []string{}
+	for _, labels := range series {
+		value := string(labels[model.LabelName(query.Label)])
+		if value != "" && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	return values, nil
+}
+
+func (c *metadataClient) series(ctx context.Context, query *PrometheusQuery) ([]model.LabelSet, error) {
+	if len(query.Match) == 0 {
+		return nil, fmt.Errorf("Query of type %s requires a series selector", query.QueryType)
+	}
+
+	params := timeRangeParams(query)
+	for _, match := range query.Match {
+		params.Add("match[]", match)
+	}
+
+	var series []model.LabelSet
+	err := c.get(ctx, epSeries, nil, params, &series)
+
+	return series, err
+}
+
+// Exemplars returns the exemplars of a query as a table or nil if there are none.
+func (c *metadataClient) Exemplars(ctx context.Context, query *PrometheusQuery) (*tsdb.Table, error) {
+	params := timeRangeParams(query)
+	params.Set("query", query.Expr)
+
+	var results []exemplarsResult
+	if err := c.get(ctx, epQueryExemplars, nil, params, &results); err != nil {
+		return nil, err
+	}
+
+	return exemplarsToTable(results), nil
+}
+
+func exemplarsToTable(results []exemplarsResult) *tsdb.Table {
+	metrics := []model.Metric{}
+	for _, result := range results {
+		metrics = append(metrics, model.Metric(result.SeriesLabels))
+		for _, exemplar := range result.Exemplars {
+			metrics = append(metrics, model.Metric(exemplar.Labels))
+		}
+	}
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	labels := labelColumns(metrics)
+	table := newLabelTable(labels)
+	for _, result := range results {
+		for _, exemplar := range result.Exemplars {
+			row := tsdb.RowValues{exemplar.Timestamp * 1000}
+			for _, label := range labels {
+				name := model.LabelName(label)
+				if value, ok := exemplar.Labels[name]; ok {
+					row = append(row, string(value))
+				} else {
+					row = append(row, string(result.SeriesLabels[name]))
+				}
+			}
+
+			value, err := strconv.ParseFloat(exemplar.Value, 64)
+			if err != nil {
+				continue
+			}
+			table.Rows = append(table.Rows, append(row, value))
+		}
+	}
+
+	return table
+}
+
+func newTextTable(values []string) *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{{Text: "text"}},
+		Rows:    []tsdb.RowValues{},
+	}
+
+	for _, value := range values {
+		table.Rows = append(table.Rows, tsdb.RowValues{value})
+	}
+
+	return table
+}
+
+func seriesToTable(series []model.LabelSet) *tsdb.Table {
+	metrics := make([]model.Metric, 0, len(series))
+	for _, labels := range series {
+		metrics = append(metrics, model.Metric(labels))
+	}
+
+	labels := labelColumns(metrics)
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{},
+		Rows:    []tsdb.RowValues{},
+	}
+
+	for _, label := range labels {
+		table.Columns = append(table.Columns, tsdb.TableColumn{Text: label})
+	}
+
+	for _, metric := range metrics {
+		row := tsdb.RowValues{}
+		for _, label := range labels {
+			row = append(row, string(metric[model.LabelName(label)]))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table
+}
+
+func metadataToTable(metadata map[string][]metricMetadata) *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{{Text: "metric"}, {Text: "type"}, {Text: "help"}, {Text: "unit"}},
+		Rows:    []tsdb.RowValues{},
+	}
+
+	metrics := make([]string, 0, len(metadata))
+	for metric := range metadata {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	for _, metric := range metrics {
+		for _, m := range metadata[metric] {
+			table.Rows = append(table.Rows, tsdb.RowValues{metric, m.Type, m.Help, m.Unit})
+		}
+	}
+
+	return table
+}
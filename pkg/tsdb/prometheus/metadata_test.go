@@ -0,0 +1,120 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb"
+	api "github.com/prometheus/client_golang/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPrometheusMetadata(t *testing.T) {
+	Convey("Prometheus metadata queries", t, func() {
+		var requestedPath string
+		var requestedQuery map[string][]string
+		response := `{"status": "success", "data": []}`
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestedPath = r.URL.Path
+			requestedQuery = r.URL.Query()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(response))
+		}))
+		defer ts.Close()
+
+		client, err := api.NewClient(api.Config{Address: ts.URL})
+		So(err, ShouldBeNil)
+		metaClient := &metadataClient{client: client}
+
+		query := &PrometheusQuery{
+			Start: time.Unix(100, 0),
+			End:   time.Unix(200, 0),
+		}
+
+		Convey("label names", func() {
+			response = `{"status": "success", "data": ["__name__", "job"]}`
+			query.QueryType = queryTypeLabelNames
+
+			res, err := metaClient.Query(context.Background(), query)
+			So(err, ShouldBeNil)
+			So(requestedPath, ShouldEqual, "/api/v1/labels")
+			So(requestedQuery["start"][0], ShouldEqual, "100.000")
+			So(len(res.Tables[0].Rows), ShouldEqual, 2)
+			So(res.Tables[0].Rows[1][0], ShouldEqual, "job")
+		})
+
+		Convey("label values without selector", func() {
+			response = `{"status": "success", "data": ["api", "db"]}`
+			query.QueryType = queryTypeLabelValues
+			query.Label = "job"
+
+			res, err := metaClient.Query(context.Background(), query)
+			So(err, ShouldBeNil)
+			So(requestedPath, ShouldEqual, "/api/v1/label/job/values")
+			So(len(res.Tables[0].Rows), ShouldEqual, 2)
+		})
+
+		Convey("label values with selector", func() {
+			response = `{"status": "success", "data": [{"job": "api", "instance": "a"}, {"job": "api", "instance": "b"}, {"instance": "c"}]}`
+			query.QueryType = queryTypeLabelValues
+			query.Label = "job"
+			query.Match = []string{"up"}
+
+			res, err := metaClient.Query(context.Background(), query)
+			So(err, ShouldBeNil)
+			So(requestedPath, ShouldEqual, "/api/v1/series")
+			So(requestedQuery["match[]"], ShouldResemble, []string{"up"})
+			So(len(res.Tables[0].Rows), ShouldEqual, 1)
+			So(res.Tables[0].Rows[0][0], ShouldEqual, "api")
+		})
+
+		Convey("series", func() {
+			response = `{"status": "success", "data": [{"__name__": "up", "job": "api"}, {"__name__": "up", "instance": "b"}]}`
+			query.QueryType = queryTypeSeries
+			query.Match = []string{"up"}
+
+			res, err := metaClient.Query(context.Background(), query)
+			So(err, ShouldBeNil)
+
+			table := res.Tables[0]
+			So(len(table.Columns), ShouldEqual, 3)
+			So(table.Columns[1].Text, ShouldEqual, "instance")
+			So(table.Rows[1][1], ShouldEqual, "b")
+		})
+
+		Convey("metadata", func() {
+			response = `{"status": "success", "data": {"up": [{"type": "gauge", "help": "Target is up", "unit": ""}]}}`
+			query.QueryType = queryTypeMetadata
+			query.Expr = "up"
+
+			res, err := metaClient.Query(context.Background(), query)
+			So(err, ShouldBeNil)
+			So(requestedQuery["metric"][0], ShouldEqual, "up")
+			So(res.Tables[0].Rows[0][1], ShouldEqual, "gauge")
+		})
+
+		Convey("exemplars", func() {
+			response = `{"status": "success", "data": [{"seriesLabels": {"job": "api"}, "exemplars": [{"labels": {"traceID": "abc"}, "value": "6", "timestamp": 150.5}]}]}`
+			query.Expr = "rate(x[1m])"
+
+			table, err := metaClient.Exemplars(context.Background(), query)
+			So(err, ShouldBeNil)
+			So(requestedQuery["query"][0], ShouldEqual, "rate(x[1m])")
+			So(len(table.Columns), ShouldEqual, 4)
+			So(table.Rows[0], ShouldResemble, tsdb.RowValues{float64(150500), "api", "abc", float64(6)})
+		})
+
+		Convey("api error", func() {
+			response = `{"status": "error", "errorType": "bad_data", "error": "parse error"}`
+			query.QueryType = queryTypeLabelNames
+
+			_, err := metaClient.Query(context.Background(), query)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "bad_data: parse error")
+		})
+	})
+}
@@ -3,7 +3,10 @@ package prometheus
 import (
 	"context"
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -60,7 +63,7 @@ func init() {
 	intervalCalculator = tsdb.NewIntervalCalculator(&tsdb.IntervalOptions{MinInterval: time.Second * 1})
 }
 
-func (e *PrometheusExecutor) getClient(dsInfo *models.DataSource) (apiv1.API, error) {
+func (e *PrometheusExecutor) getClient(dsInfo *models.DataSource) (api.Client, error) {
 	cfg := api.Config{
 		Address:      dsInfo.Url,
 		RoundTripper: e.Transport,
@@ -70,16 +73,11 @@ func (e *PrometheusExecutor) getClient(dsInfo *models.DataSource) (apiv1.API, er
 		cfg.RoundTripper = basicAuthTransport{
 			Transport: e.Transport,
 			username:  dsInfo.BasicAuthUser,
-			password:  dsInfo.BasicAuthPassword,
+			password:  dsInfo.DecryptedBasicAuthPassword(),
 		}
 	}
 
-	client, err := api.NewClient(cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	return apiv1.NewAPI(client), nil
+	return api.NewClient(cfg)
 }
 
 func (e *PrometheusExecutor) Query(ctx context.Context, dsInfo *models.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
@@ -97,14 +95,11 @@ func (e *PrometheusExecutor) Query(ctx context.Context, dsInfo *models.DataSourc
 		return nil, err
 	}
 
-	for _, query := range queries {
-		timeRange := apiv1.Range{
-			Start: query.Start,
-			End:   query.End,
-			Step:  query.Step,
-		}
+	apiClient := apiv1.NewAPI(client)
+	metaClient := &metadataClient{client: client}
 
-		plog.Debug("Sending query", "start", timeRange.Start, "end", timeRange.End, "step", timeRange.Step, "query", query.Expr)
+	for _, query := range queries {
+		plog.Debug("Sending query", "type", query.QueryType, "start", query.Start, "end", query.End, "step", query.Step, "query", query.Expr)
 
 		span, ctx := opentracing.StartSpanFromContext(ctx, "alerting.prometheus")
 		span.SetTag("expr", query.Expr)
@@ -112,16 +107,47 @@ func (e *PrometheusExecutor) Query(ctx context.Context, dsInfo *models.DataSourc
 		span.SetTag("stop_unixnano", query.End.UnixNano())
 		defer span.Finish()
 
-		value, err := client.QueryRange(ctx, query.Expr, timeRange)
-
-		if err != nil {
-			return nil, err
+		var queryResult *tsdb.QueryResult
+
+		switch query.QueryType {
+		case queryTypeRange, queryTypeInstant:
+			var value model.Value
+			if query.QueryType == queryTypeInstant {
+				value, err = apiClient.Query(ctx, query.Expr, query.End)
+			} else {
+				value, err = apiClient.QueryRange(ctx, query.Expr, apiv1.Range{
+					Start: query.Start,
+					End:   query.End,
+					Step:  query.Step,
+				})
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			queryResult, err = parseResponse(value, query)
+			if err != nil {
+				return nil, err
+			}
+
+			if query.Exemplar {
+				exemplars, err := metaClient.Exemplars(ctx, query)
+				if err != nil {
+					// exemplars are only supported by recent Prometheus versions
+					plog.Debug("Failed to query exemplars", "error", err)
+				} else if exemplars != nil {
+					queryResult.Tables = append(queryResult.Tables, exemplars)
+				}
+			}
+		default:
+			queryResult, err = metaClient.Query(ctx, query)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		queryResult, err := parseResponse(value, query)
-		if err != nil {
-			return nil, err
-		}
+		queryResult.RefId = query.RefId
 		result.Results[query.RefId] = queryResult
 	}
 
@@ -150,9 +176,23 @@ func formatLegend(metric model.Metric, query *PrometheusQuery) string {
 func parseQuery(dsInfo *models.DataSource, queries []*tsdb.Query, queryContext *tsdb.TsdbQuery) ([]*PrometheusQuery, error) {
 	qs := []*PrometheusQuery{}
 	for _, queryModel := range queries {
-		expr, err := queryModel.Model.Get("expr").String()
-		if err != nil {
-			return nil, err
+		queryType := queryModel.Model.Get("queryType").MustString(queryTypeRange)
+		if queryModel.Model.Get("instant").MustBool(false) {
+			queryType = queryTypeInstant
+		}
+
+		var expr string
+		var err error
+		switch queryType {
+		case queryTypeRange, queryTypeInstant:
+			expr, err = queryModel.Model.Get("expr").String()
+			if err != nil {
+				return nil, err
+			}
+		case queryTypeLabelNames, queryTypeLabelValues, queryTypeSeries, queryTypeMetadata:
+			expr = queryModel.Model.Get("expr").MustString("")
+		default:
+			return nil, fmt.Errorf("Unsupported query type: %s", queryType)
 		}
 
 		format := queryModel.Model.Get("legendFormat").MustString("")
@@ -173,8 +213,25 @@ func parseQuery(dsInfo *models.DataSource, queries []*tsdb.Query, queryContext *
 		}
 
 		intervalFactor := queryModel.Model.Get("intervalFactor").MustInt64(1)
+		if intervalFactor < 1 {
+			intervalFactor = 1
+		}
 		interval := intervalCalculator.Calculate(queryContext.TimeRange, dsInterval)
-		step := time.Duration(int64(interval.Value) * intervalFactor)
+		step := adjustInterval(interval.Value, dsInterval, end.Sub(start), intervalFactor)
+
+		if queryType == queryTypeRange {
+			start, end = alignRange(start, end, step)
+		}
+
+		expr = interpolateInterval(expr, step)
+
+		match := []string{}
+		for _, m := range queryModel.Model.Get("match").MustStringArray() {
+			match = append(match, m)
+		}
+		if len(match) == 0 && expr != "" && queryType != queryTypeRange && queryType != queryTypeInstant {
+			match = append(match, expr)
+		}
 
 		qs = append(qs, &PrometheusQuery{
 			Expr:         expr,
@@ -183,36 +240,189 @@ func parseQuery(dsInfo *models.DataSource, queries []*tsdb.Query, queryContext *
 			Start:        start,
 			End:          end,
 			RefId:        queryModel.RefId,
+			QueryType:    queryType,
+			Format:       queryModel.Model.Get("format").MustString(formatTimeSeries),
+			Exemplar:     queryModel.Model.Get("exemplar").MustBool(false),
+			Label:        queryModel.Model.Get("label").MustString(""),
+			Match:        match,
 		})
 	}
 
 	return qs, nil
 }
 
-func parseResponse(value model.Value, query *PrometheusQuery) (*tsdb.QueryResult, error) {
-	queryRes := tsdb.NewQueryResult()
+// safeResolution is the maximum number of points Prometheus returns per series.
+const safeResolution = 11000
 
-	data, ok := value.(model.Matrix)
-	if !ok {
-		return queryRes, fmt.Errorf("Unsupported result format: %s", value.Type().String())
+// adjustInterval mirrors the step calculation of the Prometheus datasource in
+// the frontend so alerting evaluates the same points as the graph shows.
+func adjustInterval(interval time.Duration, minInterval time.Duration, queryRange time.Duration, intervalFactor int64) time.Duration {
+	// Calibrate interval if it is too small
+	if interval != 0 && queryRange.Seconds()/float64(intervalFactor)/interval.Seconds() > safeResolution {
+		interval = time.Duration(math.Ceil(queryRange.Seconds()/float64(intervalFactor)/safeResolution)) * time.Second
 	}
 
-	for _, v := range data {
-		series := tsdb.TimeSeries{
-			Name: formatLegend(v.Metric, query),
-			Tags: map[string]string{},
+	step := time.Duration(int64(interval) * intervalFactor)
+	if step < minInterval {
+		step = minInterval
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+
+	return step
+}
+
+// alignRange aligns the start and end of a range query with the step like
+// the frontend does, so results are stable when the time range moves.
+func alignRange(start time.Time, end time.Time, step time.Duration) (time.Time, time.Time) {
+	s := int64(step)
+	alignedStart := (start.UnixNano() / s) * s
+	alignedEnd := ((end.UnixNano() + s - 1) / s) * s
+
+	return time.Unix(0, alignedStart), time.Unix(0, alignedEnd)
+}
+
+func interpolateInterval(expr string, step time.Duration) string {
+	expr = strings.Replace(expr, "$__interval_ms", strconv.FormatInt(int64(step/time.Millisecond), 10), -1)
+	expr = strings.Replace(expr, "$__interval", strconv.FormatInt(int64(step/time.Second), 10)+"s", -1)
+
+	return expr
+}
+
+func parseResponse(value model.Value, query *PrometheusQuery) (*tsdb.QueryResult, error) {
+	queryRes := tsdb.NewQueryResult()
+
+	switch data := value.(type) {
+	case model.Matrix:
+		if query.Format == formatTable {
+			queryRes.Tables = append(queryRes.Tables, matrixToTable(data))
+			return queryRes, nil
 		}
 
-		for k, v := range v.Metric {
-			series.Tags[string(k)] = string(v)
+		for _, v := range data {
+			series := newSeries(v.Metric, query)
+
+			for _, k := range v.Values {
+				series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFrom(float64(k.Value)), float64(k.Timestamp.Unix()*1000)))
+			}
+
+			queryRes.Series = append(queryRes.Series, series)
+		}
+	case model.Vector:
+		if query.Format == formatTable {
+			queryRes.Tables = append(queryRes.Tables, vectorToTable(data))
+			return queryRes, nil
 		}
 
-		for _, k := range v.Values {
-			series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFrom(float64(k.Value)), float64(k.Timestamp.Unix()*1000)))
+		for _, v := range data {
+			series := newSeries(v.Metric, query)
+			series.Points = append(series.Points, tsdb.NewTimePoint(null.FloatFrom(float64(v.Value)), float64(v.Timestamp.Unix()*1000)))
+			queryRes.Series = append(queryRes.Series, series)
+		}
+	case *model.Scalar:
+		if query.Format == formatTable {
+			table := &tsdb.Table{
+				Columns: []tsdb.TableColumn{{Text: "Time"}, {Text: "Value"}},
+				Rows:    []tsdb.RowValues{{float64(data.Timestamp.Unix() * 1000), float64(data.Value)}},
+			}
+			queryRes.Tables = append(queryRes.Tables, table)
+			return queryRes, nil
 		}
 
-		queryRes.Series = append(queryRes.Series, &series)
+		series := tsdb.NewTimeSeries("scalar", tsdb.TimeSeriesPoints{
+			tsdb.NewTimePoint(null.FloatFrom(float64(data.Value)), float64(data.Timestamp.Unix()*1000)),
+		})
+		queryRes.Series = append(queryRes.Series, series)
+	default:
+		return queryRes, fmt.Errorf("Unsupported result format: %s", value.Type().String())
 	}
 
 	return queryRes, nil
 }
+
+func newSeries(metric model.Metric, query *PrometheusQuery) *tsdb.TimeSeries {
+	series := &tsdb.TimeSeries{
+		Name: formatLegend(metric, query),
+		Tags: map[string]string{},
+	}
+
+	for k, v := range metric {
+		series.Tags[string(k)] = string(v)
+	}
+
+	return series
+}
+
+// labelColumns returns the sorted union of label names of the metrics.
+func labelColumns(metrics []model.Metric) []string {
+	names := map[string]bool{}
+	for _, m := range metrics {
+		for k := range m {
+			names[string(k)] = true
+		}
+	}
+
+	columns := make([]string, 0, len(names))
+	for name := range names {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+func newLabelTable(labels []string) *tsdb.Table {
+	table := &tsdb.Table{
+		Columns: []tsdb.TableColumn{{Text: "Time"}},
+		Rows:    []tsdb.RowValues{},
+	}
+
+	for _, label := range labels {
+		table.Columns = append(table.Columns, tsdb.TableColumn{Text: label})
+	}
+	table.Columns = append(table.Columns, tsdb.TableColumn{Text: "Value"})
+
+	return table
+}
+
+func newLabelRow(timestamp model.Time, metric model.Metric, labels []string, value model.SampleValue) tsdb.RowValues {
+	row := tsdb.RowValues{float64(timestamp.Unix() * 1000)}
+	for _, label := range labels {
+		row = append(row, string(metric[model.LabelName(label)]))
+	}
+
+	return append(row, float64(value))
+}
+
+func vectorToTable(data model.Vector) *tsdb.Table {
+	metrics := make([]model.Metric, 0, len(data))
+	for _, sample := range data {
+		metrics = append(metrics, sample.Metric)
+	}
+
+	labels := labelColumns(metrics)
+	table := newLabelTable(labels)
+	for _, sample := range data {
+		table.Rows = append(table.Rows, newLabelRow(sample.Timestamp, sample.Metric, labels, sample.Value))
+	}
+
+	return table
+}
+
+func matrixToTable(data model.Matrix) *tsdb.Table {
+	metrics := make([]model.Metric, 0, len(data))
+	for _, stream := range data {
+		metrics = append(metrics, stream.Metric)
+	}
+
+	labels := labelColumns(metrics)
+	table := newLabelTable(labels)
+	for _, stream := range data {
+		for _, pair := range stream.Values {
+			table.Rows = append(table.Rows, newLabelRow(pair.Timestamp, stream.Metric, labels, pair.Value))
+		}
+	}
+
+	return table
+}
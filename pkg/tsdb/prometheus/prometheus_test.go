@@ -156,5 +156,118 @@ func TestPrometheus(t *testing.T) {
 			})
 		})
 
+		Convey("parsing instant query model", func() {
+			json := `{
+				"expr": "up",
+				"instant": true,
+				"format": "table",
+				"refId": "A"
+			}`
+			jsonModel, _ := simplejson.NewJson([]byte(json))
+			queryContext := &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("1h", "now")}
+			queryModels := []*tsdb.Query{
+				{Model: jsonModel, RefId: "A"},
+			}
+
+			models, err := parseQuery(dsInfo, queryModels, queryContext)
+			So(err, ShouldBeNil)
+			So(models[0].QueryType, ShouldEqual, queryTypeInstant)
+			So(models[0].Format, ShouldEqual, formatTable)
+		})
+
+		Convey("parsing label values query model", func() {
+			json := `{
+				"queryType": "label_values",
+				"label": "job",
+				"expr": "up{instance=\"a\"}",
+				"refId": "A"
+			}`
+			jsonModel, _ := simplejson.NewJson([]byte(json))
+			queryContext := &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("1h", "now")}
+			queryModels := []*tsdb.Query{
+				{Model: jsonModel, RefId: "A"},
+			}
+
+			models, err := parseQuery(dsInfo, queryModels, queryContext)
+			So(err, ShouldBeNil)
+			So(models[0].QueryType, ShouldEqual, queryTypeLabelValues)
+			So(models[0].Label, ShouldEqual, "job")
+			So(models[0].Match, ShouldResemble, []string{`up{instance="a"}`})
+		})
+
+		Convey("parsing query model with unknown query type", func() {
+			jsonModel, _ := simplejson.NewJson([]byte(`{"queryType": "foo", "refId": "A"}`))
+			queryContext := &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("1h", "now")}
+
+			_, err := parseQuery(dsInfo, []*tsdb.Query{{Model: jsonModel}}, queryContext)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("interpolating interval variables", func() {
+			expr := interpolateInterval("rate(x[$__interval]) / $__interval_ms", time.Minute)
+			So(expr, ShouldEqual, "rate(x[60s]) / 60000")
+		})
+
+		Convey("adjusting interval like the frontend", func() {
+			Convey("should cap number of points", func() {
+				step := adjustInterval(time.Second, time.Second, 24*time.Hour, 1)
+				So(step, ShouldEqual, time.Second*8)
+			})
+
+			Convey("should apply interval factor and min interval", func() {
+				So(adjustInterval(time.Second*10, time.Second, time.Hour, 3), ShouldEqual, time.Second*30)
+				So(adjustInterval(time.Second*10, time.Minute, time.Hour, 1), ShouldEqual, time.Minute)
+			})
+		})
+
+		Convey("aligning range with step", func() {
+			start, end := alignRange(time.Unix(1010, 0), time.Unix(1190, 0), time.Minute)
+			So(start.Unix(), ShouldEqual, 960)
+			So(end.Unix(), ShouldEqual, 1200)
+		})
+
+		Convey("parsing vector response", func() {
+			value := p.Vector{
+				&p.Sample{
+					Metric:    p.Metric{"app": "backend", "__name__": "up"},
+					Value:     1,
+					Timestamp: p.TimeFromUnix(100),
+				},
+				&p.Sample{
+					Metric:    p.Metric{"device": "mobile", "__name__": "up"},
+					Value:     0,
+					Timestamp: p.TimeFromUnix(100),
+				},
+			}
+
+			Convey("as time series", func() {
+				res, err := parseResponse(value, &PrometheusQuery{LegendFormat: "{{app}}"})
+				So(err, ShouldBeNil)
+				So(len(res.Series), ShouldEqual, 2)
+				So(res.Series[0].Name, ShouldEqual, "backend")
+				So(res.Series[0].Points[0][1].Float64, ShouldEqual, 100000)
+			})
+
+			Convey("as table", func() {
+				res, err := parseResponse(value, &PrometheusQuery{Format: formatTable})
+				So(err, ShouldBeNil)
+				So(len(res.Tables), ShouldEqual, 1)
+
+				table := res.Tables[0]
+				So(len(table.Columns), ShouldEqual, 5)
+				So(table.Columns[0].Text, ShouldEqual, "Time")
+				So(table.Columns[1].Text, ShouldEqual, "__name__")
+				So(table.Columns[2].Text, ShouldEqual, "app")
+				So(table.Columns[4].Text, ShouldEqual, "Value")
+				So(table.Rows[1], ShouldResemble, tsdb.RowValues{float64(100000), "up", "", "mobile", float64(0)})
+			})
+		})
+
+		Convey("parsing scalar response", func() {
+			res, err := parseResponse(&p.Scalar{Value: 5, Timestamp: p.TimeFromUnix(10)}, &PrometheusQuery{})
+			So(err, ShouldBeNil)
+			So(res.Series[0].Name, ShouldEqual, "scalar")
+			So(res.Series[0].Points[0][0].Float64, ShouldEqual, 5)
+		})
 	})
 }
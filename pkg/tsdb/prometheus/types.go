@@ -9,4 +9,23 @@ type PrometheusQuery struct {
 	Start        time.Time
 	End          time.Time
 	RefId        string
+	QueryType    string
+	Format       string
+	Exemplar     bool
+	Label        string
+	Match        []string
 }
+
+const (
+	queryTypeRange       = "range"
+	queryTypeInstant     = "instant"
+	queryTypeLabelNames  = "label_names"
+	queryTypeLabelValues = "label_values"
+	queryTypeSeries      = "series"
+	queryTypeMetadata    = "metadata"
+)
+
+const (
+	formatTimeSeries = "time_series"
+	formatTable      = "table"
+)
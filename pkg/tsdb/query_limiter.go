@@ -0,0 +1,354 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+const (
+	QueryRejectedTimeout  = "timeout"
+	QueryRejectedCanceled = "canceled"
+)
+
+// ErrQueryQueueTimeout is returned when a query waited longer than the
+// configured queue timeout for a free slot on its datasource.
+var ErrQueryQueueTimeout = errors.New("Timeout waiting for data source query slot")
+
+// QueryLimits holds the per data source query limits. They are read from the
+// data source json data: maxConcurrentQueries, maxQueriesPerSecond and
+// queryQueueTimeout (seconds). A zero value disables the limit.
+type QueryLimits struct {
+	MaxConcurrentQueries int
+	MaxQueriesPerSecond  float64
+	QueueTimeout         time.Duration
+}
+
+const defaultQueryQueueTimeout = 30 * time.Second
+
+// NewQueryLimits reads the query limits from the data source json data.
+func NewQueryLimits(ds *models.DataSource) QueryLimits {
+	limits := QueryLimits{QueueTimeout: defaultQueryQueueTimeout}
+	if ds == nil || ds.JsonData == nil {
+		return limits
+	}
+
+	limits.MaxConcurrentQueries = ds.JsonData.Get("maxConcurrentQueries").MustInt(0)
+	limits.MaxQueriesPerSecond = ds.JsonData.Get("maxQueriesPerSecond").MustFloat64(0)
+	if timeout := ds.JsonData.Get("queryQueueTimeout").MustInt(0); timeout > 0 {
+		limits.QueueTimeout = time.Duration(timeout) * time.Second
+	}
+
+	return limits
+}
+
+// Enabled returns true if any limit is configured.
+func (l QueryLimits) Enabled() bool {
+	return l.MaxConcurrentQueries > 0 || l.MaxQueriesPerSecond > 0
+}
+
+func (l QueryLimits) burst() float64 {
+	return math.Max(1, math.Ceil(l.MaxQueriesPerSecond))
+}
+
+// QueryLimiterStats is the state of the query limiter of a data source,
+// exported as metrics.
+type QueryLimiterStats struct {
+	OrgId        int64
+	DataSourceId int64
+	Queued       int
+	InFlight     int
+	Rejected     map[string]int64
+}
+
+// queryLimiter bounds the number of concurrent queries and the rate of
+// queries sent to a single data source. Queries that cannot run right away
+// are queued until a slot is free, the context is done or the queue timeout
+// expires. It is the only limit of the queries of a data source, endpoints
+// running queries in parallel use the slots granted to the request, see
+// MaxConcurrentQueries.
+type queryLimiter struct {
+	orgId        int64
+	dataSourceId int64
+
+	mu       sync.Mutex
+	limits   QueryLimits
+	inFlight int
+	queued   int
+	rejected map[string]int64
+	tokens   float64
+	last     time.Time
+	changed  chan struct{}
+	now      func() time.Time
+}
+
+func newQueryLimiter(orgId int64, dataSourceId int64, limits QueryLimits) *queryLimiter {
+	return &queryLimiter{
+		orgId:        orgId,
+		dataSourceId: dataSourceId,
+		limits:       limits,
+		rejected:     map[string]int64{},
+		tokens:       limits.burst(),
+		changed:      make(chan struct{}),
+		now:          time.Now,
+	}
+}
+
+// setLimits changes the limits, the running queries and the spent tokens
+// still count against the new limits.
+func (l *queryLimiter) setLimits(limits QueryLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits = limits
+	l.tokens = math.Min(l.tokens, limits.burst())
+	l.notify()
+}
+
+type querySlotsKey struct{}
+
+// MaxConcurrentQueries returns how many queries of a request may run in
+// parallel, the slots the query limiter of the data source granted to the
+// request. It returns 0 if the data source has no concurrency limit.
+func MaxConcurrentQueries(ctx context.Context) int {
+	slots, _ := ctx.Value(querySlotsKey{}).(int)
+	return slots
+}
+
+// Acquire reserves capacity for a request with n queries. A request takes a
+// token for each query, large requests wait until enough tokens accumulated.
+// It takes a slot for each query, but at most MaxConcurrentQueries, the
+// returned context carries the slots of the request. The returned function
+// must be called when the queries have finished.
+func (l *queryLimiter) Acquire(ctx context.Context, n int) (context.Context, func(), error) {
+	if n < 1 {
+		n = 1
+	}
+
+	l.mu.Lock()
+	limits := l.limits
+	l.mu.Unlock()
+
+	var deadline time.Time
+	var timeout <-chan time.Time
+	if limits.QueueTimeout > 0 {
+		deadline = time.Now().Add(limits.QueueTimeout)
+		timer := time.NewTimer(limits.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	queued := false
+	defer func() {
+		if queued {
+			l.mu.Lock()
+			l.queued--
+			l.mu.Unlock()
+		}
+	}()
+	queue := func() {
+		if !queued {
+			queued = true
+			l.mu.Lock()
+			l.queued++
+			l.mu.Unlock()
+		}
+	}
+
+	l.mu.Lock()
+	wait, tokens := l.reserveTokens(n)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		if !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+			l.cancel(tokens, QueryRejectedTimeout)
+			return nil, nil, ErrQueryQueueTimeout
+		}
+
+		queue()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			l.cancel(tokens, QueryRejectedCanceled)
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	for {
+		l.mu.Lock()
+		slots, changed := l.tryAcquireSlots(n)
+		l.mu.Unlock()
+
+		if changed == nil {
+			return context.WithValue(ctx, querySlotsKey{}, slots), l.releaseFunc(slots), nil
+		}
+
+		queue()
+
+		select {
+		case <-ctx.Done():
+			l.cancel(tokens, QueryRejectedCanceled)
+			return nil, nil, ctx.Err()
+		case <-timeout:
+			l.cancel(tokens, QueryRejectedTimeout)
+			return nil, nil, ErrQueryQueueTimeout
+		case <-changed:
+		}
+	}
+}
+
+// reserveTokens takes n tokens of the rate limit, the tokens may become
+// negative. It returns how long to wait until the tokens are refilled and
+// how many tokens were taken.
+func (l *queryLimiter) reserveTokens(n int) (time.Duration, float64) {
+	if l.limits.MaxQueriesPerSecond <= 0 {
+		return 0, 0
+	}
+
+	now := l.now()
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(l.limits.burst(), l.tokens+elapsed*l.limits.MaxQueriesPerSecond)
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0, float64(n)
+	}
+
+	return time.Duration(-l.tokens / l.limits.MaxQueriesPerSecond * float64(time.Second)), float64(n)
+}
+
+// tryAcquireSlots takes the slots of a request with n queries if available,
+// otherwise it returns a channel closed on the next release.
+func (l *queryLimiter) tryAcquireSlots(n int) (int, chan struct{}) {
+	if l.limits.MaxConcurrentQueries <= 0 {
+		return 0, nil
+	}
+
+	slots := n
+	if slots > l.limits.MaxConcurrentQueries {
+		slots = l.limits.MaxConcurrentQueries
+	}
+
+	if l.inFlight+slots > l.limits.MaxConcurrentQueries {
+		return 0, l.changed
+	}
+
+	l.inFlight += slots
+	return slots, nil
+}
+
+// cancel returns the tokens of a rejected request.
+func (l *queryLimiter) cancel(tokens float64, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = math.Min(l.limits.burst(), l.tokens+tokens)
+	l.rejected[reason]++
+}
+
+func (l *queryLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (l *queryLimiter) releaseFunc(slots int) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight -= slots
+			l.notify()
+			l.mu.Unlock()
+		})
+	}
+}
+
+func (l *queryLimiter) stats() QueryLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := QueryLimiterStats{
+		OrgId:        l.orgId,
+		DataSourceId: l.dataSourceId,
+		Queued:       l.queued,
+		InFlight:     l.inFlight,
+		Rejected:     make(map[string]int64, len(l.rejected)),
+	}
+	for reason, count := range l.rejected {
+		stats.Rejected[reason] = count
+	}
+
+	return stats
+}
+
+type queryLimiterCacheType struct {
+	limiters map[int64]*queryLimiter
+	versions map[int64]int
+	sync.Mutex
+}
+
+var queryLimiterCache = queryLimiterCacheType{
+	limiters: make(map[int64]*queryLimiter),
+	versions: make(map[int64]int),
+}
+
+// getQueryLimiter returns the limiter for the data source or nil if no limits
+// are configured. When the data source is updated the limits of its limiter
+// change, the running and queued queries are kept.
+func getQueryLimiter(ds *models.DataSource) *queryLimiter {
+	limits := NewQueryLimits(ds)
+
+	queryLimiterCache.Lock()
+	defer queryLimiterCache.Unlock()
+
+	limiter, present := queryLimiterCache.limiters[ds.Id]
+	if !limits.Enabled() {
+		if present {
+			delete(queryLimiterCache.limiters, ds.Id)
+			delete(queryLimiterCache.versions, ds.Id)
+		}
+		return nil
+	}
+
+	if !present {
+		limiter = newQueryLimiter(ds.OrgId, ds.Id, limits)
+		queryLimiterCache.limiters[ds.Id] = limiter
+	} else if queryLimiterCache.versions[ds.Id] != ds.Version {
+		limiter.setLimits(limits)
+	}
+	queryLimiterCache.versions[ds.Id] = ds.Version
+
+	return limiter
+}
+
+// GetQueryLimiterStats returns the state of the query limiters of all data
+// sources with limits, sorted by data source id.
+func GetQueryLimiterStats() []QueryLimiterStats {
+	queryLimiterCache.Lock()
+	limiters := make([]*queryLimiter, 0, len(queryLimiterCache.limiters))
+	for _, limiter := range queryLimiterCache.limiters {
+		limiters = append(limiters, limiter)
+	}
+	queryLimiterCache.Unlock()
+
+	result := make([]QueryLimiterStats, 0, len(limiters))
+	for _, limiter := range limiters {
+		result = append(result, limiter.stats())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DataSourceId < result[j].DataSourceId
+	})
+
+	return result
+}
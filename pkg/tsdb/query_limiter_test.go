@@ -0,0 +1,191 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryLimiter(t *testing.T) {
+	Convey("Query limits", t, func() {
+		Convey("Should read limits from json data", func() {
+			ds := &models.DataSource{JsonData: simplejson.NewFromAny(map[string]interface{}{
+				"maxConcurrentQueries": 2,
+				"maxQueriesPerSecond":  5.5,
+				"queryQueueTimeout":    10,
+			})}
+
+			limits := NewQueryLimits(ds)
+			So(limits.Enabled(), ShouldBeTrue)
+			So(limits.MaxConcurrentQueries, ShouldEqual, 2)
+			So(limits.MaxQueriesPerSecond, ShouldEqual, 5.5)
+			So(limits.QueueTimeout, ShouldEqual, 10*time.Second)
+		})
+
+		Convey("Should be disabled without json data", func() {
+			limits := NewQueryLimits(&models.DataSource{})
+			So(limits.Enabled(), ShouldBeFalse)
+			So(limits.QueueTimeout, ShouldEqual, defaultQueryQueueTimeout)
+			So(getQueryLimiter(&models.DataSource{}), ShouldBeNil)
+		})
+	})
+
+	Convey("Concurrency limiter", t, func() {
+		limiter := newQueryLimiter(1, 1, QueryLimits{MaxConcurrentQueries: 1, QueueTimeout: 50 * time.Millisecond})
+
+		_, release, err := limiter.Acquire(context.Background(), 1)
+		So(err, ShouldBeNil)
+
+		Convey("Should time out while slot is taken", func() {
+			_, _, err := limiter.Acquire(context.Background(), 1)
+			So(err, ShouldEqual, ErrQueryQueueTimeout)
+			So(limiter.stats().Rejected[QueryRejectedTimeout], ShouldEqual, 1)
+			So(limiter.stats().Queued, ShouldEqual, 0)
+		})
+
+		Convey("Should return context error when canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, _, err := limiter.Acquire(ctx, 1)
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("Should run queued query after release", func() {
+			done := make(chan error)
+			go func() {
+				_, release, err := limiter.Acquire(context.Background(), 1)
+				if err == nil {
+					release()
+				}
+				done <- err
+			}()
+
+			release()
+			So(<-done, ShouldBeNil)
+		})
+
+		Convey("Should grant requests at most the limit of slots", func() {
+			release()
+			ctx, release, err := limiter.Acquire(context.Background(), 5)
+			So(err, ShouldBeNil)
+			So(limiter.inFlight, ShouldEqual, 1)
+			So(MaxConcurrentQueries(ctx), ShouldEqual, 1)
+			release()
+			So(limiter.inFlight, ShouldEqual, 0)
+		})
+
+		Convey("Should keep running queries when the limits change", func() {
+			limiter.setLimits(QueryLimits{MaxConcurrentQueries: 2, QueueTimeout: 50 * time.Millisecond})
+			So(limiter.inFlight, ShouldEqual, 1)
+
+			_, second, err := limiter.Acquire(context.Background(), 1)
+			So(err, ShouldBeNil)
+			_, _, err = limiter.Acquire(context.Background(), 1)
+			So(err, ShouldEqual, ErrQueryQueueTimeout)
+
+			second()
+			release()
+			So(limiter.inFlight, ShouldEqual, 0)
+		})
+
+		Convey("Should use the limits of the start of the request when they change while queued", func() {
+			done := make(chan error)
+			go func() {
+				_, _, err := limiter.Acquire(context.Background(), 1)
+				done <- err
+			}()
+
+			for limiter.stats().Queued == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			limiter.setLimits(QueryLimits{MaxConcurrentQueries: 1, QueueTimeout: time.Hour})
+			So(<-done, ShouldEqual, ErrQueryQueueTimeout)
+			release()
+		})
+	})
+
+	Convey("Rate limiter", t, func() {
+		now := time.Unix(0, 0)
+		limiter := newQueryLimiter(1, 1, QueryLimits{MaxQueriesPerSecond: 2, QueueTimeout: 20 * time.Millisecond})
+		limiter.now = func() time.Time { return now }
+
+		Convey("Should allow a burst and then reject", func() {
+			for i := 0; i < 2; i++ {
+				ctx, release, err := limiter.Acquire(context.Background(), 1)
+				So(err, ShouldBeNil)
+				So(MaxConcurrentQueries(ctx), ShouldEqual, 0)
+				release()
+			}
+
+			_, _, err := limiter.Acquire(context.Background(), 1)
+			So(err, ShouldEqual, ErrQueryQueueTimeout)
+
+			Convey("Should refill tokens over time", func() {
+				now = now.Add(500 * time.Millisecond)
+				_, release, err := limiter.Acquire(context.Background(), 1)
+				So(err, ShouldBeNil)
+				release()
+			})
+		})
+
+		Convey("Should take a token for every query of large requests", func() {
+			_, _, err := limiter.Acquire(context.Background(), 5)
+			So(err, ShouldEqual, ErrQueryQueueTimeout)
+			So(limiter.tokens, ShouldEqual, 2)
+
+			limiter.limits.QueueTimeout = time.Second
+			start := time.Now()
+			_, release, err := limiter.Acquire(context.Background(), 3)
+			So(err, ShouldBeNil)
+			release()
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 400*time.Millisecond)
+			So(limiter.tokens, ShouldEqual, -1)
+		})
+	})
+
+	Convey("Limiter cache", t, func() {
+		ds := &models.DataSource{Id: 4711, OrgId: 3, Version: 1, JsonData: simplejson.NewFromAny(map[string]interface{}{
+			"maxConcurrentQueries": 1,
+		})}
+
+		first := getQueryLimiter(ds)
+		So(first, ShouldNotBeNil)
+		So(getQueryLimiter(ds), ShouldEqual, first)
+
+		_, release, err := first.Acquire(context.Background(), 1)
+		So(err, ShouldBeNil)
+		defer release()
+
+		Convey("Should keep the limiter when the data source is updated", func() {
+			ds.Version = 2
+			ds.JsonData.Set("maxConcurrentQueries", 2)
+			So(getQueryLimiter(ds), ShouldEqual, first)
+			So(first.limits.MaxConcurrentQueries, ShouldEqual, 2)
+			So(first.inFlight, ShouldEqual, 1)
+		})
+
+		Convey("Should report stats by data source id", func() {
+			var stats *QueryLimiterStats
+			all := GetQueryLimiterStats()
+			for i := range all {
+				if all[i].DataSourceId == 4711 {
+					stats = &all[i]
+				}
+			}
+			So(stats, ShouldNotBeNil)
+			So(stats.OrgId, ShouldEqual, 3)
+			So(stats.InFlight, ShouldEqual, 1)
+		})
+
+		Convey("Should drop the limiter when the limits are removed", func() {
+			ds.Version = 3
+			ds.JsonData.Del("maxConcurrentQueries")
+			So(getQueryLimiter(ds), ShouldBeNil)
+			So(queryLimiterCache.limiters, ShouldNotContainKey, int64(4711))
+		})
+	})
+}
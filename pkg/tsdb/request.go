@@ -14,5 +14,14 @@ func HandleRequest(ctx context.Context, dsInfo *models.DataSource, req *TsdbQuer
 		return nil, err
 	}
 
+	if limiter := getQueryLimiter(dsInfo); limiter != nil {
+		var release func()
+		ctx, release, err = limiter.Acquire(ctx, len(req.Queries))
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
 	return endpoint.Query(ctx, dsInfo, req)
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -51,12 +52,16 @@ var NewXormEngine = func(driverName string, connectionString string) (*xorm.Engi
 }
 
 type sqlQueryEndpoint struct {
-	macroEngine       SqlMacroEngine
-	rowTransformer    SqlTableRowTransformer
-	engine            *xorm.Engine
-	timeColumnNames   []string
-	metricColumnTypes []string
-	log               log.Logger
+	macroEngine         SqlMacroEngine
+	rowTransformer      SqlTableRowTransformer
+	engine              *xorm.Engine
+	timeColumnNames     []string
+	metricColumnTypes   []string
+	dialect             string
+	readOnlyMode        SqlReadOnlyMode
+	statementTimeoutSql func(time.Duration) string
+	statementTimeoutFn  func(string, time.Duration) string
+	log                 log.Logger
 }
 
 type SqlQueryEndpointConfiguration struct {
@@ -65,14 +70,26 @@ type SqlQueryEndpointConfiguration struct {
 	ConnectionString  string
 	TimeColumnNames   []string
 	MetricColumnTypes []string
+	// ReadOnlyMode is how queries of read-only data sources are run.
+	ReadOnlyMode SqlReadOnlyMode
+	// StatementTimeoutSql returns the statement setting the statement timeout
+	// in a transaction. Without it the timeout only cancels the query context.
+	StatementTimeoutSql func(timeout time.Duration) string
+	// StatementTimeout rewrites a query to limit its execution time, for
+	// databases limiting single statements with a query hint.
+	StatementTimeout func(rawSQL string, timeout time.Duration) string
 }
 
 var NewSqlQueryEndpoint = func(config *SqlQueryEndpointConfiguration, rowTransformer SqlTableRowTransformer, macroEngine SqlMacroEngine, log log.Logger) (TsdbQueryEndpoint, error) {
 	queryEndpoint := sqlQueryEndpoint{
-		rowTransformer:  rowTransformer,
-		macroEngine:     macroEngine,
-		timeColumnNames: []string{"time"},
-		log:             log,
+		rowTransformer:      rowTransformer,
+		macroEngine:         macroEngine,
+		timeColumnNames:     []string{"time"},
+		dialect:             config.DriverName,
+		readOnlyMode:        config.ReadOnlyMode,
+		statementTimeoutSql: config.StatementTimeoutSql,
+		statementTimeoutFn:  config.StatementTimeout,
+		log:                 log,
 	}
 
 	if len(config.TimeColumnNames) > 0 {
@@ -114,6 +131,25 @@ var NewSqlQueryEndpoint = func(config *SqlQueryEndpointConfiguration, rowTransfo
 
 const rowLimit = 1000000
 
+// CheckHealth checks that the database accepts connections and queries.
+func (e *sqlQueryEndpoint) CheckHealth(ctx context.Context, dsInfo *models.DataSource) (*HealthCheckResult, error) {
+	session := e.engine.NewSession()
+	defer session.Close()
+
+	db := session.DB()
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return &HealthCheckResult{Status: HealthStatusOk, Message: "Database connection is working"}, nil
+}
+
 // Query is the main function for the SqlQueryEndpoint
 func (e *sqlQueryEndpoint) Query(ctx context.Context, dsInfo *models.DataSource, tsdbQuery *TsdbQuery) (*Response, error) {
 	result := &Response{
@@ -122,6 +158,14 @@ func (e *sqlQueryEndpoint) Query(ctx context.Context, dsInfo *models.DataSource,
 
 	var wg sync.WaitGroup
 
+	// run at most as many queries in parallel as the query limiter granted
+	var slots chan struct{}
+	if maxConcurrent := MaxConcurrentQueries(ctx); maxConcurrent > 0 {
+		slots = make(chan struct{}, maxConcurrent)
+	}
+
+	guard := NewSqlQueryGuard(dsInfo)
+
 	for _, query := range tsdbQuery.Queries {
 		rawSQL := query.Model.Get("rawSql").MustString()
 		if rawSQL == "" {
@@ -147,21 +191,46 @@ func (e *sqlQueryEndpoint) Query(ctx context.Context, dsInfo *models.DataSource,
 
 		queryResult.Meta.Set("sql", rawSQL)
 
+		if guard.RejectUnsafeQueries || (guard.ReadOnly && e.readOnlyMode == SqlReadOnlyCheck) {
+			if err := checkSqlStatement(e.dialect, rawSQL); err != nil {
+				queryResult.Error = err
+				continue
+			}
+		}
+
 		wg.Add(1)
 
 		go func(rawSQL string, query *Query, queryResult *QueryResult) {
 			defer wg.Done()
+
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					queryResult.Error = ctx.Err()
+					return
+				}
+			}
+
 			session := e.engine.NewSession()
 			defer session.Close()
 			db := session.DB()
 
-			rows, err := db.Query(rawSQL)
+			queryCtx := ctx
+			if guard.StatementTimeout > 0 {
+				var cancel context.CancelFunc
+				queryCtx, cancel = context.WithTimeout(ctx, guard.StatementTimeout)
+				defer cancel()
+			}
+
+			rows, release, err := e.queryRows(queryCtx, db, guard, rawSQL)
 			if err != nil {
 				queryResult.Error = err
 				return
 			}
 
-			defer rows.Close()
+			defer release()
 
 			format := query.Model.Get("format").MustString("time_series")
 
@@ -173,7 +242,12 @@ func (e *sqlQueryEndpoint) Query(ctx context.Context, dsInfo *models.DataSource,
 					return
 				}
 			case "table":
-				err := e.transformToTable(query, rows, queryResult, tsdbQuery)
+				transform := e.transformToTable
+				if query.Model.Get("pivot").MustBool(false) {
+					transform = e.transformToWideTable
+				}
+
+				err := transform(query, rows, queryResult, tsdbQuery)
 				if err != nil {
 					queryResult.Error = err
 					return
@@ -188,6 +262,12 @@ func (e *sqlQueryEndpoint) Query(ctx context.Context, dsInfo *models.DataSource,
 
 // global macros/substitutions for all sql datasources
 var Interpolate = func(query *Query, timeRange *TimeRange, sql string) (string, error) {
+	// org level macros go first, their templates may use the other macros
+	sql, err := expandSqlMacroLibrary(query, sql)
+	if err != nil {
+		return "", err
+	}
+
 	minInterval, err := GetIntervalFrom(query.DataSource, query.Model, time.Second*60)
 	if err != nil {
 		return sql, nil
@@ -212,6 +292,7 @@ func (e *sqlQueryEndpoint) transformToTable(query *Query, rows *core.Rows, resul
 
 	rowCount := 0
 	timeIndex := -1
+	maxRows := NewSqlQueryGuard(query.DataSource).MaxRows
 
 	table := &Table{
 		Columns: make([]TableColumn, columnCount),
@@ -235,8 +316,8 @@ func (e *sqlQueryEndpoint) transformToTable(query *Query, rows *core.Rows, resul
 	}
 
 	for ; rows.Next(); rowCount++ {
-		if rowCount > rowLimit {
-			return fmt.Errorf("query row limit exceeded, limit %d", rowLimit)
+		if rowCount > maxRows {
+			return fmt.Errorf("query row limit exceeded, limit %d", maxRows)
 		}
 
 		values, err := e.rowTransformer.Transform(columnTypes, rows)
@@ -256,8 +337,106 @@ func (e *sqlQueryEndpoint) transformToTable(query *Query, rows *core.Rows, resul
 	return nil
 }
 
+// sqlFillMode is how missing points of a value column are filled.
+type sqlFillMode struct {
+	enabled  bool
+	previous bool
+	value    null.Float
+}
+
+// parseFillMode parses a fill mode as used in the $__timeGroup macro: NULL,
+// previous, none or a number.
+func parseFillMode(mode *simplejson.Json) (sqlFillMode, error) {
+	if floatVal, err := mode.Float64(); err == nil {
+		return sqlFillMode{enabled: true, value: null.FloatFrom(floatVal)}, nil
+	}
+
+	value, err := mode.String()
+	if err != nil {
+		return sqlFillMode{}, fmt.Errorf("error parsing fill value %v", mode.Interface())
+	}
+
+	switch strings.ToLower(value) {
+	case "none":
+		return sqlFillMode{}, nil
+	case "null":
+		return sqlFillMode{enabled: true}, nil
+	case "previous":
+		return sqlFillMode{enabled: true, previous: true}, nil
+	}
+
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return sqlFillMode{}, fmt.Errorf("error parsing fill value %v", value)
+	}
+	return sqlFillMode{enabled: true, value: null.FloatFrom(floatVal)}, nil
+}
+
+// getFillModes returns the fill mode set by the $__timeGroup macro and the
+// fill modes of value columns overriding it, set in columnFill of the query.
+func getFillModes(query *Query) (sqlFillMode, map[string]sqlFillMode, error) {
+	fill := sqlFillMode{enabled: query.Model.Get("fill").MustBool(false)}
+	if fill.enabled {
+		switch query.Model.Get("fillMode").MustString() {
+		case "previous":
+			fill.previous = true
+		case "value":
+			fill.value = null.FloatFrom(query.Model.Get("fillValue").MustFloat64())
+		}
+	}
+
+	columnFills := make(map[string]sqlFillMode)
+	for column := range query.Model.Get("columnFill").MustMap() {
+		columnFill, err := parseFillMode(query.Model.GetPath("columnFill", column))
+		if err != nil {
+			return fill, nil, fmt.Errorf("column %s: %v", column, err)
+		}
+		columnFills[column] = columnFill
+	}
+
+	return fill, columnFills, nil
+}
+
+// getFillInterval returns the fill interval in milliseconds, the interval of
+// the $__timeGroup macro or the interval of the query.
+func getFillInterval(query *Query, timeRange *TimeRange) float64 {
+	if fillInterval := query.Model.Get("fillInterval").MustFloat64(); fillInterval > 0 {
+		return fillInterval * 1000
+	}
+
+	minInterval, err := GetIntervalFrom(query.DataSource, query.Model, time.Second*60)
+	if err != nil {
+		minInterval = time.Second * 60
+	}
+	return float64(sqlIntervalCalculator.Calculate(timeRange, minInterval).Value.Nanoseconds() / 1e6)
+}
+
+func (e *sqlQueryEndpoint) isMetricColumnType(columnType *sql.ColumnType) bool {
+	for _, mct := range e.metricColumnTypes {
+		if strings.EqualFold(columnType.DatabaseTypeName(), mct) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSeriesName adds the labels of a series to its name, e.g. value{host=a, dc=b}
+func formatSeriesName(name string, labelNames []string, labels map[string]string) string {
+	if len(labelNames) == 0 {
+		return name
+	}
+
+	parts := make([]string, 0, len(labelNames))
+	for _, labelName := range labelNames {
+		parts = append(parts, labelName+"="+labels[labelName])
+	}
+
+	return name + "{" + strings.Join(parts, ", ") + "}"
+}
+
 func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows, result *QueryResult, tsdbQuery *TsdbQuery) error {
 	pointsBySeries := make(map[string]*TimeSeries)
+	fillBySeries := make(map[string]sqlFillMode)
 	seriesByQueryOrder := list.New()
 
 	columnNames, err := rows.Columns()
@@ -271,39 +450,51 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 	}
 
 	rowCount := 0
+	maxRows := NewSqlQueryGuard(query.DataSource).MaxRows
 	timeIndex := -1
 	metricIndex := -1
+	labelIndexes := make([]int, 0)
+	valueIndexes := make([]int, 0)
 	metricPrefix := false
 	var metricPrefixValue string
 
 	// check columns of resultset: a column named time is mandatory
-	// the first text column is treated as metric name unless a column named metric is present
+	// the first text column is treated as metric name unless a column named metric is present,
+	// other text columns are returned as tags of the series
 	for i, col := range columnNames {
+		isTimeColumn := false
 		for _, tc := range e.timeColumnNames {
 			if col == tc {
-				timeIndex = i
-				continue
+				isTimeColumn = true
+				break
 			}
 		}
-		switch col {
-		case "metric":
+
+		switch {
+		case isTimeColumn && timeIndex == -1:
+			timeIndex = i
+		case col == "metric":
 			metricIndex = i
+		case e.isMetricColumnType(columnTypes[i]):
+			labelIndexes = append(labelIndexes, i)
 		default:
-			if metricIndex == -1 {
-				columnType := columnTypes[i].DatabaseTypeName()
-
-				for _, mct := range e.metricColumnTypes {
-					if columnType == mct {
-						metricIndex = i
-						continue
-					}
-				}
-			}
+			valueIndexes = append(valueIndexes, i)
 		}
 	}
 
+	// a single text column is the metric name, several text columns are tags
+	if metricIndex == -1 && len(labelIndexes) == 1 {
+		metricIndex = labelIndexes[0]
+		labelIndexes = labelIndexes[:0]
+	}
+
+	labelNames := make([]string, 0, len(labelIndexes))
+	for _, i := range labelIndexes {
+		labelNames = append(labelNames, columnNames[i])
+	}
+
 	// use metric column as prefix with multiple value columns
-	if metricIndex != -1 && len(columnNames) > 3 {
+	if metricIndex != -1 && len(valueIndexes) > 1 {
 		metricPrefix = true
 	}
 
@@ -311,21 +502,14 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 		return fmt.Errorf("Found no column named %s", strings.Join(e.timeColumnNames, " or "))
 	}
 
-	fillMissing := query.Model.Get("fill").MustBool(false)
-	var fillInterval float64
-	fillValue := null.Float{}
-	fillPrevious := false
+	defaultFill, columnFills, err := getFillModes(query)
+	if err != nil {
+		return err
+	}
 
-	if fillMissing {
-		fillInterval = query.Model.Get("fillInterval").MustFloat64() * 1000
-		switch query.Model.Get("fillMode").MustString() {
-		case "null":
-		case "previous":
-			fillPrevious = true
-		case "value":
-			fillValue.Float64 = query.Model.Get("fillValue").MustFloat64()
-			fillValue.Valid = true
-		}
+	var fillInterval float64
+	if defaultFill.enabled || len(columnFills) > 0 {
+		fillInterval = getFillInterval(query, tsdbQuery.TimeRange)
 	}
 
 	for rows.Next() {
@@ -333,8 +517,8 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 		var value null.Float
 		var metric string
 
-		if rowCount > rowLimit {
-			return fmt.Errorf("query row limit exceeded, limit %d", rowLimit)
+		if rowCount > maxRows {
+			return fmt.Errorf("query row limit exceeded, limit %d", maxRows)
 		}
 
 		values, err := e.rowTransformer.Transform(columnTypes, rows)
@@ -368,10 +552,23 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 			}
 		}
 
-		for i, col := range columnNames {
-			if i == timeIndex || i == metricIndex {
-				continue
+		var labels map[string]string
+		if len(labelIndexes) > 0 {
+			labels = make(map[string]string, len(labelIndexes))
+			for _, i := range labelIndexes {
+				switch columnValue := values[i].(type) {
+				case string:
+					labels[columnNames[i]] = columnValue
+				case nil:
+					labels[columnNames[i]] = ""
+				default:
+					return fmt.Errorf("Label column must be of type %s. column name: %s type: %s but datatype is %T", strings.Join(e.metricColumnTypes, ", "), columnNames[i], columnTypes[i].DatabaseTypeName(), values[i])
+				}
 			}
+		}
+
+		for _, i := range valueIndexes {
+			col := columnNames[i]
 
 			if value, err = ConvertSqlValueColumnToFloat(col, values[i]); err != nil {
 				return err
@@ -383,14 +580,24 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 				metric = metricPrefixValue + " " + col
 			}
 
-			series, exist := pointsBySeries[metric]
+			key := formatSeriesName(metric, labelNames, labels)
+			series, exist := pointsBySeries[key]
 			if !exist {
-				series = &TimeSeries{Name: metric}
-				pointsBySeries[metric] = series
-				seriesByQueryOrder.PushBack(metric)
+				series = &TimeSeries{Name: key}
+				if len(labels) > 0 {
+					series.Tags = labels
+				}
+				pointsBySeries[key] = series
+				seriesByQueryOrder.PushBack(key)
+
+				fill := defaultFill
+				if columnFill, ok := columnFills[col]; ok {
+					fill = columnFill
+				}
+				fillBySeries[key] = fill
 			}
 
-			if fillMissing {
+			if fill := fillBySeries[key]; fill.enabled {
 				var intervalStart float64
 				if !exist {
 					intervalStart = float64(tsdbQuery.TimeRange.MustGetFrom().UnixNano() / 1e6)
@@ -398,7 +605,8 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 					intervalStart = series.Points[len(series.Points)-1][1].Float64 + fillInterval
 				}
 
-				if fillPrevious {
+				fillValue := fill.value
+				if fill.previous {
 					if len(series.Points) > 0 {
 						fillValue = series.Points[len(series.Points)-1][0]
 					} else {
@@ -425,13 +633,14 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 		key := elem.Value.(string)
 		result.Series = append(result.Series, pointsBySeries[key])
 
-		if fillMissing {
+		if fill := fillBySeries[key]; fill.enabled {
 			series := pointsBySeries[key]
 			// fill in values from last fetched value till interval end
 			intervalStart := series.Points[len(series.Points)-1][1].Float64
 			intervalEnd := float64(tsdbQuery.TimeRange.MustGetTo().UnixNano() / 1e6)
 
-			if fillPrevious {
+			fillValue := fill.value
+			if fill.previous {
 				if len(series.Points) > 0 {
 					fillValue = series.Points[len(series.Points)-1][0]
 				} else {
@@ -452,6 +661,51 @@ func (e *sqlQueryEndpoint) transformToTimeSeries(query *Query, rows *core.Rows,
 	return nil
 }
 
+// transformToWideTable pivots a result in long format, with the labels of a
+// value in text columns, to a table with a column per label combination and
+// value column, and a row per time.
+func (e *sqlQueryEndpoint) transformToWideTable(query *Query, rows *core.Rows, result *QueryResult, tsdbQuery *TsdbQuery) error {
+	seriesResult := &QueryResult{Meta: simplejson.New()}
+	if err := e.transformToTimeSeries(query, rows, seriesResult, tsdbQuery); err != nil {
+		return err
+	}
+
+	table := &Table{
+		Columns: []TableColumn{{Text: "time"}},
+		Rows:    make([]RowValues, 0),
+	}
+
+	rowsByTime := make(map[float64]RowValues)
+	times := make([]float64, 0)
+	for i, series := range seriesResult.Series {
+		table.Columns = append(table.Columns, TableColumn{Text: series.Name})
+
+		for _, point := range series.Points {
+			timestamp := point[1].Float64
+			row, exists := rowsByTime[timestamp]
+			if !exists {
+				row = make(RowValues, len(seriesResult.Series)+1)
+				row[0] = timestamp
+				rowsByTime[timestamp] = row
+				times = append(times, timestamp)
+			}
+
+			if point[0].Valid {
+				row[i+1] = point[0].Float64
+			}
+		}
+	}
+
+	sort.Float64s(times)
+	for _, timestamp := range times {
+		table.Rows = append(table.Rows, rowsByTime[timestamp])
+	}
+
+	result.Tables = append(result.Tables, table)
+	result.Meta.Set("rowCount", len(table.Rows))
+	return nil
+}
+
 // ConvertSqlTimeColumnToEpochMs converts column named time to unix timestamp in milliseconds
 // to make native datetime types and epoch dates work in annotation and table queries.
 func ConvertSqlTimeColumnToEpochMs(values RowValues, timeIndex int) {
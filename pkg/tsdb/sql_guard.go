@@ -0,0 +1,350 @@
+package tsdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-xorm/core"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+var ErrSqlMultipleStatements = errors.New("query guard: multiple statements are not allowed")
+
+// SqlReadOnlyMode is how a driver runs queries of read-only data sources.
+type SqlReadOnlyMode int
+
+const (
+	// SqlReadOnlyTransaction runs queries in a read-only transaction.
+	SqlReadOnlyTransaction SqlReadOnlyMode = iota
+	// SqlRollbackTransaction runs queries in a transaction that is always
+	// rolled back, for drivers without read-only transactions.
+	SqlRollbackTransaction
+	// SqlReadOnlyCheck rejects statements other than queries, for drivers
+	// without transactions.
+	SqlReadOnlyCheck
+)
+
+// SqlQueryGuard holds the query guard settings of a sql data source. They are
+// read from the data source json data: readOnly, statementTimeout (seconds),
+// rejectUnsafeQueries and maxRows.
+type SqlQueryGuard struct {
+	ReadOnly            bool
+	StatementTimeout    time.Duration
+	RejectUnsafeQueries bool
+	MaxRows             int
+}
+
+// IsReadOnlyDataSource returns the readOnly setting of a sql data source, it
+// is off unless set.
+func IsReadOnlyDataSource(ds *models.DataSource) bool {
+	if ds == nil || ds.JsonData == nil {
+		return false
+	}
+	return ds.JsonData.Get("readOnly").MustBool(false)
+}
+
+// NewSqlQueryGuard reads the query guard settings from the data source json data.
+func NewSqlQueryGuard(ds *models.DataSource) SqlQueryGuard {
+	guard := SqlQueryGuard{MaxRows: rowLimit}
+	if ds == nil || ds.JsonData == nil {
+		return guard
+	}
+
+	guard.ReadOnly = IsReadOnlyDataSource(ds)
+	guard.RejectUnsafeQueries = ds.JsonData.Get("rejectUnsafeQueries").MustBool(false)
+	if timeout := ds.JsonData.Get("statementTimeout").MustInt(0); timeout > 0 {
+		guard.StatementTimeout = time.Duration(timeout) * time.Second
+	}
+	if maxRows := ds.JsonData.Get("maxRows").MustInt(0); maxRows > 0 {
+		guard.MaxRows = maxRows
+	}
+
+	return guard
+}
+
+// allowedSqlStatements are the statements accepted by the query check.
+var allowedSqlStatements = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"VALUES":   true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+}
+
+// forbiddenSqlKeywords are rejected in statement position, as the first word
+// of a query or of a parenthesized subquery, so that columns and functions
+// with the same name, like the merge table function of ClickHouse, pass.
+var forbiddenSqlKeywords = map[string]bool{
+	"INSERT":     true,
+	"UPDATE":     true,
+	"DELETE":     true,
+	"MERGE":      true,
+	"UPSERT":     true,
+	"CREATE":     true,
+	"ALTER":      true,
+	"DROP":       true,
+	"TRUNCATE":   true,
+	"RENAME":     true,
+	"GRANT":      true,
+	"REVOKE":     true,
+	"INTO":       true,
+	"COPY":       true,
+	"CALL":       true,
+	"EXEC":       true,
+	"EXECUTE":    true,
+	"PREPARE":    true,
+	"LOCK":       true,
+	"ATTACH":     true,
+	"DETACH":     true,
+	"PRAGMA":     true,
+	"VACUUM":     true,
+	"OPTIMIZE":   true,
+	"KILL":       true,
+	"SHUTDOWN":   true,
+	"LOAD":       true,
+	"HANDLER":    true,
+	"DEALLOCATE": true,
+}
+
+// forbiddenSqlClauses are rejected anywhere in a query, they turn a query
+// into a write (SELECT ... INTO) or lock rows (FOR UPDATE, FOR SHARE and the
+// postgres FOR NO KEY UPDATE and FOR KEY SHARE, LOCK IN SHARE MODE of mysql).
+var forbiddenSqlClauses = [][]string{
+	{"INTO"},
+	{"FOR", "UPDATE"},
+	{"FOR", "NO", "KEY", "UPDATE"},
+	{"FOR", "SHARE"},
+	{"FOR", "KEY", "SHARE"},
+	{"LOCK", "IN", "SHARE", "MODE"},
+}
+
+// checkSqlStatement rejects multiple statements and statements other than
+// queries. It is a lexical check and no replacement for a read-only database
+// user or read-only transactions.
+func checkSqlStatement(dialect string, rawSQL string) error {
+	statements := [][]string{{}}
+	for _, token := range sqlTokens(dialect, rawSQL) {
+		if token == ";" {
+			statements = append(statements, []string{})
+			continue
+		}
+		statements[len(statements)-1] = append(statements[len(statements)-1], token)
+	}
+
+	words := []string{}
+	for _, statement := range statements {
+		if len(statement) == 0 {
+			continue
+		}
+		if len(words) > 0 {
+			return ErrSqlMultipleStatements
+		}
+		words = statement
+	}
+
+	for i, word := range words {
+		previous := ""
+		if i > 0 {
+			previous = words[i-1]
+		}
+		if forbiddenSqlKeywords[word] && (i == 0 || previous == "(") {
+			return fmt.Errorf("query guard: %s is not allowed", word)
+		}
+		for _, clause := range forbiddenSqlClauses {
+			if hasSqlPrefix(words[i:], clause) {
+				return fmt.Errorf("query guard: %s is not allowed", strings.Join(clause, " "))
+			}
+		}
+	}
+
+	for _, word := range words {
+		if word == "(" {
+			continue
+		}
+		if !allowedSqlStatements[word] {
+			return fmt.Errorf("query guard: only queries are allowed, got %s", word)
+		}
+		break
+	}
+
+	return nil
+}
+
+// hasSqlPrefix returns true if words start with the words of prefix.
+func hasSqlPrefix(words []string, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if words[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlTokens returns the upper cased words of a statement, ";" and "(" and
+// skips strings, quoted identifiers and comments.
+func sqlTokens(dialect string, rawSQL string) []string {
+	tokens := []string{}
+	runes := []rune(rawSQL)
+	backslashEscapes := dialect == "mysql"
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case isLineComment(dialect, runes, i):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+2 < len(runes) && runes[i+1] == '*' && runes[i+2] == '!' && dialect == "mysql":
+			// mysql executes the content of /*! ... */ comments
+			i += 2
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '\'' || c == '"':
+			i = skipQuoted(runes, i, c, backslashEscapes)
+		case c == '`':
+			i = skipQuoted(runes, i, c, false)
+		case c == '[' && dialect == "mssql":
+			i = skipQuoted(runes, i, ']', false)
+		case c == '$' && dialect == "postgres":
+			if tag := dollarQuoteTag(runes, i); tag != "" {
+				i = skipDollarQuoted(runes, i, tag)
+			}
+		case c == ';' || c == '(':
+			tokens = append(tokens, string(c))
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || runes[i+1] == '$' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			word := strings.ToUpper(string(runes[start : i+1]))
+
+			// postgres escape strings: E'...'
+			if word == "E" && dialect == "postgres" && i+1 < len(runes) && runes[i+1] == '\'' {
+				i = skipQuoted(runes, i+1, '\'', true)
+				continue
+			}
+			tokens = append(tokens, word)
+		}
+	}
+
+	return tokens
+}
+
+// isLineComment returns true if a comment running to the end of the line
+// starts at i. In mysql -- only starts a comment if followed by a space.
+func isLineComment(dialect string, runes []rune, i int) bool {
+	if dialect == "mysql" && runes[i] == '#' {
+		return true
+	}
+
+	if runes[i] != '-' || i+1 >= len(runes) || runes[i+1] != '-' {
+		return false
+	}
+
+	return dialect != "mysql" || i+2 >= len(runes) || unicode.IsSpace(runes[i+2]) || unicode.IsControl(runes[i+2])
+}
+
+// skipQuoted returns the index of the quote closing the string starting at
+// start. Doubled quotes are part of the string.
+func skipQuoted(runes []rune, start int, quote rune, backslashEscapes bool) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch {
+		case backslashEscapes && runes[i] == '\\':
+			i++
+		case runes[i] == quote:
+			if i+1 < len(runes) && runes[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// dollarQuoteTag returns the tag of a postgres dollar quoted string starting
+// at start, e.g. $body$, or "" if there is none.
+func dollarQuoteTag(runes []rune, start int) string {
+	end := start + 1
+	for end < len(runes) && (runes[end] == '_' || unicode.IsLetter(runes[end]) || (end > start+1 && unicode.IsDigit(runes[end]))) {
+		end++
+	}
+	if end < len(runes) && runes[end] == '$' {
+		return string(runes[start : end+1])
+	}
+	return ""
+}
+
+// skipDollarQuoted returns the index of the end of the closing tag of the
+// dollar quoted string starting at start.
+func skipDollarQuoted(runes []rune, start int, tag string) int {
+	tagLen := len([]rune(tag))
+	for i := start + tagLen; i+tagLen <= len(runes); i++ {
+		if string(runes[i:i+tagLen]) == tag {
+			return i + tagLen - 1
+		}
+	}
+	return len(runes)
+}
+
+// queryRows runs a query with the read-only mode and statement timeout of the
+// data source. The returned function releases the rows and transaction.
+func (e *sqlQueryEndpoint) queryRows(ctx context.Context, db *core.DB, guard SqlQueryGuard, rawSQL string) (*core.Rows, func(), error) {
+	useTx := guard.ReadOnly && e.readOnlyMode != SqlReadOnlyCheck
+
+	if guard.StatementTimeout > 0 && e.statementTimeoutFn != nil {
+		rawSQL = e.statementTimeoutFn(rawSQL, guard.StatementTimeout)
+	}
+
+	var timeoutSQL string
+	if guard.StatementTimeout > 0 && e.statementTimeoutSql != nil {
+		timeoutSQL = e.statementTimeoutSql(guard.StatementTimeout)
+		useTx = true
+	}
+
+	if !useTx {
+		rows, err := db.QueryContext(ctx, rawSQL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &core.Rows{Rows: rows, Mapper: db.Mapper}, func() { rows.Close() }, nil
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: guard.ReadOnly && e.readOnlyMode == SqlReadOnlyTransaction})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if timeoutSQL != "" {
+		if _, err := tx.ExecContext(ctx, timeoutSQL); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, rawSQL)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	// queries never need to be committed, rolling back also undoes writes
+	// of drivers without read-only transactions
+	return &core.Rows{Rows: rows, Mapper: db.Mapper}, func() {
+		rows.Close()
+		tx.Rollback()
+	}, nil
+}
@@ -0,0 +1,100 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSqlQueryGuard(t *testing.T) {
+	Convey("Sql query guard", t, func() {
+		Convey("Reads settings from json data", func() {
+			guard := NewSqlQueryGuard(&models.DataSource{JsonData: simplejson.NewFromAny(map[string]interface{}{
+				"readOnly":            true,
+				"statementTimeout":    30,
+				"rejectUnsafeQueries": true,
+				"maxRows":             500,
+			})})
+
+			So(guard.ReadOnly, ShouldBeTrue)
+			So(guard.StatementTimeout, ShouldEqual, 30*time.Second)
+			So(guard.RejectUnsafeQueries, ShouldBeTrue)
+			So(guard.MaxRows, ShouldEqual, 500)
+		})
+
+		Convey("Defaults to the row limit", func() {
+			guard := NewSqlQueryGuard(&models.DataSource{JsonData: simplejson.New()})
+			So(guard.ReadOnly, ShouldBeFalse)
+			So(guard.MaxRows, ShouldEqual, rowLimit)
+		})
+
+		Convey("Accepts queries", func() {
+			queries := []string{
+				"SELECT * FROM metrics WHERE host = 'a; DROP TABLE metrics'",
+				"select value as \"delete\" from metrics;",
+				"WITH t AS (SELECT 1) SELECT * FROM t",
+				"(SELECT 1) UNION (SELECT 2)",
+				"SELECT 1 -- ; DROP TABLE metrics",
+				"SELECT 1 /* ; DROP TABLE metrics */",
+				"SELECT updated_at, last_update FROM metrics",
+				"SELECT create, drop, lock FROM events",
+				"SELECT replace(host, 'a', 'b') FROM metrics",
+			}
+
+			for _, query := range queries {
+				So(checkSqlStatement("postgres", query), ShouldBeNil)
+			}
+
+			So(checkSqlStatement("postgres", "SELECT $body$; DROP TABLE x$body$"), ShouldBeNil)
+			So(checkSqlStatement("mysql", "SELECT 'it\\'s; DROP TABLE x' # ; DELETE"), ShouldBeNil)
+			So(checkSqlStatement("mssql", "SELECT [drop] FROM metrics"), ShouldBeNil)
+			So(checkSqlStatement("clickhouse", "SELECT count() FROM merge(currentDatabase(), '^metrics')"), ShouldBeNil)
+		})
+
+		Convey("Rejects multiple statements", func() {
+			So(checkSqlStatement("postgres", "SELECT 1; SELECT 2"), ShouldEqual, ErrSqlMultipleStatements)
+		})
+
+		Convey("Rejects ddl and dml", func() {
+			queries := []string{
+				"DELETE FROM metrics",
+				"drop table metrics",
+				"SELECT * INTO backup FROM metrics",
+				"WITH d AS (DELETE FROM metrics RETURNING *) SELECT * FROM d",
+				"SELECT * FROM metrics FOR UPDATE",
+				"SELECT * FROM metrics FOR SHARE",
+				"SELECT * FROM metrics FOR NO KEY UPDATE",
+				"SELECT * FROM metrics for key share nowait",
+				"SELECT * FROM metrics FOR UPDATE OF metrics SKIP LOCKED",
+				"SET search_path = x",
+				"SELECT * FROM (DELETE FROM metrics RETURNING *) d",
+				"SELECT 1 INTO OUTFILE '/tmp/x'",
+			}
+
+			for _, query := range queries {
+				So(checkSqlStatement("postgres", query), ShouldNotBeNil)
+			}
+		})
+
+		Convey("Rejects locking reads of mysql", func() {
+			So(checkSqlStatement("mysql", "SELECT * FROM metrics LOCK IN SHARE MODE"), ShouldNotBeNil)
+			So(checkSqlStatement("mysql", "SELECT * FROM metrics /* x */ LOCK  IN\nSHARE MODE"), ShouldNotBeNil)
+			So(checkSqlStatement("mysql", "SELECT * FROM metrics FOR SHARE"), ShouldNotBeNil)
+			So(checkSqlStatement("mysql", "SELECT 'LOCK IN SHARE MODE' AS lock_mode FROM metrics"), ShouldBeNil)
+		})
+
+		Convey("Reads dialect specific syntax like the database", func() {
+			// standard strings have no backslash escapes
+			So(checkSqlStatement("postgres", "SELECT 'a\\'; DROP TABLE x; --'"), ShouldNotBeNil)
+			So(checkSqlStatement("postgres", "SELECT E'a\\'; DROP TABLE x; --'"), ShouldBeNil)
+			// mysql executes versioned comments and needs a space after --
+			So(checkSqlStatement("mysql", "SELECT 1 /*! ; DROP TABLE x */"), ShouldNotBeNil)
+			So(checkSqlStatement("mysql", "SELECT 1--1; DROP TABLE x"), ShouldNotBeNil)
+			So(checkSqlStatement("postgres", "SELECT 1 # ; DROP TABLE x"), ShouldNotBeNil)
+		})
+	})
+}
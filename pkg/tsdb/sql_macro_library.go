@@ -0,0 +1,291 @@
+package tsdb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// maxSqlMacroDepth bounds the expansion of macros that use other macros of the
+// library, and stops macros that reference each other.
+const maxSqlMacroDepth = 10
+
+// sqlMacroLibraryCacheTTL bounds how long a library changed on another
+// instance is used, changes on this instance invalidate the cache right away.
+const sqlMacroLibraryCacheTTL = time.Minute
+
+func init() {
+	bus.AddEventListener(handleSqlMacrosChanged)
+}
+
+// getSqlMacroLibrary returns the org level sql macros by name.
+var getSqlMacroLibrary = func(orgId int64) (map[string]*models.SqlMacro, error) {
+	query := &models.GetSqlMacrosQuery{OrgId: orgId}
+	if err := bus.Dispatch(query); err != nil {
+		if err == bus.ErrHandlerNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	library := make(map[string]*models.SqlMacro, len(query.Result))
+	for _, macro := range query.Result {
+		library[macro.Name] = macro
+	}
+
+	return library, nil
+}
+
+type sqlMacroLibraryCacheItem struct {
+	library map[string]*models.SqlMacro
+	expires time.Time
+}
+
+type sqlMacroLibraryCacheType struct {
+	libraries map[int64]sqlMacroLibraryCacheItem
+	sync.Mutex
+}
+
+var sqlMacroLibraryCache = sqlMacroLibraryCacheType{
+	libraries: make(map[int64]sqlMacroLibraryCacheItem),
+}
+
+// getCachedSqlMacroLibrary returns the sql macro library of the org, loaded at
+// most once per sqlMacroLibraryCacheTTL.
+func getCachedSqlMacroLibrary(orgId int64) (map[string]*models.SqlMacro, error) {
+	sqlMacroLibraryCache.Lock()
+	item, ok := sqlMacroLibraryCache.libraries[orgId]
+	sqlMacroLibraryCache.Unlock()
+
+	if ok && time.Now().Before(item.expires) {
+		return item.library, nil
+	}
+
+	library, err := getSqlMacroLibrary(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlMacroLibraryCache.Lock()
+	sqlMacroLibraryCache.libraries[orgId] = sqlMacroLibraryCacheItem{
+		library: library,
+		expires: time.Now().Add(sqlMacroLibraryCacheTTL),
+	}
+	sqlMacroLibraryCache.Unlock()
+
+	return library, nil
+}
+
+func handleSqlMacrosChanged(evt *events.SqlMacrosChanged) error {
+	sqlMacroLibraryCache.Lock()
+	defer sqlMacroLibraryCache.Unlock()
+
+	delete(sqlMacroLibraryCache.libraries, evt.OrgId)
+	return nil
+}
+
+// expandSqlMacroLibrary replaces the macros of the org sql macro library with
+// the template for the type of the data source. Macros not in the library are
+// left for the data source macro engine.
+func expandSqlMacroLibrary(query *Query, sql string) (string, error) {
+	if query.DataSource == nil || !strings.Contains(sql, "$__") {
+		return sql, nil
+	}
+
+	library, err := getCachedSqlMacroLibrary(query.DataSource.OrgId)
+	if err != nil {
+		return "", err
+	}
+
+	if len(library) == 0 {
+		return sql, nil
+	}
+
+	for depth := 0; depth < maxSqlMacroDepth; depth++ {
+		var macroError error
+		expanded := false
+
+		sql = replaceSqlMacroCalls(sql, func(name string, args []string) (string, bool) {
+			macro, ok := library[name]
+			if !ok {
+				return "", false
+			}
+
+			expanded = true
+			res, err := evaluateSqlMacro(macro, query.DataSource.Type, args)
+			if err != nil && macroError == nil {
+				macroError = err
+			}
+			return res, true
+		})
+
+		if macroError != nil {
+			return "", macroError
+		}
+
+		if !expanded {
+			return sql, nil
+		}
+	}
+
+	return "", fmt.Errorf("sql macro expansion exceeded %d levels", maxSqlMacroDepth)
+}
+
+// replaceSqlMacroCalls calls replace for each $__name(args) in sql and
+// replaces the call with the result if replace returns true. The arguments
+// are split on the commas outside of quotes and parentheses.
+func replaceSqlMacroCalls(sql string, replace func(name string, args []string) (string, bool)) string {
+	var result strings.Builder
+
+	for i := 0; i < len(sql); {
+		start := strings.Index(sql[i:], "$__")
+		if start < 0 {
+			result.WriteString(sql[i:])
+			break
+		}
+		start += i
+
+		nameEnd := start + 3
+		for nameEnd < len(sql) && isSqlMacroNameChar(sql[nameEnd]) {
+			nameEnd++
+		}
+
+		if nameEnd == start+3 || nameEnd >= len(sql) || sql[nameEnd] != '(' {
+			result.WriteString(sql[i:nameEnd])
+			i = nameEnd
+			continue
+		}
+
+		args, end, ok := parseSqlMacroArgs(sql, nameEnd)
+		if !ok {
+			result.WriteString(sql[i:])
+			break
+		}
+
+		result.WriteString(sql[i:start])
+		if res, ok := replace(sql[start+3:nameEnd], args); ok {
+			result.WriteString(res)
+		} else {
+			result.WriteString(sql[start:end])
+		}
+		i = end
+	}
+
+	return result.String()
+}
+
+// parseSqlMacroArgs reads the arguments of the call with the opening
+// parenthesis at open. It returns the trimmed arguments, the index after the
+// closing parenthesis and false if the call is not closed.
+func parseSqlMacroArgs(sql string, open int) ([]string, int, bool) {
+	args := []string{}
+	depth := 0
+	argStart := open + 1
+
+	for i := open + 1; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case '\'', '"', '`':
+			i++
+			for i < len(sql) && sql[i] != c {
+				if sql[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(sql) {
+				return nil, 0, false
+			}
+		case '(', '[':
+			depth++
+		case ']':
+			depth--
+		case ')':
+			if depth == 0 {
+				if last := strings.TrimSpace(sql[argStart:i]); last != "" || len(args) > 0 {
+					args = append(args, last)
+				}
+				return args, i + 1, true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(sql[argStart:i]))
+				argStart = i + 1
+			}
+		}
+	}
+
+	return nil, 0, false
+}
+
+func isSqlMacroNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// evaluateSqlMacro fills the template of the macro for the data source type.
+// ${param} is replaced with the argument as a string literal, ${param:raw}
+// with the argument as is, e.g. for column names.
+func evaluateSqlMacro(macro *models.SqlMacro, dsType string, args []string) (string, error) {
+	template, ok := macro.Templates[dsType]
+	if !ok {
+		return "", fmt.Errorf("macro $__%s has no template for data source type %s", macro.Name, dsType)
+	}
+
+	if len(args) != len(macro.Params) {
+		return "", fmt.Errorf("macro $__%s expects %d arguments, got %d", macro.Name, len(macro.Params), len(args))
+	}
+
+	replacements := make([]string, 0, len(args)*4)
+	for i, param := range macro.Params {
+		replacements = append(replacements,
+			"${"+param+":raw}", args[i],
+			"${"+param+"}", quoteSqlString(dsType, unquoteSqlString(dsType, args[i])),
+		)
+	}
+
+	return strings.NewReplacer(replacements...).Replace(template), nil
+}
+
+// sqlBackslashEscapes returns true for the data source types reading
+// backslash escapes in string literals.
+func sqlBackslashEscapes(dsType string) bool {
+	return dsType == models.DS_MYSQL || dsType == "clickhouse"
+}
+
+// quoteSqlString returns value as a string literal of the data source type.
+func quoteSqlString(dsType string, value string) string {
+	if sqlBackslashEscapes(dsType) {
+		value = strings.Replace(value, `\`, `\\`, -1)
+	}
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// unquoteSqlString returns the value of an argument that is a string literal,
+// other arguments are returned as is.
+func unquoteSqlString(dsType string, arg string) string {
+	if len(arg) < 2 || arg[0] != '\'' || arg[len(arg)-1] != '\'' {
+		return arg
+	}
+
+	var value strings.Builder
+	inner := arg[1 : len(arg)-1]
+	for i := 0; i < len(inner); i++ {
+		switch {
+		case inner[i] == '\\' && sqlBackslashEscapes(dsType) && i+1 < len(inner):
+			i++
+		case inner[i] == '\'' && i+1 < len(inner) && inner[i+1] == '\'':
+			i++
+		case inner[i] == '\'':
+			// not a single literal, e.g. 'a' || 'b'
+			return arg
+		}
+		value.WriteByte(inner[i])
+	}
+
+	return value.String()
+}
@@ -0,0 +1,139 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSqlMacroLibrary(t *testing.T) {
+	Convey("Sql macro library", t, func() {
+		library := map[string]*models.SqlMacro{
+			"tenantFilter": {
+				Name:   "tenantFilter",
+				Params: []string{"column"},
+				Templates: map[string]string{
+					"mysql":    "${column:raw} = 'acme'",
+					"postgres": "${column:raw} = 'acme'::text",
+				},
+			},
+			"tenant": {
+				Name:   "tenant",
+				Params: []string{"name"},
+				Templates: map[string]string{
+					"mysql":    "tenant = ${name}",
+					"postgres": "tenant = ${name}",
+				},
+			},
+			"recent": {
+				Name:   "recent",
+				Params: []string{"column", "tenant"},
+				Templates: map[string]string{
+					"mysql": "$__timeFilter(${column:raw}) AND $__tenantFilter(${tenant:raw})",
+				},
+			},
+			"loop": {
+				Name:      "loop",
+				Templates: map[string]string{"mysql": "$__loop()"},
+			},
+		}
+
+		var requestedOrg int64
+		loads := 0
+		origGetSqlMacroLibrary := getSqlMacroLibrary
+		getSqlMacroLibrary = func(orgId int64) (map[string]*models.SqlMacro, error) {
+			requestedOrg = orgId
+			loads++
+			return library, nil
+		}
+		Reset(func() {
+			getSqlMacroLibrary = origGetSqlMacroLibrary
+			sqlMacroLibraryCache.libraries = make(map[int64]sqlMacroLibraryCacheItem)
+		})
+
+		timeRange := NewFakeTimeRange("5m", "now", time.Now())
+		query := &Query{DataSource: &models.DataSource{OrgId: 3, Type: models.DS_MYSQL}, Model: simplejson.New()}
+
+		Convey("Expands macro with the template of the data source type", func() {
+			sql, err := Interpolate(query, timeRange, "SELECT * FROM t WHERE $__tenantFilter(tenant)")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT * FROM t WHERE tenant = 'acme'")
+			So(requestedOrg, ShouldEqual, 3)
+
+			query.DataSource.Type = models.DS_POSTGRES
+			sql, err = Interpolate(query, timeRange, "SELECT * FROM t WHERE $__tenantFilter(tenant)")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT * FROM t WHERE tenant = 'acme'::text")
+		})
+
+		Convey("Expands nested macros and leaves the data source macros", func() {
+			sql, err := Interpolate(query, timeRange, "SELECT * FROM t WHERE $__recent(created, org)")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT * FROM t WHERE $__timeFilter(created) AND org = 'acme'")
+		})
+
+		Convey("Splits arguments outside of quotes and parentheses", func() {
+			sql, err := Interpolate(query, timeRange, "SELECT * FROM t WHERE $__recent(coalesce(created, updated), concat(a, ')'))")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT * FROM t WHERE $__timeFilter(coalesce(created, updated)) AND concat(a, ')') = 'acme'")
+		})
+
+		Convey("Inserts parameters as string literals", func() {
+			sql, err := Interpolate(query, timeRange, "SELECT * FROM t WHERE $__tenant(acme' OR 1=1 -- )")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT * FROM t WHERE $__tenant(acme' OR 1=1 -- )")
+
+			sql, err = Interpolate(query, timeRange, `SELECT * FROM t WHERE $__tenant('o''brien\\')`)
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `SELECT * FROM t WHERE tenant = 'o''brien\\'`)
+
+			sql, err = Interpolate(query, timeRange, `SELECT * FROM t WHERE $__tenant(a\' OR 1=1)`)
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `SELECT * FROM t WHERE $__tenant(a\' OR 1=1)`)
+
+			sql, err = Interpolate(query, timeRange, "SELECT * FROM t WHERE $__tenant(x OR 1=1)")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT * FROM t WHERE tenant = 'x OR 1=1'")
+
+			sql, err = Interpolate(query, timeRange, `SELECT * FROM t WHERE $__tenant(a\b)`)
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `SELECT * FROM t WHERE tenant = 'a\\b'`)
+		})
+
+		Convey("Caches the library until it changes", func() {
+			for i := 0; i < 3; i++ {
+				_, err := Interpolate(query, timeRange, "SELECT $__tenantFilter(tenant)")
+				So(err, ShouldBeNil)
+			}
+			So(loads, ShouldEqual, 1)
+
+			So(handleSqlMacrosChanged(&events.SqlMacrosChanged{OrgId: 3}), ShouldBeNil)
+			_, err := Interpolate(query, timeRange, "SELECT $__tenantFilter(tenant)")
+			So(err, ShouldBeNil)
+			So(loads, ShouldEqual, 2)
+		})
+
+		Convey("Returns error for wrong number of arguments", func() {
+			_, err := Interpolate(query, timeRange, "SELECT $__tenantFilter()")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "macro $__tenantFilter expects 1 arguments, got 0")
+		})
+
+		Convey("Returns error for missing template", func() {
+			query.DataSource.Type = models.DS_MSSQL
+			_, err := Interpolate(query, timeRange, "SELECT $__tenantFilter(tenant)")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "macro $__tenantFilter has no template for data source type mssql")
+		})
+
+		Convey("Returns error for recursive macros", func() {
+			_, err := Interpolate(query, timeRange, "SELECT $__loop()")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
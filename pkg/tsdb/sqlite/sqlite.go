@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-xorm/core"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/grafana/grafana/pkg/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+)
+
+func init() {
+	tsdb.RegisterTsdbQueryEndpoint("sqlite", newSqliteQueryEndpoint)
+}
+
+func newSqliteQueryEndpoint(datasource *models.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+	logger := log.New("tsdb.sqlite")
+
+	cnnstr, err := generateConnectionString(datasource)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("getEngine", "connection", cnnstr)
+
+	config := tsdb.SqlQueryEndpointConfiguration{
+		DriverName:        "sqlite3",
+		ConnectionString:  cnnstr,
+		Datasource:        datasource,
+		TimeColumnNames:   []string{"time", "time_sec"},
+		MetricColumnTypes: []string{"TEXT", "CHAR", "VARCHAR", "NCHAR", "NVARCHAR", "CLOB"},
+	}
+
+	rowTransformer := sqliteRowTransformer{
+		log: logger,
+	}
+
+	return tsdb.NewSqlQueryEndpoint(&config, &rowTransformer, newSqliteMacroEngine(), logger)
+}
+
+// generateConnectionString opens the database file configured as database,
+// or url for data sources without database. The file must be in one of the
+// allowed_paths of the [sqlite] section, it is opened read-only unless the
+// data source is not read-only and allow_write is on.
+func generateConnectionString(datasource *models.DataSource) (string, error) {
+	path := datasource.Database
+	if path == "" {
+		path = datasource.Url
+	}
+	path = strings.TrimPrefix(path, "file:")
+
+	if path == "" {
+		return "", fmt.Errorf("Missing path to the sqlite database file")
+	}
+
+	path, err := resolveDatabasePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	if setting.SqliteAllowWrite && !tsdb.IsReadOnlyDataSource(datasource) {
+		params.Set("mode", "rw")
+	} else {
+		params.Set("mode", "ro")
+	}
+	params.Set("_loc", "UTC")
+	if timeout := datasource.JsonData.Get("busyTimeout").MustInt(0); timeout > 0 {
+		params.Set("_busy_timeout", fmt.Sprintf("%d", timeout))
+	}
+
+	// the path is escaped, ? and # in file names must not end the path of the uri
+	return fmt.Sprintf("file:%s?%s", (&url.URL{Path: path}).EscapedPath(), params.Encode()), nil
+}
+
+// resolveDatabasePath returns the path of the database file with symlinks
+// resolved, if it is in one of the allowed paths and is not the database of
+// Grafana.
+func resolveDatabasePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("The path to the sqlite database file must be absolute")
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("Failed to open sqlite database file %s", path)
+	}
+
+	grafanaDb := filepath.Clean(setting.GrafanaDatabasePath)
+	if resolvedDb, err := filepath.EvalSymlinks(grafanaDb); err == nil {
+		grafanaDb = resolvedDb
+	}
+	// also the -wal, -shm and -journal files of the grafana database
+	if filepath.Dir(resolved) == filepath.Dir(grafanaDb) && strings.HasPrefix(filepath.Base(resolved), filepath.Base(grafanaDb)) {
+		return "", fmt.Errorf("The database of Grafana can not be used as data source")
+	}
+
+	for _, allowedPath := range setting.SqliteAllowedPaths {
+		allowedDir, err := filepath.EvalSymlinks(filepath.Clean(allowedPath))
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(allowedDir, resolved)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+
+	return "", fmt.Errorf("The sqlite database file %s is not in the allowed_paths of the [sqlite] section", path)
+}
+
+type sqliteRowTransformer struct {
+	log log.Logger
+}
+
+func (t *sqliteRowTransformer) Transform(columnTypes []*sql.ColumnType, rows *core.Rows) (tsdb.RowValues, error) {
+	values := make([]interface{}, len(columnTypes))
+	valuePtrs := make([]interface{}, len(columnTypes))
+
+	for i := range columnTypes {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	// sqlite values are dynamically typed, text values are read as bytes
+	// unless the column is declared as a date or time.
+	for i := range columnTypes {
+		if value, ok := values[i].([]byte); ok {
+			values[i] = string(value)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,290 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLite(t *testing.T) {
+	Convey("SQLite", t, func() {
+		dir, err := ioutil.TempDir("", "grafana-sqlite-ds")
+		So(err, ShouldBeNil)
+
+		path := filepath.Join(dir, "telemetry.db")
+		x, err := xorm.NewEngine("sqlite3", path)
+		So(err, ShouldBeNil)
+
+		_, err = x.Exec(`CREATE TABLE metrics (ts DATETIME, epoch INTEGER, host TEXT, value REAL)`)
+		So(err, ShouldBeNil)
+
+		fromStart := time.Date(2018, 3, 15, 13, 0, 0, 0, time.UTC)
+		for i := 0; i < 4; i++ {
+			ts := fromStart.Add(time.Duration(i) * 5 * time.Minute)
+			_, err = x.Exec(`INSERT INTO metrics VALUES (?, ?, ?, ?)`, ts.Format("2006-01-02 15:04:05"), ts.Unix(), "server1", float64(i)*1.5)
+			So(err, ShouldBeNil)
+		}
+
+		_, err = x.Exec(`CREATE TABLE readings (ts INTEGER, host TEXT, dc TEXT, cpu REAL, mem REAL)`)
+		So(err, ShouldBeNil)
+
+		for _, r := range []struct {
+			minute int
+			host   string
+			cpu    float64
+			mem    float64
+		}{{0, "a", 1, 10}, {0, "b", 2, 20}, {5, "a", 3, 30}, {15, "a", 5, 50}} {
+			ts := fromStart.Add(time.Duration(r.minute) * time.Minute)
+			_, err = x.Exec(`INSERT INTO readings VALUES (?, ?, ?, ?, ?)`, ts.Unix(), r.host, "east", r.cpu, r.mem)
+			So(err, ShouldBeNil)
+		}
+		So(x.Close(), ShouldBeNil)
+
+		setting.SqliteAllowedPaths = []string{dir}
+		setting.GrafanaDatabasePath = filepath.Join(dir, "grafana.db")
+
+		datasource := &models.DataSource{
+			Id:       time.Now().UnixNano(),
+			Database: path,
+			JsonData: simplejson.New(),
+		}
+
+		endpoint, err := newSqliteQueryEndpoint(datasource)
+		So(err, ShouldBeNil)
+
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+
+		timeRange := &tsdb.TimeRange{
+			From: fmt.Sprintf("%v", fromStart.Unix()*1000),
+			To:   fmt.Sprintf("%v", fromStart.Add(20*time.Minute).Unix()*1000),
+		}
+
+		query := func(model map[string]interface{}) *tsdb.QueryResult {
+			resp, err := endpoint.Query(context.Background(), datasource, &tsdb.TsdbQuery{
+				TimeRange: timeRange,
+				Queries: []*tsdb.Query{
+					{
+						DataSource: datasource,
+						Model:      simplejson.NewFromAny(model),
+						RefId:      "A",
+					},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			queryResult := resp.Results["A"]
+			So(queryResult.Error, ShouldBeNil)
+			return queryResult
+		}
+
+		Convey("When generating the connection string", func() {
+			resolvedDir, err := filepath.EvalSymlinks(dir)
+			So(err, ShouldBeNil)
+
+			Convey("Should open the database read-only unless writes are allowed", func() {
+				cnnstr, err := generateConnectionString(&models.DataSource{Database: path, JsonData: simplejson.New()})
+				So(err, ShouldBeNil)
+				So(cnnstr, ShouldEqual, "file:"+filepath.Join(resolvedDir, "telemetry.db")+"?_loc=UTC&mode=ro")
+
+				setting.SqliteAllowWrite = true
+				defer func() { setting.SqliteAllowWrite = false }()
+
+				cnnstr, err = generateConnectionString(&models.DataSource{Database: path, JsonData: simplejson.New()})
+				So(err, ShouldBeNil)
+				So(cnnstr, ShouldEndWith, "mode=rw")
+
+				cnnstr, err = generateConnectionString(&models.DataSource{Database: path, JsonData: simplejson.NewFromAny(map[string]interface{}{"readOnly": true})})
+				So(err, ShouldBeNil)
+				So(cnnstr, ShouldEndWith, "mode=ro")
+			})
+
+			Convey("Should escape the path", func() {
+				escaped := filepath.Join(dir, "a?mode=rw#.db")
+				So(ioutil.WriteFile(escaped, nil, 0644), ShouldBeNil)
+
+				cnnstr, err := generateConnectionString(&models.DataSource{Database: escaped, JsonData: simplejson.New()})
+				So(err, ShouldBeNil)
+				So(cnnstr, ShouldEqual, "file:"+filepath.Join(resolvedDir, "a%3Fmode=rw%23.db")+"?_loc=UTC&mode=ro")
+			})
+
+			Convey("Should reject files outside of the allowed paths", func() {
+				_, err := generateConnectionString(&models.DataSource{Database: filepath.Join(dir, "..", "other.db"), JsonData: simplejson.New()})
+				So(err, ShouldNotBeNil)
+
+				setting.SqliteAllowedPaths = []string{}
+				_, err = generateConnectionString(&models.DataSource{Database: path, JsonData: simplejson.New()})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Should reject the database of grafana", func() {
+				So(ioutil.WriteFile(setting.GrafanaDatabasePath, nil, 0644), ShouldBeNil)
+
+				_, err := generateConnectionString(&models.DataSource{Database: setting.GrafanaDatabasePath, JsonData: simplejson.New()})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Should reject symlinks out of the allowed paths", func() {
+				other, err := ioutil.TempDir("", "grafana-sqlite-other")
+				So(err, ShouldBeNil)
+				defer os.RemoveAll(other)
+				So(ioutil.WriteFile(filepath.Join(other, "other.db"), nil, 0644), ShouldBeNil)
+				So(os.Symlink(filepath.Join(other, "other.db"), filepath.Join(dir, "link.db")), ShouldBeNil)
+
+				_, err = generateConnectionString(&models.DataSource{Database: filepath.Join(dir, "link.db"), JsonData: simplejson.New()})
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When checking the health of the data source", func() {
+			result, err := endpoint.(tsdb.TsdbHealthChecker).CheckHealth(context.Background(), datasource)
+			So(err, ShouldBeNil)
+			So(result.Status, ShouldEqual, tsdb.HealthStatusOk)
+		})
+
+		Convey("When doing a time series query using the time macros", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT $__timeGroupAlias(ts, '5m'), host AS metric, avg(value) AS value FROM metrics WHERE $__timeFilter(ts) GROUP BY 1, 2 ORDER BY 1",
+				"format": "time_series",
+			})
+
+			So(len(queryResult.Series), ShouldEqual, 1)
+			So(queryResult.Series[0].Name, ShouldEqual, "server1")
+			points := queryResult.Series[0].Points
+			So(len(points), ShouldEqual, 4)
+			So(points[0][1].Float64, ShouldEqual, float64(fromStart.Unix()*1000))
+			So(points[3][0].Float64, ShouldEqual, 4.5)
+		})
+
+		Convey("When doing a time series query using the time macros on an epoch column", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT $__timeGroupAlias(epoch, '5m'), avg(value) AS value FROM metrics WHERE $__timeFilter(epoch) GROUP BY 1 ORDER BY 1",
+				"format": "time_series",
+			})
+
+			So(len(queryResult.Series), ShouldEqual, 1)
+			points := queryResult.Series[0].Points
+			So(len(points), ShouldEqual, 4)
+			So(points[0][1].Float64, ShouldEqual, float64(fromStart.Unix()*1000))
+			So(points[3][0].Float64, ShouldEqual, 4.5)
+		})
+
+		Convey("When doing a time series query with fill", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT $__unixEpochGroupAlias(epoch, '150s', 0), avg(value) AS value FROM metrics WHERE $__unixEpochFilter(epoch) GROUP BY 1 ORDER BY 1",
+				"format": "time_series",
+			})
+
+			So(len(queryResult.Series), ShouldEqual, 1)
+			points := queryResult.Series[0].Points
+			So(len(points), ShouldEqual, 8)
+			So(points[1][0].Float64, ShouldEqual, 0)
+			So(points[2][0].Float64, ShouldEqual, 1.5)
+		})
+
+		Convey("When doing a table query with a datetime column", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT ts AS time, host, value FROM metrics ORDER BY ts LIMIT 1",
+				"format": "table",
+			})
+
+			So(len(queryResult.Tables), ShouldEqual, 1)
+			row := queryResult.Tables[0].Rows[0]
+			So(row[0], ShouldEqual, float64(fromStart.Unix()*1000))
+			So(row[1], ShouldEqual, "server1")
+			So(row[2], ShouldEqual, 0)
+		})
+
+		Convey("When doing a time series query with several text columns", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT ts AS time, host, dc, cpu FROM readings ORDER BY ts, host",
+				"format": "time_series",
+			})
+
+			So(len(queryResult.Series), ShouldEqual, 2)
+			So(queryResult.Series[0].Name, ShouldEqual, "cpu{host=a, dc=east}")
+			So(queryResult.Series[0].Tags, ShouldResemble, map[string]string{"host": "a", "dc": "east"})
+			So(len(queryResult.Series[0].Points), ShouldEqual, 3)
+			So(queryResult.Series[1].Name, ShouldEqual, "cpu{host=b, dc=east}")
+			So(queryResult.Series[1].Tags["host"], ShouldEqual, "b")
+		})
+
+		Convey("When doing a time series query with fill per column", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql":       "SELECT ts AS time, cpu, mem FROM readings WHERE host = 'a' ORDER BY ts",
+				"format":       "time_series",
+				"fillInterval": 300,
+				"columnFill":   map[string]interface{}{"cpu": 0, "mem": "previous"},
+			})
+
+			So(len(queryResult.Series), ShouldEqual, 2)
+			cpu := queryResult.Series[0].Points
+			So(len(cpu), ShouldEqual, 4)
+			So(cpu[2][0].Float64, ShouldEqual, 0)
+			So(cpu[2][1].Float64, ShouldEqual, float64(fromStart.Add(10*time.Minute).Unix()*1000))
+			mem := queryResult.Series[1].Points
+			So(len(mem), ShouldEqual, 4)
+			So(mem[2][0].Float64, ShouldEqual, 30)
+		})
+
+		Convey("When doing a pivoted table query", func() {
+			queryResult := query(map[string]interface{}{
+				"rawSql": "SELECT ts AS time, host, cpu FROM readings ORDER BY ts, host",
+				"format": "table",
+				"pivot":  true,
+			})
+
+			So(len(queryResult.Tables), ShouldEqual, 1)
+			table := queryResult.Tables[0]
+			So(table.Columns, ShouldResemble, []tsdb.TableColumn{{Text: "time"}, {Text: "a"}, {Text: "b"}})
+			So(len(table.Rows), ShouldEqual, 3)
+			So(table.Rows[0], ShouldResemble, tsdb.RowValues{float64(fromStart.Unix() * 1000), 1.0, 2.0})
+			So(table.Rows[1][2], ShouldBeNil)
+			So(queryResult.Meta.Get("rowCount").MustInt(), ShouldEqual, 3)
+		})
+
+		Convey("Given a data source with query guard", func() {
+			queryWithError := func(rawSQL string) error {
+				resp, err := endpoint.Query(context.Background(), datasource, &tsdb.TsdbQuery{
+					TimeRange: timeRange,
+					Queries: []*tsdb.Query{
+						{
+							DataSource: datasource,
+							Model:      simplejson.NewFromAny(map[string]interface{}{"rawSql": rawSQL, "format": "table"}),
+							RefId:      "A",
+						},
+					},
+				})
+				So(err, ShouldBeNil)
+				return resp.Results["A"].Error
+			}
+
+			Convey("Unsafe queries are rejected", func() {
+				datasource.JsonData.Set("rejectUnsafeQueries", true)
+
+				err := queryWithError("SELECT 1; DELETE FROM metrics")
+				So(err, ShouldEqual, tsdb.ErrSqlMultipleStatements)
+			})
+
+			Convey("Rows are capped", func() {
+				datasource.JsonData.Set("maxRows", 2)
+
+				err := queryWithError("SELECT ts AS time, value FROM metrics")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "query row limit exceeded, limit 2")
+			})
+		})
+	})
+}
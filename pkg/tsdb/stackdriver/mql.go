@@ -0,0 +1,185 @@
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/null"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/tsdb"
+	"github.com/opentracing/opentracing-go"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const mqlTimeFormat = "2006/01/02 15:04:05"
+
+// buildMQLQuery restricts the MQL query to the time range of the panel and
+// sets the graph period from the alignment period, unless it is disabled.
+func buildMQLQuery(mql string, startTime time.Time, endTime time.Time, model *simplejson.Json, intervalMs int64) string {
+	query := mql
+	if graphPeriod := model.Get("graphPeriod").MustString("grafana-auto"); graphPeriod != "disabled" {
+		period := calcAlignmentPeriod(graphPeriod, intervalMs, int(endTime.Sub(startTime).Seconds()))
+		query += " | graph_period " + strings.TrimPrefix(period, "+")
+	}
+
+	return query + fmt.Sprintf(" | within d'%s', d'%s'", startTime.UTC().Format(mqlTimeFormat), endTime.UTC().Format(mqlTimeFormat))
+}
+
+func (e *StackdriverExecutor) executeMQLQuery(ctx context.Context, query *StackdriverQuery, tsdbQuery *tsdb.TsdbQuery) *tsdb.QueryResult {
+	queryResult := &tsdb.QueryResult{Meta: simplejson.New(), RefId: query.RefID}
+	queryResult.Meta.Set("rawQuery", query.MQL)
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "stackdriver mql query")
+	span.SetTag("target", query.MQL)
+	span.SetTag("from", tsdbQuery.TimeRange.From)
+	span.SetTag("until", tsdbQuery.TimeRange.To)
+	span.SetTag("datasource_id", e.dsInfo.Id)
+	span.SetTag("org_id", e.dsInfo.OrgId)
+
+	defer span.Finish()
+
+	pageToken := ""
+	for {
+		body := map[string]string{"query": query.MQL}
+		if pageToken != "" {
+			body["pageToken"] = pageToken
+		}
+		buf, err := json.Marshal(body)
+		if err != nil {
+			queryResult.Error = err
+			return queryResult
+		}
+
+		req, err := e.createRequest(ctx, e.dsInfo, e.projectName(query), "timeSeries:query", bytes.NewReader(buf))
+		if err != nil {
+			queryResult.Error = err
+			return queryResult
+		}
+
+		opentracing.GlobalTracer().Inject(
+			span.Context(),
+			opentracing.HTTPHeaders,
+			opentracing.HTTPHeadersCarrier(req.Header))
+
+		res, err := ctxhttp.Do(ctx, e.httpClient, req)
+		if err != nil {
+			queryResult.Error = err
+			return queryResult
+		}
+
+		var data StackdriverMQLResponse
+		if err := decodeResponse(res, &data); err != nil {
+			queryResult.Error = err
+			return queryResult
+		}
+
+		parseMQLResponse(queryResult, data, query)
+
+		if data.NextPageToken == "" {
+			break
+		}
+		pageToken = data.NextPageToken
+	}
+
+	return queryResult
+}
+
+// parseMQLResponse returns a series for every point of the time series of the
+// response. The labels of the time series are used as tags and alias patterns.
+func parseMQLResponse(queryRes *tsdb.QueryResult, data StackdriverMQLResponse, query *StackdriverQuery) {
+	descriptor := data.TimeSeriesDescriptor
+
+	for _, series := range data.TimeSeriesData {
+		labels := make(map[string]string)
+		metricLabels := make(map[string]string)
+		resourceLabels := make(map[string]string)
+		labelValues := make([]string, 0)
+
+		for i, labelDescriptor := range descriptor.LabelDescriptors {
+			if i >= len(series.LabelValues) {
+				break
+			}
+
+			value := series.LabelValues[i].String()
+			labels[labelDescriptor.Key] = value
+			labelValues = append(labelValues, value)
+			if strings.HasPrefix(labelDescriptor.Key, "metric.") {
+				metricLabels[strings.TrimPrefix(labelDescriptor.Key, "metric.")] = value
+			} else if strings.HasPrefix(labelDescriptor.Key, "resource.") {
+				resourceLabels[strings.TrimPrefix(labelDescriptor.Key, "resource.")] = value
+			}
+		}
+
+		for j, pointDescriptor := range descriptor.PointDescriptors {
+			defaultName := strings.Join(labelValues, " ")
+			if len(descriptor.PointDescriptors) > 1 || defaultName == "" {
+				defaultName = strings.TrimSpace(defaultName + " " + pointDescriptor.Key)
+			}
+
+			additionalLabels := map[string]string{"point": pointDescriptor.Key}
+			for key, value := range labels {
+				additionalLabels[key] = value
+			}
+			for key, value := range query.AliasLabels {
+				additionalLabels[key] = value
+			}
+
+			timeSeries := &tsdb.TimeSeries{
+				Name:   formatLegendKeys("", defaultName, "", metricLabels, resourceLabels, additionalLabels, query),
+				Tags:   labels,
+				Points: make([]tsdb.TimePoint, 0),
+			}
+
+			for _, point := range series.PointData {
+				if j >= len(point.Values) {
+					continue
+				}
+				timeSeries.Points = append(timeSeries.Points, tsdb.NewTimePoint(point.Values[j].Float(), float64(point.TimeInterval.EndTime.Unix())*1000))
+			}
+
+			sort.Slice(timeSeries.Points, func(a, b int) bool {
+				return timeSeries.Points[a][1].Float64 < timeSeries.Points[b][1].Float64
+			})
+
+			queryRes.Series = append(queryRes.Series, timeSeries)
+		}
+	}
+}
+
+func (v StackdriverMQLValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.Int64Value != nil:
+		return *v.Int64Value
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'f', -1, 64)
+	}
+	return ""
+}
+
+// Float returns the numeric value of a point, null for values that are not numeric.
+func (v StackdriverMQLValue) Float() null.Float {
+	switch {
+	case v.DoubleValue != nil:
+		return null.FloatFrom(*v.DoubleValue)
+	case v.Int64Value != nil:
+		if value, err := strconv.ParseFloat(*v.Int64Value, 64); err == nil {
+			return null.FloatFrom(value)
+		}
+	case v.BoolValue != nil:
+		if *v.BoolValue {
+			return null.FloatFrom(1)
+		}
+		return null.FloatFrom(0)
+	}
+	return null.FloatFromPtr(nil)
+}
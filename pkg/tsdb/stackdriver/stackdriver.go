@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/http"
@@ -38,8 +39,26 @@ var (
 const (
 	gceAuthentication string = "gce"
 	jwtAuthentication string = "jwt"
+
+	metricQueryType string = "metrics"
+	sloQueryType    string = "slo"
+	mqlQueryType    string = "mql"
+
+	rawFilterMode string = "raw"
+
+	// projectPlaceholder is replaced by the default project of the data
+	// source in the filter of SLO queries without a project
+	projectPlaceholder string = "$__default_project"
 )
 
+var sloSelectors = map[string]bool{
+	"select_slo_health":          true,
+	"select_slo_compliance":      true,
+	"select_slo_budget":          true,
+	"select_slo_budget_fraction": true,
+	"select_slo_burn_rate":       true,
+}
+
 // StackdriverExecutor executes queries for the Stackdriver datasource
 type StackdriverExecutor struct {
 	httpClient *http.Client
@@ -109,6 +128,11 @@ func (e *StackdriverExecutor) executeTimeSeriesQuery(ctx context.Context, tsdbQu
 	}
 
 	for _, query := range queries {
+		if query.QueryType == mqlQueryType {
+			result.Results[query.RefID] = e.executeMQLQuery(ctx, query, tsdbQuery)
+			continue
+		}
+
 		queryRes, resp, err := e.executeQuery(ctx, query, tsdbQuery)
 		if err != nil {
 			return nil, err
@@ -141,15 +165,67 @@ func (e *StackdriverExecutor) buildQueries(tsdbQuery *tsdb.TsdbQuery) ([]*Stackd
 	for _, query := range tsdbQuery.Queries {
 		var target string
 
-		metricType := query.Model.Get("metricType").MustString()
-		filterParts := query.Model.Get("filters").MustArray()
+		queryType := query.Model.Get("queryType").MustString(metricQueryType)
+		sq := &StackdriverQuery{
+			RefID:       query.RefId,
+			QueryType:   queryType,
+			ProjectName: query.Model.Get("projectName").MustString(),
+			AliasBy:     query.Model.Get("aliasBy").MustString(),
+			GroupBys:    make([]string, 0),
+		}
+
+		if queryType == mqlQueryType {
+			mql := strings.TrimSpace(query.Model.Get("mql").MustString())
+			if mql == "" {
+				return nil, fmt.Errorf("Query %s: MQL query is empty", query.RefId)
+			}
+			sq.MQL = buildMQLQuery(mql, startTime, endTime, query.Model, query.IntervalMs)
+			stackdriverQueries = append(stackdriverQueries, sq)
+			continue
+		}
 
 		params := url.Values{}
 		params.Add("interval.startTime", startTime.UTC().Format(time.RFC3339))
 		params.Add("interval.endTime", endTime.UTC().Format(time.RFC3339))
-		params.Add("filter", buildFilterString(metricType, filterParts))
-		params.Add("view", query.Model.Get("view").MustString("FULL"))
-		setAggParams(&params, query, durationSeconds)
+
+		if queryType == sloQueryType {
+			sloQuery := query.Model.Get("sloQuery")
+			filter, err := buildSLOFilter(sloQuery, sq)
+			if err != nil {
+				return nil, fmt.Errorf("Query %s: %v", query.RefId, err)
+			}
+			params.Add("filter", filter)
+			params.Add("view", "FULL")
+
+			// the health of a SLO is a ratio, the other selectors are
+			// cumulative values that must not be averaged
+			perSeriesAligner := "ALIGN_NEXT_OLDER"
+			if sloQuery.Get("selectorName").MustString("select_slo_health") == "select_slo_health" {
+				perSeriesAligner = "ALIGN_MEAN"
+			}
+			aggModel := simplejson.NewFromAny(map[string]interface{}{
+				"primaryAggregation": "REDUCE_NONE",
+				"perSeriesAligner":   perSeriesAligner,
+				"alignmentPeriod":    sloQuery.Get("alignmentPeriod").MustString(),
+			})
+			setAggParams(&params, aggModel, query.IntervalMs, durationSeconds)
+
+			if aliasBy := sloQuery.Get("aliasBy").MustString(); aliasBy != "" {
+				sq.AliasBy = aliasBy
+			}
+		} else {
+			filter, err := buildQueryFilter(query.Model)
+			if err != nil {
+				return nil, fmt.Errorf("Query %s: %v", query.RefId, err)
+			}
+			params.Add("filter", filter)
+			params.Add("view", query.Model.Get("view").MustString("FULL"))
+			setAggParams(&params, query.Model, query.IntervalMs, durationSeconds)
+
+			for _, groupBy := range query.Model.Get("groupBys").MustArray() {
+				sq.GroupBys = append(sq.GroupBys, groupBy.(string))
+			}
+		}
 
 		target = params.Encode()
 
@@ -157,21 +233,9 @@ func (e *StackdriverExecutor) buildQueries(tsdbQuery *tsdb.TsdbQuery) ([]*Stackd
 			slog.Debug("Stackdriver request", "params", params)
 		}
 
-		groupBys := query.Model.Get("groupBys").MustArray()
-		groupBysAsStrings := make([]string, 0)
-		for _, groupBy := range groupBys {
-			groupBysAsStrings = append(groupBysAsStrings, groupBy.(string))
-		}
-
-		aliasBy := query.Model.Get("aliasBy").MustString()
-
-		stackdriverQueries = append(stackdriverQueries, &StackdriverQuery{
-			Target:   target,
-			Params:   params,
-			RefID:    query.RefId,
-			GroupBys: groupBysAsStrings,
-			AliasBy:  aliasBy,
-		})
+		sq.Target = target
+		sq.Params = params
+		stackdriverQueries = append(stackdriverQueries, sq)
 	}
 
 	return stackdriverQueries, nil
@@ -209,6 +273,59 @@ func interpolateFilterWildcards(value string) string {
 	return value
 }
 
+// buildQueryFilter returns the filter of a metric query. In raw filter mode the
+// filter is sent as written in the query editor.
+func buildQueryFilter(model *simplejson.Json) (string, error) {
+	if model.Get("filterMode").MustString() == rawFilterMode {
+		filter := strings.TrimSpace(model.Get("rawFilter").MustString())
+		if filter == "" {
+			return "", errors.New("raw filter is empty")
+		}
+		return filter, nil
+	}
+
+	return buildFilterString(model.Get("metricType").MustString(), model.Get("filters").MustArray()), nil
+}
+
+// buildSLOFilter returns the time series selector of a SLO query and sets the
+// alias patterns of the query.
+func buildSLOFilter(sloQuery *simplejson.Json, query *StackdriverQuery) (string, error) {
+	serviceID := sloQuery.Get("serviceId").MustString()
+	sloID := sloQuery.Get("sloId").MustString()
+	if serviceID == "" || sloID == "" {
+		return "", errors.New("SLO query requires a service and a SLO")
+	}
+
+	selectorName := sloQuery.Get("selectorName").MustString("select_slo_health")
+	if !sloSelectors[selectorName] {
+		return "", fmt.Errorf("unknown SLO selector %s", selectorName)
+	}
+
+	if projectName := sloQuery.Get("projectName").MustString(); projectName != "" {
+		query.ProjectName = projectName
+	}
+
+	query.AliasLabels = map[string]string{
+		"project":  query.ProjectName,
+		"service":  serviceID,
+		"slo":      sloID,
+		"selector": selectorName,
+	}
+
+	name := fmt.Sprintf("projects/%s/services/%s/serviceLevelObjectives/%s", query.ProjectName, serviceID, sloID)
+	if query.ProjectName == "" {
+		// the project is added when the request is created
+		name = fmt.Sprintf("projects/%s/services/%s/serviceLevelObjectives/%s", projectPlaceholder, serviceID, sloID)
+	}
+
+	if selectorName == "select_slo_burn_rate" {
+		lookbackPeriod := sloQuery.Get("lookbackPeriod").MustString("3600s")
+		return fmt.Sprintf(`%s("%s", "%s")`, selectorName, name, lookbackPeriod), nil
+	}
+
+	return fmt.Sprintf(`%s("%s")`, selectorName, name), nil
+}
+
 func buildFilterString(metricType string, filterParts []interface{}) string {
 	filterString := ""
 	for i, part := range filterParts {
@@ -232,10 +349,10 @@ func buildFilterString(metricType string, filterParts []interface{}) string {
 	return strings.Trim(fmt.Sprintf(`metric.type="%s" %s`, metricType, filterString), " ")
 }
 
-func setAggParams(params *url.Values, query *tsdb.Query, durationSeconds int) {
-	primaryAggregation := query.Model.Get("primaryAggregation").MustString()
-	perSeriesAligner := query.Model.Get("perSeriesAligner").MustString()
-	alignmentPeriod := query.Model.Get("alignmentPeriod").MustString()
+func setAggParams(params *url.Values, model *simplejson.Json, intervalMs int64, durationSeconds int) {
+	primaryAggregation := model.Get("primaryAggregation").MustString()
+	perSeriesAligner := model.Get("perSeriesAligner").MustString()
+	alignmentPeriod := calcAlignmentPeriod(model.Get("alignmentPeriod").MustString(), intervalMs, durationSeconds)
 
 	if primaryAggregation == "" {
 		primaryAggregation = "REDUCE_NONE"
@@ -245,8 +362,21 @@ func setAggParams(params *url.Values, query *tsdb.Query, durationSeconds int) {
 		perSeriesAligner = "ALIGN_MEAN"
 	}
 
+	params.Add("aggregation.crossSeriesReducer", primaryAggregation)
+	params.Add("aggregation.perSeriesAligner", perSeriesAligner)
+	params.Add("aggregation.alignmentPeriod", alignmentPeriod)
+
+	groupBys := model.Get("groupBys").MustArray()
+	if len(groupBys) > 0 {
+		for i := 0; i < len(groupBys); i++ {
+			params.Add("aggregation.groupByFields", groupBys[i].(string))
+		}
+	}
+}
+
+func calcAlignmentPeriod(alignmentPeriod string, intervalMs int64, durationSeconds int) string {
 	if alignmentPeriod == "grafana-auto" || alignmentPeriod == "" {
-		alignmentPeriodValue := int(math.Max(float64(query.IntervalMs)/1000, 60.0))
+		alignmentPeriodValue := int(math.Max(float64(intervalMs)/1000, 60.0))
 		alignmentPeriod = "+" + strconv.Itoa(alignmentPeriodValue) + "s"
 	}
 
@@ -267,28 +397,33 @@ func setAggParams(params *url.Values, query *tsdb.Query, durationSeconds int) {
 		alignmentPeriod = "+3600s"
 	}
 
-	params.Add("aggregation.crossSeriesReducer", primaryAggregation)
-	params.Add("aggregation.perSeriesAligner", perSeriesAligner)
-	params.Add("aggregation.alignmentPeriod", alignmentPeriod)
-
-	groupBys := query.Model.Get("groupBys").MustArray()
-	if len(groupBys) > 0 {
-		for i := 0; i < len(groupBys); i++ {
-			params.Add("aggregation.groupByFields", groupBys[i].(string))
-		}
-	}
+	return alignmentPeriod
 }
 
 func (e *StackdriverExecutor) executeQuery(ctx context.Context, query *StackdriverQuery, tsdbQuery *tsdb.TsdbQuery) (*tsdb.QueryResult, StackdriverResponse, error) {
 	queryResult := &tsdb.QueryResult{Meta: simplejson.New(), RefId: query.RefID}
 
-	req, err := e.createRequest(ctx, e.dsInfo)
+	projectName := e.projectName(query)
+	if query.AliasLabels != nil && query.AliasLabels["project"] == "" {
+		query.AliasLabels["project"] = projectName
+	}
+
+	req, err := e.createRequest(ctx, e.dsInfo, projectName, "timeSeries", nil)
 	if err != nil {
 		queryResult.Error = err
 		return queryResult, StackdriverResponse{}, nil
 	}
 
-	req.URL.RawQuery = query.Params.Encode()
+	params := url.Values{}
+	for key, values := range query.Params {
+		for _, value := range values {
+			if key == "filter" {
+				value = strings.Replace(value, projectPlaceholder, projectName, -1)
+			}
+			params.Add(key, value)
+		}
+	}
+	req.URL.RawQuery = params.Encode()
 	queryResult.Meta.Set("rawQuery", req.URL.RawQuery)
 	alignmentPeriod, ok := req.URL.Query()["aggregation.alignmentPeriod"]
 
@@ -330,25 +465,30 @@ func (e *StackdriverExecutor) executeQuery(ctx context.Context, query *Stackdriv
 }
 
 func (e *StackdriverExecutor) unmarshalResponse(res *http.Response) (StackdriverResponse, error) {
+	var data StackdriverResponse
+	err := decodeResponse(res, &data)
+	return data, err
+}
+
+func decodeResponse(res *http.Response, data interface{}) error {
 	body, err := ioutil.ReadAll(res.Body)
 	defer res.Body.Close()
 	if err != nil {
-		return StackdriverResponse{}, err
+		return err
 	}
 
 	if res.StatusCode/100 != 2 {
 		slog.Error("Request failed", "status", res.Status, "body", string(body))
-		return StackdriverResponse{}, fmt.Errorf(string(body))
+		return errors.New(string(body))
 	}
 
-	var data StackdriverResponse
-	err = json.Unmarshal(body, &data)
+	err = json.Unmarshal(body, data)
 	if err != nil {
 		slog.Error("Failed to unmarshal Stackdriver response", "error", err, "status", res.Status, "body", string(body))
-		return StackdriverResponse{}, err
+		return err
 	}
 
-	return data, nil
+	return nil
 }
 
 func (e *StackdriverExecutor) parseResponse(queryRes *tsdb.QueryResult, data StackdriverResponse, query *StackdriverQuery) error {
@@ -412,57 +552,14 @@ func (e *StackdriverExecutor) parseResponse(queryRes *tsdb.QueryResult, data Sta
 				points = append(points, tsdb.NewTimePoint(null.FloatFrom(value), float64((point.Interval.EndTime).Unix())*1000))
 			}
 
-			metricName := formatLegendKeys(series.Metric.Type, defaultMetricName, series.Resource.Type, series.Metric.Labels, series.Resource.Labels, make(map[string]string), query)
+			metricName := formatLegendKeys(series.Metric.Type, defaultMetricName, series.Resource.Type, series.Metric.Labels, series.Resource.Labels, query.AliasLabels, query)
 
 			queryRes.Series = append(queryRes.Series, &tsdb.TimeSeries{
 				Name:   metricName,
 				Points: points,
 			})
 		} else {
-			buckets := make(map[int]*tsdb.TimeSeries)
-
-			for i := len(series.Points) - 1; i >= 0; i-- {
-				point := series.Points[i]
-				if len(point.Value.DistributionValue.BucketCounts) == 0 {
-					continue
-				}
-				maxKey := 0
-				for i := 0; i < len(point.Value.DistributionValue.BucketCounts); i++ {
-					value, err := strconv.ParseFloat(point.Value.DistributionValue.BucketCounts[i], 64)
-					if err != nil {
-						continue
-					}
-					if _, ok := buckets[i]; !ok {
-						// set lower bounds
-						// https://cloud.google.com/monitoring/api/ref_v3/rest/v3/TimeSeries#Distribution
-						bucketBound := calcBucketBound(point.Value.DistributionValue.BucketOptions, i)
-						additionalLabels := map[string]string{"bucket": bucketBound}
-						buckets[i] = &tsdb.TimeSeries{
-							Name:   formatLegendKeys(series.Metric.Type, defaultMetricName, series.Resource.Type, series.Metric.Labels, series.Resource.Labels, additionalLabels, query),
-							Points: make([]tsdb.TimePoint, 0),
-						}
-						if maxKey < i {
-							maxKey = i
-						}
-					}
-					buckets[i].Points = append(buckets[i].Points, tsdb.NewTimePoint(null.FloatFrom(value), float64((point.Interval.EndTime).Unix())*1000))
-				}
-
-				// fill empty bucket
-				for i := 0; i < maxKey; i++ {
-					if _, ok := buckets[i]; !ok {
-						bucketBound := calcBucketBound(point.Value.DistributionValue.BucketOptions, i)
-						additionalLabels := map[string]string{"bucket": bucketBound}
-						buckets[i] = &tsdb.TimeSeries{
-							Name:   formatLegendKeys(series.Metric.Type, defaultMetricName, series.Resource.Type, series.Metric.Labels, series.Resource.Labels, additionalLabels, query),
-							Points: make([]tsdb.TimePoint, 0),
-						}
-					}
-				}
-			}
-			for i := 0; i < len(buckets); i++ {
-				queryRes.Series = append(queryRes.Series, buckets[i])
-			}
+			queryRes.Series = append(queryRes.Series, distributionToBuckets(series.Points, len(data.TimeSeries) > 1, defaultMetricName, series.Metric.Type, series.Resource.Type, series.Metric.Labels, series.Resource.Labels, query)...)
 		}
 	}
 
@@ -474,6 +571,67 @@ func (e *StackdriverExecutor) parseResponse(queryRes *tsdb.QueryResult, data Sta
 	return nil
 }
 
+// distributionToBuckets returns a series per bucket of a distribution. The
+// series are named after the lower bound of the bucket and have the bounds as
+// tags, so they can be shown by the heatmap panel as time series buckets.
+func distributionToBuckets(points []StackdriverPoint, multipleSeries bool, defaultMetricName string, metricType string, resourceType string, metricLabels map[string]string, resourceLabels map[string]string, query *StackdriverQuery) []*tsdb.TimeSeries {
+	bucketCount := 0
+	var bucketOptions StackdriverBucketOptions
+	for _, point := range points {
+		if n := len(point.Value.DistributionValue.BucketCounts); n > bucketCount {
+			bucketCount = n
+			bucketOptions = point.Value.DistributionValue.BucketOptions
+		}
+	}
+
+	buckets := make([]*tsdb.TimeSeries, bucketCount)
+	for i := 0; i < bucketCount; i++ {
+		// https://cloud.google.com/monitoring/api/ref_v3/rest/v3/TimeSeries#Distribution
+		lowerBound := calcBucketBound(bucketOptions, i)
+		upperBound := "+Inf"
+		if i+1 < bucketOptions.bucketCount() {
+			upperBound = calcBucketBound(bucketOptions, i+1)
+		}
+
+		defaultName := lowerBound
+		if multipleSeries {
+			defaultName = defaultMetricName + " " + lowerBound
+		}
+
+		additionalLabels := map[string]string{"bucket": lowerBound, "lowerBound": lowerBound, "upperBound": upperBound}
+		for key, value := range query.AliasLabels {
+			additionalLabels[key] = value
+		}
+
+		buckets[i] = &tsdb.TimeSeries{
+			Name:   formatLegendKeys(metricType, defaultName, resourceType, metricLabels, resourceLabels, additionalLabels, query),
+			Tags:   map[string]string{"lowerBound": lowerBound, "upperBound": upperBound},
+			Points: make([]tsdb.TimePoint, 0),
+		}
+	}
+
+	// reverse the order to be ascending
+	for i := len(points) - 1; i >= 0; i-- {
+		point := points[i]
+		counts := point.Value.DistributionValue.BucketCounts
+		if len(counts) == 0 {
+			continue
+		}
+
+		for j := 0; j < bucketCount; j++ {
+			value := 0.0
+			if j < len(counts) {
+				if parsedValue, err := strconv.ParseFloat(counts[j], 64); err == nil {
+					value = parsedValue
+				}
+			}
+			buckets[j].Points = append(buckets[j].Points, tsdb.NewTimePoint(null.FloatFrom(value), float64((point.Interval.EndTime).Unix())*1000))
+		}
+	}
+
+	return buckets
+}
+
 func containsLabel(labels []string, newLabel string) bool {
 	for _, val := range labels {
 		if val == newLabel {
@@ -555,20 +713,36 @@ func calcBucketBound(bucketOptions StackdriverBucketOptions, n int) string {
 	}
 
 	if bucketOptions.LinearBuckets != nil {
-		bucketBound = strconv.FormatInt(bucketOptions.LinearBuckets.Offset+(bucketOptions.LinearBuckets.Width*int64(n-1)), 10)
+		bucketBound = strconv.FormatFloat(bucketOptions.LinearBuckets.Offset+(bucketOptions.LinearBuckets.Width*float64(n-1)), 'f', -1, 64)
 	} else if bucketOptions.ExponentialBuckets != nil {
-		bucketBound = strconv.FormatInt(int64(bucketOptions.ExponentialBuckets.Scale*math.Pow(bucketOptions.ExponentialBuckets.GrowthFactor, float64(n-1))), 10)
-	} else if bucketOptions.ExplicitBuckets != nil {
-		bucketBound = strconv.FormatInt(bucketOptions.ExplicitBuckets.Bounds[(n-1)], 10)
+		bucketBound = strconv.FormatFloat(bucketOptions.ExponentialBuckets.Scale*math.Pow(bucketOptions.ExponentialBuckets.GrowthFactor, float64(n-1)), 'f', -1, 64)
+	} else if bucketOptions.ExplicitBuckets != nil && n-1 < len(bucketOptions.ExplicitBuckets.Bounds) {
+		bucketBound = strconv.FormatFloat(bucketOptions.ExplicitBuckets.Bounds[(n-1)], 'f', -1, 64)
 	}
 	return bucketBound
 }
 
-func (e *StackdriverExecutor) createRequest(ctx context.Context, dsInfo *models.DataSource) (*http.Request, error) {
+// projectName returns the project of the query, the default project of the
+// data source is used when the query has none.
+func (e *StackdriverExecutor) projectName(query *StackdriverQuery) string {
+	if query.ProjectName != "" {
+		return query.ProjectName
+	}
+	return e.dsInfo.JsonData.Get("defaultProject").MustString()
+}
+
+// createRequest creates a request to an endpoint of the timeSeries API of the
+// project, a request with a body is sent as POST.
+func (e *StackdriverExecutor) createRequest(ctx context.Context, dsInfo *models.DataSource, projectName string, endpoint string, body io.Reader) (*http.Request, error) {
 	u, _ := url.Parse(dsInfo.Url)
 	u.Path = path.Join(u.Path, "render")
 
-	req, err := http.NewRequest(http.MethodGet, "https://monitoring.googleapis.com/", nil)
+	method := http.MethodGet
+	if body != nil {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(method, "https://monitoring.googleapis.com/", body)
 	if err != nil {
 		slog.Error("Failed to create request", "error", err)
 		return nil, fmt.Errorf("Failed to create request. error: %v", err)
@@ -591,8 +765,7 @@ func (e *StackdriverExecutor) createRequest(ctx context.Context, dsInfo *models.
 		}
 	}
 
-	projectName := dsInfo.JsonData.Get("defaultProject").MustString()
-	proxyPass := fmt.Sprintf("stackdriver%s", "v3/projects/"+projectName+"/timeSeries")
+	proxyPass := fmt.Sprintf("stackdriver%s", "v3/projects/"+projectName+"/"+endpoint)
 
 	pluginproxy.ApplyRoute(ctx, req, proxyPass, stackdriverRoute, dsInfo)
 
@@ -475,6 +475,164 @@ func TestStackdriver(t *testing.T) {
 				})
 			})
 		})
+
+		Convey("Parse queries of the other query types", func() {
+			fromStart := time.Date(2018, 3, 15, 13, 0, 0, 0, time.UTC).In(time.Local)
+			tsdbQuery := &tsdb.TsdbQuery{
+				TimeRange: &tsdb.TimeRange{
+					From: fmt.Sprintf("%v", fromStart.Unix()*1000),
+					To:   fmt.Sprintf("%v", fromStart.Add(34*time.Minute).Unix()*1000),
+				},
+				Queries: []*tsdb.Query{{RefId: "A"}},
+			}
+
+			Convey("and filter mode is raw", func() {
+				tsdbQuery.Queries[0].Model = simplejson.NewFromAny(map[string]interface{}{
+					"metricType": "a/metric/type",
+					"filterMode": "raw",
+					"rawFilter":  `metric.type="a/metric/type" AND resource.labels.zone=one_of("a", "b")`,
+					"filters":    []interface{}{"key", "=", "value"},
+				})
+
+				queries, err := executor.buildQueries(tsdbQuery)
+				So(err, ShouldBeNil)
+				So(queries[0].Params["filter"][0], ShouldEqual, `metric.type="a/metric/type" AND resource.labels.zone=one_of("a", "b")`)
+			})
+
+			Convey("and filter mode is raw without a filter", func() {
+				tsdbQuery.Queries[0].Model = simplejson.NewFromAny(map[string]interface{}{
+					"filterMode": "raw",
+				})
+
+				_, err := executor.buildQueries(tsdbQuery)
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("and query is a SLO query", func() {
+				tsdbQuery.Queries[0].Model = simplejson.NewFromAny(map[string]interface{}{
+					"queryType": "slo",
+					"sloQuery": map[string]interface{}{
+						"projectName":  "test-proj",
+						"serviceId":    "test-service",
+						"sloId":        "test-slo",
+						"selectorName": "select_slo_burn_rate",
+						"aliasBy":      "{{service}} {{slo}}",
+					},
+				})
+
+				queries, err := executor.buildQueries(tsdbQuery)
+				So(err, ShouldBeNil)
+				So(queries[0].ProjectName, ShouldEqual, "test-proj")
+				So(queries[0].Params["filter"][0], ShouldEqual, `select_slo_burn_rate("projects/test-proj/services/test-service/serviceLevelObjectives/test-slo", "3600s")`)
+				So(queries[0].Params["aggregation.perSeriesAligner"][0], ShouldEqual, "ALIGN_NEXT_OLDER")
+				So(queries[0].Params["aggregation.crossSeriesReducer"][0], ShouldEqual, "REDUCE_NONE")
+				So(queries[0].AliasLabels["slo"], ShouldEqual, "test-slo")
+
+				res := &tsdb.QueryResult{Meta: simplejson.New(), RefId: "A"}
+				data, err := loadTestFile("./test-data/1-series-response-agg-one-metric.json")
+				So(err, ShouldBeNil)
+				err = executor.parseResponse(res, data, queries[0])
+				So(err, ShouldBeNil)
+				So(res.Series[0].Name, ShouldEqual, "test-service test-slo")
+			})
+
+			Convey("and SLO query has no slo", func() {
+				tsdbQuery.Queries[0].Model = simplejson.NewFromAny(map[string]interface{}{
+					"queryType": "slo",
+					"sloQuery":  map[string]interface{}{"serviceId": "test-service"},
+				})
+
+				_, err := executor.buildQueries(tsdbQuery)
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("and SLO query uses the default project", func() {
+				tsdbQuery.Queries[0].Model = simplejson.NewFromAny(map[string]interface{}{
+					"queryType": "slo",
+					"sloQuery": map[string]interface{}{
+						"serviceId": "test-service",
+						"sloId":     "test-slo",
+					},
+				})
+
+				queries, err := executor.buildQueries(tsdbQuery)
+				So(err, ShouldBeNil)
+				So(queries[0].Params["filter"][0], ShouldEqual, `select_slo_health("projects/$__default_project/services/test-service/serviceLevelObjectives/test-slo")`)
+				So(queries[0].Params["aggregation.perSeriesAligner"][0], ShouldEqual, "ALIGN_MEAN")
+			})
+
+			Convey("and query is a MQL query", func() {
+				tsdbQuery.Queries[0].IntervalMs = 120000
+				tsdbQuery.Queries[0].Model = simplejson.NewFromAny(map[string]interface{}{
+					"queryType": "mql",
+					"mql":       "fetch gce_instance::compute.googleapis.com/instance/cpu/utilization",
+				})
+
+				queries, err := executor.buildQueries(tsdbQuery)
+				So(err, ShouldBeNil)
+				So(queries[0].MQL, ShouldEqual, "fetch gce_instance::compute.googleapis.com/instance/cpu/utilization | graph_period 120s | within d'2018/03/15 13:00:00', d'2018/03/15 13:34:00'")
+			})
+		})
+
+		Convey("Parse distribution buckets with bounds", func() {
+			data, err := loadTestFile("./test-data/3-series-response-distribution.json")
+			So(err, ShouldBeNil)
+
+			res := &tsdb.QueryResult{Meta: simplejson.New(), RefId: "A"}
+			query := &StackdriverQuery{}
+			err = executor.parseResponse(res, data, query)
+			So(err, ShouldBeNil)
+
+			So(len(res.Series), ShouldEqual, 11)
+			So(res.Series[0].Name, ShouldEqual, "0")
+			So(res.Series[0].Tags["lowerBound"], ShouldEqual, "0")
+			So(res.Series[0].Tags["upperBound"], ShouldEqual, "1")
+			So(res.Series[3].Name, ShouldEqual, "4")
+			So(res.Series[3].Tags["upperBound"], ShouldEqual, "8")
+			So(res.Series[10].Tags["lowerBound"], ShouldEqual, "512")
+			So(res.Series[10].Tags["upperBound"], ShouldEqual, "1024")
+		})
+
+		Convey("Calculate bucket bounds with fractions", func() {
+			var options StackdriverBucketOptions
+			err := json.Unmarshal([]byte(`{"linearBuckets": {"numFiniteBuckets": 4, "width": 0.5, "offset": 0.25}}`), &options)
+			So(err, ShouldBeNil)
+
+			So(calcBucketBound(options, 0), ShouldEqual, "0")
+			So(calcBucketBound(options, 1), ShouldEqual, "0.25")
+			So(calcBucketBound(options, 3), ShouldEqual, "1.25")
+			So(options.bucketCount(), ShouldEqual, 6)
+		})
+
+		Convey("Parse MQL response", func() {
+			var data StackdriverMQLResponse
+			err := json.Unmarshal([]byte(`{
+				"timeSeriesDescriptor": {
+					"labelDescriptors": [{"key": "resource.zone"}, {"key": "metric.instance_name"}],
+					"pointDescriptors": [{"key": "value.utilization", "valueType": "DOUBLE"}]
+				},
+				"timeSeriesData": [{
+					"labelValues": [{"stringValue": "us-east1-b"}, {"stringValue": "instance-1"}],
+					"pointData": [
+						{"values": [{"doubleValue": 0.2}], "timeInterval": {"startTime": "2018-09-11T12:30:00Z", "endTime": "2018-09-11T12:31:00Z"}},
+						{"values": [{"doubleValue": 0.1}], "timeInterval": {"startTime": "2018-09-11T12:29:00Z", "endTime": "2018-09-11T12:30:00Z"}}
+					]
+				}]
+			}`), &data)
+			So(err, ShouldBeNil)
+
+			res := &tsdb.QueryResult{Meta: simplejson.New(), RefId: "A"}
+			parseMQLResponse(res, data, &StackdriverQuery{})
+			So(len(res.Series), ShouldEqual, 1)
+			So(res.Series[0].Name, ShouldEqual, "us-east1-b instance-1")
+			So(res.Series[0].Tags["metric.instance_name"], ShouldEqual, "instance-1")
+			So(res.Series[0].Points[0][0].Float64, ShouldEqual, 0.1)
+			So(res.Series[0].Points[1][0].Float64, ShouldEqual, 0.2)
+
+			res = &tsdb.QueryResult{Meta: simplejson.New(), RefId: "A"}
+			parseMQLResponse(res, data, &StackdriverQuery{AliasBy: "{{metric.label.instance_name}} in {{resource.zone}}"})
+			So(res.Series[0].Name, ShouldEqual, "instance-1 in us-east1-b")
+		})
 	})
 }
 
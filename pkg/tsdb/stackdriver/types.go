@@ -7,18 +7,23 @@ import (
 
 // StackdriverQuery is the query that Grafana sends from the frontend
 type StackdriverQuery struct {
-	Target   string
-	Params   url.Values
-	RefID    string
-	GroupBys []string
-	AliasBy  string
+	Target      string
+	Params      url.Values
+	RefID       string
+	GroupBys    []string
+	AliasBy     string
+	QueryType   string
+	ProjectName string
+	MQL         string
+	// AliasLabels are the query specific patterns of the alias, e.g. the slo of a SLO query
+	AliasLabels map[string]string
 }
 
 type StackdriverBucketOptions struct {
 	LinearBuckets *struct {
-		NumFiniteBuckets int64 `json:"numFiniteBuckets"`
-		Width            int64 `json:"width"`
-		Offset           int64 `json:"offset"`
+		NumFiniteBuckets int64   `json:"numFiniteBuckets"`
+		Width            float64 `json:"width"`
+		Offset           float64 `json:"offset"`
 	} `json:"linearBuckets"`
 	ExponentialBuckets *struct {
 		NumFiniteBuckets int64   `json:"numFiniteBuckets"`
@@ -26,10 +31,25 @@ type StackdriverBucketOptions struct {
 		Scale            float64 `json:"scale"`
 	} `json:"exponentialBuckets"`
 	ExplicitBuckets *struct {
-		Bounds []int64 `json:"bounds"`
+		Bounds []float64 `json:"bounds"`
 	} `json:"explicitBuckets"`
 }
 
+// bucketCount returns the number of buckets including the underflow and
+// overflow buckets, or 0 if the options are unknown.
+func (o StackdriverBucketOptions) bucketCount() int {
+	if o.LinearBuckets != nil {
+		return int(o.LinearBuckets.NumFiniteBuckets) + 2
+	}
+	if o.ExponentialBuckets != nil {
+		return int(o.ExponentialBuckets.NumFiniteBuckets) + 2
+	}
+	if o.ExplicitBuckets != nil {
+		return len(o.ExplicitBuckets.Bounds) + 1
+	}
+	return 0
+}
+
 // StackdriverResponse is the data returned from the external Google Stackdriver API
 type StackdriverResponse struct {
 	TimeSeries []struct {
@@ -41,35 +61,70 @@ type StackdriverResponse struct {
 			Type   string            `json:"type"`
 			Labels map[string]string `json:"labels"`
 		} `json:"resource"`
-		MetricKind string `json:"metricKind"`
-		ValueType  string `json:"valueType"`
-		Points     []struct {
-			Interval struct {
+		MetricKind string             `json:"metricKind"`
+		ValueType  string             `json:"valueType"`
+		Points     []StackdriverPoint `json:"points"`
+	} `json:"timeSeries"`
+}
+
+// StackdriverPoint is a point of a time series returned by the Stackdriver API
+type StackdriverPoint struct {
+	Interval struct {
+		StartTime time.Time `json:"startTime"`
+		EndTime   time.Time `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue       float64 `json:"doubleValue"`
+		StringValue       string  `json:"stringValue"`
+		BoolValue         bool    `json:"boolValue"`
+		IntValue          string  `json:"int64Value"`
+		DistributionValue struct {
+			Count                 string  `json:"count"`
+			Mean                  float64 `json:"mean"`
+			SumOfSquaredDeviation float64 `json:"sumOfSquaredDeviation"`
+			Range                 struct {
+				Min int `json:"min"`
+				Max int `json:"max"`
+			} `json:"range"`
+			BucketOptions StackdriverBucketOptions `json:"bucketOptions"`
+			BucketCounts  []string                 `json:"bucketCounts"`
+			Examplars     []struct {
+				Value     float64 `json:"value"`
+				Timestamp string  `json:"timestamp"`
+				// attachments
+			} `json:"examplars"`
+		} `json:"distributionValue"`
+	} `json:"value"`
+}
+
+// StackdriverMQLResponse is the data returned by the timeSeries:query endpoint for MQL queries
+type StackdriverMQLResponse struct {
+	TimeSeriesDescriptor struct {
+		LabelDescriptors []struct {
+			Key string `json:"key"`
+		} `json:"labelDescriptors"`
+		PointDescriptors []struct {
+			Key       string `json:"key"`
+			ValueType string `json:"valueType"`
+		} `json:"pointDescriptors"`
+	} `json:"timeSeriesDescriptor"`
+	TimeSeriesData []struct {
+		LabelValues []StackdriverMQLValue `json:"labelValues"`
+		PointData   []struct {
+			Values       []StackdriverMQLValue `json:"values"`
+			TimeInterval struct {
 				StartTime time.Time `json:"startTime"`
 				EndTime   time.Time `json:"endTime"`
-			} `json:"interval"`
-			Value struct {
-				DoubleValue       float64 `json:"doubleValue"`
-				StringValue       string  `json:"stringValue"`
-				BoolValue         bool    `json:"boolValue"`
-				IntValue          string  `json:"int64Value"`
-				DistributionValue struct {
-					Count                 string  `json:"count"`
-					Mean                  float64 `json:"mean"`
-					SumOfSquaredDeviation float64 `json:"sumOfSquaredDeviation"`
-					Range                 struct {
-						Min int `json:"min"`
-						Max int `json:"max"`
-					} `json:"range"`
-					BucketOptions StackdriverBucketOptions `json:"bucketOptions"`
-					BucketCounts  []string                 `json:"bucketCounts"`
-					Examplars     []struct {
-						Value     float64 `json:"value"`
-						Timestamp string  `json:"timestamp"`
-						// attachments
-					} `json:"examplars"`
-				} `json:"distributionValue"`
-			} `json:"value"`
-		} `json:"points"`
-	} `json:"timeSeries"`
+			} `json:"timeInterval"`
+		} `json:"pointData"`
+	} `json:"timeSeriesData"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// StackdriverMQLValue is a label or point value of a MQL response
+type StackdriverMQLValue struct {
+	BoolValue   *bool    `json:"boolValue"`
+	Int64Value  *string  `json:"int64Value"`
+	DoubleValue *float64 `json:"doubleValue"`
+	StringValue *string  `json:"stringValue"`
 }